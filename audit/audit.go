@@ -0,0 +1,229 @@
+// Package audit keeps an append-only record of the tool calls an agent
+// executes, as rotated JSONL files.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/gofrs/flock"
+)
+
+// How a call came to be executed
+const (
+	ApprovalNone    = "none"    // Nothing checked the call
+	ApprovalDefault = "default" // The permission policy's default allowed it
+	ApprovalPolicy  = "policy"  // A permission policy allow rule matched
+	ApprovalUser    = "user"    // The user approved it when asked
+)
+
+// Record is one executed tool call
+type Record struct {
+	Time       time.Time       `json:"time"`
+	Context    string          `json:"context,omitempty"`
+	ToolCallID string          `json:"tool_call_id"`
+	Tool       string          `json:"tool"`
+	Type       string          `json:"type,omitempty"`    // From Tool.GetType; empty when the tool was not found
+	Source     string          `json:"source,omitempty"`  // From Tool.GetSource
+	Sandbox    *sandbox.Config `json:"sandbox,omitempty"` // Absent when the tool ran unsandboxed
+	Args       json.RawMessage `json:"args,omitempty"`
+	ResultHash string          `json:"result_sha256"`
+	DurationMs int64           `json:"duration_ms"`
+	Error      string          `json:"error,omitempty"`
+	Approval   string          `json:"approval"`
+	Rule       string          `json:"rule,omitempty"` // Permission rule that allowed the call
+}
+
+// HashResult returns the hex SHA-256 of a tool result, as kept in records.
+func HashResult(result string) string {
+	sum := sha256.Sum256([]byte(result))
+	return hex.EncodeToString(sum[:])
+}
+
+// RawArgs returns tool call arguments for a record, quoting them as a
+// string when they are not valid JSON.
+func RawArgs(arguments string) json.RawMessage {
+	if arguments == "" {
+		return nil
+	}
+	if json.Valid([]byte(arguments)) {
+		return json.RawMessage(arguments)
+	}
+	quoted, _ := json.Marshal(arguments)
+	return quoted
+}
+
+// Sink receives a record for every tool call an agent executes
+type Sink interface {
+	Write(Record) error
+}
+
+// currentFile is the file records are appended to; rotated files are
+// renamed to audit-<time>.jsonl
+const currentFile = "audit.jsonl"
+
+// DefaultMaxSize is the size at which a Log rotates its file
+const DefaultMaxSize = 10 << 20
+
+// Log is a Sink that appends records to audit.jsonl in a directory, which
+// is renamed aside once it grows past a size limit. Rotated files are kept.
+// Writes are safe across goroutines and processes.
+type Log struct {
+	dir     string
+	maxSize int64
+	mu      sync.Mutex
+}
+
+// NewLog returns a log in dir that rotates at maxSize bytes
+// (DefaultMaxSize when 0). The directory is created on first write.
+func NewLog(dir string, maxSize int64) *Log {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	return &Log{dir: dir, maxSize: maxSize}
+}
+
+// Write appends rec to the log, rotating the file first if it is full.
+func (l *Log) Write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encode audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return fmt.Errorf("create audit directory: %w", err)
+	}
+	lock := flock.New(filepath.Join(l.dir, "audit.lock"))
+	if err := lock.Lock(); err != nil {
+		return fmt.Errorf("lock audit log: %w", err)
+	}
+	defer lock.Unlock()
+
+	path := filepath.Join(l.dir, currentFile)
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > l.maxSize {
+		stamp := time.Now().UTC().Format("20060102T150405.000000000")
+		rotated := filepath.Join(l.dir, "audit-"+stamp+".jsonl")
+		// Never replace an earlier file, whatever the clock's resolution
+		for n := 1; fileExists(rotated); n++ {
+			rotated = filepath.Join(l.dir, fmt.Sprintf("audit-%s_%d.jsonl", stamp, n))
+		}
+		if err := os.Rename(path, rotated); err != nil {
+			return fmt.Errorf("rotate audit log: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	Context string    // Exact context name
+	Tool    string    // Glob on the tool name
+	Since   time.Time // Records at or after this time
+	Until   time.Time // Records before this time
+}
+
+// Match reports whether rec passes the filter.
+func (f Filter) Match(rec Record) bool {
+	if f.Context != "" && rec.Context != f.Context {
+		return false
+	}
+	if f.Tool != "" {
+		if ok, _ := filepath.Match(f.Tool, rec.Tool); !ok {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && rec.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !rec.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Read returns the records in dir that match filter, oldest first. A
+// missing directory has no records.
+func Read(dir string, filter Filter) ([]Record, error) {
+	if filter.Tool != "" {
+		if _, err := filepath.Match(filter.Tool, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool pattern %q: %w", filter.Tool, err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	// Rotated names sort by the time they were rotated
+	slices.Sort(files)
+	files = append(files, filepath.Join(dir, currentFile))
+
+	var records []Record
+	for _, path := range files {
+		found, err := readFile(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, found...)
+	}
+	return records, nil
+}
+
+func readFile(path string, filter Filter) ([]Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if filter.Match(rec) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log %s: %w", path, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogRotatesAndReads(t *testing.T) {
+	dir := t.TempDir()
+	log := NewLog(dir, 400)
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, tool := range []string{"bash", "read_file", "bash", "github__create_issue", "bash", "read_file"} {
+		rec := Record{
+			Time:       start.Add(time.Duration(i) * time.Hour),
+			Context:    "work",
+			ToolCallID: "tc",
+			Tool:       tool,
+			Args:       RawArgs(`{"command":"ls"}`),
+			ResultHash: HashResult("ok"),
+			Approval:   ApprovalUser,
+		}
+		if i%2 == 1 {
+			rec.Context = "other"
+		}
+		if err := log.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) == 0 {
+		t.Fatal("no rotated files after writing past the size limit")
+	}
+	for _, path := range append(rotated, filepath.Join(dir, currentFile)) {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 400 {
+			t.Errorf("%s is %d bytes, over the 400 byte limit", path, info.Size())
+		}
+	}
+
+	all, err := Read(dir, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 6 {
+		t.Fatalf("Read() = %d records, want 6", len(all))
+	}
+	for i, rec := range all {
+		if !rec.Time.Equal(start.Add(time.Duration(i) * time.Hour)) {
+			t.Errorf("record %d time = %v, want records oldest first", i, rec.Time)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"context", Filter{Context: "work"}, 3},
+		{"tool glob", Filter{Tool: "github__*"}, 1},
+		{"context and tool", Filter{Context: "work", Tool: "bash"}, 3},
+		{"since", Filter{Since: start.Add(4 * time.Hour)}, 2},
+		{"until", Filter{Until: start.Add(2 * time.Hour)}, 2},
+		{"range", Filter{Since: start.Add(time.Hour), Until: start.Add(3 * time.Hour), Tool: "read_file"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(dir, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Read(%+v) = %d records, want %d", tt.filter, len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestReadMissingDirectory(t *testing.T) {
+	records, err := Read(filepath.Join(t.TempDir(), "none"), Filter{})
+	if err != nil || len(records) != 0 {
+		t.Fatalf("Read() = %v, %v; want no records", records, err)
+	}
+}
+
+func TestRawArgs(t *testing.T) {
+	if got := string(RawArgs(`{"a":1}`)); got != `{"a":1}` {
+		t.Errorf("RawArgs(valid) = %s", got)
+	}
+	if got := string(RawArgs(`{"a":`)); got != `"{\"a\":"` {
+		t.Errorf("RawArgs(invalid) = %s, want a JSON string", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/audit"
+	"github.com/urfave/cli/v3"
+)
+
+func auditCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "audit",
+		Usage: "Show the tool calls recorded in the audit log",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "context",
+				Aliases: []string{"c"},
+				Usage:   "Only calls made in this context",
+			},
+			&cli.StringFlag{
+				Name:  "tool",
+				Usage: "Only calls of tools matching this glob (e.g. bash or github__*)",
+			},
+			&cli.StringFlag{
+				Name:  "since",
+				Usage: "Only calls at or after this time (RFC 3339, YYYY-MM-DD, or a duration ago such as 24h)",
+			},
+			&cli.StringFlag{
+				Name:  "until",
+				Usage: "Only calls before this time (same formats as --since)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the matching records as JSONL",
+			},
+		},
+		Action: runAudit,
+	}
+}
+
+// auditDir returns where tool calls are recorded, overridable with
+// POLLYTOOL_AUDIT_DIR.
+func auditDir() string {
+	if dir := os.Getenv("POLLYTOOL_AUDIT_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pollytool", "audit")
+}
+
+// newAuditLog returns the sink agents record their tool calls to, or nil
+// when there is nowhere to keep it.
+func newAuditLog() audit.Sink {
+	dir := auditDir()
+	if dir == "" {
+		return nil
+	}
+	return audit.NewLog(dir, 0)
+}
+
+func runAudit(ctx context.Context, cmd *cli.Command) error {
+	dir := auditDir()
+	if dir == "" {
+		return fmt.Errorf("cannot determine audit log directory")
+	}
+	filter := audit.Filter{
+		Context: cmd.String("context"),
+		Tool:    cmd.String("tool"),
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(cmd.String("since"), time.Now()); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if filter.Until, err = parseAuditTime(cmd.String("until"), time.Now()); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	records, err := audit.Read(dir, filter)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		for _, rec := range records {
+			if err := enc.Encode(rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(records) == 0 {
+		fmt.Println("No tool calls found")
+		return nil
+	}
+	for _, rec := range records {
+		fmt.Println(formatAuditRecord(rec))
+	}
+	return nil
+}
+
+// parseAuditTime reads a --since or --until value: an RFC 3339 time, a
+// local date, or a duration before now. Empty means no bound.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time, date or duration", value)
+}
+
+// formatAuditRecord renders rec as one line: local time, context, duration,
+// tool and its arguments, how it was approved, and any error.
+func formatAuditRecord(rec audit.Record) string {
+	contextName := rec.Context
+	if contextName == "" {
+		contextName = "-"
+	}
+	label := rec.Tool
+	if summary := summarizeToolArgs(rec.Tool, string(rec.Args)); summary != "" {
+		label += " " + summary
+	}
+	approval := rec.Approval
+	if rec.Sandbox == nil {
+		approval += ", unsandboxed"
+	}
+	line := fmt.Sprintf("%s  %s  %.1fs %s (%s)", rec.Time.Local().Format(time.DateTime), contextName, float64(rec.DurationMs)/1000, label, approval)
+	if rec.Error != "" {
+		line += " — " + rec.Error
+	}
+	return line
+}
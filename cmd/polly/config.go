@@ -4,22 +4,28 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
 	"github.com/urfave/cli/v3"
 )
 
 var (
-	validModelProviders  = []string{"openai", "anthropic", "gemini", "ollama", "huggingface"}
 	validEmbedProviders  = []string{"openai", "gemini"}
 	purgeDisallowedFlags = []string{
-		"context", "last", "prompt", "file", "model", "temp",
+		"context", "last", "resume", "prompt", "file", "model", "fallback-model", "temp",
 		"maxtokens", "maxiterations", "timeout", "tool", "mcp", "system", "schema",
-		"tooltimeout", "maxcontext", "thinkingeffort", "baseurl",
+		"tooltimeout", "maxcontext", "compact", "thinkingeffort", "baseurl",
+		"budget", "context-budget",
 		"skilldir", "skill", "noskills", "listskills",
+		"mcp-prompt", "arg", "listprompts", "sampling-model", "sampling-maxtokens",
+		"task-maxiterations", "task-token-budget",
 	}
 )
 
@@ -33,6 +39,10 @@ func getCommand() *cli.Command {
 		Action:                 runCommand,
 		Commands: []*cli.Command{
 			embedCommand(),
+			serveCommand(),
+			mcpServerCommand(),
+			daemonCommand(),
+			auditCommand(),
 		},
 		OnUsageError: func(ctx context.Context, cmd *cli.Command, err error, isSubcommand bool) error {
 			// Just return the error without showing usage
@@ -51,18 +61,25 @@ func parseConfig(cmd *cli.Command) *Config {
 			Temperature:      cmd.Float64("temp"),
 			MaxTokens:        cmd.Int("maxtokens"),
 			MaxHistoryTokens: cmd.Int("maxcontext"),
+			Compaction:       compactionFromFlag(cmd),
 			ThinkingEffort:   cmd.String("thinkingeffort"),
 			SystemPrompt:     cmd.String("system"),
 			ToolTimeout:      cmd.Duration("tooltimeout"),
 			SkillDirs:        cmd.StringSlice("skilldir"),
+			Budget:           cmd.Float64("context-budget"),
 		},
 
 		// Runtime configuration
-		Timeout:       cmd.Duration("timeout"),
-		MaxIterations: int(cmd.Int("maxiterations")),
-		BaseURL:       cmd.String("baseurl"),
-		Confirm:       cmd.Bool("confirm"),
-		NoSandbox:     cmd.Bool("nosandbox"),
+		Timeout:        cmd.Duration("timeout"),
+		MaxIterations:  int(cmd.Int("maxiterations")),
+		FallbackModels: cmd.StringSlice("fallback-model"),
+		RequestBudget:  cmd.Float64("budget"),
+		BaseURL:        cmd.String("baseurl"),
+		Confirm:        cmd.Bool("confirm"),
+		Plan:           cmd.Bool("plan"),
+		NoSandbox:      cmd.Bool("nosandbox"),
+		MaxToolOutput:  int(cmd.Int("max-tool-output")),
+		TaskConfig:     taskConfigFromFlags(cmd),
 
 		// Skill configuration
 		NoSkills:   cmd.Bool("noskills"),
@@ -72,6 +89,7 @@ func parseConfig(cmd *cli.Command) *Config {
 		ContextID:      cmd.String("context"),
 		ResetContext:   cmd.String("reset"),
 		UseLastContext: cmd.Bool("last"),
+		Resume:         cmd.Bool("resume"),
 		ListContexts:   cmd.Bool("list"),
 		DeleteContext:  cmd.String("delete"),
 		AddToContext:   cmd.Bool("add"),
@@ -83,24 +101,151 @@ func parseConfig(cmd *cli.Command) *Config {
 		Prompt:     cmd.String("prompt"),
 		Files:      cmd.StringSlice("file"),
 		SchemaPath: cmd.String("schema"),
+		Output:     cmd.String("output"),
 		Quiet:      cmd.Bool("quiet"),
 		Debug:      cmd.Bool("debug"),
 		Tools:      cmd.StringSlice("tool"),
 		Skills:     cmd.StringSlice("skill"),
+
+		// MCP prompt templates
+		MCPPrompt:   cmd.String("mcp-prompt"),
+		PromptArgs:  cmd.StringSlice("arg"),
+		ListPrompts: cmd.Bool("listprompts"),
+
+		// MCP sampling
+		SamplingModels:    cmd.StringSlice("sampling-model"),
+		SamplingMaxTokens: int(cmd.Int("sampling-maxtokens")),
 	}
 
 	return config
 }
 
-// loadAPIKeys loads API keys from environment variables
-func loadAPIKeys() map[string]string {
-	return map[string]string{
-		"ollama":      os.Getenv("POLLYTOOL_OLLAMAKEY"),
-		"openai":      os.Getenv("POLLYTOOL_OPENAIKEY"),
-		"anthropic":   os.Getenv("POLLYTOOL_ANTHROPICKEY"),
-		"gemini":      os.Getenv("POLLYTOOL_GEMINIKEY"),
-		"huggingface": os.Getenv("POLLYTOOL_HUGGINGFACEKEY"),
+// loadAPIKeys loads API keys for every known provider from environment variables
+func loadAPIKeys(registry *llm.ProviderRegistry) map[string]string {
+	return registry.APIKeysFromEnv()
+}
+
+// providersConfigPath returns the custom providers file, overridable with
+// POLLYTOOL_PROVIDERS.
+func providersConfigPath() string {
+	if path := os.Getenv("POLLYTOOL_PROVIDERS"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pollytool", "providers.json")
+}
+
+// loadProviders returns the built-in providers plus the aliases declared in
+// the providers file. It is loaded once per process.
+var loadProviders = sync.OnceValues(func() (*llm.ProviderRegistry, error) {
+	registry := llm.DefaultProviders().Clone()
+	if path := providersConfigPath(); path != "" {
+		if err := registry.LoadConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+})
+
+// pricingConfigPath returns the pricing overrides file, overridable with
+// POLLYTOOL_PRICING.
+func pricingConfigPath() string {
+	if path := os.Getenv("POLLYTOOL_PRICING"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(home, ".pollytool", "pricing.json")
+}
+
+// loadPricing returns the built-in prices merged with the pricing file.
+// It is loaded once per process.
+var loadPricing = sync.OnceValues(func() (*llm.PricingTable, error) {
+	pricing := llm.DefaultPricing()
+	if path := pricingConfigPath(); path != "" {
+		if err := pricing.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return pricing, nil
+})
+
+// permissionsConfigPath returns the user's tool permission policy file,
+// overridable with POLLYTOOL_PERMISSIONS. "Always allow" answers are saved
+// here.
+func permissionsConfigPath() string {
+	if path := os.Getenv("POLLYTOOL_PERMISSIONS"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pollytool", "permissions.json")
+}
+
+// projectPermissionsPath returns the nearest .pollytool/permissions.json in
+// the working directory or its parents, or "" if there is none. The user's
+// own file is not a project file.
+func projectPermissionsPath(userPath string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ".pollytool", "permissions.json")
+		if path != userPath {
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadPermissions loads the project and user permission policy files.
+// A project file comes with whatever was cloned, so only its deny and ask
+// rules apply. Calls no rule matches are asked about when ask is set and
+// allowed otherwise.
+func loadPermissions(ask bool) (*tools.PermissionPolicy, error) {
+	fallback := tools.PermissionAllow
+	if ask {
+		fallback = tools.PermissionAsk
+	}
+	policy := tools.NewPermissionPolicy(fallback)
+	userPath := permissionsConfigPath()
+	if path := projectPermissionsPath(userPath); path != "" {
+		if err := policy.LoadUntrustedFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if userPath != "" {
+		if err := policy.LoadFile(userPath); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+// compactionFromFlag maps --compact to a compaction strategy. An unset flag
+// leaves the context's stored strategy alone.
+func compactionFromFlag(cmd *cli.Command) string {
+	if !cmd.IsSet("compact") {
+		return ""
+	}
+	if cmd.Bool("compact") {
+		return sessions.CompactionSummarize
+	}
+	return sessions.CompactionTrim
 }
 
 func defineFlagsWithGroups() ([]cli.Flag, []cli.MutuallyExclusiveFlags) {
@@ -120,6 +265,7 @@ func defineFlagsWithGroups() ([]cli.Flag, []cli.MutuallyExclusiveFlags) {
 	flags = append(flags, apiConfigFlags()...)
 	flags = append(flags, skillConfigFlags(listSkillsFlag)...)
 	flags = append(flags, toolConfigFlags()...)
+	flags = append(flags, taskConfigFlags()...)
 	flags = append(flags, inputConfigFlags()...)
 	flags = append(flags, contextManagementFlags(resetFlag, listFlag, deleteFlag, addFlag, purgeFlag, createFlag, showFlag)...)
 	flags = append(flags, historyConfigFlags()...)
@@ -154,6 +300,19 @@ func modelConfigFlags() []cli.Flag {
 				return validateModel(model)
 			},
 		},
+		&cli.StringSliceFlag{
+			Name:    "fallback-model",
+			Usage:   "Model to fail over to when the model is rate-limited or unavailable (can be specified multiple times, tried in order)",
+			Sources: cli.EnvVars("POLLYTOOL_FALLBACK_MODEL"),
+			Validator: func(models []string) error {
+				for _, model := range models {
+					if err := validateModel(model); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
 		&cli.Float64Flag{
 			Name:    "temp",
 			Usage:   "Temperature for sampling",
@@ -182,6 +341,17 @@ func modelConfigFlags() []cli.Flag {
 			Sources: cli.EnvVars("POLLYTOOL_TIMEOUT"),
 		},
 		newThinkingEffortFlag(),
+		&cli.Float64Flag{
+			Name:      "budget",
+			Usage:     "Maximum spend in USD for this request; stops before an LLM call would exceed it (0 = unlimited)",
+			Sources:   cli.EnvVars("POLLYTOOL_BUDGET"),
+			Validator: validateBudget,
+		},
+		&cli.Float64Flag{
+			Name:      "context-budget",
+			Usage:     "Maximum total spend in USD for the context (saved with the context; 0 = unlimited)",
+			Validator: validateBudget,
+		},
 	}
 }
 
@@ -216,6 +386,43 @@ func skillConfigFlags(listSkillsFlag *cli.BoolFlag) []cli.Flag {
 	}
 }
 
+// taskConfigFlags limit the sub-agents of the task tool; shared by the root
+// command and the agent servers
+func taskConfigFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.IntFlag{
+			Name:    "task-maxiterations",
+			Usage:   "Maximum LLM calls per sub-agent started by the task tool",
+			Value:   10,
+			Sources: cli.EnvVars("POLLYTOOL_TASK_MAXITERATIONS"),
+		},
+		&cli.IntFlag{
+			Name:    "task-token-budget",
+			Usage:   "Maximum input and output tokens per sub-agent started by the task tool (0 = unlimited)",
+			Value:   200000,
+			Sources: cli.EnvVars("POLLYTOOL_TASK_TOKEN_BUDGET"),
+		},
+	}
+}
+
+func taskConfigFromFlags(cmd *cli.Command) llm.TaskConfig {
+	return llm.TaskConfig{
+		MaxIterations: int(cmd.Int("task-maxiterations")),
+		TokenBudget:   int(cmd.Int("task-token-budget")),
+	}
+}
+
+// maxToolOutputFlag caps tool results; shared by the root command and the
+// agent servers
+func maxToolOutputFlag() cli.Flag {
+	return &cli.IntFlag{
+		Name:    "max-tool-output",
+		Usage:   "Truncate tool results longer than this many bytes, keeping the head and tail; the model can page through the rest with read_tool_output (0 = unlimited)",
+		Value:   50000,
+		Sources: cli.EnvVars("POLLYTOOL_MAX_TOOL_OUTPUT"),
+	}
+}
+
 func toolConfigFlags() []cli.Flag {
 	return []cli.Flag{
 		&cli.StringSliceFlag{
@@ -229,6 +436,33 @@ func toolConfigFlags() []cli.Flag {
 			Value:   30 * time.Second,
 			Sources: cli.EnvVars("POLLYTOOL_TOOLTIMEOUT"),
 		},
+		maxToolOutputFlag(),
+		&cli.StringFlag{
+			Name:  "mcp-prompt",
+			Usage: "Expand a prompt from a loaded MCP server (server/name) ahead of the user message",
+		},
+		&cli.StringSliceFlag{
+			Name:  "arg",
+			Usage: "Argument for --mcp-prompt as key=value (can be specified multiple times)",
+		},
+		newPromptAndFileFreeBoolFlag("listprompts", "List prompts published by the MCP servers loaded with --tool"),
+		&cli.StringSliceFlag{
+			Name:  "sampling-model",
+			Usage: "Model MCP servers may pick for sampling requests via model hints (can be specified multiple times; default: the session model only)",
+			Validator: func(models []string) error {
+				for _, model := range models {
+					if err := validateModel(model); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		&cli.IntFlag{
+			Name:    "sampling-maxtokens",
+			Usage:   "Let MCP servers request LLM completions of up to this many tokens, billed to the session (0 = sampling disabled)",
+			Sources: cli.EnvVars("POLLYTOOL_SAMPLING_MAXTOKENS"),
+		},
 	}
 }
 
@@ -237,7 +471,7 @@ func inputConfigFlags() []cli.Flag {
 		&cli.StringFlag{
 			Name:    "prompt",
 			Aliases: []string{"p"},
-			Usage:   "Initial prompt (reads from stdin if not provided)",
+			Usage:   "Initial prompt (reads from stdin if not provided, interactive mode on a terminal)",
 		},
 		&cli.StringFlag{
 			Name:    "system",
@@ -249,7 +483,7 @@ func inputConfigFlags() []cli.Flag {
 		&cli.StringSliceFlag{
 			Name:    "file",
 			Aliases: []string{"f"},
-			Usage:   "File, image, or URL to include (can be specified multiple times)",
+			Usage:   "File, image, URL, or mcp://server/uri resource to include (can be specified multiple times)",
 		},
 		&cli.StringFlag{
 			Name:  "schema",
@@ -271,6 +505,7 @@ func contextManagementFlags(resetFlag *cli.StringFlag, listFlag *cli.BoolFlag, d
 			Aliases: []string{"L"},
 			Usage:   "Use the last active context",
 		},
+		newPromptAndFileFreeBoolFlag("resume", "Continue the context's run that stopped early (max iterations, timeout or Ctrl-C)"),
 		resetFlag,
 		listFlag,
 		deleteFlag,
@@ -288,6 +523,10 @@ func historyConfigFlags() []cli.Flag {
 			Usage: "Maximum tokens to keep in history (0 = unlimited)",
 			Value: 100000,
 		},
+		&cli.BoolFlag{
+			Name:  "compact",
+			Usage: "Summarize old turns instead of dropping them when --maxcontext is exceeded (saved with the context; --compact=false to turn off)",
+		},
 	}
 }
 
@@ -297,6 +536,10 @@ func approvalConfigFlags() []cli.Flag {
 			Name:  "confirm",
 			Usage: "Require confirmation before each tool call",
 		},
+		&cli.BoolFlag{
+			Name:  "plan",
+			Usage: "Plan each request with tools disabled, and run the steps one by one after you approve or edit the plan",
+		},
 	}
 }
 
@@ -312,6 +555,14 @@ func sandboxConfigFlags() []cli.Flag {
 
 func outputConfigFlags() []cli.Flag {
 	return []cli.Flag{
+		&cli.StringFlag{
+			Name:      "output",
+			Aliases:   []string{"o"},
+			Usage:     "Output format: text, or jsonl for one JSON event per line",
+			Value:     outputText,
+			Sources:   cli.EnvVars("POLLYTOOL_OUTPUT"),
+			Validator: validateOutputFormat,
+		},
 		&cli.BoolFlag{
 			Name:  "quiet",
 			Usage: "Suppress status and tool display output",
@@ -397,11 +648,27 @@ func validateNoPromptOrFiles(cmd *cli.Command, flagName string) error {
 }
 
 func validateModel(model string) error {
-	return validateModelWithProviders(model, validModelProviders, "anthropic/claude-sonnet-4-6")
+	registry, err := loadProviders()
+	if err != nil {
+		return err
+	}
+	return validateModelWithProviders(model, registry.Names(), "anthropic/claude-sonnet-4-6")
 }
 
+// validateEmbedModel accepts the providers that can embed, including custom
+// providers backed by one of them
 func validateEmbedModel(model string) error {
-	return validateModelWithProviders(model, validEmbedProviders, "openai/text-embedding-3-large")
+	registry, err := loadProviders()
+	if err != nil {
+		return err
+	}
+	var providers []string
+	for _, name := range registry.Names() {
+		if kind, _ := registry.Type(name); slices.Contains(validEmbedProviders, kind) {
+			providers = append(providers, name)
+		}
+	}
+	return validateModelWithProviders(model, providers, "openai/text-embedding-3-large")
 }
 
 func validateModelWithProviders(model string, providers []string, example string) error {
@@ -422,6 +689,13 @@ func validateModelWithProviders(model string, providers []string, example string
 	return nil
 }
 
+func validateBudget(budget float64) error {
+	if budget < 0 {
+		return fmt.Errorf("budget must not be negative, got %.2f", budget)
+	}
+	return nil
+}
+
 func validateTemperature(temp float64) error {
 	if temp < 0.0 || temp > 2.0 {
 		return fmt.Errorf("temperature must be between 0.0 and 2.0, got %.1f", temp)
@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
 	"github.com/urfave/cli/v3"
 )
 
@@ -137,3 +145,94 @@ func runConfigValidationCommand(args ...string) error {
 
 	return cmd.Run(context.Background(), append([]string{"polly"}, args...))
 }
+
+func TestTurnBudget(t *testing.T) {
+	store := sessions.NewSyncMapSessionStore(nil)
+	session, err := store.Get("default")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	conv := &conversation{
+		config:  &Config{RequestBudget: 1, Settings: Settings{Budget: 5}},
+		session: session,
+	}
+
+	if got, err := conv.turnBudget(); err != nil || got != 1 {
+		t.Fatalf("turnBudget() = %v, %v; want request budget 1", got, err)
+	}
+
+	conv.recordSpend(4.5)
+	if got, err := conv.turnBudget(); err != nil || math.Abs(got-0.5) > 1e-9 {
+		t.Fatalf("turnBudget() = %v, %v; want remaining context budget 0.5", got, err)
+	}
+
+	conv.recordSpend(0.5)
+	if _, err := conv.turnBudget(); !errors.Is(err, llm.ErrBudgetExceeded) {
+		t.Fatalf("turnBudget() error = %v, want ErrBudgetExceeded", err)
+	}
+	if got := session.GetMetadata().TotalCost; math.Abs(got-5) > 1e-9 {
+		t.Fatalf("TotalCost = %v, want 5", got)
+	}
+}
+
+func TestAbandonRunAnswersPendingCalls(t *testing.T) {
+	store := sessions.NewSyncMapSessionStore(&sessions.Metadata{})
+	session, err := store.Get("default")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	call := messages.ChatMessageToolCall{ID: "tc1", Name: "slow", Arguments: `{}`}
+	session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleAssistant, ToolCalls: []messages.ChatMessageToolCall{call}})
+	saveRunState(session, &sessions.RunState{Iteration: 1, PendingToolCalls: []messages.ChatMessageToolCall{call}})
+
+	abandonRun(session)
+	if state := session.GetMetadata().RunState; state != nil {
+		t.Fatalf("RunState = %+v after abandoning, want nil", state)
+	}
+	history := session.GetHistory()
+	if last := history[len(history)-1]; last.Role != messages.MessageRoleTool || last.ToolCallID != "tc1" {
+		t.Fatalf("last message = %+v, want a result for tc1", last)
+	}
+
+	// Nothing stopped, nothing to do
+	abandonRun(session)
+	if got := len(session.GetHistory()); got != len(history) {
+		t.Fatalf("history grew to %d messages with no stopped run", got)
+	}
+}
+
+func TestLoadPermissionsFindsProjectFile(t *testing.T) {
+	root := t.TempDir()
+	userPath := filepath.Join(root, "user.json")
+	t.Setenv("POLLYTOOL_PERMISSIONS", userPath)
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(filepath.Join(root, "project", ".pollytool", "permissions.json"), `{"rules": [{"tool": "bash", "action": "deny"}, {"tool": "*", "action": "allow"}]}`)
+	writeFile(userPath, `{"rules": [{"tool": "read_file", "action": "allow"}]}`)
+	nested := filepath.Join(root, "project", "src", "pkg")
+	if err := os.MkdirAll(nested, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(nested)
+
+	policy, err := loadPermissions(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tool, want := range map[string]tools.PermissionAction{
+		"bash":       tools.PermissionDeny,
+		"read_file":  tools.PermissionAllow,
+		"write_file": tools.PermissionAsk, // The project's allow-everything rule is ignored
+	} {
+		if got := policy.Check(tool, nil).Action; got != want {
+			t.Errorf("Check(%s) = %s, want %s", tool, got, want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/internal/log"
+	"github.com/alexschlessinger/pollytool/tools"
+	"github.com/urfave/cli/v3"
+)
+
+// daemonStartTimeout bounds how long daemon start waits for the socket
+const daemonStartTimeout = 5 * time.Second
+
+func daemonCommand() *cli.Command {
+	idleFlag := &cli.DurationFlag{
+		Name:    "idle",
+		Usage:   "Stop servers unused for this long, and the daemon once none are left (0 keeps them until stopped)",
+		Value:   10 * time.Minute,
+		Sources: cli.EnvVars("POLLYTOOL_DAEMON_IDLE"),
+	}
+	return &cli.Command{
+		Name:  "daemon",
+		Usage: "Keep stdio MCP servers running between invocations",
+		Commands: []*cli.Command{
+			{
+				Name:   "start",
+				Usage:  "Start the daemon in the background",
+				Flags:  []cli.Flag{idleFlag},
+				Action: runDaemonStart,
+			},
+			{
+				Name:   "run",
+				Usage:  "Run the daemon in the foreground",
+				Flags:  []cli.Flag{idleFlag},
+				Action: runDaemon,
+			},
+			{
+				Name:   "status",
+				Usage:  "Show the daemon's servers",
+				Action: runDaemonStatus,
+			},
+			{
+				Name:   "stop",
+				Usage:  "Stop the daemon and its servers",
+				Action: runDaemonStop,
+			},
+		},
+	}
+}
+
+// daemonSocketPath returns where the daemon listens
+func daemonSocketPath() string {
+	if path := os.Getenv("POLLYTOOL_DAEMON_SOCKET"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".pollytool", "daemon.sock")
+}
+
+func runDaemon(ctx context.Context, cmd *cli.Command) error {
+	log.InitLogger(cmd.Bool("debug"))
+
+	socket := daemonSocketPath()
+	if socket == "" {
+		return fmt.Errorf("cannot determine daemon socket path")
+	}
+	if _, err := tools.QueryMCPPool(socket); err == nil {
+		return fmt.Errorf("daemon already running on %s", socket)
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return err
+	}
+	// A socket left by a daemon that didn't exit cleanly
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Keep running after the terminal that started the daemon closes
+	signal.Ignore(syscall.SIGHUP)
+
+	fmt.Fprintf(os.Stderr, "polly daemon listening on %s\n", socket)
+	return tools.NewMCPPool(newSandbox, cmd.Duration("idle")).Serve(ctx, l)
+}
+
+func runDaemonStart(ctx context.Context, cmd *cli.Command) error {
+	socket := daemonSocketPath()
+	if status, err := tools.QueryMCPPool(socket); err == nil {
+		fmt.Printf("polly daemon already running (pid %d)\n", status.PID)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(filepath.Dir(socket), "daemon.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	args := []string{"daemon", "run", "--idle", cmd.Duration("idle").String()}
+	if cmd.Bool("debug") {
+		args = append([]string{"--debug"}, args...)
+	}
+	child := exec.Command(exe, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	detach(child)
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start daemon: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	deadline := time.After(daemonStartTimeout)
+	for {
+		if status, err := tools.QueryMCPPool(socket); err == nil {
+			fmt.Printf("polly daemon started (pid %d)\n", status.PID)
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("daemon exited during startup (see %s): %v", logFile.Name(), err)
+		case <-deadline:
+			return fmt.Errorf("daemon did not start within %s (see %s)", daemonStartTimeout, logFile.Name())
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+func runDaemonStatus(ctx context.Context, cmd *cli.Command) error {
+	status, err := tools.QueryMCPPool(daemonSocketPath())
+	if err != nil {
+		fmt.Println("polly daemon is not running")
+		return nil
+	}
+
+	fmt.Printf("polly daemon running (pid %d, up %s)\n", status.PID, time.Since(status.Started).Round(time.Second))
+	if len(status.Servers) == 0 {
+		fmt.Println("No servers running")
+		return nil
+	}
+	for _, s := range status.Servers {
+		usage := fmt.Sprintf("%d in use", s.Clients)
+		if s.Clients == 0 {
+			usage = fmt.Sprintf("idle %s", time.Since(s.LastUsed).Round(time.Second))
+		}
+		fmt.Printf("  %s  %s  %s  %s\n    %s\n", s.Name, s.State, usage, s.Dir, s.Command)
+	}
+	return nil
+}
+
+func runDaemonStop(ctx context.Context, cmd *cli.Command) error {
+	if err := tools.StopMCPPool(daemonSocketPath()); err != nil {
+		fmt.Println("polly daemon is not running")
+		return nil
+	}
+	fmt.Println("polly daemon stopped")
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mcpElicitor answers MCP elicitation requests, where a server asks the user
+// for input in the middle of a tool call, by prompting on the terminal.
+type mcpElicitor struct {
+	mu          sync.Mutex
+	interactive bool   // Without a terminal every request is declined
+	clearStatus func() // Clears the status line before prompting, if set
+	in          *bufio.Reader
+	out         io.Writer
+}
+
+func newMCPElicitor() *mcpElicitor {
+	return &mcpElicitor{
+		interactive: isTerminal() && isStdinTerminal(),
+		in:          bufio.NewReader(os.Stdin),
+		out:         os.Stderr,
+	}
+}
+
+// elicitSchema is the flat object schema MCP allows for form elicitation
+type elicitSchema struct {
+	Properties map[string]elicitProperty `json:"properties"`
+	Required   []string                  `json:"required"`
+}
+
+type elicitProperty struct {
+	Type        string   `json:"type"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Default     any      `json:"default"`
+	Enum        []string `json:"enum"`
+	OneOf       []struct {
+		Const string `json:"const"`
+		Title string `json:"title"`
+	} `json:"oneOf"`
+	Format    string   `json:"format"`
+	MinLength *int     `json:"minLength"`
+	MaxLength *int     `json:"maxLength"`
+	Minimum   *float64 `json:"minimum"`
+	Maximum   *float64 `json:"maximum"`
+}
+
+// handle implements tools.ElicitationHandler.
+func (e *mcpElicitor) handle(_ context.Context, server string, params *mcp.ElicitParams) (*mcp.ElicitResult, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.interactive {
+		return &mcp.ElicitResult{Action: "decline"}, nil
+	}
+	if e.clearStatus != nil {
+		e.clearStatus()
+	}
+
+	fmt.Fprintf(e.out, "  %s asks: %s\n", dimStyle.Styled(server), params.Message)
+	if params.Mode == "url" {
+		fmt.Fprintf(e.out, "  %s\n", params.URL)
+		return e.confirm("  continue once done?")
+	}
+
+	var schema elicitSchema
+	if params.RequestedSchema != nil {
+		data, err := json.Marshal(params.RequestedSchema)
+		if err != nil {
+			return nil, fmt.Errorf("invalid requested schema: %w", err)
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("invalid requested schema: %w", err)
+		}
+	}
+	if len(schema.Properties) == 0 {
+		return e.confirm("  accept?")
+	}
+
+	result, err := e.confirm("  respond?")
+	if err != nil || result.Action != "accept" {
+		return result, err
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result.Content = make(map[string]any)
+	for _, name := range names {
+		value, ok, err := e.askField(name, schema.Properties[name], slices.Contains(schema.Required, name))
+		if err != nil {
+			// Input closed mid-form
+			return &mcp.ElicitResult{Action: "cancel"}, nil
+		}
+		if ok {
+			result.Content[name] = value
+		}
+	}
+	return result, nil
+}
+
+// confirm asks a yes/no question, mapping the answer to accept or decline
+func (e *mcpElicitor) confirm(question string) (*mcp.ElicitResult, error) {
+	fmt.Fprintf(e.out, "%s (Y/n): ", question)
+	answer, err := e.readLine()
+	if err != nil {
+		return &mcp.ElicitResult{Action: "cancel"}, nil
+	}
+	switch strings.ToLower(answer) {
+	case "", "y", "yes":
+		return &mcp.ElicitResult{Action: "accept"}, nil
+	default:
+		return &mcp.ElicitResult{Action: "decline"}, nil
+	}
+}
+
+// askField prompts until the input is valid for prop. ok is false when an
+// optional field is left empty.
+func (e *mcpElicitor) askField(name string, prop elicitProperty, required bool) (value any, ok bool, err error) {
+	label := name
+	if prop.Title != "" {
+		label = prop.Title
+	}
+	if prop.Description != "" {
+		label += " (" + prop.Description + ")"
+	}
+	for i, choice := range prop.choices() {
+		fmt.Fprintf(e.out, "    %d) %s\n", i+1, choice)
+	}
+	if prop.Default != nil {
+		label += fmt.Sprintf(" [%v]", prop.Default)
+	} else if required {
+		label += " *"
+	}
+
+	for {
+		fmt.Fprintf(e.out, "  %s: ", label)
+		input, err := e.readLine()
+		if err != nil {
+			return nil, false, err
+		}
+		if input == "" {
+			if prop.Default != nil {
+				return prop.Default, true, nil
+			}
+			if !required {
+				return nil, false, nil
+			}
+			fmt.Fprintf(e.out, "  %s\n", errorStyle.Styled("a value is required"))
+			continue
+		}
+		value, err := prop.parse(input)
+		if err != nil {
+			fmt.Fprintf(e.out, "  %s\n", errorStyle.Styled(err.Error()))
+			continue
+		}
+		return value, true, nil
+	}
+}
+
+func (e *mcpElicitor) readLine() (string, error) {
+	line, err := e.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// choices returns the allowed values of an enum property for display
+func (p elicitProperty) choices() []string {
+	if len(p.Enum) > 0 {
+		return p.Enum
+	}
+	var choices []string
+	for _, option := range p.OneOf {
+		choices = append(choices, cmp.Or(option.Title, option.Const))
+	}
+	return choices
+}
+
+// parse converts input to the property's type and checks its constraints
+func (p elicitProperty) parse(input string) (any, error) {
+	switch p.Type {
+	case "boolean":
+		switch strings.ToLower(input) {
+		case "y", "yes", "true":
+			return true, nil
+		case "n", "no", "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("enter yes or no")
+
+	case "number", "integer":
+		var n float64
+		if p.Type == "integer" {
+			i, err := strconv.ParseInt(input, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("enter a whole number")
+			}
+			n = float64(i)
+		} else {
+			f, err := strconv.ParseFloat(input, 64)
+			if err != nil {
+				return nil, fmt.Errorf("enter a number")
+			}
+			n = f
+		}
+		if p.Minimum != nil && n < *p.Minimum {
+			return nil, fmt.Errorf("must be at least %v", *p.Minimum)
+		}
+		if p.Maximum != nil && n > *p.Maximum {
+			return nil, fmt.Errorf("must be at most %v", *p.Maximum)
+		}
+		if p.Type == "integer" {
+			return int64(n), nil
+		}
+		return n, nil
+	}
+
+	// Strings, including enums chosen by number or value
+	if len(p.Enum) > 0 || len(p.OneOf) > 0 {
+		values := p.Enum
+		if len(values) == 0 {
+			for _, option := range p.OneOf {
+				values = append(values, option.Const)
+			}
+		}
+		if i, err := strconv.Atoi(input); err == nil && i >= 1 && i <= len(values) {
+			return values[i-1], nil
+		}
+		for i, choice := range p.choices() {
+			if input == values[i] || strings.EqualFold(input, choice) {
+				return values[i], nil
+			}
+		}
+		return nil, fmt.Errorf("choose one of the listed options")
+	}
+
+	length := len([]rune(input))
+	if p.MinLength != nil && length < *p.MinLength {
+		return nil, fmt.Errorf("must be at least %d characters", *p.MinLength)
+	}
+	if p.MaxLength != nil && length > *p.MaxLength {
+		return nil, fmt.Errorf("must be at most %d characters", *p.MaxLength)
+	}
+	switch p.Format {
+	case "email":
+		if _, err := mail.ParseAddress(input); err != nil {
+			return nil, fmt.Errorf("enter an email address")
+		}
+	case "uri":
+		if u, err := url.Parse(input); err != nil || u.Scheme == "" {
+			return nil, fmt.Errorf("enter a URI with a scheme")
+		}
+	case "date":
+		if _, err := time.Parse(time.DateOnly, input); err != nil {
+			return nil, fmt.Errorf("enter a date as YYYY-MM-DD")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, input); err != nil {
+			return nil, fmt.Errorf("enter a date and time in RFC 3339 format")
+		}
+	}
+	return input, nil
+}
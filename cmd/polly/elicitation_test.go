@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func scriptedElicitor(input string) *mcpElicitor {
+	return &mcpElicitor{
+		interactive: true,
+		in:          bufio.NewReader(strings.NewReader(input)),
+		out:         io.Discard,
+	}
+}
+
+var deploySchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"env":      map[string]any{"type": "string", "enum": []any{"staging", "production"}},
+		"replicas": map[string]any{"type": "integer", "minimum": 1, "maximum": 5},
+		"notify":   map[string]any{"type": "boolean", "default": true},
+		"note":     map[string]any{"type": "string", "maxLength": 10},
+	},
+	"required": []any{"env", "replicas"},
+}
+
+func TestElicitorForm(t *testing.T) {
+	// Fields are asked in name order: env, note, notify, replicas. Invalid
+	// and missing answers are asked again.
+	e := scriptedElicitor("y\n\n2\n\n\n9\nthree\n3\n")
+	result, err := e.handle(context.Background(), "deploy", &mcp.ElicitParams{Message: "Deploy where?", RequestedSchema: deploySchema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != "accept" {
+		t.Fatalf("Action = %q", result.Action)
+	}
+	want := map[string]any{"env": "production", "notify": true, "replicas": int64(3)}
+	if len(result.Content) != len(want) {
+		t.Fatalf("Content = %v, want %v", result.Content, want)
+	}
+	for key, value := range want {
+		if result.Content[key] != value {
+			t.Errorf("Content[%s] = %v (%T), want %v", key, result.Content[key], result.Content[key], value)
+		}
+	}
+}
+
+func TestElicitorDeclineAndCancel(t *testing.T) {
+	params := &mcp.ElicitParams{Message: "Deploy where?", RequestedSchema: deploySchema}
+
+	result, _ := scriptedElicitor("n\n").handle(context.Background(), "deploy", params)
+	if result.Action != "decline" {
+		t.Errorf("answering no: Action = %q", result.Action)
+	}
+
+	result, _ = scriptedElicitor("y\nstaging\n").handle(context.Background(), "deploy", params)
+	if result.Action != "cancel" {
+		t.Errorf("input closed mid-form: Action = %q", result.Action)
+	}
+
+	e := scriptedElicitor("y\n")
+	e.interactive = false
+	result, _ = e.handle(context.Background(), "deploy", params)
+	if result.Action != "decline" {
+		t.Errorf("without a terminal: Action = %q", result.Action)
+	}
+}
+
+func TestElicitPropertyParse(t *testing.T) {
+	minLen := 3
+	tests := []struct {
+		prop  elicitProperty
+		input string
+		want  any
+		ok    bool
+	}{
+		{elicitProperty{Type: "string", MinLength: &minLen}, "ab", nil, false},
+		{elicitProperty{Type: "string", Format: "email"}, "dev@example.com", "dev@example.com", true},
+		{elicitProperty{Type: "string", Format: "email"}, "dev", nil, false},
+		{elicitProperty{Type: "string", Format: "date"}, "2026-10-16", "2026-10-16", true},
+		{elicitProperty{Type: "string", Format: "uri"}, "example.com", nil, false},
+		{elicitProperty{Type: "number"}, "1.5", 1.5, true},
+		{elicitProperty{Type: "integer"}, "1.5", nil, false},
+		{elicitProperty{Type: "boolean"}, "no", false, true},
+		{elicitProperty{Type: "string", Enum: []string{"a", "b"}}, "c", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := tt.prop.parse(tt.input)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("parse(%q) with %+v = %v, %v", tt.input, tt.prop, got, err)
+		}
+	}
+}
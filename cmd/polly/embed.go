@@ -76,6 +76,10 @@ func runEmbed(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("no input provided. use -i, -f, positional args, or stdin")
 	}
 
+	providers, err := loadProviders()
+	if err != nil {
+		return err
+	}
 	resp, err := llm.Embed(ctx, &llm.EmbeddingRequest{
 		Model:      cmd.String("model"),
 		BaseURL:    cmd.String("baseurl"),
@@ -83,6 +87,7 @@ func runEmbed(ctx context.Context, cmd *cli.Command) error {
 		Input:      input,
 		Dimensions: int(cmd.Int("dimensions")),
 		TaskType:   cmd.String("task-type"),
+		Providers:  providers,
 	})
 	if err != nil {
 		return err
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
 )
 
 // supportedImageTypes lists common image MIME types
@@ -200,14 +202,79 @@ func fetchURL(urlStr string) (*messages.ContentPart, error) {
 	}, nil
 }
 
-// processFiles reads all specified files and returns content parts
-func processFiles(paths []string) ([]messages.ContentPart, error) {
+// parseMCPResource splits "mcp://server/uri" into the server namespace and
+// the resource URI
+func parseMCPResource(path string) (server, uri string, ok bool) {
+	rest, ok := strings.CutPrefix(path, "mcp://")
+	if !ok {
+		return "", "", false
+	}
+	server, uri, _ = strings.Cut(rest, "/")
+	return server, uri, server != "" && uri != ""
+}
+
+// readMCPResource reads a resource from an MCP server loaded in registry and
+// returns one content part per resource content
+func readMCPResource(registry *tools.ToolRegistry, server, uri string) ([]messages.ContentPart, error) {
+	if registry == nil {
+		return nil, fmt.Errorf("no MCP servers are loaded")
+	}
+	client, ok := registry.MCPServerClient(server)
+	if !ok || !client.HasResources() {
+		return nil, fmt.Errorf("no loaded MCP server %q exposes resources (load it with --tool)", server)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	contents, err := client.ReadResource(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []messages.ContentPart
+	for _, content := range contents {
+		switch {
+		case content.Blob == nil:
+			parts = append(parts, messages.ContentPart{
+				Type:     "text",
+				Text:     content.Text,
+				FileName: content.URI,
+			})
+		case isImageType(content.MIMEType):
+			parts = append(parts, messages.ContentPart{
+				Type:      "image_base64",
+				ImageData: base64.StdEncoding.EncodeToString(content.Blob),
+				MimeType:  content.MIMEType,
+				FileName:  content.URI,
+			})
+		default:
+			return nil, fmt.Errorf("resource %s is binary (%s) and cannot be attached", content.URI, content.MIMEType)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("resource %s has no contents", uri)
+	}
+	return parts, nil
+}
+
+// processFiles reads all specified files and returns content parts.
+// mcp://server/uri paths are read from MCP servers loaded in registry.
+func processFiles(paths []string, registry *tools.ToolRegistry) ([]messages.ContentPart, error) {
 	var parts []messages.ContentPart
 
 	for _, path := range paths {
 		var part *messages.ContentPart
 		var err error
 
+		if server, uri, ok := parseMCPResource(path); ok {
+			resourceParts, err := readMCPResource(registry, server, uri)
+			if err != nil {
+				return nil, fmt.Errorf("error reading MCP resource %s: %w", path, err)
+			}
+			parts = append(parts, resourceParts...)
+			continue
+		}
+
 		// Check if path is a URL
 		if isURL(path) {
 			// Fetch from URL
@@ -239,14 +306,14 @@ func processFiles(paths []string) ([]messages.ContentPart, error) {
 }
 
 // buildMessageWithFiles creates a message with text and file content
-func buildMessageWithFiles(prompt string, files []string) (messages.ChatMessage, error) {
+func buildMessageWithFiles(prompt string, files []string, registry *tools.ToolRegistry) (messages.ChatMessage, error) {
 	msg := messages.ChatMessage{
 		Role: messages.MessageRoleUser,
 	}
 
 	// Process files if any
 	if len(files) > 0 {
-		parts, err := processFiles(files)
+		parts, err := processFiles(files, registry)
 		if err != nil {
 			return msg, err
 		}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMCPResource(t *testing.T) {
+	tests := []struct {
+		path, server, uri string
+		ok                bool
+	}{
+		{"mcp://docs/docs://readme", "docs", "docs://readme", true},
+		{"mcp://fs/file:///etc/hosts", "fs", "file:///etc/hosts", true},
+		{"mcp://docs", "", "", false},
+		{"mcp:///docs://readme", "", "", false},
+		{"notes.txt", "", "", false},
+	}
+	for _, tt := range tests {
+		server, uri, ok := parseMCPResource(tt.path)
+		if ok != tt.ok || (ok && (server != tt.server || uri != tt.uri)) {
+			t.Errorf("parseMCPResource(%q) = %q, %q, %v", tt.path, server, uri, ok)
+		}
+	}
+}
+
+func TestProcessFilesMCPResourceWithoutServer(t *testing.T) {
+	_, err := processFiles([]string{"mcp://docs/docs://readme"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no MCP servers are loaded") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+)
+
+// Output formats accepted by --output
+const (
+	outputText  = "text"
+	outputJSONL = "jsonl"
+)
+
+// Record types written in jsonl output mode. These names are part of the
+// CLI's scripting interface; add new types rather than renaming these.
+const (
+	recordContent    = "content"
+	recordReasoning  = "reasoning"
+	recordToolCall   = "tool_call"
+	recordToolResult = "tool_result"
+	recordResponse   = "response"
+	recordError      = "error"
+	recordSummary    = "summary"
+	recordSubAgent   = "subagent"
+	recordPlan       = "plan"
+	recordPlanStep   = "plan_step"
+)
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputText, outputJSONL:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q (expected %s or %s)", format, outputText, outputJSONL)
+}
+
+type textRecord struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolCallRecord struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+type toolResultRecord struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Result     string `json:"result"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type responseRecord struct {
+	Type         string `json:"type"`
+	Model        string `json:"model,omitempty"`
+	StopReason   string `json:"stop_reason"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	ToolCalls    int    `json:"tool_calls"`
+}
+
+type errorRecord struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// subAgentRecord wraps an event of a sub-agent started by the task tool in
+// the record the parent would write for it
+type subAgentRecord struct {
+	Type   string `json:"type"`
+	Depth  int    `json:"depth"`
+	TaskID string `json:"task_id"`
+	Event  any    `json:"event"`
+}
+
+type planRecord struct {
+	Type  string   `json:"type"`
+	Task  string   `json:"task"`
+	Steps []string `json:"steps"`
+}
+
+type planStepRecord struct {
+	Type        string `json:"type"`
+	Step        int    `json:"step"` // 1-based
+	Description string `json:"description"`
+	Status      string `json:"status"`
+	Result      string `json:"result,omitempty"`
+}
+
+type summaryRecord struct {
+	Type         string  `json:"type"`
+	Context      string  `json:"context,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	StopReason   string  `json:"stop_reason,omitempty"`
+	Content      string  `json:"content"`
+	Iterations   int     `json:"iterations"`
+	ToolCalls    int     `json:"tool_calls"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"`
+	DurationMs   int64   `json:"duration_ms"`
+	Error        string  `json:"error,omitempty"`
+	Resumable    bool    `json:"resumable,omitempty"` // The run stopped early and --resume can continue it
+}
+
+// eventWriter writes one JSON object per line for each agent event, followed
+// by a summary record for the run. It is safe for concurrent use since tool
+// results arrive from parallel executions.
+type eventWriter struct {
+	mu    sync.Mutex
+	enc   *json.Encoder
+	start time.Time
+
+	inputTokens  int
+	outputTokens int
+	toolCalls    int
+}
+
+func newEventWriter(w io.Writer) *eventWriter {
+	return &eventWriter{enc: json.NewEncoder(w), start: time.Now()}
+}
+
+func (e *eventWriter) write(record any) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_ = e.enc.Encode(record)
+}
+
+func (e *eventWriter) Content(text string) {
+	e.write(textRecord{Type: recordContent, Text: text})
+}
+
+func (e *eventWriter) Reasoning(text string) {
+	e.write(textRecord{Type: recordReasoning, Text: text})
+}
+
+// ToolCalls writes a record per call, with arguments as a JSON object when
+// they parse and as the raw string otherwise.
+func (e *eventWriter) ToolCalls(calls []messages.ChatMessageToolCall) {
+	for _, tc := range calls {
+		e.write(newToolCallRecord(tc))
+	}
+}
+
+func newToolCallRecord(tc messages.ChatMessageToolCall) toolCallRecord {
+	var args any = tc.Arguments
+	if json.Valid([]byte(tc.Arguments)) {
+		args = json.RawMessage(tc.Arguments)
+	}
+	return toolCallRecord{Type: recordToolCall, ID: tc.ID, Name: tc.Name, Arguments: args}
+}
+
+func (e *eventWriter) ToolResult(tc messages.ChatMessageToolCall, result string, duration time.Duration, err error) {
+	e.write(newToolResultRecord(tc, result, duration, err))
+}
+
+func newToolResultRecord(tc messages.ChatMessageToolCall, result string, duration time.Duration, err error) toolResultRecord {
+	record := toolResultRecord{
+		Type:       recordToolResult,
+		ID:         tc.ID,
+		Name:       tc.Name,
+		Result:     result,
+		DurationMs: duration.Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
+
+// Response records the usage of one LLM call and adds it to the run totals.
+func (e *eventWriter) Response(msg *messages.ChatMessage) {
+	e.write(e.countResponse(msg))
+}
+
+// countResponse adds a call's usage to the run totals and returns its record.
+func (e *eventWriter) countResponse(msg *messages.ChatMessage) responseRecord {
+	e.mu.Lock()
+	e.inputTokens += msg.GetInputTokens()
+	e.outputTokens += msg.GetOutputTokens()
+	e.toolCalls += len(msg.ToolCalls)
+	e.mu.Unlock()
+
+	return responseRecord{
+		Type:         recordResponse,
+		Model:        msg.GetModel(),
+		StopReason:   string(msg.StopReason),
+		InputTokens:  msg.GetInputTokens(),
+		OutputTokens: msg.GetOutputTokens(),
+		ToolCalls:    len(msg.ToolCalls),
+	}
+}
+
+func (e *eventWriter) Error(err error) {
+	e.write(errorRecord{Type: recordError, Error: err.Error()})
+}
+
+// Plan records the approved plan of a planned turn.
+func (e *eventWriter) Plan(plan *sessions.Plan) {
+	record := planRecord{Type: recordPlan, Task: plan.Task, Steps: []string{}}
+	for _, step := range plan.Steps {
+		record.Steps = append(record.Steps, step.Description)
+	}
+	e.write(record)
+}
+
+// PlanStep records how step i of plan finished.
+func (e *eventWriter) PlanStep(plan *sessions.Plan, i int) {
+	step := plan.Steps[i]
+	e.write(planStepRecord{Type: recordPlanStep, Step: i + 1, Description: step.Description, Status: step.Status, Result: step.Result})
+}
+
+// SubAgent writes an event of a sub-agent started by the task tool, wrapped
+// with its depth and the task call running it. Sub-agent calls count toward
+// the run totals, as their cost does.
+func (e *eventWriter) SubAgent(event llm.SubAgentEvent) {
+	var records []any
+	switch event.Type {
+	case llm.SubAgentContent:
+		records = append(records, textRecord{Type: recordContent, Text: event.Content})
+	case llm.SubAgentReasoning:
+		records = append(records, textRecord{Type: recordReasoning, Text: event.Content})
+	case llm.SubAgentToolStart:
+		for _, tc := range event.Calls {
+			records = append(records, newToolCallRecord(tc))
+		}
+	case llm.SubAgentToolEnd:
+		records = append(records, newToolResultRecord(event.Calls[0], event.Content, event.Duration, event.Err))
+	case llm.SubAgentResponse:
+		records = append(records, e.countResponse(event.Response))
+	case llm.SubAgentError:
+		records = append(records, errorRecord{Type: recordError, Error: event.Err.Error()})
+	}
+	for _, record := range records {
+		e.write(subAgentRecord{Type: recordSubAgent, Depth: event.Depth, TaskID: event.Task.ID, Event: record})
+	}
+}
+
+// Summary writes the final record for a run. resp may be nil when the run
+// failed before producing any messages.
+func (e *eventWriter) Summary(contextID, model string, resp *llm.AgentResponse, runErr error) {
+	e.mu.Lock()
+	record := summaryRecord{
+		Type:         recordSummary,
+		Context:      contextID,
+		Model:        model,
+		ToolCalls:    e.toolCalls,
+		InputTokens:  e.inputTokens,
+		OutputTokens: e.outputTokens,
+		DurationMs:   time.Since(e.start).Milliseconds(),
+	}
+	e.mu.Unlock()
+
+	if resp != nil {
+		record.Iterations = resp.IterationCount
+		record.Cost = resp.Cost
+		record.Resumable = resp.State != nil
+		if msg := resp.Message; msg != nil {
+			record.Content = msg.Content
+			record.StopReason = string(msg.StopReason)
+			if answered := msg.GetModel(); answered != "" {
+				record.Model = answered
+			}
+		}
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	e.write(record)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+func TestEventWriterRecords(t *testing.T) {
+	var buf bytes.Buffer
+	events := newEventWriter(&buf)
+
+	call := messages.ChatMessageToolCall{ID: "tc1", Name: "read_file", Arguments: `{"file_path":"a.txt"}`}
+	toolUse := &messages.ChatMessage{
+		Role:       messages.MessageRoleAssistant,
+		ToolCalls:  []messages.ChatMessageToolCall{call},
+		StopReason: messages.StopReasonToolUse,
+		Metadata:   map[string]any{messages.MetadataKeyInputTokens: 10, messages.MetadataKeyOutputTokens: 5},
+	}
+	final := &messages.ChatMessage{
+		Role:       messages.MessageRoleAssistant,
+		Content:    "hello",
+		StopReason: messages.StopReasonEndTurn,
+		Metadata:   map[string]any{messages.MetadataKeyInputTokens: 20, messages.MetadataKeyOutputTokens: 3},
+	}
+
+	events.Reasoning("thinking")
+	events.ToolCalls([]messages.ChatMessageToolCall{call, {ID: "tc2", Name: "bad", Arguments: "{not json"}})
+	events.Response(toolUse)
+	events.ToolResult(call, "contents", 1500*time.Millisecond, nil)
+	events.ToolResult(messages.ChatMessageToolCall{ID: "tc2", Name: "bad"}, "Error", 0, errors.New("boom"))
+	events.Content("hello")
+	events.Response(final)
+	events.Summary("ctx", "openai/gpt-4o", &llm.AgentResponse{Message: final, IterationCount: 2, Cost: 0.01}, nil)
+
+	var records []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+
+	wantTypes := []string{
+		recordReasoning, recordToolCall, recordToolCall, recordResponse,
+		recordToolResult, recordToolResult, recordContent, recordResponse, recordSummary,
+	}
+	if len(records) != len(wantTypes) {
+		t.Fatalf("got %d records, want %d: %v", len(records), len(wantTypes), records)
+	}
+	for i, want := range wantTypes {
+		if records[i]["type"] != want {
+			t.Errorf("record %d type = %v, want %s", i, records[i]["type"], want)
+		}
+	}
+
+	if args, ok := records[1]["arguments"].(map[string]any); !ok || args["file_path"] != "a.txt" {
+		t.Errorf("tool_call arguments = %#v, want parsed object", records[1]["arguments"])
+	}
+	if records[2]["arguments"] != "{not json" {
+		t.Errorf("invalid arguments = %#v, want raw string", records[2]["arguments"])
+	}
+	if records[4]["duration_ms"] != float64(1500) {
+		t.Errorf("tool_result duration_ms = %v", records[4]["duration_ms"])
+	}
+	if records[5]["error"] != "boom" {
+		t.Errorf("tool_result error = %v", records[5]["error"])
+	}
+
+	summary := records[len(records)-1]
+	if summary["content"] != "hello" || summary["stop_reason"] != "end_turn" || summary["context"] != "ctx" {
+		t.Errorf("summary = %v", summary)
+	}
+	if summary["input_tokens"] != float64(30) || summary["output_tokens"] != float64(8) || summary["tool_calls"] != float64(1) {
+		t.Errorf("summary usage = %v", summary)
+	}
+	if summary["iterations"] != float64(2) || summary["cost"] != 0.01 {
+		t.Errorf("summary run stats = %v", summary)
+	}
+}
+
+func TestEventWriterSummaryOnError(t *testing.T) {
+	var buf bytes.Buffer
+	newEventWriter(&buf).Summary("", "openai/gpt-4o", nil, llm.ErrBudgetExceeded)
+
+	var summary summaryRecord
+	if err := json.Unmarshal(buf.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Type != recordSummary || summary.Error != llm.ErrBudgetExceeded.Error() || summary.Model != "openai/gpt-4o" {
+		t.Fatalf("summary = %+v", summary)
+	}
+}
+
+func TestEventWriterSubAgentRecords(t *testing.T) {
+	var buf bytes.Buffer
+	events := newEventWriter(&buf)
+
+	task := messages.ChatMessageToolCall{ID: "t1", Name: llm.TaskToolName}
+	call := messages.ChatMessageToolCall{ID: "c1", Name: "grep", Arguments: `{"pattern":"x"}`}
+	response := &messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "done"}
+	response.SetTokenUsage(7, 2)
+
+	events.SubAgent(llm.SubAgentEvent{Depth: 1, Task: task, Type: llm.SubAgentToolStart, Calls: []messages.ChatMessageToolCall{call}})
+	events.SubAgent(llm.SubAgentEvent{Depth: 1, Task: task, Type: llm.SubAgentToolEnd, Calls: []messages.ChatMessageToolCall{call}, Content: "match"})
+	events.SubAgent(llm.SubAgentEvent{Depth: 2, Task: task, Type: llm.SubAgentResponse, Response: response})
+	events.Summary("", "openai/gpt-4o", &llm.AgentResponse{Message: response}, nil)
+
+	var records []subAgentRecord
+	var summary summaryRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var record subAgentRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record.Type == recordSummary {
+			json.Unmarshal(scanner.Bytes(), &summary)
+			continue
+		}
+		records = append(records, record)
+	}
+
+	wantTypes := []string{recordToolCall, recordToolResult, recordResponse}
+	if len(records) != len(wantTypes) {
+		t.Fatalf("got %d sub-agent records, want %d: %+v", len(records), len(wantTypes), records)
+	}
+	for i, record := range records {
+		event, _ := record.Event.(map[string]any)
+		if record.Type != recordSubAgent || record.TaskID != "t1" || event["type"] != wantTypes[i] {
+			t.Errorf("record %d = %+v, want a %s wrapped for task t1", i, record, wantTypes[i])
+		}
+	}
+	if records[2].Depth != 2 {
+		t.Errorf("depth = %d, want 2", records[2].Depth)
+	}
+	if summary.InputTokens != 7 || summary.OutputTokens != 2 {
+		t.Errorf("summary = %+v, want the sub-agent's tokens counted", summary)
+	}
+}
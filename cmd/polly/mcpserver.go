@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/urfave/cli/v3"
+)
+
+func mcpServerCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "mcp-server",
+		Usage: "Serve polly as an MCP server (ask, embed and one tool per skill) over stdio or HTTP",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "http",
+				Usage: "Serve streamable HTTP on this address instead of stdio",
+			},
+		}, agentServerFlags()...),
+		Action: runMCPServer,
+	}
+}
+
+func runMCPServer(ctx context.Context, cmd *cli.Command) error {
+	server, err := newChatServer(agentServerConfig(cmd), cmd.String("embed-model"), "")
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mcpServer := server.mcpServer()
+	addr := cmd.String("http")
+	if addr == "" {
+		return mcpServer.Run(ctx, &mcp.StdioTransport{})
+	}
+
+	httpServer := &http.Server{
+		Addr: addr,
+		Handler: mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+			return mcpServer
+		}, nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "polly mcp-server listening on http://%s\n", addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+type askInput struct {
+	Prompt  string         `json:"prompt" jsonschema:"the question or task for the model"`
+	Model   string         `json:"model,omitempty" jsonschema:"provider/model to use, e.g. openai/gpt-5; defaults to the server's model"`
+	Schema  map[string]any `json:"schema,omitempty" jsonschema:"JSON schema the answer must conform to"`
+	Context string         `json:"context,omitempty" jsonschema:"polly context name that keeps the conversation across calls"`
+}
+
+type embedInput struct {
+	Input      []string `json:"input" jsonschema:"texts to embed, one vector per text"`
+	Model      string   `json:"model,omitempty" jsonschema:"provider/model embedding model; defaults to the server's embedding model"`
+	Dimensions int      `json:"dimensions,omitempty" jsonschema:"output vector dimensions (0 = model default)"`
+}
+
+type skillInput struct {
+	Prompt  string `json:"prompt" jsonschema:"the task to carry out with the skill"`
+	Model   string `json:"model,omitempty" jsonschema:"provider/model to use; defaults to the server's model"`
+	Context string `json:"context,omitempty" jsonschema:"polly context name that keeps the conversation across calls"`
+}
+
+// mcpServer publishes ask, embed and a skill_<name> tool per discovered skill.
+func (s *chatServer) mcpServer() *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{
+		Name:    "pollytool",
+		Version: "1.0.0",
+	}, &mcp.ServerOptions{
+		Instructions: "polly runs prompts against any configured LLM provider, executing its own sandboxed tools. Use ask to delegate a task, embed for embedding vectors, and skill_* tools to run a task with an Agent Skill.",
+	})
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "ask",
+		Description: "Run a prompt through polly's agent loop and return the final answer. Tools configured on the server run automatically.",
+	}, s.askTool)
+
+	mcp.AddTool(server, &mcp.Tool{
+		Name:        "embed",
+		Description: "Generate embedding vectors for text input.",
+	}, s.embedTool)
+
+	for _, skill := range s.skillCatalog.List() {
+		mcp.AddTool(server, &mcp.Tool{
+			Name:        "skill_" + skill.Name,
+			Description: skill.Description,
+		}, s.skillTool(skill.Name))
+	}
+
+	return server
+}
+
+func (s *chatServer) askTool(ctx context.Context, _ *mcp.CallToolRequest, in askInput) (*mcp.CallToolResult, any, error) {
+	if strings.TrimSpace(in.Prompt) == "" {
+		return nil, nil, errors.New("prompt must be a non-empty string")
+	}
+
+	req := s.newRequest(in.Model, messages.User(in.Prompt))
+	if in.Schema != nil {
+		req.ResponseSchema = &llm.Schema{Raw: in.Schema, Strict: true}
+	}
+
+	resp, err := s.run(ctx, req, in.Context, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return answerResult(resp.Message, req.ResponseSchema != nil), nil, nil
+}
+
+func (s *chatServer) embedTool(ctx context.Context, _ *mcp.CallToolRequest, in embedInput) (*mcp.CallToolResult, any, error) {
+	if len(in.Input) == 0 {
+		return nil, nil, errors.New("input must not be empty")
+	}
+	model := in.Model
+	if model == "" {
+		model = s.embedModel
+	}
+	if err := validateEmbedModel(model); err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.embed(ctx, &llm.EmbeddingRequest{
+		Model:      model,
+		Timeout:    s.config.Timeout,
+		Input:      in.Input,
+		Dimensions: in.Dimensions,
+		Providers:  s.providers,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := embedOutput{
+		Model:       resp.Model,
+		Embeddings:  resp.Embeddings,
+		InputTokens: resp.InputTokens,
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &mcp.CallToolResult{
+		Content:           []mcp.Content{&mcp.TextContent{Text: string(data)}},
+		StructuredContent: out,
+	}, nil, nil
+}
+
+// skillTool runs a prompt with one skill active. Each call gets its own tool
+// registry so a skill's tools and allowed-tools policy don't leak into other
+// calls.
+func (s *chatServer) skillTool(name string) mcp.ToolHandlerFor[skillInput, any] {
+	return func(ctx context.Context, _ *mcp.CallToolRequest, in skillInput) (*mcp.CallToolResult, any, error) {
+		if strings.TrimSpace(in.Prompt) == "" {
+			return nil, nil, errors.New("prompt must be a non-empty string")
+		}
+
+		registry, runtime, err := newServerRegistry(s.config, s.skillResult)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer registry.Close()
+
+		instructions, err := runtime.Activate(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("activate skill %s: %w", name, err)
+		}
+
+		scoped := *s
+		scoped.registry = registry
+		// The instructions travel with the task rather than in the system
+		// prompt, which a stored context keeps as it was
+		req := scoped.newRequest(in.Model, messages.User(instructions+"\n\nTask: "+in.Prompt))
+
+		resp, err := scoped.run(ctx, req, in.Context, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return answerResult(resp.Message, false), nil, nil
+	}
+}
+
+// answerResult wraps the agent's final message as a tool result, adding the
+// parsed JSON as structured content for schema answers.
+func answerResult(msg *messages.ChatMessage, structured bool) *mcp.CallToolResult {
+	text := strings.TrimSpace(msg.Content)
+	result := &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}
+	if structured {
+		var data map[string]any
+		if err := json.Unmarshal([]byte(text), &data); err == nil {
+			result.StructuredContent = data
+		}
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectMCPServer serves server over in-memory transports and returns a
+// connected client session.
+func connectMCPServer(t *testing.T, server *chatServer) *mcp.ClientSession {
+	t.Helper()
+	ctx := context.Background()
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.mcpServer().Connect(ctx, serverTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { serverSession.Close() })
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func toolText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+	if len(result.Content) != 1 {
+		t.Fatalf("content = %+v", result.Content)
+	}
+	text, ok := result.Content[0].(*mcp.TextContent)
+	if !ok {
+		t.Fatalf("content[0] = %T", result.Content[0])
+	}
+	return text.Text
+}
+
+func TestMCPServerTools(t *testing.T) {
+	root := t.TempDir()
+	createSkillWithScript(t, root, "my-skill")
+
+	server := newTestChatServer(t, &echoLLM{})
+	server.config.NoSandbox = true
+	server.config.SkillDirs = []string{root}
+	skillResult, err := loadSkillCatalog(server.config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.skillResult = skillResult
+	server.skillCatalog = skillResult.catalog
+	server.embed = func(_ context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+		return &llm.EmbeddingResponse{Model: req.Model, Embeddings: [][]float64{{0.5}}, InputTokens: 1}, nil
+	}
+
+	session := connectMCPServer(t, server)
+	ctx := context.Background()
+
+	list, err := session.ListTools(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "ask,embed,skill_my-skill" {
+		t.Fatalf("tools = %v", names)
+	}
+
+	result, err := session.CallTool(ctx, &mcp.CallToolParams{Name: "ask", Arguments: map[string]any{"prompt": "hi", "context": "delegate"}})
+	if err != nil || result.IsError {
+		t.Fatalf("ask = %+v, %v", result, err)
+	}
+	if got := toolText(t, result); got != "echo: hi" {
+		t.Fatalf("ask text = %q", got)
+	}
+	if !server.store.Exists("delegate") {
+		t.Fatal("ask did not record the named context")
+	}
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "embed", Arguments: map[string]any{"input": []string{"a"}}})
+	if err != nil || result.IsError {
+		t.Fatalf("embed = %+v, %v", result, err)
+	}
+	if got := toolText(t, result); !strings.Contains(got, `"model":"openai/text-embedding-3-small"`) {
+		t.Fatalf("embed text = %q", got)
+	}
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "skill_my-skill", Arguments: map[string]any{"prompt": "do it"}})
+	if err != nil || result.IsError {
+		t.Fatalf("skill = %+v, %v", result, err)
+	}
+	if got := toolText(t, result); !strings.Contains(got, "Use the helper script") || !strings.HasSuffix(got, "Task: do it") {
+		t.Fatalf("skill text = %q", got)
+	}
+
+	result, err = session.CallTool(ctx, &mcp.CallToolParams{Name: "ask", Arguments: map[string]any{"prompt": " "}})
+	if err != nil || !result.IsError {
+		t.Fatalf("empty prompt = %+v, %v", result, err)
+	}
+}
+
+func TestMCPServerWithoutSkills(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	session := connectMCPServer(t, server)
+
+	list, err := session.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Tools) != 2 {
+		t.Fatalf("tools without skills = %d, want 2", len(list.Tools))
+	}
+}
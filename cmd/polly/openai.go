@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+// Wire types for the subset of the OpenAI HTTP API served by polly serve.
+
+type openAIChatRequest struct {
+	Model               string            `json:"model"`
+	Messages            []openAIMessage   `json:"messages"`
+	Stream              bool              `json:"stream"`
+	StreamOptions       *openAIStreamOpts `json:"stream_options,omitempty"`
+	Temperature         *float32          `json:"temperature,omitempty"`
+	MaxTokens           int               `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int               `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string            `json:"reasoning_effort,omitempty"`
+	ResponseFormat      *openAIRespFormat `json:"response_format,omitempty"`
+	Tools               []json.RawMessage `json:"tools,omitempty"`
+}
+
+type openAIStreamOpts struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIRespFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name   string          `json:"name"`
+		Schema json.RawMessage `json:"schema"`
+	} `json:"json_schema,omitempty"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    json.RawMessage  `json:"content,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL string `json:"url"`
+	} `json:"image_url,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIChatResponse struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []openAIChatChoice `json:"choices"`
+	Usage   *openAIUsage       `json:"usage,omitempty"`
+}
+
+type openAIChatChoice struct {
+	Index        int               `json:"index"`
+	Message      *openAIReplyDelta `json:"message,omitempty"`
+	Delta        *openAIReplyDelta `json:"delta,omitempty"`
+	FinishReason *string           `json:"finish_reason"`
+}
+
+// openAIReplyDelta is used both for whole messages and for stream deltas.
+type openAIReplyDelta struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+type openAIEmbeddingRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	Dimensions     int             `json:"dimensions,omitempty"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+}
+
+type openAIEmbedding struct {
+	Object    string `json:"object"`
+	Index     int    `json:"index"`
+	Embedding any    `json:"embedding"`
+}
+
+type openAIEmbeddingResponse struct {
+	Object string            `json:"object"`
+	Data   []openAIEmbedding `json:"data"`
+	Model  string            `json:"model"`
+	Usage  openAIUsage       `json:"usage"`
+}
+
+type openAIError struct {
+	Error openAIErrorBody `json:"error"`
+}
+
+type openAIErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+// toChatMessage converts an OpenAI message to polly's provider-agnostic form.
+func (m openAIMessage) toChatMessage() (messages.ChatMessage, error) {
+	msg := messages.ChatMessage{Role: m.Role, ToolCallID: m.ToolCallID}
+	switch m.Role {
+	case messages.MessageRoleSystem, messages.MessageRoleUser, messages.MessageRoleAssistant, messages.MessageRoleTool:
+	case "developer":
+		msg.Role = messages.MessageRoleSystem
+	default:
+		return msg, fmt.Errorf("unsupported message role %q", m.Role)
+	}
+
+	for _, tc := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, messages.ChatMessageToolCall{
+			ID:        tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+
+	if len(m.Content) == 0 || string(m.Content) == "null" {
+		return msg, nil
+	}
+	var text string
+	if err := json.Unmarshal(m.Content, &text); err == nil {
+		msg.Content = text
+		return msg, nil
+	}
+
+	var parts []openAIContentPart
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return msg, fmt.Errorf("message content must be a string or an array of parts")
+	}
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			msg.Parts = append(msg.Parts, messages.ContentPart{Type: "text", Text: part.Text})
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return msg, fmt.Errorf("image_url part is missing its url")
+			}
+			msg.Parts = append(msg.Parts, imagePartFromURL(part.ImageURL.URL))
+		default:
+			return msg, fmt.Errorf("unsupported content part type %q", part.Type)
+		}
+	}
+	return msg, nil
+}
+
+// imagePartFromURL keeps remote URLs as-is and unpacks base64 data URLs.
+func imagePartFromURL(url string) messages.ContentPart {
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		if meta, data, ok := strings.Cut(rest, ","); ok && strings.HasSuffix(meta, ";base64") {
+			return messages.ContentPart{
+				Type:      "image_base64",
+				ImageData: data,
+				MimeType:  strings.TrimSuffix(meta, ";base64"),
+			}
+		}
+	}
+	return messages.ContentPart{Type: "image_url", ImageURL: url}
+}
+
+// responseSchema returns the schema of a json_schema response format, or nil.
+func (f *openAIRespFormat) responseSchema() (*llm.Schema, error) {
+	if f == nil || f.Type != "json_schema" {
+		return nil, nil
+	}
+	if f.JSONSchema == nil {
+		return nil, fmt.Errorf("response_format json_schema is missing its schema")
+	}
+	schema := llm.SchemaFromJSON(string(f.JSONSchema.Schema))
+	if schema == nil {
+		return nil, fmt.Errorf("response_format json_schema has an invalid schema")
+	}
+	return schema, nil
+}
+
+// finishReason maps a stop reason to OpenAI's finish_reason values.
+func finishReason(reason messages.StopReason) string {
+	switch reason {
+	case messages.StopReasonMaxTokens:
+		return "length"
+	case messages.StopReasonContentFilter:
+		return "content_filter"
+	default:
+		return "stop"
+	}
+}
+
+// embeddingInput accepts the string and array-of-strings forms of input.
+func embeddingInput(raw json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of strings")
+	}
+	return many, nil
+}
+
+// encodeEmbedding returns vec as floats, or as base64 little-endian float32
+// when the client asked for encoding_format "base64".
+func encodeEmbedding(vec []float64, format string) any {
+	if format != "base64" {
+		return vec
+	}
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
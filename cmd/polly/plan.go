@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+)
+
+// errPlanRejected is returned when the user discards the plan of a turn.
+var errPlanRejected = errors.New("plan not approved")
+
+// stepNumberPattern matches the numbering in front of an edited plan step
+var stepNumberPattern = regexp.MustCompile(`^(\d+[.)]|[-*])\s*`)
+
+// planTurn runs userMsg as a plan-then-execute turn: a planning turn with
+// tools disabled, the user's review of the plan, then one turn per step.
+// Nothing is added to the session until the plan is approved. The
+// response covers the planning turn and every step.
+func (c *conversation) planTurn(ctx context.Context, userMsg messages.ChatMessage) (*llm.AgentResponse, error) {
+	plan, planResp, err := c.makePlan(ctx, userMsg)
+	if err != nil {
+		return nil, err
+	}
+	plan, err = c.reviewPlan(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	// The planning turn's output goes into the history as the approved plan
+	planResp.AllMessages = nil
+
+	abandonRun(c.session)
+	c.session.AddMessage(userMsg)
+	// Every step sees the approved plan in the history
+	c.session.AddMessage(messages.ChatMessage{
+		Role:    messages.MessageRoleAssistant,
+		Content: "Plan:\n" + llm.FormatPlan(plan),
+	})
+	savePlan(c.session, plan)
+	if c.events != nil {
+		c.events.Plan(plan)
+	}
+	resp, err := c.runPlan(ctx, plan)
+	return addResponse(planResp, resp), err
+}
+
+// makePlan asks the model for a plan for userMsg with tools disabled.
+func (c *conversation) makePlan(ctx context.Context, userMsg messages.ChatMessage) (*sessions.Plan, *llm.AgentResponse, error) {
+	if c.statusLine != nil {
+		c.statusLine.ShowSpinner("planning")
+	}
+	budget, err := c.turnBudget()
+	if err != nil {
+		if c.statusLine != nil {
+			c.statusLine.Clear()
+		}
+		return nil, nil, err
+	}
+
+	req := createCompletionRequest(c.config, c.session, c.registry, c.skillCatalog, nil)
+	req.Messages = append(req.Messages, userMsg)
+	plan, resp, err := c.agent.WithBudget(budget).Plan(ctx, req, &llm.AgentCallbacks{
+		OnReasoning: func(content string) {
+			if c.events != nil {
+				c.events.Reasoning(content)
+			}
+			if c.statusLine != nil {
+				c.statusLine.UpdateThinkingProgress(len(content))
+			}
+		},
+		OnResponse: func(response *messages.ChatMessage) {
+			if c.events != nil {
+				c.events.Response(response)
+			}
+		},
+		OnError: c.reportError,
+	})
+	if resp != nil {
+		c.recordSpend(resp.Cost)
+	}
+	if c.statusLine != nil {
+		c.statusLine.Clear()
+	}
+	return plan, resp, err
+}
+
+// reviewPlan shows plan and waits for the user to approve, edit or discard
+// it. It returns the plan to run, or errPlanRejected.
+func (c *conversation) reviewPlan(plan *sessions.Plan) (*sessions.Plan, error) {
+	for {
+		fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("Plan:"))
+		for i, step := range plan.Steps {
+			fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, step.Description)
+		}
+
+		switch promptPlanReview() {
+		case 'y':
+			return plan, nil
+		case 'e':
+			edited, err := editPlan(plan)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", errorStyle.Styled("Error: "+err.Error()))
+				continue
+			}
+			if edited == nil {
+				return nil, errPlanRejected
+			}
+			plan = edited
+		default:
+			return nil, errPlanRejected
+		}
+	}
+}
+
+// editPlan opens the steps of plan in $VISUAL or $EDITOR (default vi) and
+// returns the edited plan, or nil when every step was deleted.
+func editPlan(plan *sessions.Plan) (*sessions.Plan, error) {
+	f, err := os.CreateTemp("", "polly-plan-*.txt")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = fmt.Fprintf(f, "# Edit the steps, one per line. Lines starting with # are ignored.\n# Delete every step to discard the plan.\n%s", llm.FormatPlan(plan))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), f.Name())
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	text, err := os.ReadFile(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	steps := parsePlanText(string(text))
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	edited := &sessions.Plan{Task: plan.Task}
+	for _, step := range steps {
+		edited.Steps = append(edited.Steps, sessions.PlanStep{Description: step, Status: sessions.PlanStepPending})
+	}
+	return edited, nil
+}
+
+// parsePlanText reads the steps of an edited plan, one per line, dropping
+// comments, blank lines and any numbering or bullets.
+func parsePlanText(text string) []string {
+	var steps []string
+	for line := range strings.Lines(text) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if step := stepNumberPattern.ReplaceAllString(line, ""); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// runPlan runs the steps of plan that are not done yet, each as a turn of
+// its own, and saves their progress in the session. A step that stopped
+// early resumes from where it stopped. The response ends with the last
+// step's message and adds up the usage of all the steps run.
+func (c *conversation) runPlan(ctx context.Context, plan *sessions.Plan) (*llm.AgentResponse, error) {
+	var total *llm.AgentResponse
+	for i := plan.NextStep(); i >= 0; i = plan.NextStep() {
+		if i > 0 && c.events == nil && plan.Steps[i-1].Status == sessions.PlanStepDone {
+			fmt.Println()
+		}
+		if !c.config.Quiet && c.events == nil {
+			fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled(fmt.Sprintf("Step %d/%d: %s", i+1, len(plan.Steps), plan.Steps[i].Description)))
+		}
+
+		plan.Steps[i].Status = sessions.PlanStepRunning
+		plan.Steps[i].Result = ""
+		savePlan(c.session, plan)
+
+		var resp *llm.AgentResponse
+		var err error
+		if state := c.session.GetMetadata().RunState; state != nil {
+			resp, err = c.runAgent(ctx, state)
+		} else {
+			c.session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleUser, Content: llm.PlanStepPrompt(plan, i)})
+			if err = c.compactHistory(ctx); err == nil {
+				resp, err = c.runAgent(ctx, nil)
+			}
+		}
+		total = addResponse(total, resp)
+
+		if err != nil {
+			plan.Steps[i].Status = sessions.PlanStepFailed
+			plan.Steps[i].Result = err.Error()
+		} else {
+			plan.Steps[i].Status = sessions.PlanStepDone
+			plan.Steps[i].Result = resp.Message.Content
+		}
+		savePlan(c.session, plan)
+		if c.events != nil {
+			c.events.PlanStep(plan, i)
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// addResponse folds resp, the response of one part of a planned turn, into
+// total. Either may be nil.
+func addResponse(total, resp *llm.AgentResponse) *llm.AgentResponse {
+	if total == nil || resp == nil {
+		if total == nil {
+			return resp
+		}
+		return total
+	}
+	sum := *resp
+	sum.AllMessages = append(slices.Clip(total.AllMessages), resp.AllMessages...)
+	sum.IterationCount += total.IterationCount
+	sum.Cost += total.Cost
+	sum.SubAgents = append(slices.Clip(total.SubAgents), resp.SubAgents...)
+	return &sum
+}
+
+// savePlan records plan and the progress of its steps in the session
+// metadata; nil drops it.
+func savePlan(session sessions.Session, plan *sessions.Plan) {
+	metadata := *session.GetMetadata()
+	if metadata.Plan == nil && plan == nil {
+		return
+	}
+	if plan != nil {
+		// Keep a copy so later progress is saved explicitly
+		saved := *plan
+		saved.Steps = append([]sessions.PlanStep(nil), plan.Steps...)
+		plan = &saved
+	}
+	metadata.Plan = plan
+	session.SetMetadata(&metadata)
+}
+
+// unfinishedPlan returns a copy of the session's plan if it has steps left
+// to run, or nil.
+func unfinishedPlan(session sessions.Session) *sessions.Plan {
+	plan := session.GetMetadata().Plan
+	if plan.NextStep() < 0 {
+		return nil
+	}
+	resumed := *plan
+	resumed.Steps = append([]sessions.PlanStep(nil), plan.Steps...)
+	return &resumed
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+func TestParsePlanText(t *testing.T) {
+	text := "# Edit the steps\n1. read the file\n\n2) fix the bug\n- run the tests\n   write notes  \n3.\n"
+	want := []string{"read the file", "fix the bug", "run the tests", "write notes"}
+	if got := parsePlanText(text); !slices.Equal(got, want) {
+		t.Errorf("parsePlanText() = %q, want %q", got, want)
+	}
+}
+
+func TestRunPlanTracksProgress(t *testing.T) {
+	store := sessions.NewSyncMapSessionStore(&sessions.Metadata{})
+	session, err := store.Get("plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	registry := tools.NewToolRegistry(nil)
+	conv := &conversation{
+		config:      &Config{Quiet: true},
+		session:     session,
+		agent:       llm.NewAgent(&echoLLM{}, registry, llm.AgentConfig{}),
+		registry:    registry,
+		skillResult: &skillCatalogResult{},
+	}
+	plan := &sessions.Plan{Task: "two things", Steps: []sessions.PlanStep{
+		{Description: "first", Status: sessions.PlanStepDone, Result: "earlier"},
+		{Description: "second", Status: sessions.PlanStepFailed},
+		{Description: "third", Status: sessions.PlanStepPending},
+	}}
+	savePlan(session, plan)
+	if !canResume(session) {
+		t.Fatal("canResume() = false with steps left to run")
+	}
+
+	resp, err := conv.resumeTurn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.IterationCount != 2 || !strings.HasPrefix(resp.Message.Content, "echo: Carry out step 3 of 3") {
+		t.Errorf("response = %d iterations ending %q, want the last two steps run", resp.IterationCount, resp.Message.Content)
+	}
+
+	saved := session.GetMetadata().Plan
+	for i, step := range saved.Steps {
+		if step.Status != sessions.PlanStepDone {
+			t.Errorf("step %d status = %s, want done", i+1, step.Status)
+		}
+	}
+	if saved.Steps[0].Result != "earlier" || !strings.Contains(saved.Steps[1].Result, "step 2 of 3 of the plan: second") {
+		t.Errorf("step results = %+v", saved.Steps)
+	}
+	if canResume(session) {
+		t.Error("canResume() = true after the plan finished")
+	}
+}
+
+func TestAbandonRunDropsUnfinishedPlan(t *testing.T) {
+	session, err := sessions.NewSyncMapSessionStore(&sessions.Metadata{}).Get("plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	savePlan(session, &sessions.Plan{Steps: []sessions.PlanStep{{Description: "later", Status: sessions.PlanStepPending}}})
+	abandonRun(session)
+	if session.GetMetadata().Plan != nil {
+		t.Error("unfinished plan kept after a new turn started")
+	}
+
+	done := &sessions.Plan{Steps: []sessions.PlanStep{{Description: "did it", Status: sessions.PlanStepDone}}}
+	savePlan(session, done)
+	abandonRun(session)
+	if session.GetMetadata().Plan == nil {
+		t.Error("finished plan dropped; it should stay for inspection")
+	}
+}
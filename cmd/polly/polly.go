@@ -4,9 +4,11 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
@@ -24,6 +26,9 @@ import (
 func main() {
 	command := getCommand()
 	if err := command.Run(context.Background(), os.Args); err != nil {
+		if errors.Is(err, errInterrupted) {
+			cleanupAndExit(130) // 128 + SIGINT(2) = 130
+		}
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		cleanupAndExit(1)
 	}
@@ -103,6 +108,9 @@ func (r *commandRunner) handleManagementFlags() (bool, error) {
 	if cfg.ListSkills {
 		return true, handleListSkills(cfg)
 	}
+	if cfg.ListPrompts {
+		return true, handleListPrompts(r.ctx, cfg)
+	}
 	if cfg.DeleteContext != "" {
 		return true, handleDeleteContext(store, cfg.DeleteContext)
 	}
@@ -131,8 +139,11 @@ func runCommand(ctx context.Context, cmd *cli.Command) error {
 	return runner.Run()
 }
 
-// initializeSession sets up everything needed for a conversation session
-func initializeSession(config *Config, sessionStore sessions.SessionStore, contextID string, cmd *cli.Command) (string, sessions.Session, *llm.Agent, *tools.ToolRegistry, *skills.Catalog, *tools.SkillRuntime, *skillCatalogResult, error) {
+// initializeSession sets up everything needed for a conversation session.
+// approver, when non-nil, also gates MCP sampling requests under --confirm,
+// policy decides the agent's tool calls, and elicitor, when non-nil,
+// answers MCP servers' requests for user input.
+func initializeSession(config *Config, sessionStore sessions.SessionStore, contextID string, cmd *cli.Command, approver *toolApprover, policy *tools.PermissionPolicy, elicitor *mcpElicitor) (string, sessions.Session, *llm.Agent, *tools.ToolRegistry, *skills.Catalog, *tools.SkillRuntime, *skillCatalogResult, error) {
 	// Initialize conversation using helper function
 	var err error
 	contextID, _, err = initializeConversation(config, sessionStore, contextID, cmd)
@@ -140,11 +151,23 @@ func initializeSession(config *Config, sessionStore sessions.SessionStore, conte
 		return "", nil, nil, nil, nil, nil, nil, err
 	}
 
-	// Load API keys
-	apiKeys := loadAPIKeys()
+	// Load providers (built-ins plus ~/.pollytool/providers.json) and their API keys
+	providers, err := loadProviders()
+	if err != nil {
+		return "", nil, nil, nil, nil, nil, nil, err
+	}
+	apiKeys := loadAPIKeys(providers)
+
+	// Create LLM provider, wrapped with retry and failover when fallbacks are configured
+	var llmClient llm.LLM = llm.NewMultiPassWithProviders(apiKeys, providers)
+	if len(config.FallbackModels) > 0 {
+		llmClient = llm.NewFallbackLLM(llmClient, config.FallbackModels, llm.DefaultRetryPolicy)
+	}
 
-	// Create LLM provider
-	llmClient := llm.NewMultiPass(apiKeys)
+	pricing, err := loadPricing()
+	if err != nil {
+		return "", nil, nil, nil, nil, nil, nil, err
+	}
 
 	// Get or create session early so we can read persisted skill sources.
 	needFileStore := needsFileStore(config, contextID)
@@ -171,6 +194,20 @@ func initializeSession(config *Config, sessionStore sessions.SessionStore, conte
 		session.Close()
 		return "", nil, nil, nil, nil, nil, nil, err
 	}
+	samplingApprover := approver
+	if !config.Confirm {
+		samplingApprover = nil
+	}
+	sampler := newMCPSampler(llmClient, config, pricing, samplingApprover)
+	if sampler != nil {
+		registryOpts = append(registryOpts, tools.WithMCPClientOptions(tools.WithSampling(sampler.handle)))
+	}
+	if elicitor != nil {
+		registryOpts = append(registryOpts, tools.WithMCPClientOptions(tools.WithElicitation(elicitor.handle)))
+	}
+	if socket := daemonSocketPath(); socket != "" {
+		registryOpts = append(registryOpts, tools.WithMCPPool(socket))
+	}
 
 	// Handle command-line tools if provided - they replace session tools
 	var toolRegistry *tools.ToolRegistry
@@ -178,6 +215,7 @@ func initializeSession(config *Config, sessionStore sessions.SessionStore, conte
 	if len(config.Tools) > 0 {
 		// Load command-line tools directly into the registry we'll use
 		toolRegistry = tools.NewToolRegistry(nil, registryOpts...)
+		registerTaskTool(toolRegistry, config)
 		for _, source := range config.Tools {
 			_, err := toolRegistry.LoadToolAuto(source)
 			if err != nil {
@@ -196,6 +234,8 @@ func initializeSession(config *Config, sessionStore sessions.SessionStore, conte
 			return "", nil, nil, nil, nil, nil, nil, err
 		}
 	}
+	toolRegistry.OnToolListChanged(func() { syncActiveTools(session, toolRegistry) })
+
 	skillRuntime, err := newSkillRuntime(skillCatalog, toolRegistry)
 	if err != nil {
 		session.Close()
@@ -220,10 +260,18 @@ func initializeSession(config *Config, sessionStore sessions.SessionStore, conte
 	updateContextInfo(session, config, cmd)
 
 	// Create the agent with the tool registry
-	agent := llm.NewAgent(llmClient, toolRegistry, llm.AgentConfig{
+	agentConfig := llm.AgentConfig{
 		MaxIterations: config.MaxIterations,
 		ToolTimeout:   config.ToolTimeout,
-	})
+		Pricing:       pricing,
+		MaxToolOutput: config.MaxToolOutput,
+		Permissions:   policy,
+		Audit:         newAuditLog(),
+	}
+	if sampler != nil {
+		agentConfig.ExternalCost = sampler.takeCost
+	}
+	agent := llm.NewAgent(llmClient, toolRegistry, agentConfig)
 
 	return contextID, session, agent, toolRegistry, skillCatalog, skillRuntime, skillResult, nil
 }
@@ -244,8 +292,23 @@ func sandboxRegistryOptions(config *Config) ([]tools.RegistryOption, error) {
 }
 
 func runConversation(ctx context.Context, config *Config, sessionStore sessions.SessionStore, contextID string, cmd *cli.Command) error {
+	// Load the tool permission policy; with --confirm, calls no rule
+	// matches are asked about
+	confirm := config.Confirm && isTerminal()
+	policy, err := loadPermissions(confirm)
+	if err != nil {
+		return err
+	}
+
+	// Set up tool approval if --confirm is active or the policy may ask
+	var approver *toolApprover
+	if confirm || (isTerminal() && !policy.Empty()) {
+		approver = &toolApprover{policy: policy, policyPath: permissionsConfigPath()}
+	}
+	elicitor := newMCPElicitor()
+
 	// Initialize session
-	contextID, session, agent, toolRegistry, skillCatalog, skillRuntime, skillResult, err := initializeSession(config, sessionStore, contextID, cmd)
+	contextID, session, agent, toolRegistry, skillCatalog, skillRuntime, skillResult, err := initializeSession(config, sessionStore, contextID, cmd, approver, policy, elicitor)
 	if err != nil {
 		return err
 	}
@@ -260,14 +323,37 @@ func runConversation(ctx context.Context, config *Config, sessionStore sessions.
 		return err
 	}
 
-	// If no prompt provided and no stdin, return error as interactive mode is disabled
-	if prompt == "" {
-		return fmt.Errorf("no prompt provided. Please provide a prompt via -p flag or stdin")
+	// An MCP prompt template can carry the whole first turn on its own
+	promptMsgs, err := expandMCPPrompt(ctx, toolRegistry, config.MCPPrompt, config.PromptArgs)
+	if err != nil {
+		return err
+	}
+	if prompt == "" && len(promptMsgs) > 0 {
+		if last := promptMsgs[len(promptMsgs)-1]; last.Role == messages.MessageRoleUser && len(last.Parts) == 0 {
+			prompt = last.Content
+			promptMsgs = promptMsgs[:len(promptMsgs)-1]
+		}
 	}
 
-	// Set up signal handling
-	ctx, cancel := setupSignalHandling(ctx)
-	defer cancel()
+	if config.Resume && (prompt != "" || len(promptMsgs) > 0) {
+		return fmt.Errorf("--resume continues the stopped run and does not take a prompt")
+	}
+
+	// No -p flag and no piped input: interactive mode needs a terminal on both ends
+	interactive := prompt == "" && !config.Resume
+	if interactive && (!isTerminal() || !isStdinTerminal()) {
+		return fmt.Errorf("no prompt provided. Please provide a prompt via -p flag or stdin")
+	}
+	if interactive && config.Output == outputJSONL {
+		return fmt.Errorf("--output jsonl requires a prompt via -p flag or stdin")
+	}
+	if config.Plan && config.SchemaPath != "" {
+		return fmt.Errorf("--plan cannot be combined with --schema")
+	}
+	// Reviewing the plan reads the answer from the terminal
+	if config.Plan && !config.Resume && !isStdinTerminal() {
+		return fmt.Errorf("--plan needs a terminal on stdin to review the plan; pass the prompt with -p")
+	}
 
 	// Load schema if specified
 	var schema *llm.Schema
@@ -279,51 +365,196 @@ func runConversation(ctx context.Context, config *Config, sessionStore sessions.
 		}
 	}
 
-	// Build user message with files if provided
-	userMsg, err := buildMessageWithFiles(prompt, config.Files)
-	if err != nil {
-		return fmt.Errorf("error processing files: %w", err)
+	conv := &conversation{
+		config:       config,
+		sessionStore: sessionStore,
+		session:      session,
+		agent:        agent,
+		registry:     toolRegistry,
+		skillCatalog: skillCatalog,
+		skillRuntime: skillRuntime,
+		skillResult:  skillResult,
+		schema:       schema,
+		approver:     approver,
 	}
 
-	// Add user message to session
-	session.AddMessage(userMsg)
+	if config.Output == outputJSONL {
+		conv.events = newEventWriter(os.Stdout)
+	}
+
+	for _, msg := range promptMsgs {
+		session.AddMessage(msg)
+	}
 
 	// Create status line if appropriate
-	var statusLine StatusHandler
 	if status := createStatusLine(config); status != nil {
-		statusLine = status
-		statusLine.Start()
-		defer statusLine.Stop()
+		conv.statusLine = status
+		conv.statusLine.Start()
+		defer conv.statusLine.Stop()
+		if approver != nil {
+			approver.clearStatus = status.Clear
+		}
+		elicitor.clearStatus = status.Clear
+		if toolRegistry != nil {
+			toolRegistry.OnMCPConnectionState(status.ShowMCPConnection)
+		}
+	}
+
+	if interactive {
+		return runInteractive(ctx, conv)
+	}
+
+	// Set up signal handling
+	ctx, cancel := setupSignalHandling(ctx)
+	defer cancel()
+
+	var resp *llm.AgentResponse
+	if config.Resume {
+		resp, err = conv.resumeTurn(ctx)
+	} else {
+		// Build user message with files if provided
+		userMsg, buildErr := buildMessageWithFiles(prompt, config.Files, toolRegistry)
+		if buildErr != nil {
+			return fmt.Errorf("error processing files: %w", buildErr)
+		}
+		if config.Plan {
+			resp, err = conv.planTurn(ctx, userMsg)
+		} else {
+			resp, err = conv.runTurn(ctx, userMsg)
+		}
+	}
+	err = interruptedError(ctx, err)
+	if conv.events != nil {
+		conv.events.Summary(contextID, config.Model, resp, err)
+		return err
+	}
+	if err != nil {
+		if canResume(session) && !config.Quiet {
+			if _, saved := session.(*sessions.FileSession); saved {
+				fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("(continue this run with: polly -c "+session.GetName()+" --resume)"))
+			}
+		}
+		return err
+	}
+
+	conv.finishTurn(resp)
+	return nil
+}
+
+// conversation bundles everything initialized for a chat run so that the
+// single-shot and interactive modes share the same per-turn logic.
+type conversation struct {
+	config       *Config
+	sessionStore sessions.SessionStore
+	session      sessions.Session
+	agent        *llm.Agent
+	registry     *tools.ToolRegistry
+	skillCatalog *skills.Catalog
+	skillRuntime *tools.SkillRuntime
+	skillResult  *skillCatalogResult
+	schema       *llm.Schema
+	statusLine   StatusHandler
+	approver     *toolApprover
+	events       *eventWriter // Set in jsonl output mode, replaces text output
+
+	errorReported bool // reportError showed the error of the current turn
+}
+
+// reportError shows an error the agent hit during a turn
+func (c *conversation) reportError(err error) {
+	c.errorReported = true
+	if c.events != nil {
+		c.events.Error(err)
+		return
+	}
+	if c.statusLine != nil {
+		c.statusLine.Clear()
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+}
+
+// contextName returns the name of the conversation's context, or "" when
+// it runs in a throwaway in-memory session.
+func (c *conversation) contextName() string {
+	if _, ok := c.session.(*sessions.FileSession); !ok {
+		return ""
+	}
+	return c.session.GetName()
+}
+
+// runTurn adds userMsg to the session, runs the agent loop, and persists every
+// generated message (even on error) plus the active skill state.
+func (c *conversation) runTurn(ctx context.Context, userMsg messages.ChatMessage) (*llm.AgentResponse, error) {
+	// A new turn drops any stopped run rather than resuming it
+	abandonRun(c.session)
+
+	// Add user message to session
+	c.session.AddMessage(userMsg)
+	if err := c.compactHistory(ctx); err != nil {
+		return nil, err
+	}
+	return c.runAgent(ctx, nil)
+}
+
+// resumeTurn continues the run saved when the context's last turn stopped
+// early, persisting its messages like runTurn. A planned turn carries on
+// with its remaining steps.
+func (c *conversation) resumeTurn(ctx context.Context) (*llm.AgentResponse, error) {
+	if plan := unfinishedPlan(c.session); plan != nil {
+		return c.runPlan(ctx, plan)
+	}
+	state := c.session.GetMetadata().RunState
+	if state == nil {
+		return nil, fmt.Errorf("context %s has no stopped run to resume", c.session.GetName())
 	}
+	return c.runAgent(ctx, state)
+}
+
+// runAgent runs the agent loop over the session history, resuming state
+// when it is set.
+func (c *conversation) runAgent(ctx context.Context, state *sessions.RunState) (*llm.AgentResponse, error) {
+	config := c.config
+	statusLine := c.statusLine
+	approver := c.approver
+	events := c.events
 
 	// Show initial spinner
 	if statusLine != nil {
 		statusLine.ShowSpinner("waiting")
 	}
 
+	budget, err := c.turnBudget()
+	if err != nil {
+		if statusLine != nil {
+			statusLine.Clear()
+		}
+		return nil, err
+	}
+
 	// Create completion request
-	req := createCompletionRequest(config, session, toolRegistry, skillCatalog, schema)
+	req := createCompletionRequest(config, c.session, c.registry, c.skillCatalog, c.schema)
 
 	// Track whether we need a newline before the next content block
 	// (i.e., tool calls happened since the last content output)
 	needsNewline := false
 	contentPrinted := false
 
-	// Set up tool approval if --confirm is active
-	var approver *toolApprover
-	if config.Confirm && isTerminal() {
-		approver = &toolApprover{}
-	}
-
 	// Run completion using the agent
-	resp, err := agent.Run(ctx, req, &llm.AgentCallbacks{
+	callbacks := &llm.AgentCallbacks{
 		OnReasoning: func(content string) {
+			if events != nil {
+				events.Reasoning(content)
+			}
 			if statusLine != nil {
 				// Track total reasoning length for status
 				statusLine.UpdateThinkingProgress(len(content))
 			}
 		},
 		OnContent: func(content string) {
+			if events != nil {
+				events.Content(content)
+				return
+			}
 			if statusLine != nil {
 				statusLine.ClearForContent()
 			}
@@ -338,6 +569,9 @@ func runConversation(ctx context.Context, config *Config, sessionStore sessions.
 			}
 		},
 		OnToolStart: func(calls []messages.ChatMessageToolCall) {
+			if events != nil {
+				events.ToolCalls(calls)
+			}
 			needsNewline = true
 			if toolDisplayEnabled(config) {
 				if contentPrinted {
@@ -345,7 +579,7 @@ func runConversation(ctx context.Context, config *Config, sessionStore sessions.
 					contentPrinted = false
 				}
 				for _, tc := range calls {
-					printToolStart(tc)
+					printToolStart(tc, 0)
 				}
 			}
 			if statusLine != nil && len(calls) > 0 && approver == nil {
@@ -363,49 +597,228 @@ func runConversation(ctx context.Context, config *Config, sessionStore sessions.
 			}
 			return nil
 		}(),
+		OnToolProgress: func(tc messages.ChatMessageToolCall, progress tools.ToolProgress) {
+			if statusLine != nil {
+				statusLine.ShowToolProgress(tc.Name, progress)
+			}
+		},
 		OnToolEnd: func(tc messages.ChatMessageToolCall, result string, duration time.Duration, err error) {
+			if events != nil {
+				events.ToolResult(tc, result, duration, err)
+			}
 			if statusLine != nil {
 				statusLine.Clear()
 			}
 			if toolDisplayEnabled(config) {
-				printToolEnd(tc, duration, err)
+				printToolEnd(tc, duration, err, 0)
 			}
 		},
-		OnError: func(err error) {
-			if statusLine != nil {
-				statusLine.Clear()
+		OnResponse: func(response *messages.ChatMessage) {
+			if events != nil {
+				events.Response(response)
 			}
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		},
-	})
+		OnError: c.reportError,
+		// Sub-agents only show their tool calls; their text goes back to
+		// the model as the task result
+		OnSubAgentEvent: func(event llm.SubAgentEvent) {
+			if events != nil {
+				events.SubAgent(event)
+			}
+			switch event.Type {
+			case llm.SubAgentToolStart:
+				if statusLine != nil {
+					statusLine.Clear()
+				}
+				if toolDisplayEnabled(config) {
+					for _, tc := range event.Calls {
+						printToolStart(tc, event.Depth)
+					}
+				}
+				if statusLine != nil && approver == nil {
+					statusLine.ShowToolCall(event.Calls[0].Name)
+				}
+			case llm.SubAgentToolEnd:
+				if statusLine != nil {
+					statusLine.Clear()
+				}
+				if toolDisplayEnabled(config) {
+					printToolEnd(event.Calls[0], event.Duration, event.Err, event.Depth)
+				}
+				if statusLine != nil && approver == nil {
+					statusLine.ShowToolCall(llm.TaskToolName)
+				}
+			}
+		},
+	}
+	agent := c.agent.WithBudget(budget).WithContextName(c.contextName())
+	var resp *llm.AgentResponse
+	if state != nil {
+		resp, err = agent.Resume(ctx, req, state, callbacks)
+	} else {
+		resp, err = agent.Run(ctx, req, callbacks)
+	}
 
 	// Add all generated messages to session, even if there was an error
 	if resp != nil {
 		for _, msg := range resp.AllMessages {
-			session.AddMessage(msg)
+			c.session.AddMessage(msg)
 		}
+		c.recordSpend(resp.Cost)
+		saveRunState(c.session, resp.State)
+		recordSubAgents(c.session, resp.SubAgents)
+	}
+	if err := persistActiveSkills(c.session, c.skillRuntime, c.skillResult.sources); err != nil {
+		return resp, fmt.Errorf("failed to persist active skills: %w", err)
+	}
+
+	return resp, err
+}
+
+// saveRunState records where a stopped run can resume from, or clears the
+// record once a run finishes.
+func saveRunState(session sessions.Session, state *sessions.RunState) {
+	metadata := *session.GetMetadata()
+	if metadata.RunState == nil && state == nil {
+		return
+	}
+	metadata.RunState = state
+	session.SetMetadata(&metadata)
+}
+
+// recordSubAgents keeps the transcripts of sub-agents started by the task
+// tool in the session metadata for inspection.
+func recordSubAgents(session sessions.Session, runs []sessions.SubAgentRun) {
+	if len(runs) == 0 {
+		return
+	}
+	metadata := *session.GetMetadata()
+	metadata.SubAgents = append(slices.Clip(metadata.SubAgents), runs...)
+	session.SetMetadata(&metadata)
+}
+
+// abandonRun closes out a stopped run that will not be resumed, answering
+// its unrun tool calls so the history stays valid for the next request. An
+// unfinished plan is dropped with it.
+func abandonRun(session sessions.Session) {
+	if unfinishedPlan(session) != nil {
+		savePlan(session, nil)
+	}
+	state := session.GetMetadata().RunState
+	if state == nil {
+		return
+	}
+	for _, msg := range state.AbandonPending() {
+		session.AddMessage(msg)
 	}
-	if err := persistActiveSkills(session, skillRuntime, skillResult.sources); err != nil {
-		return fmt.Errorf("failed to persist active skills: %w", err)
+	saveRunState(session, nil)
+}
+
+// canResume reports whether the session has a stopped run or an unfinished
+// plan for --resume to continue.
+func canResume(session sessions.Session) bool {
+	return session.GetMetadata().RunState != nil || unfinishedPlan(session) != nil
+}
+
+// turnBudget returns the spend allowed for the next turn: the request
+// budget, capped by what is left of the context budget.
+func (c *conversation) turnBudget() (float64, error) {
+	return sessionTurnBudget(c.session, c.config.RequestBudget, c.config.Settings.Budget)
+}
+
+// recordSpend adds the cost of a run to the context's running total.
+func (c *conversation) recordSpend(cost float64) {
+	recordSessionSpend(c.session, cost)
+}
+
+// sessionTurnBudget returns the spend allowed for the next turn in session:
+// budget, capped by what is left of the context's limit (0 = unlimited).
+// Once the limit is spent it fails with llm.ErrBudgetExceeded.
+func sessionTurnBudget(session sessions.Session, budget, limit float64) (float64, error) {
+	if limit <= 0 {
+		return budget, nil
+	}
+
+	spent := session.GetMetadata().TotalCost
+	remaining := limit - spent
+	if remaining <= 0 {
+		return 0, fmt.Errorf("%w: context has spent $%.4f of its $%.2f budget (raise it with --context-budget)", llm.ErrBudgetExceeded, spent, limit)
+	}
+	if budget == 0 || remaining < budget {
+		budget = remaining
 	}
+	return budget, nil
+}
 
+// recordSessionSpend adds the cost of a run to the context's running total.
+func recordSessionSpend(session sessions.Session, cost float64) {
+	if cost <= 0 {
+		return
+	}
+	total := session.GetMetadata().TotalCost + cost
+	if err := session.UpdateMetadata(&sessions.Metadata{TotalCost: total}); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record spend: %v\n", err)
+	}
+}
+
+// compactSession folds the oldest turns of session into a rolling summary
+// written by model, when the context uses summarize compaction. Contexts
+// that summarize are not trimmed, so every path that adds a turn to one
+// must call this before running the agent.
+func compactSession(ctx context.Context, session sessions.Session, client llm.LLM, model string) error {
+	compactor, ok := session.(sessions.Compactor)
+	if !ok || !sessions.UsesSummaryCompaction(session.GetMetadata()) {
+		return nil
+	}
+	return compactor.Compact(ctx, func(ctx context.Context, previous string, dropped []messages.ChatMessage) (string, error) {
+		return llm.SummarizeConversation(ctx, client, model, previous, dropped)
+	})
+}
+
+// compactHistory folds old turns into a rolling summary before the request
+// is built, when the context uses summarize compaction. Only a turn too
+// large for the history limit fails; other compaction errors are warnings.
+func (c *conversation) compactHistory(ctx context.Context) error {
+	if _, ok := c.session.(sessions.Compactor); !ok || !sessions.UsesSummaryCompaction(c.session.GetMetadata()) {
+		return nil
+	}
+
+	if c.statusLine != nil {
+		c.statusLine.ShowSpinner("compacting")
+	}
+	err := compactSession(ctx, c.session, c.agent.Client(), c.config.Model)
 	if err != nil {
-		return err
+		if c.statusLine != nil {
+			c.statusLine.Clear()
+		}
+		if errors.Is(err, sessions.ErrTurnTooLarge) {
+			return fmt.Errorf("%w (raise --maxcontext or shorten the message)", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 	}
+	return nil
+}
 
+// finishTurn prints the truncation warning and the final output for a completed turn.
+func (c *conversation) finishTurn(resp *llm.AgentResponse) {
 	// Warn if response was truncated due to token limit
 	if resp.Message != nil && resp.Message.StopReason == messages.StopReasonMaxTokens {
-		fmt.Fprintf(os.Stderr, "\nWarning: response truncated (hit %d token limit, use --maxtokens to increase)\n", config.MaxTokens)
+		fmt.Fprintf(os.Stderr, "\nWarning: response truncated (hit %d token limit, use --maxtokens to increase)\n", c.config.MaxTokens)
 	}
 
 	// Output final result
-	if config.SchemaPath != "" {
-		outputStructured(resp.Message.Content, schema)
+	if c.config.SchemaPath != "" {
+		outputStructured(resp.Message.Content, c.schema)
 	} else {
 		fmt.Println() // Final newline
 	}
 
-	return nil
+	// Note when a fallback model answered instead of the configured one
+	if resp.Message != nil && !c.config.Quiet {
+		if model := resp.Message.GetModel(); model != "" && model != c.config.Model {
+			fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("(answered by fallback model "+model+")"))
+		}
+	}
 }
 
 func getPrompt(config *Config) (string, error) {
@@ -472,6 +885,9 @@ func initializeConversation(config *Config, sessionStore sessions.SessionStore,
 			if !cmd.IsSet("maxcontext") && contextInfo.MaxHistoryTokens != 0 {
 				config.Settings.MaxHistoryTokens = contextInfo.MaxHistoryTokens
 			}
+			if !cmd.IsSet("compact") && contextInfo.Compaction != "" {
+				config.Settings.Compaction = contextInfo.Compaction
+			}
 			// Only use stored system prompt if flag wasn't explicitly set
 			if !cmd.IsSet("system") && contextInfo.SystemPrompt != "" {
 				config.Settings.SystemPrompt = contextInfo.SystemPrompt
@@ -491,6 +907,9 @@ func initializeConversation(config *Config, sessionStore sessions.SessionStore,
 			if !cmd.IsSet("skilldir") && len(contextInfo.SkillDirs) > 0 {
 				config.Settings.SkillDirs = contextInfo.SkillDirs
 			}
+			if !cmd.IsSet("context-budget") && contextInfo.Budget > 0 {
+				config.Settings.Budget = contextInfo.Budget
+			}
 		}
 	}
 
@@ -534,6 +953,12 @@ func updateContextInfo(session sessions.Session, config *Config, cmd *cli.Comman
 	if cmd.IsSet("maxcontext") {
 		update.MaxHistoryTokens = config.Settings.MaxHistoryTokens
 	}
+	if cmd.IsSet("compact") {
+		update.Compaction = config.Settings.Compaction
+	}
+	if cmd.IsSet("context-budget") {
+		update.Budget = config.Settings.Budget
+	}
 	if cmd.IsSet("system") {
 		update.SystemPrompt = config.Settings.SystemPrompt
 	}
@@ -570,17 +995,41 @@ func hasStdinData() bool {
 	return (stat.Mode() & os.ModeCharDevice) == 0
 }
 
+// errInterrupted is the cause of a context cancelled by Ctrl-C or SIGTERM.
+// main exits with 130 for it rather than reporting an error.
+var errInterrupted = errors.New("interrupted")
+
 // setupSignalHandling sets up signal handling for graceful shutdown
 func setupSignalHandling(ctx context.Context) (context.Context, context.CancelFunc) {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-sigChan
-		// Cleanup before canceling context
-		cleanupAndExit(130) // 128 + SIGINT(2) = 130
+		if _, ok := <-sigChan; !ok {
+			return
+		}
+		// Cancel so in-flight MCP tool calls tell their servers and the turn
+		// unwinds, saving its run for --resume; main exits with 130 once it
+		// returns. A second Ctrl-C exits at once without waiting for the save
+		cancel(errInterrupted)
+		if _, ok := <-sigChan; ok {
+			cleanupAndExit(130) // 128 + SIGINT(2) = 130
+		}
 	}()
-	return ctx, cancel
+	return ctx, func() {
+		signal.Stop(sigChan)
+		close(sigChan)
+		cancel(nil)
+	}
+}
+
+// interruptedError returns errInterrupted for a turn that stopped because
+// of a signal, whatever error the cancellation surfaced as where it stopped
+func interruptedError(ctx context.Context, err error) error {
+	if err != nil && errors.Is(context.Cause(ctx), errInterrupted) {
+		return errInterrupted
+	}
+	return err
 }
 
 // outputStructured formats and outputs structured response
@@ -607,7 +1056,7 @@ func outputStructured(content string, schema *llm.Schema) {
 func createStatusLine(config *Config) *Status {
 	// Use terminal title for status updates when in a terminal
 	// Status line works fine with schema since it outputs to stderr
-	if !config.Quiet && isTerminal() {
+	if !config.Quiet && config.Output != outputJSONL && isTerminal() {
 		return NewStatus()
 	}
 
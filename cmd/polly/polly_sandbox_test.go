@@ -21,7 +21,7 @@ func TestInitializeSessionFailsWhenSandboxRequestedButUnavailable(t *testing.T)
 	store := sessions.NewSyncMapSessionStore(nil)
 	_, session, _, registry, _, _, _, err := initializeSession(&Config{
 		NoSkills: true,
-	}, store, "", getCommand())
+	}, store, "", getCommand(), nil, nil, nil)
 	if err == nil {
 		t.Fatal("initializeSession() error = nil, want sandbox startup failure")
 	}
@@ -51,7 +51,7 @@ func TestInitializeSessionSucceedsWithoutSandboxWhenBackendUnavailable(t *testin
 	_, session, agent, registry, _, _, _, err := initializeSession(&Config{
 		NoSandbox: true,
 		NoSkills:  true,
-	}, store, "", getCommand())
+	}, store, "", getCommand(), nil, nil, nil)
 	if err != nil {
 		t.Fatalf("initializeSession() error = %v", err)
 	}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// parsePromptArgs turns key=value pairs into prompt arguments
+func parsePromptArgs(pairs []string) (map[string]string, error) {
+	args := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --arg %q (expected key=value)", pair)
+		}
+		args[key] = value
+	}
+	return args, nil
+}
+
+// expandMCPPrompt fetches the "server/name" prompt from a loaded MCP server
+// and returns its messages. An empty spec expands to nothing.
+func expandMCPPrompt(ctx context.Context, registry *tools.ToolRegistry, spec string, pairs []string) ([]messages.ChatMessage, error) {
+	if spec == "" {
+		if len(pairs) > 0 {
+			return nil, fmt.Errorf("--arg requires --mcp-prompt")
+		}
+		return nil, nil
+	}
+
+	server, name, ok := strings.Cut(spec, "/")
+	if !ok || server == "" || name == "" {
+		return nil, fmt.Errorf("invalid --mcp-prompt %q (expected server/name)", spec)
+	}
+	args, err := parsePromptArgs(pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	var client *tools.MCPClient
+	if registry != nil {
+		client, _ = registry.MCPServerClient(server)
+	}
+	if client == nil || !client.HasPrompts() {
+		return nil, fmt.Errorf("no loaded MCP server %q publishes prompts (load it with --tool)", server)
+	}
+
+	result, err := client.GetPrompt(ctx, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]messages.ChatMessage, 0, len(result.Messages))
+	for _, pm := range result.Messages {
+		msg, err := promptMessage(pm)
+		if err != nil {
+			return nil, fmt.Errorf("prompt %s: %w", spec, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// promptMessage converts an MCP prompt message to a chat message
+func promptMessage(pm *mcp.PromptMessage) (messages.ChatMessage, error) {
+	msg := messages.ChatMessage{Role: string(pm.Role)}
+	switch msg.Role {
+	case messages.MessageRoleUser, messages.MessageRoleAssistant:
+	default:
+		return msg, fmt.Errorf("unsupported message role %q", pm.Role)
+	}
+
+	switch content := pm.Content.(type) {
+	case *mcp.TextContent:
+		msg.Content = content.Text
+	case *mcp.ImageContent:
+		msg.Parts = []messages.ContentPart{{
+			Type:      "image_base64",
+			ImageData: base64.StdEncoding.EncodeToString(content.Data),
+			MimeType:  content.MIMEType,
+		}}
+	case *mcp.EmbeddedResource:
+		resource := content.Resource
+		if resource == nil || resource.Blob != nil {
+			return msg, fmt.Errorf("embedded binary resources are not supported")
+		}
+		msg.Content = fmt.Sprintf("=== %s ===\n%s", resource.URI, resource.Text)
+	default:
+		return msg, fmt.Errorf("unsupported content type %T", pm.Content)
+	}
+	return msg, nil
+}
+
+// handleListPrompts prints the prompts of every MCP server given with --tool
+func handleListPrompts(ctx context.Context, config *Config) error {
+	registryOpts, err := sandboxRegistryOptions(config)
+	if err != nil {
+		return err
+	}
+	registry := tools.NewToolRegistry(nil, registryOpts...)
+	defer registry.Close()
+
+	for _, source := range config.Tools {
+		if _, err := registry.LoadToolAuto(source); err != nil {
+			return fmt.Errorf("failed to load tool %s: %w", source, err)
+		}
+	}
+
+	found := false
+	for _, server := range registry.MCPServerNamespaces() {
+		client, _ := registry.MCPServerClient(server)
+		if !client.HasPrompts() {
+			continue
+		}
+		prompts, err := client.ListPrompts(ctx)
+		if err != nil {
+			return fmt.Errorf("server %s: %w", server, err)
+		}
+		for _, prompt := range prompts {
+			found = true
+			fmt.Println(formatPrompt(server, prompt))
+		}
+	}
+	if !found {
+		fmt.Println("No prompts found")
+	}
+	return nil
+}
+
+// formatPrompt renders a prompt as "server/name - description (args: a*, b)",
+// where * marks required arguments
+func formatPrompt(server string, prompt *mcp.Prompt) string {
+	line := server + "/" + prompt.Name
+	if prompt.Description != "" {
+		line += " - " + prompt.Description
+	}
+	if len(prompt.Arguments) > 0 {
+		var args []string
+		for _, arg := range prompt.Arguments {
+			name := arg.Name
+			if arg.Required {
+				name += "*"
+			}
+			args = append(args, name)
+		}
+		line += " (args: " + strings.Join(args, ", ") + ")"
+	}
+	return line
+}
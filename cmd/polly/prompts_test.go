@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestParsePromptArgs(t *testing.T) {
+	args, err := parsePromptArgs([]string{"focus=errors", "query=a=b", "empty="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args["focus"] != "errors" || args["query"] != "a=b" || args["empty"] != "" {
+		t.Fatalf("args = %v", args)
+	}
+	for _, bad := range []string{"novalue", "=x"} {
+		if _, err := parsePromptArgs([]string{bad}); err == nil {
+			t.Errorf("parsePromptArgs(%q) should fail", bad)
+		}
+	}
+}
+
+func TestExpandMCPPromptErrors(t *testing.T) {
+	ctx := context.Background()
+	registry := tools.NewToolRegistry(nil)
+
+	if msgs, err := expandMCPPrompt(ctx, registry, "", nil); err != nil || msgs != nil {
+		t.Fatalf("empty spec = %v, %v", msgs, err)
+	}
+	tests := []struct {
+		spec  string
+		args  []string
+		error string
+	}{
+		{"", []string{"a=b"}, "--arg requires --mcp-prompt"},
+		{"review", nil, "expected server/name"},
+		{"library/review", []string{"bad"}, "expected key=value"},
+		{"library/review", nil, `no loaded MCP server "library"`},
+	}
+	for _, tt := range tests {
+		_, err := expandMCPPrompt(ctx, registry, tt.spec, tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("expandMCPPrompt(%q, %v) = %v, want %q", tt.spec, tt.args, err, tt.error)
+		}
+	}
+}
+
+func TestPromptMessage(t *testing.T) {
+	msg, err := promptMessage(&mcp.PromptMessage{Role: "assistant", Content: &mcp.TextContent{Text: "ok"}})
+	if err != nil || msg.Role != messages.MessageRoleAssistant || msg.Content != "ok" {
+		t.Fatalf("text = %+v, %v", msg, err)
+	}
+
+	msg, err = promptMessage(&mcp.PromptMessage{Role: "user", Content: &mcp.ImageContent{Data: []byte{1}, MIMEType: "image/png"}})
+	if err != nil || len(msg.Parts) != 1 || msg.Parts[0].ImageData != "AQ==" || msg.Parts[0].MimeType != "image/png" {
+		t.Fatalf("image = %+v, %v", msg, err)
+	}
+
+	msg, err = promptMessage(&mcp.PromptMessage{Role: "user", Content: &mcp.EmbeddedResource{
+		Resource: &mcp.ResourceContents{URI: "docs://readme", Text: "hello"},
+	}})
+	if err != nil || msg.Content != "=== docs://readme ===\nhello" {
+		t.Fatalf("resource = %+v, %v", msg, err)
+	}
+
+	if _, err := promptMessage(&mcp.PromptMessage{Role: "system", Content: &mcp.TextContent{Text: "x"}}); err == nil {
+		t.Fatal("system role should be rejected")
+	}
+}
+
+func TestFormatPrompt(t *testing.T) {
+	got := formatPrompt("library", &mcp.Prompt{
+		Name:        "review",
+		Description: "Review a change",
+		Arguments:   []*mcp.PromptArgument{{Name: "focus", Required: true}, {Name: "tone"}},
+	})
+	if want := "library/review - Review a change (args: focus*, tone)"; got != want {
+		t.Fatalf("formatPrompt() = %q, want %q", got, want)
+	}
+}
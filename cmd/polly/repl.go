@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"golang.org/x/term"
+)
+
+// maxHistoryEntries bounds the persisted REPL line history.
+const maxHistoryEntries = 1000
+
+// errQuit signals that the user asked to leave the interactive session.
+var errQuit = errors.New("quit")
+
+// replCommands lists the slash commands understood by the interactive mode.
+var replCommands = []struct {
+	name  string
+	usage string
+}{
+	{"/model", "/model [provider/model]   show or switch the model"},
+	{"/tools", "/tools                    list available tools"},
+	{"/skills", "/skills [name]            list skills, or activate one"},
+	{"/reset", "/reset                    clear the conversation history"},
+	{"/resume", "/resume                   continue the last turn if it stopped early"},
+	{"/save", "/save <name>              save the conversation to a named context"},
+	{"/help", "/help                     show this help"},
+	{"/exit", "/exit                     leave polly (also Ctrl-D)"},
+}
+
+// runInteractive runs a multi-turn read-eval-print loop on the terminal.
+// Every turn goes through conversation.runTurn, so messages are persisted to
+// the active context exactly as in single-shot mode.
+func runInteractive(ctx context.Context, conv *conversation) error {
+	initColors()
+
+	editor, err := newLineEditor(conv.config)
+	if err != nil {
+		return err
+	}
+
+	original := conv.session
+	defer func() {
+		if conv.session != original {
+			conv.session.Close()
+		}
+	}()
+
+	fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled(fmt.Sprintf("polly %s (%s) — /help for commands, Ctrl-D to exit", conv.config.Model, conv.session.GetName())))
+
+	files := conv.config.Files
+	for {
+		line, err := editor.readInput()
+		if err == io.EOF {
+			fmt.Fprintln(os.Stderr)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		input := strings.TrimSpace(line)
+		if input == "" {
+			continue
+		}
+
+		// /resume runs a turn, so it goes through the turn's Ctrl-C handling
+		if input == "/resume" {
+			if !canResume(conv.session) {
+				fmt.Fprintf(os.Stderr, "%s\n", errorStyle.Styled("Error: no stopped run to resume"))
+				continue
+			}
+			conv.runInteractiveTurn(ctx, conv.resumeTurn)
+			continue
+		}
+
+		if strings.HasPrefix(input, "/") {
+			if err := conv.handleSlashCommand(input); err != nil {
+				if err == errQuit {
+					return nil
+				}
+				fmt.Fprintf(os.Stderr, "%s\n", errorStyle.Styled("Error: "+err.Error()))
+			}
+			continue
+		}
+
+		// Files from -f are attached to the first turn only
+		userMsg, err := buildMessageWithFiles(line, files, conv.registry)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", errorStyle.Styled(fmt.Sprintf("Error processing files: %v", err)))
+			continue
+		}
+		files = nil
+
+		conv.runInteractiveTurn(ctx, func(ctx context.Context) (*llm.AgentResponse, error) {
+			if conv.config.Plan {
+				return conv.planTurn(ctx, userMsg)
+			}
+			return conv.runTurn(ctx, userMsg)
+		})
+	}
+}
+
+// runInteractiveTurn runs one turn, cancelling it (but not the REPL) on Ctrl-C.
+func (c *conversation) runInteractiveTurn(ctx context.Context, turn func(context.Context) (*llm.AgentResponse, error)) {
+	turnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt)
+	defer signal.Stop(sigChan)
+	go func() {
+		select {
+		case <-sigChan:
+			cancel()
+		case <-turnCtx.Done():
+		}
+	}()
+
+	c.errorReported = false
+	resp, err := turn(turnCtx)
+	if c.statusLine != nil {
+		c.statusLine.Clear()
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintf(os.Stderr, "\n%s\n", dimStyle.Styled("(interrupted)"))
+	case errors.Is(err, errPlanRejected):
+		fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("(plan discarded)"))
+	case err != nil:
+		// Errors from before the agent ran, e.g. an exhausted budget, were
+		// not reported through OnError
+		if !c.errorReported {
+			fmt.Fprintf(os.Stderr, "%s\n", errorStyle.Styled("Error: "+err.Error()))
+		}
+	default:
+		c.finishTurn(resp)
+	}
+	if err != nil && canResume(c.session) {
+		fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("(/resume continues this run)"))
+	}
+}
+
+// handleSlashCommand executes a REPL slash command. It returns errQuit when
+// the user asked to leave.
+func (c *conversation) handleSlashCommand(input string) error {
+	fields := strings.Fields(input)
+	name, args := fields[0], fields[1:]
+
+	switch name {
+	case "/exit", "/quit":
+		return errQuit
+	case "/help":
+		for _, cmd := range replCommands {
+			fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+		}
+		return nil
+	case "/model":
+		return c.switchModel(args)
+	case "/tools":
+		c.listTools()
+		return nil
+	case "/skills":
+		return c.skills(args)
+	case "/reset":
+		c.session.Clear()
+		saveRunState(c.session, nil)
+		savePlan(c.session, nil)
+		fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("Conversation cleared"))
+		return nil
+	case "/save":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: /save <name>")
+		}
+		return c.saveAs(args[0])
+	default:
+		return fmt.Errorf("unknown command %s (try /help)", name)
+	}
+}
+
+// switchModel shows the current model or switches to a new one for future turns.
+func (c *conversation) switchModel(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "  %s\n", c.config.Model)
+		return nil
+	}
+	model := args[0]
+	if err := validateModel(model); err != nil {
+		return err
+	}
+	c.config.Model = model
+	if err := c.session.UpdateMetadata(&sessions.Metadata{Model: model}); err != nil {
+		return fmt.Errorf("failed to persist model: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("Model set to "+model))
+	return nil
+}
+
+// listTools prints the tools currently exposed to the model.
+func (c *conversation) listTools() {
+	if c.registry == nil {
+		fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled("No tools loaded"))
+		return
+	}
+	all := c.registry.All()
+	if len(all) == 0 {
+		fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled("No tools loaded"))
+		return
+	}
+	lines := make([]string, 0, len(all))
+	for _, tool := range all {
+		lines = append(lines, fmt.Sprintf("%s [%s]", tool.GetName(), tool.GetType()))
+	}
+	slices.Sort(lines)
+	for _, line := range lines {
+		fmt.Fprintf(os.Stderr, "  %s\n", line)
+	}
+}
+
+// skills lists discovered skills, or activates the named skill.
+func (c *conversation) skills(args []string) error {
+	if c.skillCatalog == nil || c.skillCatalog.IsEmpty() {
+		fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled("No skills found"))
+		return nil
+	}
+
+	if len(args) > 0 {
+		if _, err := c.skillRuntime.Activate(args[0]); err != nil {
+			return err
+		}
+		if err := persistActiveSkills(c.session, c.skillRuntime, c.skillResult.sources); err != nil {
+			return fmt.Errorf("failed to persist active skills: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled("Activated skill "+args[0]))
+		return nil
+	}
+
+	active := c.skillRuntime.ActivatedSkills()
+	for _, skill := range c.skillCatalog.List() {
+		marker := " "
+		if slices.Contains(active, skill.Name) {
+			marker = "*"
+		}
+		fmt.Fprintf(os.Stderr, " %s %s - %s\n", marker, skill.Name, skill.Description)
+	}
+	return nil
+}
+
+// saveAs copies the conversation into a named file-backed context and makes
+// it the active context, so subsequent turns persist there.
+func (c *conversation) saveAs(name string) error {
+	if _, ok := c.sessionStore.(*sessions.FileSessionStore); ok && c.session.GetName() == name {
+		fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled(fmt.Sprintf("Context '%s' is already saved", name)))
+		return nil
+	}
+
+	store := c.sessionStore
+	if _, ok := store.(*sessions.FileSessionStore); !ok {
+		fileStore, err := sessions.NewFileSessionStore("", nil)
+		if err != nil {
+			return fmt.Errorf("failed to open context store: %w", err)
+		}
+		store = fileStore
+	}
+
+	if store.Exists(name) && !promptYesNo(fmt.Sprintf("Context '%s' exists. Overwrite?", name), false) {
+		return nil
+	}
+
+	target, err := store.Get(name)
+	if err != nil {
+		return fmt.Errorf("failed to create context: %w", err)
+	}
+
+	metadata := *c.session.GetMetadata()
+	metadata.Name = name
+	metadata.Created = time.Now()
+	metadata.LastUsed = time.Now()
+	target.SetMetadata(&metadata)
+	target.Clear()
+
+	// Clear re-seeds the system prompt, so skip the copy from the source history
+	history := c.session.GetHistory()
+	if len(history) > 0 && history[0].Role == messages.MessageRoleSystem && metadata.SystemPrompt != "" {
+		history = history[1:]
+	}
+	for _, msg := range history {
+		target.AddMessage(msg)
+	}
+
+	previous := c.session
+	c.session = target
+	c.sessionStore = store
+	if previous != nil {
+		previous.Close()
+	}
+
+	fmt.Fprintf(os.Stderr, "%s\n", dimStyle.Styled(fmt.Sprintf("Saved to context '%s' (resume with: polly -c %s)", name, name)))
+	return nil
+}
+
+// lineEditor reads input lines with editing and persistent history.
+type lineEditor struct {
+	fd       int
+	terminal *term.Terminal
+}
+
+// newLineEditor creates a line editor over stdin/stdout.
+func newLineEditor(config *Config) (*lineEditor, error) {
+	fd := int(os.Stdin.Fd())
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}
+
+	t := term.NewTerminal(rw, userStyle.Styled("> "))
+	if path, err := historyPath(); err == nil {
+		t.History = loadReplHistory(path, maxHistoryEntries)
+	}
+	t.AutoCompleteCallback = completeSlashCommand
+	if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		_ = t.SetSize(width, height)
+	}
+
+	return &lineEditor{fd: fd, terminal: t}, nil
+}
+
+// readInput reads one logical input, joining lines that end in a backslash.
+// The terminal is only in raw mode while a line is being edited, so tool
+// approval prompts and streamed output behave as in single-shot mode.
+func (e *lineEditor) readInput() (string, error) {
+	state, err := term.MakeRaw(e.fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to enter raw mode: %w", err)
+	}
+	defer term.Restore(e.fd, state)
+
+	defer e.terminal.SetPrompt(userStyle.Styled("> "))
+
+	var lines []string
+	for {
+		line, err := e.terminal.ReadLine()
+		if err == term.ErrPasteIndicator {
+			err = nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if strings.HasSuffix(line, "\\") {
+			lines = append(lines, strings.TrimSuffix(line, "\\"))
+			e.terminal.SetPrompt(dimStyle.Styled("… "))
+			continue
+		}
+		lines = append(lines, line)
+		return strings.Join(lines, "\n"), nil
+	}
+}
+
+// completeSlashCommand completes slash command names on Tab.
+func completeSlashCommand(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || !strings.HasPrefix(line, "/") || strings.Contains(line, " ") {
+		return "", 0, false
+	}
+	for _, cmd := range replCommands {
+		if strings.HasPrefix(cmd.name, line) && cmd.name != line {
+			return cmd.name + " ", len(cmd.name) + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+// historyPath returns the location of the persisted REPL history.
+func historyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pollytool", "history"), nil
+}
+
+// replHistory implements term.History, appending each entry to a file so
+// history survives across sessions. Entries are stored one JSON string per
+// line so multi-line inputs round-trip.
+type replHistory struct {
+	path    string
+	max     int
+	entries []string // oldest first
+	lines   int      // entries in the file, which is trimmed to max at 2*max
+}
+
+// loadReplHistory loads up to max entries from path. A missing or unreadable
+// file yields an empty history.
+func loadReplHistory(path string, max int) *replHistory {
+	h := &replHistory{path: path, max: max}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		h.lines++
+		var entry string
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry != "" {
+			h.entries = append(h.entries, entry)
+		}
+	}
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+	}
+	return h
+}
+
+// Add records a new entry, skipping blanks and immediate duplicates.
+func (h *replHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" {
+		return
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return
+	}
+	if h.lines >= 2*h.max {
+		h.rewrite()
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err == nil {
+		h.lines++
+	}
+}
+
+// rewrite replaces the file with the entries held in memory, dropping the
+// older ones appended past the cap
+func (h *replHistory) rewrite() {
+	var buf bytes.Buffer
+	for _, entry := range h.entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		buf.Write(append(data, '\n'))
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		os.Remove(tmp)
+		return
+	}
+	h.lines = len(h.entries)
+}
+
+// Len returns the number of entries.
+func (h *replHistory) Len() int {
+	return len(h.entries)
+}
+
+// At returns an entry where index 0 is the most recent.
+func (h *replHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+)
+
+func TestReplHistoryPersistsAcrossLoads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := loadReplHistory(path, 3)
+	h.Add("first")
+	h.Add("first") // immediate duplicate is dropped
+	h.Add("  ")    // blank is dropped
+	h.Add("multi\nline")
+	h.Add("third")
+	h.Add("fourth")
+
+	if h.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", h.Len())
+	}
+	if got := h.At(0); got != "fourth" {
+		t.Fatalf("At(0) = %q, want most recent entry", got)
+	}
+
+	reloaded := loadReplHistory(path, 3)
+	if reloaded.Len() != 3 {
+		t.Fatalf("reloaded Len() = %d, want 3", reloaded.Len())
+	}
+	if got := reloaded.At(2); got != "multi\nline" {
+		t.Fatalf("reloaded At(2) = %q, want multi-line entry", got)
+	}
+}
+
+func TestCompleteSlashCommand(t *testing.T) {
+	line, pos, ok := completeSlashCommand("/mo", 3, '\t')
+	if !ok || line != "/model " || pos != len("/model ") {
+		t.Fatalf("completeSlashCommand(/mo) = %q, %d, %v", line, pos, ok)
+	}
+	if _, _, ok := completeSlashCommand("hello", 5, '\t'); ok {
+		t.Fatal("completeSlashCommand() should ignore non-command input")
+	}
+	if _, _, ok := completeSlashCommand("/mo", 3, 'x'); ok {
+		t.Fatal("completeSlashCommand() should only complete on Tab")
+	}
+}
+
+func TestHandleSlashCommandModelAndReset(t *testing.T) {
+	store := sessions.NewSyncMapSessionStore(&sessions.Metadata{SystemPrompt: "be terse"})
+	session, err := store.Get("default")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	conv := &conversation{
+		config:       &Config{Settings: Settings{Model: "anthropic/claude-sonnet-4-6"}},
+		sessionStore: store,
+		session:      session,
+	}
+
+	if err := conv.handleSlashCommand("/model nope"); err == nil {
+		t.Fatal("expected invalid model to be rejected")
+	}
+	if err := conv.handleSlashCommand("/model openai/gpt-5.4"); err != nil {
+		t.Fatalf("/model error = %v", err)
+	}
+	if conv.config.Model != "openai/gpt-5.4" {
+		t.Fatalf("config model = %q, want switched model", conv.config.Model)
+	}
+	if got := session.GetMetadata().Model; got != "openai/gpt-5.4" {
+		t.Fatalf("persisted model = %q, want switched model", got)
+	}
+
+	session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleUser, Content: "hi"})
+	if err := conv.handleSlashCommand("/reset"); err != nil {
+		t.Fatalf("/reset error = %v", err)
+	}
+	if history := session.GetHistory(); len(history) != 1 || history[0].Role != messages.MessageRoleSystem {
+		t.Fatalf("history after /reset = %#v, want only system prompt", history)
+	}
+
+	if err := conv.handleSlashCommand("/exit"); err != errQuit {
+		t.Fatalf("/exit error = %v, want errQuit", err)
+	}
+	if err := conv.handleSlashCommand("/bogus"); err == nil {
+		t.Fatal("expected unknown command error")
+	}
+}
+
+func TestHandleSlashCommandSaveSwitchesToFileContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	store := sessions.NewSyncMapSessionStore(&sessions.Metadata{SystemPrompt: "be terse"})
+	session, err := store.Get("default")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleUser, Content: "hello"})
+	session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "hi"})
+
+	conv := &conversation{
+		config:       &Config{},
+		sessionStore: store,
+		session:      session,
+	}
+	if err := conv.handleSlashCommand("/save notes"); err != nil {
+		t.Fatalf("/save error = %v", err)
+	}
+	defer conv.session.Close()
+
+	if _, ok := conv.sessionStore.(*sessions.FileSessionStore); !ok {
+		t.Fatalf("session store = %T, want file store after /save", conv.sessionStore)
+	}
+	if conv.session.GetName() != "notes" {
+		t.Fatalf("active session = %q, want notes", conv.session.GetName())
+	}
+	history := conv.session.GetHistory()
+	if len(history) != 3 {
+		t.Fatalf("saved history length = %d, want 3 (system + 2 turns): %#v", len(history), history)
+	}
+	if history[0].Content != "be terse" || history[1].Content != "hello" || history[2].Content != "hi" {
+		t.Fatalf("saved history = %#v", history)
+	}
+}
+
+func TestReplHistoryTrimsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h := loadReplHistory(path, 3)
+	for i := range 20 {
+		h.Add(fmt.Sprintf("entry %d", i))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > 6 {
+		t.Fatalf("history file has %d lines, want at most twice the cap of 3", lines)
+	}
+
+	reloaded := loadReplHistory(path, 3)
+	if reloaded.Len() != 3 || reloaded.At(0) != "entry 19" || reloaded.At(2) != "entry 17" {
+		t.Fatalf("reloaded history = %q, want the last 3 entries", reloaded.entries)
+	}
+}
+
+func TestInterruptedError(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	failed := errors.New("provider failed")
+	if got := interruptedError(ctx, failed); got != failed {
+		t.Errorf("before a signal = %v, want the error unchanged", got)
+	}
+
+	cancel(errInterrupted)
+	wrapped := fmt.Errorf("agent: %w", context.Canceled)
+	if got := interruptedError(ctx, wrapped); got != errInterrupted {
+		t.Errorf("after a signal = %v, want errInterrupted", got)
+	}
+	if got := interruptedError(ctx, nil); got != nil {
+		t.Errorf("finished turn = %v, want nil", got)
+	}
+}
+
+// TestSetupSignalHandlingWaitsForTurn checks that a first Ctrl-C only
+// cancels the turn, leaving it time to save its run
+func TestSetupSignalHandlingWaitsForTurn(t *testing.T) {
+	ctx, cancel := setupSignalHandling(context.Background())
+	defer cancel()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("SIGINT did not cancel the context")
+	}
+	if !errors.Is(context.Cause(ctx), errInterrupted) {
+		t.Fatalf("cause = %v, want errInterrupted", context.Cause(ctx))
+	}
+	// Still running well after the old one second grace period
+	time.Sleep(1500 * time.Millisecond)
+}
+
+func TestRunInteractiveTurnReportsErrorsOnce(t *testing.T) {
+	store := sessions.NewSyncMapSessionStore(&sessions.Metadata{})
+	session, err := store.Get("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conv := &conversation{config: &Config{}, session: session}
+
+	// An error from before the agent ran is printed by the REPL
+	out := captureStderr(t, func() {
+		conv.runInteractiveTurn(context.Background(), func(context.Context) (*llm.AgentResponse, error) {
+			return nil, errors.New("context budget exhausted")
+		})
+	})
+	if !strings.Contains(out, "context budget exhausted") {
+		t.Fatalf("stderr = %q, want the error", out)
+	}
+
+	// An error the agent reported is not printed again
+	out = captureStderr(t, func() {
+		conv.runInteractiveTurn(context.Background(), func(context.Context) (*llm.AgentResponse, error) {
+			err := errors.New("provider down")
+			conv.reportError(err)
+			return nil, err
+		})
+	})
+	if strings.Count(out, "provider down") != 1 {
+		t.Fatalf("stderr = %q, want the error once", out)
+	}
+}
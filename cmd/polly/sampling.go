@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// mcpSampler answers MCP sampling requests, where a server asks polly to run
+// an LLM completion for it.
+type mcpSampler struct {
+	client    llm.LLM
+	model     string        // Session model, used unless a hint picks an allowed model
+	allowed   []string      // Models servers may pick via hints; empty allows only the session model
+	maxTokens int           // Cap on the tokens a single request may generate
+	timeout   time.Duration // Per-request timeout
+	approver  *toolApprover // Asks the user when --confirm is active
+	pricing   *llm.PricingTable
+
+	mu    sync.Mutex
+	spent float64 // Cost of requests answered since the last takeCost
+}
+
+// newMCPSampler returns nil when sampling is disabled.
+func newMCPSampler(client llm.LLM, config *Config, pricing *llm.PricingTable, approver *toolApprover) *mcpSampler {
+	if config.SamplingMaxTokens <= 0 {
+		return nil
+	}
+	return &mcpSampler{
+		client:    client,
+		model:     config.Model,
+		allowed:   config.SamplingModels,
+		maxTokens: config.SamplingMaxTokens,
+		timeout:   config.Timeout,
+		approver:  approver,
+		pricing:   pricing,
+	}
+}
+
+// takeCost returns the cost of the requests answered since the last call,
+// so the agent running the server's tools can count it as its own spend.
+func (s *mcpSampler) takeCost() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cost := s.spent
+	s.spent = 0
+	return cost
+}
+
+// handle implements tools.SamplingHandler.
+func (s *mcpSampler) handle(ctx context.Context, server string, params *mcp.CreateMessageParams) (*mcp.CreateMessageResult, error) {
+	model := s.pickModel(params.ModelPreferences)
+	maxTokens := int(params.MaxTokens)
+	if maxTokens <= 0 || maxTokens > s.maxTokens {
+		maxTokens = s.maxTokens
+	}
+
+	if s.approver != nil && !s.approver.approveSampling(server, model, maxTokens) {
+		return nil, fmt.Errorf("sampling request from %s was declined", server)
+	}
+
+	msgs, err := samplingMessages(params)
+	if err != nil {
+		return nil, err
+	}
+	req := &llm.CompletionRequest{
+		Model:     model,
+		MaxTokens: maxTokens,
+		Messages:  msgs,
+		Timeout:   s.timeout,
+	}
+	if params.Temperature > 0 {
+		req.Temperature = llm.Float32Ptr(float32(params.Temperature))
+	}
+
+	var reply *messages.ChatMessage
+	for event := range s.client.ChatCompletionStream(ctx, req, &llm.SimpleProcessor{}) {
+		switch event.Type {
+		case messages.EventTypeComplete:
+			reply = event.Message
+		case messages.EventTypeError:
+			return nil, event.Error
+		}
+	}
+	if reply == nil {
+		return nil, fmt.Errorf("no response from %s", model)
+	}
+	// Price the call by the model that answered, which a fallback may change
+	priced := model
+	if answered := reply.GetModel(); answered != "" {
+		priced = answered
+	}
+	if cost, ok := s.pricing.Cost(priced, reply.GetInputTokens(), reply.GetOutputTokens()); ok {
+		s.mu.Lock()
+		s.spent += cost
+		s.mu.Unlock()
+	}
+
+	stopReason := "endTurn"
+	if reply.StopReason == messages.StopReasonMaxTokens {
+		stopReason = "maxTokens"
+	}
+	return &mcp.CreateMessageResult{
+		Content:    &mcp.TextContent{Text: reply.Content},
+		Model:      model,
+		Role:       "assistant",
+		StopReason: stopReason,
+	}, nil
+}
+
+// pickModel returns the first allowed model matching one of the server's
+// hints, falling back to the session model when it is allowed.
+func (s *mcpSampler) pickModel(prefs *mcp.ModelPreferences) string {
+	candidates := s.allowed
+	if len(candidates) == 0 {
+		candidates = []string{s.model}
+	}
+	if prefs != nil {
+		for _, hint := range prefs.Hints {
+			name := strings.ToLower(hint.Name)
+			if name == "" {
+				continue
+			}
+			for _, model := range candidates {
+				if strings.Contains(strings.ToLower(model), name) {
+					return model
+				}
+			}
+		}
+	}
+	if slices.Contains(candidates, s.model) {
+		return s.model
+	}
+	return candidates[0]
+}
+
+// samplingMessages converts a sampling request to chat messages.
+func samplingMessages(params *mcp.CreateMessageParams) ([]messages.ChatMessage, error) {
+	var msgs []messages.ChatMessage
+	if params.SystemPrompt != "" {
+		msgs = append(msgs, messages.ChatMessage{Role: messages.MessageRoleSystem, Content: params.SystemPrompt})
+	}
+	for _, sm := range params.Messages {
+		msg := messages.ChatMessage{Role: string(sm.Role)}
+		switch content := sm.Content.(type) {
+		case *mcp.TextContent:
+			msg.Content = content.Text
+		case *mcp.ImageContent:
+			msg.Parts = []messages.ContentPart{{
+				Type:      "image_base64",
+				ImageData: base64.StdEncoding.EncodeToString(content.Data),
+				MimeType:  content.MIMEType,
+			}}
+		default:
+			return nil, fmt.Errorf("unsupported sampling content type %T", sm.Content)
+		}
+		msgs = append(msgs, msg)
+	}
+	if len(msgs) == 0 || msgs[len(msgs)-1].Role == messages.MessageRoleSystem {
+		return nil, fmt.Errorf("sampling request has no messages")
+	}
+	return msgs, nil
+}
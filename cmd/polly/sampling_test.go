@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestSamplerPickModel(t *testing.T) {
+	hints := func(names ...string) *mcp.ModelPreferences {
+		prefs := &mcp.ModelPreferences{}
+		for _, name := range names {
+			prefs.Hints = append(prefs.Hints, &mcp.ModelHint{Name: name})
+		}
+		return prefs
+	}
+
+	s := &mcpSampler{model: "anthropic/claude-sonnet-4-6"}
+	if got := s.pickModel(hints("gpt")); got != "anthropic/claude-sonnet-4-6" {
+		t.Errorf("without an allowlist, pickModel() = %q", got)
+	}
+
+	s.allowed = []string{"openai/gpt-5.4-mini", "anthropic/claude-haiku-4-5"}
+	tests := []struct {
+		prefs *mcp.ModelPreferences
+		want  string
+	}{
+		{hints("haiku"), "anthropic/claude-haiku-4-5"},
+		{hints("gemini", "GPT"), "openai/gpt-5.4-mini"},
+		{nil, "openai/gpt-5.4-mini"}, // session model is not allowed
+	}
+	for _, tt := range tests {
+		if got := s.pickModel(tt.prefs); got != tt.want {
+			t.Errorf("pickModel(%v) = %q, want %q", tt.prefs, got, tt.want)
+		}
+	}
+}
+
+func TestSamplerHandle(t *testing.T) {
+	client := &echoLLM{}
+	pricing := llm.NewPricingTable(map[string]llm.ModelPrice{"openai/gpt-4o": {Input: 1, Output: 1}})
+	s := newMCPSampler(client, &Config{Settings: Settings{Model: "openai/gpt-4o"}, SamplingMaxTokens: 50}, pricing, nil)
+
+	result, err := s.handle(context.Background(), "docs", &mcp.CreateMessageParams{
+		SystemPrompt: "be brief",
+		MaxTokens:    1000,
+		Messages:     []*mcp.SamplingMessage{{Role: "user", Content: &mcp.TextContent{Text: "hi"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, ok := result.Content.(*mcp.TextContent)
+	if !ok || text.Text != "echo: hi" || result.Model != "openai/gpt-4o" || result.StopReason != "endTurn" {
+		t.Fatalf("result = %+v", result)
+	}
+
+	// echoLLM reports 10 tokens, at $1 per million
+	if got := s.takeCost(); math.Abs(got-10e-6) > 1e-12 {
+		t.Errorf("takeCost() = %v, want the request's cost", got)
+	}
+	if got := s.takeCost(); got != 0 {
+		t.Errorf("second takeCost() = %v, want 0", got)
+	}
+
+	sent := client.requests[0]
+	if len(sent) != 2 || sent[0].Role != messages.MessageRoleSystem || sent[0].Content != "be brief" {
+		t.Fatalf("sent messages = %+v", sent)
+	}
+
+	if _, err := s.handle(context.Background(), "docs", &mcp.CreateMessageParams{SystemPrompt: "only"}); err == nil {
+		t.Fatal("a request without messages should fail")
+	}
+}
+
+func TestNewMCPSamplerDisabled(t *testing.T) {
+	if s := newMCPSampler(&echoLLM{}, &Config{}, nil, nil); s != nil {
+		t.Fatal("sampling should be disabled with a zero token cap")
+	}
+}
@@ -0,0 +1,771 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/skills"
+	"github.com/alexschlessinger/pollytool/tools"
+	"github.com/urfave/cli/v3"
+)
+
+// contextHeader names the polly context a chat completion reads its history
+// from and appends to.
+const contextHeader = "X-Polly-Context"
+
+// maxRequestBody caps request bodies; large enough for a few inline images.
+const maxRequestBody = 32 << 20
+
+func serveCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "serve",
+		Usage: "Serve an OpenAI-compatible HTTP API backed by polly's providers and tools",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "addr",
+				Usage:   "Address to listen on",
+				Value:   "127.0.0.1:8080",
+				Sources: cli.EnvVars("POLLYTOOL_SERVE_ADDR"),
+			},
+			&cli.StringFlag{
+				Name:    "api-key",
+				Usage:   "Require clients to send this bearer token",
+				Sources: cli.EnvVars("POLLYTOOL_SERVE_KEY"),
+			},
+			&cli.StringSliceFlag{
+				Name:  "allow-origin",
+				Usage: "Browser origin allowed to call the API, e.g. http://localhost:3000 (can be repeated; requests from other pages are refused)",
+			},
+		}, agentServerFlags()...),
+		Action: runServe,
+	}
+}
+
+// agentServerFlags are the flags shared by serve and mcp-server, which both
+// answer requests with polly's agent loop.
+func agentServerFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:      "model",
+			Aliases:   []string{"m"},
+			Usage:     "Model used when a request names none (provider/model format)",
+			Value:     "anthropic/claude-sonnet-4-6",
+			Sources:   cli.EnvVars("POLLYTOOL_MODEL"),
+			Validator: validateModel,
+		},
+		&cli.StringSliceFlag{
+			Name:  "fallback-model",
+			Usage: "Model to try when the model fails with a retryable error (can be repeated)",
+			Validator: func(models []string) error {
+				for _, model := range models {
+					if err := validateModel(model); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		&cli.StringFlag{
+			Name:      "embed-model",
+			Usage:     "Embedding model used when a request names none",
+			Value:     "openai/text-embedding-3-large",
+			Sources:   cli.EnvVars("POLLYTOOL_EMBED_MODEL"),
+			Validator: validateEmbedModel,
+		},
+		&cli.StringFlag{
+			Name:    "system",
+			Aliases: []string{"s"},
+			Usage:   "System prompt for requests and new contexts that bring none",
+		},
+		&cli.IntFlag{
+			Name:  "maxtokens",
+			Usage: "Maximum tokens per response when a request sets none",
+			Value: 4096,
+		},
+		&cli.IntFlag{
+			Name:  "maxcontext",
+			Usage: "Maximum history tokens kept in a context (0 = unlimited)",
+		},
+		&cli.IntFlag{
+			Name:  "maxiterations",
+			Usage: "Maximum LLM calls per request",
+			Value: 10,
+		},
+		&cli.DurationFlag{
+			Name:  "timeout",
+			Usage: "Timeout for each LLM call",
+			Value: 2 * time.Minute,
+		},
+		&cli.DurationFlag{
+			Name:  "tooltimeout",
+			Usage: "Timeout for each tool call",
+			Value: 2 * time.Minute,
+		},
+		maxToolOutputFlag(),
+		&cli.StringSliceFlag{
+			Name:    "tool",
+			Aliases: []string{"t"},
+			Usage:   "Tool to execute server-side: built-in name, shell script, or MCP server (can be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "skilldir",
+			Usage: "Directory to discover skills from (can be repeated)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "skill",
+			Usage: "Skill to load and activate (local path or URL, can be repeated)",
+		},
+		&cli.BoolFlag{
+			Name:  "noskills",
+			Usage: "Disable skill discovery",
+		},
+		&cli.BoolFlag{
+			Name:  "nosandbox",
+			Usage: "Run shell tools without sandboxing",
+		},
+	}, taskConfigFlags()...)
+}
+
+// agentServerConfig reads the flags from agentServerFlags.
+func agentServerConfig(cmd *cli.Command) *Config {
+	return &Config{
+		Settings: Settings{
+			Model:            cmd.String("model"),
+			MaxTokens:        int(cmd.Int("maxtokens")),
+			MaxHistoryTokens: int(cmd.Int("maxcontext")),
+			SystemPrompt:     cmd.String("system"),
+			ToolTimeout:      cmd.Duration("tooltimeout"),
+			SkillDirs:        cmd.StringSlice("skilldir"),
+		},
+		Timeout:        cmd.Duration("timeout"),
+		MaxIterations:  int(cmd.Int("maxiterations")),
+		FallbackModels: cmd.StringSlice("fallback-model"),
+		NoSandbox:      cmd.Bool("nosandbox"),
+		MaxToolOutput:  int(cmd.Int("max-tool-output")),
+		TaskConfig:     taskConfigFromFlags(cmd),
+		NoSkills:       cmd.Bool("noskills"),
+		Tools:          cmd.StringSlice("tool"),
+		Skills:         cmd.StringSlice("skill"),
+	}
+}
+
+func runServe(ctx context.Context, cmd *cli.Command) error {
+	server, err := newChatServer(agentServerConfig(cmd), cmd.String("embed-model"), cmd.String("api-key"))
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+	server.allowedOrigins = cmd.StringSlice("allow-origin")
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Addr:              cmd.String("addr"),
+		Handler:           server.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+	fmt.Fprintf(os.Stderr, "polly serve listening on http://%s/v1\n", httpServer.Addr)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
+
+// chatServer answers OpenAI-style requests by running polly's agent loop,
+// executing any configured tools server-side.
+type chatServer struct {
+	client       llm.LLM
+	registry     *tools.ToolRegistry
+	skillCatalog *skills.Catalog
+	skillResult  *skillCatalogResult
+	store        sessions.SessionStore // Contexts addressed by X-Polly-Context
+	agentConfig  llm.AgentConfig
+	config       *Config
+	providers    *llm.ProviderRegistry // Built-in and providers.json providers
+	embedModel   string
+	apiKey       string
+
+	// Origins of the web pages allowed to call the API. Browsers send
+	// Origin with cross-site requests, so any other page is refused.
+	allowedOrigins []string
+
+	// embed is llm.Embed; tests replace it
+	embed func(context.Context, *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error)
+}
+
+func newChatServer(config *Config, embedModel, apiKey string) (*chatServer, error) {
+	providers, err := loadProviders()
+	if err != nil {
+		return nil, err
+	}
+	var client llm.LLM = llm.NewMultiPassWithProviders(loadAPIKeys(providers), providers)
+	if len(config.FallbackModels) > 0 {
+		client = llm.NewFallbackLLM(client, config.FallbackModels, llm.DefaultRetryPolicy)
+	}
+
+	skillResult, err := loadSkillCatalog(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skill activation in this registry is shared by every request
+	registry, _, err := newServerRegistry(config, skillResult)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := sessions.NewFileSessionStore("", &sessions.Metadata{
+		SystemPrompt:     config.SystemPrompt,
+		MaxHistoryTokens: config.MaxHistoryTokens,
+	})
+	if err != nil {
+		_ = registry.Close()
+		return nil, fmt.Errorf("failed to create context store: %w", err)
+	}
+
+	pricing, err := loadPricing()
+	if err != nil {
+		_ = registry.Close()
+		return nil, err
+	}
+	// There is no one to ask, so calls the policy says to ask about are refused
+	policy, err := loadPermissions(false)
+	if err != nil {
+		_ = registry.Close()
+		return nil, err
+	}
+
+	return &chatServer{
+		client:       client,
+		registry:     registry,
+		skillCatalog: skillResult.catalog,
+		skillResult:  skillResult,
+		store:        store,
+		agentConfig: llm.AgentConfig{
+			MaxIterations: config.MaxIterations,
+			ToolTimeout:   config.ToolTimeout,
+			Pricing:       pricing,
+			MaxToolOutput: config.MaxToolOutput,
+			Permissions:   policy,
+			Audit:         newAuditLog(),
+		},
+		config:     config,
+		providers:  providers,
+		embedModel: embedModel,
+		apiKey:     apiKey,
+		embed:      llm.Embed,
+	}, nil
+}
+
+// newServerRegistry loads the configured tools and skill runtime into a new
+// registry, activating the skills named with --skill.
+func newServerRegistry(config *Config, skillResult *skillCatalogResult) (*tools.ToolRegistry, *tools.SkillRuntime, error) {
+	registryOpts, err := sandboxRegistryOptions(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	registry := tools.NewToolRegistry(nil, registryOpts...)
+	registerTaskTool(registry, config)
+	for _, source := range config.Tools {
+		if _, err := registry.LoadToolAuto(source); err != nil {
+			_ = registry.Close()
+			return nil, nil, fmt.Errorf("failed to load tool %s: %w", source, err)
+		}
+	}
+
+	skillRuntime, err := newSkillRuntime(skillResult.catalog, registry)
+	if err == nil {
+		err = autoActivateSkills(skillResult.autoActivate, skillRuntime)
+	}
+	if err != nil {
+		_ = registry.Close()
+		return nil, nil, err
+	}
+	return registry, skillRuntime, nil
+}
+
+// Close shuts down the tools the server loaded.
+func (s *chatServer) Close() error {
+	if s.registry == nil {
+		return nil
+	}
+	return s.registry.Close()
+}
+
+func (s *chatServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /v1/chat/completions", requireJSON(http.HandlerFunc(s.handleChatCompletions)))
+	mux.Handle("POST /v1/embeddings", requireJSON(http.HandlerFunc(s.handleEmbeddings)))
+	mux.HandleFunc("GET /v1/models", s.handleModels)
+	return s.checkOrigin(s.requireKey(mux))
+}
+
+// checkOrigin refuses requests made by web pages whose origin is not
+// allowed, and answers CORS preflights for those that are.
+func (s *chatServer) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !slices.Contains(s.allowedOrigins, origin) {
+			writeAPIError(w, http.StatusForbidden, "permission_error", fmt.Errorf("origin %s is not allowed (see --allow-origin)", origin))
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+contextHeader)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireJSON refuses bodies not sent as application/json. Browsers only
+// send other types, such as text/plain, without a preflight.
+func requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			writeAPIError(w, http.StatusUnsupportedMediaType, "invalid_request_error", errors.New("request body must be sent with Content-Type: application/json"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireKey rejects requests without the configured bearer token.
+func (s *chatServer) requireKey(next http.Handler) http.Handler {
+	if s.apiKey == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.apiKey)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, "authentication_error", errors.New("invalid or missing API key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *chatServer) handleModels(w http.ResponseWriter, r *http.Request) {
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	data := []model{{ID: s.config.Model, Object: "model", OwnedBy: "polly"}}
+	for _, fallback := range s.config.FallbackModels {
+		data = append(data, model{ID: fallback, Object: "model", OwnedBy: "polly"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func (s *chatServer) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var body openAIChatRequest
+	if err := decodeBody(w, r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	req, err := s.completionRequest(&body)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	var usage openAIUsage
+	cb := &llm.AgentCallbacks{
+		OnResponse: func(response *messages.ChatMessage) {
+			usage.PromptTokens += response.GetInputTokens()
+			usage.CompletionTokens += response.GetOutputTokens()
+			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+		},
+	}
+
+	completion := openAIChatResponse{
+		ID:      newCompletionID(),
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+
+	var stream *sseStream
+	if body.Stream {
+		stream = &sseStream{w: w, base: completion}
+		stream.base.Object = "chat.completion.chunk"
+		cb.OnContent = func(content string) {
+			stream.delta(openAIReplyDelta{Content: content})
+		}
+		cb.OnReasoning = func(content string) {
+			stream.delta(openAIReplyDelta{ReasoningContent: content})
+		}
+	}
+
+	resp, err := s.run(r.Context(), req, r.Header.Get(contextHeader), cb)
+	if err != nil {
+		switch {
+		case stream != nil && stream.started:
+			stream.error(err)
+		case errors.Is(err, errContextUnavailable):
+			writeAPIError(w, http.StatusConflict, "invalid_request_error", err)
+		case errors.Is(err, llm.ErrBudgetExceeded):
+			writeAPIError(w, http.StatusTooManyRequests, "insufficient_quota", err)
+		case errors.Is(err, errNoNewTurn), errors.Is(err, sessions.ErrTurnTooLarge):
+			writeAPIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		default:
+			writeAPIError(w, http.StatusBadGateway, "api_error", err)
+		}
+		return
+	}
+
+	reply := resp.Message
+	if model := reply.GetModel(); model != "" {
+		completion.Model = model
+	}
+	finish := finishReason(reply.StopReason)
+
+	if stream != nil {
+		stream.finish(finish, completion.Model)
+		if body.StreamOptions != nil && body.StreamOptions.IncludeUsage {
+			stream.usage(usage)
+		}
+		stream.done()
+		return
+	}
+
+	completion.Object = "chat.completion"
+	completion.Choices = []openAIChatChoice{{
+		Message: &openAIReplyDelta{
+			Role:             messages.MessageRoleAssistant,
+			Content:          reply.Content,
+			ReasoningContent: reply.Reasoning,
+		},
+		FinishReason: &finish,
+	}}
+	completion.Usage = &usage
+	writeJSON(w, http.StatusOK, completion)
+}
+
+// errContextUnavailable wraps failures to open the context a request names.
+var errContextUnavailable = errors.New("context unavailable")
+
+// run executes req with the agent. When contextName is set the context's
+// history is prepended and the new turn is recorded in it; otherwise the
+// server's system prompt is added if the request brings none.
+func (s *chatServer) run(ctx context.Context, req *llm.CompletionRequest, contextName string, cb *llm.AgentCallbacks) (*llm.AgentResponse, error) {
+	if contextName == "" {
+		if s.config.SystemPrompt != "" && (len(req.Messages) == 0 || req.Messages[0].Role != messages.MessageRoleSystem) {
+			req.Messages = append([]messages.ChatMessage{{Role: messages.MessageRoleSystem, Content: s.config.SystemPrompt}}, req.Messages...)
+		}
+		return llm.NewAgent(s.client, s.registry, s.agentConfig).Run(ctx, req, cb)
+	}
+
+	session, err := s.store.Get(contextName)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errContextUnavailable, contextName, err)
+	}
+	defer session.Close()
+
+	// The context's budget holds for every client, as it does in the CLI
+	budget, err := sessionTurnBudget(session, s.config.RequestBudget, session.GetMetadata().Budget)
+	if err != nil {
+		return nil, err
+	}
+
+	abandonRun(session)
+	if err := s.appendToContext(session, req.Messages); err != nil {
+		return nil, err
+	}
+	if err := compactSession(ctx, session, s.client, req.Model); err != nil {
+		if errors.Is(err, sessions.ErrTurnTooLarge) {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Warning: context %s: %v\n", contextName, err)
+	}
+	req.Messages = session.GetHistory()
+	resp, err := llm.NewAgent(s.client, s.registry, s.agentConfig).WithBudget(budget).WithContextName(contextName).Run(ctx, req, cb)
+	if resp != nil {
+		recordSessionSpend(session, resp.Cost)
+		for _, msg := range resp.AllMessages {
+			session.AddMessage(msg)
+		}
+		// The server has no resume, so close out a run that stopped early
+		for _, msg := range resp.State.AbandonPending() {
+			session.AddMessage(msg)
+		}
+		recordSubAgents(session, resp.SubAgents)
+	}
+	return resp, err
+}
+
+// completionRequest validates an OpenAI request and converts it, filling in
+// the server's defaults.
+func (s *chatServer) completionRequest(body *openAIChatRequest) (*llm.CompletionRequest, error) {
+	if len(body.Tools) > 0 {
+		return nil, errors.New("client-side tools are not supported; polly serve executes its own tools (see --tool)")
+	}
+	if len(body.Messages) == 0 {
+		return nil, errors.New("messages must not be empty")
+	}
+
+	model := body.Model
+	if model == "" {
+		model = s.config.Model
+	}
+	if !strings.Contains(model, "/") {
+		return nil, fmt.Errorf("model must include provider prefix (e.g., %q). Got: %s", s.config.Model, model)
+	}
+
+	effort := llm.ThinkingOff
+	if body.ReasoningEffort != "" {
+		var err error
+		if effort, err = llm.ParseThinkingEffort(body.ReasoningEffort); err != nil {
+			return nil, err
+		}
+	}
+
+	schema, err := body.ResponseFormat.responseSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]messages.ChatMessage, 0, len(body.Messages))
+	for i, m := range body.Messages {
+		msg, err := m.toChatMessage()
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	maxTokens := s.config.MaxTokens
+	if body.MaxCompletionTokens > 0 {
+		maxTokens = body.MaxCompletionTokens
+	} else if body.MaxTokens > 0 {
+		maxTokens = body.MaxTokens
+	}
+
+	req := s.newRequest(model, msgs)
+	req.Temperature = body.Temperature
+	req.MaxTokens = maxTokens
+	req.ResponseSchema = schema
+	req.ThinkingEffort = effort
+	return req, nil
+}
+
+// newRequest builds a completion request with the server's defaults.
+func (s *chatServer) newRequest(model string, msgs []messages.ChatMessage) *llm.CompletionRequest {
+	if model == "" {
+		model = s.config.Model
+	}
+	return &llm.CompletionRequest{
+		Timeout:   s.config.Timeout,
+		Model:     model,
+		MaxTokens: s.config.MaxTokens,
+		Messages:  msgs,
+		Tools:     s.registry.All(),
+		Skills:    s.skillCatalog,
+	}
+}
+
+// errNoNewTurn rejects a request to a context that brings only messages
+// the context already holds.
+var errNoNewTurn = errors.New("no new messages after the last assistant message; the context already holds the conversation")
+
+// appendToContext adds the request's new turn to session. Clients that
+// resend the whole conversation bring earlier turns the context already
+// holds, so once it has a conversation only the messages after the
+// request's last assistant or tool message are added. A context keeps its own system prompt, so request system messages
+// only seed contexts that have none.
+func (s *chatServer) appendToContext(session sessions.Session, msgs []messages.ChatMessage) error {
+	history := session.GetHistory()
+	hasSystem := len(history) > 0 && history[0].Role == messages.MessageRoleSystem
+	if slices.ContainsFunc(history, func(msg messages.ChatMessage) bool { return msg.Role != messages.MessageRoleSystem }) {
+		for i := len(msgs) - 1; i >= 0; i-- {
+			if msgs[i].Role == messages.MessageRoleAssistant || msgs[i].Role == messages.MessageRoleTool {
+				msgs = msgs[i+1:]
+				break
+			}
+		}
+		if !slices.ContainsFunc(msgs, func(msg messages.ChatMessage) bool { return msg.Role != messages.MessageRoleSystem }) {
+			return errNoNewTurn
+		}
+	}
+	for _, msg := range msgs {
+		if msg.Role == messages.MessageRoleSystem && hasSystem {
+			continue
+		}
+		session.AddMessage(msg)
+	}
+	return nil
+}
+
+func (s *chatServer) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var body openAIEmbeddingRequest
+	if err := decodeBody(w, r, &body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	input, err := embeddingInput(body.Input)
+	if err == nil && len(input) == 0 {
+		err = errors.New("input must not be empty")
+	}
+	model := body.Model
+	if model == "" {
+		model = s.embedModel
+	}
+	if err == nil {
+		err = validateEmbedModel(model)
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid_request_error", err)
+		return
+	}
+
+	resp, err := s.embed(r.Context(), &llm.EmbeddingRequest{
+		Model:      model,
+		Timeout:    s.config.Timeout,
+		Input:      input,
+		Dimensions: body.Dimensions,
+		Providers:  s.providers,
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, "api_error", err)
+		return
+	}
+
+	out := openAIEmbeddingResponse{
+		Object: "list",
+		Model:  resp.Model,
+		Usage:  openAIUsage{PromptTokens: resp.InputTokens, TotalTokens: resp.InputTokens},
+	}
+	for i, vec := range resp.Embeddings {
+		out.Data = append(out.Data, openAIEmbedding{Object: "embedding", Index: i, Embedding: encodeEmbedding(vec, body.EncodingFormat)})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// sseStream writes chat.completion.chunk events for a streamed completion.
+// Headers go out with the first chunk, so failures before any output can
+// still be reported with an HTTP status.
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	base    openAIChatResponse
+	started bool
+}
+
+func (s *sseStream) start() {
+	s.started = true
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("Connection", "keep-alive")
+	s.w.WriteHeader(http.StatusOK)
+
+	s.flusher, _ = s.w.(http.Flusher)
+	s.delta(openAIReplyDelta{Role: messages.MessageRoleAssistant})
+}
+
+func (s *sseStream) send(v any) {
+	if !s.started {
+		s.start()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.w, "data: %s\n\n", data)
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func (s *sseStream) delta(delta openAIReplyDelta) {
+	chunk := s.base
+	chunk.Choices = []openAIChatChoice{{Delta: &delta}}
+	s.send(chunk)
+}
+
+func (s *sseStream) finish(reason, model string) {
+	chunk := s.base
+	chunk.Model = model
+	chunk.Choices = []openAIChatChoice{{Delta: &openAIReplyDelta{}, FinishReason: &reason}}
+	s.send(chunk)
+}
+
+func (s *sseStream) usage(usage openAIUsage) {
+	chunk := s.base
+	chunk.Choices = []openAIChatChoice{}
+	chunk.Usage = &usage
+	s.send(chunk)
+}
+
+// error reports a failure after streaming has begun, when the status code
+// can no longer change.
+func (s *sseStream) error(err error) {
+	s.send(openAIError{Error: openAIErrorBody{Message: err.Error(), Type: "api_error"}})
+	s.done()
+}
+
+func (s *sseStream) done() {
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, kind string, err error) {
+	writeJSON(w, status, openAIError{Error: openAIErrorBody{Message: err.Error(), Type: kind}})
+}
+
+func newCompletionID() string {
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "chatcmpl-" + hex.EncodeToString(b[:])
+}
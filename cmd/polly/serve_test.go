@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+// echoLLM answers every request with the last user message, recording the
+// history it was sent.
+type echoLLM struct {
+	mu       sync.Mutex
+	requests [][]messages.ChatMessage
+	summary  string // Reply to requests that start with a system prompt, e.g. summaries
+}
+
+func (e *echoLLM) ChatCompletionStream(_ context.Context, req *llm.CompletionRequest, processor llm.EventStreamProcessor) <-chan *messages.StreamEvent {
+	e.mu.Lock()
+	e.requests = append(e.requests, req.Messages)
+	e.mu.Unlock()
+
+	last := req.Messages[len(req.Messages)-1]
+	content := "echo: " + last.GetContent()
+	if e.summary != "" && req.Messages[0].Role == messages.MessageRoleSystem {
+		content = e.summary
+	}
+	msgChan := make(chan messages.ChatMessage, 1)
+	msgChan <- messages.ChatMessage{
+		Role:       messages.MessageRoleAssistant,
+		Content:    content,
+		StopReason: messages.StopReasonEndTurn,
+		Metadata:   map[string]any{messages.MetadataKeyInputTokens: 7, messages.MetadataKeyOutputTokens: 3},
+	}
+	close(msgChan)
+	return processor.ProcessMessagesToEvents(msgChan)
+}
+
+func newTestChatServer(t *testing.T, client llm.LLM) *chatServer {
+	t.Helper()
+	store, err := sessions.NewFileSessionStore(t.TempDir(), &sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &chatServer{
+		client:      client,
+		registry:    tools.NewToolRegistry(nil),
+		store:       store,
+		agentConfig: llm.AgentConfig{MaxIterations: 3},
+		config:      &Config{Settings: Settings{Model: "openai/gpt-4o", MaxTokens: 100}},
+		embedModel:  "openai/text-embedding-3-small",
+	}
+}
+
+func postJSON(t *testing.T, handler http.Handler, path, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeChatCompletion(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	rec := postJSON(t, server.routes(), "/v1/chat/completions", `{"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var resp openAIChatResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Object != "chat.completion" || resp.Model != "openai/gpt-4o" || len(resp.Choices) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	if got := resp.Choices[0].Message.Content; got != "echo: hi" {
+		t.Errorf("content = %q", got)
+	}
+	if *resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("finish_reason = %q", *resp.Choices[0].FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 10 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestServeChatCompletionStream(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	rec := postJSON(t, server.routes(), "/v1/chat/completions",
+		`{"stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`, nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var content strings.Builder
+	var finish string
+	var usage *openAIUsage
+	var done bool
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("chunk %q: %v", data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Fatalf("chunk object = %q", chunk.Object)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+	}
+
+	if content.String() != "echo: hi" || finish != "stop" || !done {
+		t.Fatalf("content = %q, finish = %q, done = %v", content.String(), finish, done)
+	}
+	if usage == nil || usage.PromptTokens != 7 || usage.CompletionTokens != 3 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestServeChatCompletionWithContext(t *testing.T) {
+	client := &echoLLM{}
+	server := newTestChatServer(t, client)
+	handler := server.routes()
+	header := http.Header{contextHeader: {"editor"}}
+
+	for _, body := range []string{
+		`{"messages":[{"role":"user","content":"first"}]}`,
+		`{"messages":[{"role":"user","content":"second"}]}`,
+		// A client resending the whole conversation only adds its new turn
+		`{"messages":[{"role":"user","content":"first"},{"role":"assistant","content":"echo: first"},{"role":"user","content":"second"},{"role":"assistant","content":"echo: second"},{"role":"user","content":"third"}]}`,
+	} {
+		rec := postJSON(t, handler, "/v1/chat/completions", body, header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+	}
+
+	// The second request carries the first turn from the stored context
+	second := client.requests[1]
+	if len(second) != 3 || second[0].Content != "first" || second[1].Content != "echo: first" || second[2].Content != "second" {
+		t.Fatalf("second request history = %+v", second)
+	}
+	if third := client.requests[2]; len(third) != 5 || third[4].Content != "third" {
+		t.Fatalf("third request history = %+v", third)
+	}
+
+	// A request with nothing after the conversation has no turn to run
+	rec := postJSON(t, handler, "/v1/chat/completions", `{"messages":[{"role":"user","content":"first"},{"role":"assistant","content":"echo: first"}]}`, header)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400, body = %s", rec.Code, rec.Body)
+	}
+
+	session, err := server.store.Get("editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	if got := len(session.GetHistory()); got != 6 {
+		t.Fatalf("stored history has %d messages, want 6", got)
+	}
+}
+
+func TestServeContextCompaction(t *testing.T) {
+	client := &echoLLM{summary: "the user sent long messages"}
+	server := newTestChatServer(t, client)
+	handler := server.routes()
+	header := http.Header{contextHeader: {"notes"}}
+
+	session, err := server.store.Get("notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.UpdateMetadata(&sessions.Metadata{Compaction: sessions.CompactionSummarize, MaxHistoryTokens: 150}); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+
+	body := fmt.Sprintf(`{"messages":[{"role":"user","content":%q}]}`, strings.Repeat("x", 200))
+	for range 6 {
+		rec := postJSON(t, handler, "/v1/chat/completions", body, header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+	}
+
+	summarized := false
+	for _, req := range client.requests {
+		if req[0].Role == messages.MessageRoleSystem {
+			continue // A summary request
+		}
+		total := 0
+		for _, msg := range req {
+			total += sessions.CountMessageTokens(msg, nil)
+			summarized = summarized || sessions.IsSummaryMessage(msg)
+		}
+		if total > 150 {
+			t.Fatalf("request of %d tokens exceeds the context's 150 token limit", total)
+		}
+	}
+	if !summarized {
+		t.Fatal("no request carried a summary of the earlier turns")
+	}
+}
+
+func TestServeContextBudget(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	// Each echoLLM call uses 10 tokens: $1 at this price
+	server.agentConfig.Pricing = llm.NewPricingTable(map[string]llm.ModelPrice{"openai/gpt-4o": {Input: 1e5, Output: 1e5}})
+	handler := server.routes()
+	header := http.Header{contextHeader: {"metered"}}
+
+	session, err := server.store.Get("metered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.UpdateMetadata(&sessions.Metadata{Budget: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+
+	body := `{"messages":[{"role":"user","content":"hi"}]}`
+	if rec := postJSON(t, handler, "/v1/chat/completions", body, header); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	session, err = server.store.Get("metered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent := session.GetMetadata().TotalCost
+	session.Close()
+	if math.Abs(spent-1) > 1e-9 {
+		t.Fatalf("context spend = %v, want 1", spent)
+	}
+
+	// The second call would take the context past its budget
+	if rec := postJSON(t, handler, "/v1/chat/completions", body, header); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429, body = %s", rec.Code, rec.Body)
+	}
+	// Once the budget is spent, requests fail before reaching the LLM
+	session, err = server.store.Get("metered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.UpdateMetadata(&sessions.Metadata{TotalCost: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	session.Close()
+	client := server.client.(*echoLLM)
+	calls := len(client.requests)
+	rec := postJSON(t, handler, "/v1/chat/completions", body, header)
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "insufficient_quota") {
+		t.Fatalf("status = %d, want 429, body = %s", rec.Code, rec.Body)
+	}
+	if len(client.requests) != calls {
+		t.Fatal("a request ran against a spent context budget")
+	}
+}
+
+func TestServeRejectsBadRequests(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	server.apiKey = "secret"
+	handler := server.routes()
+	auth := http.Header{"Authorization": {"Bearer secret"}}
+
+	tests := []struct {
+		name   string
+		body   string
+		header http.Header
+		status int
+	}{
+		{"missing key", `{"messages":[{"role":"user","content":"hi"}]}`, nil, http.StatusUnauthorized},
+		{"client tools", `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function"}]}`, auth, http.StatusBadRequest},
+		{"no provider", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, auth, http.StatusBadRequest},
+		{"bad role", `{"messages":[{"role":"robot","content":"hi"}]}`, auth, http.StatusBadRequest},
+		{"bad context", `{"messages":[{"role":"user","content":"hi"}]}`, http.Header{"Authorization": {"Bearer secret"}, contextHeader: {"../x"}}, http.StatusConflict},
+		{"not json", `{"messages":[{"role":"user","content":"hi"}]}`, http.Header{"Authorization": {"Bearer secret"}, "Content-Type": {"text/plain"}}, http.StatusUnsupportedMediaType},
+		{"other origin", `{"messages":[{"role":"user","content":"hi"}]}`, http.Header{"Authorization": {"Bearer secret"}, "Origin": {"https://evil.example"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := postJSON(t, handler, "/v1/chat/completions", tt.body, tt.header)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d, body = %s", rec.Code, tt.status, rec.Body)
+			}
+			var apiErr openAIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &apiErr); err != nil || apiErr.Error.Message == "" {
+				t.Fatalf("error body = %s", rec.Body)
+			}
+		})
+	}
+}
+
+func TestServeAllowedOrigin(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	server.allowedOrigins = []string{"http://localhost:3000"}
+	handler := server.routes()
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/v1/chat/completions", nil)
+	preflight.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Fatalf("preflight status = %d, headers = %v", rec.Code, rec.Header())
+	}
+
+	rec = postJSON(t, handler, "/v1/chat/completions", `{"messages":[{"role":"user","content":"hi"}]}`, http.Header{"Origin": {"http://localhost:3000"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "http://localhost:3000" {
+		t.Fatalf("status = %d, headers = %v, body = %s", rec.Code, rec.Header(), rec.Body)
+	}
+}
+
+func TestServeEmbeddings(t *testing.T) {
+	server := newTestChatServer(t, &echoLLM{})
+	var got *llm.EmbeddingRequest
+	server.embed = func(_ context.Context, req *llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+		got = req
+		return &llm.EmbeddingResponse{Model: "text-embedding-3-small", Embeddings: [][]float64{{1, 0}, {0, 1}}, InputTokens: 4}, nil
+	}
+
+	rec := postJSON(t, server.routes(), "/v1/embeddings", `{"input":["a","b"],"dimensions":2}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got.Model != "openai/text-embedding-3-small" || len(got.Input) != 2 || got.Dimensions != 2 {
+		t.Fatalf("embedding request = %+v", got)
+	}
+
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage openAIUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 2 || resp.Data[1].Index != 1 || resp.Data[1].Embedding[1] != 1 || resp.Usage.PromptTokens != 4 {
+		t.Fatalf("response = %+v", resp)
+	}
+
+	// base64 packs little-endian float32s: 1.0 is 0x3f800000
+	rec = postJSON(t, server.routes(), "/v1/embeddings", `{"input":"a","encoding_format":"base64"}`, nil)
+	if !strings.Contains(rec.Body.String(), `"embedding":"AACAPwAAAAA="`) {
+		t.Fatalf("base64 body = %s", rec.Body)
+	}
+}
@@ -199,16 +199,27 @@ func TestAutoActivateSkills(t *testing.T) {
 
 func captureStdout(t *testing.T, fn func()) string {
 	t.Helper()
+	return captureOutput(t, &os.Stdout, fn)
+}
+
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureOutput(t, &os.Stderr, fn)
+}
+
+// captureOutput returns what fn writes to *file, e.g. os.Stdout
+func captureOutput(t *testing.T, file **os.File, fn func()) string {
+	t.Helper()
 
-	originalStdout := os.Stdout
+	original := *file
 	reader, writer, err := os.Pipe()
 	if err != nil {
 		t.Fatalf("Pipe() error = %v", err)
 	}
 
-	os.Stdout = writer
+	*file = writer
 	defer func() {
-		os.Stdout = originalStdout
+		*file = original
 	}()
 
 	fn()
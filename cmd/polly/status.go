@@ -7,6 +7,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/alexschlessinger/pollytool/tools"
 )
 
 // StatusHandler is the interface for different status display implementations
@@ -20,6 +22,8 @@ type StatusHandler interface {
 	ShowSpinner(text string)
 	ShowThinking(tokens int)
 	ShowToolCall(name string)
+	ShowToolProgress(name string, progress tools.ToolProgress)
+	ShowMCPConnection(server string, state tools.MCPConnectionState)
 
 	// Progress updates
 	ClearForContent()
@@ -113,6 +117,16 @@ func (s *Status) ShowToolCall(name string) {
 	s.SetStatus("running tool: %s", name)
 }
 
+// ShowToolProgress shows the latest progress or log update of a running tool
+func (s *Status) ShowToolProgress(name string, progress tools.ToolProgress) {
+	s.SetStatus("running tool: %s %s", name, progress)
+}
+
+// ShowMCPConnection shows that an MCP server's connection dropped or came back
+func (s *Status) ShowMCPConnection(server string, state tools.MCPConnectionState) {
+	s.SetStatus("mcp %s: %s", server, state)
+}
+
 // ClearForContent clears status before content streaming
 func (s *Status) ClearForContent() {
 	// Start spinner with streaming status
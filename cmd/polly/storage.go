@@ -62,12 +62,25 @@ func handleListContexts(store sessions.SessionStore) error {
 			modelInfo = fmt.Sprintf(" [%s]", info.Model)
 		}
 
-		fmt.Printf("%s%s - last used: %s%s\n", name, modelInfo, timeStr, marker)
+		spendInfo := ""
+		if info.TotalCost > 0 {
+			spendInfo = " - spent: " + formatCost(info.TotalCost)
+		}
+
+		fmt.Printf("%s%s - last used: %s%s%s\n", name, modelInfo, timeStr, spendInfo, marker)
 	}
 
 	return nil
 }
 
+// formatCost formats a USD amount, keeping precision for sub-cent spend.
+func formatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
+
 // formatDuration formats a duration in a human-readable way
 func formatDuration(d time.Duration) string {
 	if d < time.Minute {
@@ -150,7 +163,7 @@ func handleAddToContext(store sessions.SessionStore, config *Config, contextID s
 	// Check if files are provided via --file flag
 	if len(config.Files) > 0 {
 		// Process files to get their content
-		parts, err := processFiles(config.Files)
+		parts, err := processFiles(config.Files, nil)
 		if err != nil {
 			return fmt.Errorf("error processing files: %w", err)
 		}
@@ -290,8 +303,21 @@ func handleShowContext(store sessions.SessionStore, contextID string) error {
 
 	// Conversation settings
 	fmt.Printf("  Max Context: %d tokens\n", info.MaxHistoryTokens)
+	compaction := info.Compaction
+	if compaction == "" {
+		compaction = sessions.CompactionTrim
+	}
+	fmt.Printf("  Compaction: %s\n", compaction)
 	fmt.Printf("  TTL: %s\n", info.TTL)
 
+	// Spending
+	fmt.Printf("  Spent: %s\n", formatCost(info.TotalCost))
+	if info.Budget > 0 {
+		fmt.Printf("  Budget: %s\n", formatCost(info.Budget))
+	} else {
+		fmt.Printf("  Budget: unlimited\n")
+	}
+
 	// Prompts and description
 	fmt.Printf("  Description: %s\n", info.Description)
 	fmt.Printf("  System Prompt: %s\n", info.SystemPrompt)
@@ -314,6 +340,22 @@ func handleShowContext(store sessions.SessionStore, contextID string) error {
 		fmt.Printf("  Active Skills: []\n")
 	}
 	fmt.Printf("  Tool Timeout: %s\n", info.ToolTimeout)
+	if len(info.SubAgents) > 0 {
+		fmt.Println("  Sub-Agent Runs:")
+		for _, run := range info.SubAgents {
+			status := fmt.Sprintf("%d iterations", run.Iterations)
+			if run.Error != "" {
+				status += ", stopped: " + run.Error
+			}
+			fmt.Printf("    - %s (depth %d) %s: %s\n", run.TaskID, run.Depth, run.Started.Format("2006-01-02 15:04:05"), status)
+		}
+	}
+	if info.Plan != nil {
+		fmt.Printf("  Plan: %s\n", truncate(info.Plan.Task, 80))
+		for i, step := range info.Plan.Steps {
+			fmt.Printf("    %d. [%s] %s\n", i+1, step.Status, step.Description)
+		}
+	}
 
 	return nil
 }
@@ -433,8 +475,10 @@ func resetContext(sessionStore sessions.SessionStore, name string) error {
 	// Ensure we release the file lock
 	defer session.Close()
 
-	// Clear the session history
+	// Clear the session history and any run or plan left to resume
 	session.Clear()
+	saveRunState(session, nil)
+	savePlan(session, nil)
 
 	return nil
 }
@@ -2,11 +2,14 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
 	"github.com/muesli/termenv"
 	"golang.org/x/term"
 )
@@ -61,13 +64,10 @@ func promptYesNo(prompt string, defaultValue bool) bool {
 		promptStr = fmt.Sprintf("%s (y/N): ", prompt)
 	}
 
-	fmt.Fprint(os.Stderr, promptStr)
-	reader := bufio.NewReader(os.Stdin)
-	response, err := reader.ReadString('\n')
-	if err != nil {
+	response, ok := readAnswer(promptStr)
+	if !ok {
 		return false
 	}
-	response = strings.TrimSpace(strings.ToLower(response))
 	// Return default if user just presses enter
 	if response == "" {
 		return defaultValue
@@ -75,32 +75,73 @@ func promptYesNo(prompt string, defaultValue bool) bool {
 	return response == "y" || response == "yes"
 }
 
-// promptYesNoAll prompts for y/n/a (yes, no, approve all). Returns 'y', 'n', or 'a'.
-func promptYesNoAll(prompt string) byte {
-	fmt.Fprintf(os.Stderr, "%s (Y/n/a): ", prompt)
+// readAnswer prints prompt to stderr and returns the line read from stdin,
+// trimmed and lowercased. ok is false if stdin could not be read.
+func readAnswer(prompt string) (answer string, ok bool) {
+	fmt.Fprint(os.Stderr, prompt)
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
 	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(strings.ToLower(response)), true
+}
+
+// promptChoice prompts for a single-letter answer. Enter, y and yes return
+// 'y'; choices maps the other accepted answers; anything else returns 'n'.
+func promptChoice(prompt string, choices map[string]byte) byte {
+	response, ok := readAnswer(prompt)
+	if !ok {
 		return 'n'
 	}
-	response = strings.TrimSpace(strings.ToLower(response))
 	switch response {
 	case "", "y", "yes":
 		return 'y'
-	case "a", "all":
-		return 'a'
-	default:
-		return 'n'
 	}
+	if choice, ok := choices[response]; ok {
+		return choice
+	}
+	return 'n'
+}
+
+// promptYesNoAll prompts for y/n/a (yes, no, approve all). Returns 'y', 'n', or 'a'.
+func promptYesNoAll(prompt string) byte {
+	return promptChoice(prompt+" (Y/n/a): ", map[string]byte{"a": 'a', "all": 'a'})
+}
+
+// promptToolApproval prompts for y/n/a, plus s (always allow, saved to the
+// permission policy) when canSave is set. Returns 'y', 'n', 'a', or 's'.
+func promptToolApproval(canSave bool) byte {
+	if !canSave {
+		return promptYesNoAll("  allow?")
+	}
+	return promptChoice("  allow? (Y/n/a/s to always allow): ", map[string]byte{
+		"a": 'a', "all": 'a',
+		"s": 's', "always": 's',
+	})
+}
+
+// promptPlanReview prompts for y/n/e (run, discard, edit the plan). Returns
+// 'y', 'n', or 'e'.
+func promptPlanReview() byte {
+	return promptChoice("Run this plan? (Y/n/e to edit): ", map[string]byte{"e": 'e', "edit": 'e'})
 }
 
-// toolApprover manages tool call approval state across a session.
+// toolApprover manages tool call approval state across a session. MCP
+// sampling requests arrive from running tools, so prompts are serialized.
 type toolApprover struct {
-	approveAll bool
+	mu          sync.Mutex
+	approveAll  bool
+	clearStatus func()                  // Clears the status line before prompting, if set
+	policy      *tools.PermissionPolicy // Takes "always allow" answers, if set
+	policyPath  string                  // File "always allow" answers are saved to
 }
 
 // approveToolCalls prompts the user to approve each tool in a batch.
 func (ta *toolApprover) approveToolCalls(calls []messages.ChatMessageToolCall) []bool {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
 	approved := make([]bool, len(calls))
 	if ta.approveAll {
 		for i := range approved {
@@ -117,9 +158,19 @@ func (ta *toolApprover) approveToolCalls(calls []messages.ChatMessageToolCall) [
 		}
 		fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled(label))
 
-		switch promptYesNoAll("  allow?") {
+		var args map[string]any
+		_ = json.Unmarshal([]byte(tc.Arguments), &args)
+		switch promptToolApproval(ta.canSave(tc.Name, args)) {
 		case 'y':
 			approved[i] = true
+		case 's':
+			approved[i] = true
+			rule, err := ta.policy.AlwaysAllow(ta.policyPath, tc.Name, args)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "  %s\n", errorStyle.Styled("Warning: "+err.Error()))
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled(fmt.Sprintf("saved %q to %s", rule.String(), ta.policyPath)))
 		case 'a':
 			ta.approveAll = true
 			for j := i; j < len(calls); j++ {
@@ -133,7 +184,48 @@ func (ta *toolApprover) approveToolCalls(calls []messages.ChatMessageToolCall) [
 	return approved
 }
 
+// canSave reports whether an "always allow" answer for a call of tool name
+// with args can be saved. Calls an ask or deny rule matched cannot be
+// allowed by adding a rule, as those rules take precedence, and calls with
+// no command or path have nothing to limit the rule to.
+func (ta *toolApprover) canSave(name string, args map[string]any) bool {
+	if _, ok := tools.ScopedAllowRule(name, args); !ok {
+		return false
+	}
+	return ta.policy != nil && ta.policyPath != "" && ta.policy.Check(name, args).Rule == nil
+}
+
+// approveSampling prompts the user to let an MCP server run a completion.
+func (ta *toolApprover) approveSampling(server, model string, maxTokens int) bool {
+	ta.mu.Lock()
+	defer ta.mu.Unlock()
+
+	if ta.approveAll {
+		return true
+	}
+	if ta.clearStatus != nil {
+		ta.clearStatus()
+	}
+
+	label := fmt.Sprintf("%s sampling: %s, up to %d tokens", server, model, maxTokens)
+	fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled(label))
+	switch promptYesNoAll("  allow?") {
+	case 'y':
+		return true
+	case 'a':
+		ta.approveAll = true
+		return true
+	default:
+		return false
+	}
+}
+
 // isTerminal checks if output is going to a terminal
 func isTerminal() bool {
 	return term.IsTerminal(int(os.Stdout.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
 }
+
+// isStdinTerminal checks if input is coming from a terminal
+func isStdinTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
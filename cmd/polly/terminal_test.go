@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// withStdin runs fn with os.Stdin reading input
+func withStdin(t *testing.T, input string, fn func()) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if _, err := writer.WriteString(input); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	original := os.Stdin
+	os.Stdin = reader
+	defer func() { os.Stdin = original }()
+	fn()
+}
+
+func TestPromptAnswers(t *testing.T) {
+	tests := []struct {
+		name   string
+		prompt func() byte
+		input  string
+		want   byte
+	}{
+		{"enter approves", func() byte { return promptYesNoAll("allow?") }, "\n", 'y'},
+		{"all", func() byte { return promptYesNoAll("allow?") }, " ALL \n", 'a'},
+		{"always is not offered", func() byte { return promptToolApproval(false) }, "s\n", 'n'},
+		{"always", func() byte { return promptToolApproval(true) }, "always\n", 's'},
+		{"edit", func() byte { return promptPlanReview() }, "e\n", 'e'},
+		{"unknown declines", func() byte { return promptPlanReview() }, "maybe\n", 'n'},
+		{"closed stdin declines", func() byte { return promptPlanReview() }, "", 'n'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got byte
+			captureStderr(t, func() {
+				withStdin(t, tt.input, func() { got = tt.prompt() })
+			})
+			if got != tt.want {
+				t.Fatalf("answer = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
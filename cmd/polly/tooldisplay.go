@@ -13,24 +13,29 @@ import (
 
 // toolDisplayEnabled returns true when tool display should be shown.
 func toolDisplayEnabled(config *Config) bool {
-	return !config.Quiet && isTerminal()
+	return !config.Quiet && config.Output != outputJSONL && isTerminal()
 }
 
-// printToolStart prints a tool start indicator with summarized args to stderr.
-func printToolStart(tc messages.ChatMessageToolCall) {
-	fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled("→ "+toolLabel(tc)))
+// printToolStart prints a tool start indicator with summarized args to
+// stderr, indented one step further for each level of sub-agent.
+func printToolStart(tc messages.ChatMessageToolCall, depth int) {
+	fmt.Fprintf(os.Stderr, "%s%s\n", toolIndent(depth), dimStyle.Styled("→ "+toolLabel(tc)))
 }
 
 // printToolEnd prints a tool completion line with duration to stderr.
-func printToolEnd(tc messages.ChatMessageToolCall, duration time.Duration, err error) {
+func printToolEnd(tc messages.ChatMessageToolCall, duration time.Duration, err error, depth int) {
 	label := toolLabel(tc)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "  %s\n", errorStyle.Styled(fmt.Sprintf("✗ %.1fs %s — %s", duration.Seconds(), label, err)))
+		fmt.Fprintf(os.Stderr, "%s%s\n", toolIndent(depth), errorStyle.Styled(fmt.Sprintf("✗ %.1fs %s — %s", duration.Seconds(), label, err)))
 	} else {
-		fmt.Fprintf(os.Stderr, "  %s\n", dimStyle.Styled(fmt.Sprintf("✓ %.1fs %s", duration.Seconds(), label)))
+		fmt.Fprintf(os.Stderr, "%s%s\n", toolIndent(depth), dimStyle.Styled(fmt.Sprintf("✓ %.1fs %s", duration.Seconds(), label)))
 	}
 }
 
+func toolIndent(depth int) string {
+	return strings.Repeat("  ", depth+1)
+}
+
 func toolLabel(tc messages.ChatMessageToolCall) string {
 	summary := summarizeToolArgs(tc.Name, tc.Arguments)
 	if summary == "" {
@@ -53,11 +58,11 @@ func summarizeToolArgs(toolName, argsJSON string) string {
 	switch toolName {
 	case "bash":
 		return summarizeBashCommand(args)
-	case "read":
+	case "read", "read_file":
 		return summarizeReadArgs(args)
-	case "write":
+	case "write", "write_file":
 		return truncate(args.String("file_path"), 120)
-	case "edit":
+	case "edit", "edit_file":
 		return truncate(args.String("file_path"), 120)
 	case "glob":
 		return truncate(args.String("pattern"), 120)
@@ -67,6 +72,8 @@ func summarizeToolArgs(toolName, argsJSON string) string {
 		return truncate(args.String("name"), 120)
 	case "read_skill_file":
 		return summarizeReadSkillFileArgs(args)
+	case "task":
+		return truncate(args.String("prompt"), 120)
 	default:
 		return ""
 	}
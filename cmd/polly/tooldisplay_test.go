@@ -18,6 +18,8 @@ func TestSummarizeToolArgs(t *testing.T) {
 		{"read offset only", "read", `{"file_path":"src/main.go","offset":10}`, "src/main.go (from line 10)"},
 		{"write file", "write", `{"file_path":"out.txt","content":"hello"}`, "out.txt"},
 		{"edit file", "edit", `{"file_path":"src/main.go","old_string":"a","new_string":"b"}`, "src/main.go"},
+		{"read_file tool", "read_file", `{"file_path":"src/main.go","offset":10,"limit":20}`, "src/main.go (lines 10-30)"},
+		{"edit_file tool", "edit_file", `{"file_path":"src/main.go","old_string":"a","new_string":"b"}`, "src/main.go"},
 		{"glob pattern", "glob", `{"pattern":"**/*.go"}`, "**/*.go"},
 		{"grep pattern", "grep", `{"pattern":"func main"}`, "func main"},
 		{"activate skill", "activate_skill", `{"name":"doc"}`, "doc"},
@@ -3,9 +3,19 @@ package main
 import (
 	"fmt"
 
+	"github.com/alexschlessinger/pollytool/llm"
+	"github.com/alexschlessinger/pollytool/sessions"
 	"github.com/alexschlessinger/pollytool/tools"
 )
 
+// registerTaskTool makes the task tool loadable by name, with the sub-agent
+// limits from config
+func registerTaskTool(registry *tools.ToolRegistry, config *Config) {
+	registry.RegisterNative(llm.TaskToolName, func() tools.Tool {
+		return llm.NewTaskTool(config.TaskConfig)
+	})
+}
+
 // loadTools loads tools based on ToolLoaderInfo list
 func loadTools(loaderInfos []tools.ToolLoaderInfo, opts ...tools.RegistryOption) (*tools.ToolRegistry, error) {
 	registry := tools.NewToolRegistry(nil, opts...)
@@ -47,3 +57,23 @@ func loadTools(loaderInfos []tools.ToolLoaderInfo, opts ...tools.RegistryOption)
 
 	return registry, nil
 }
+
+// syncActiveTools persists the registry's tools after an MCP server changed
+// its tool list. Only sources the session already had are kept, so tools
+// activated by skills stay out of ActiveTools.
+func syncActiveTools(session sessions.Session, registry *tools.ToolRegistry) {
+	metadata := session.GetMetadata()
+	sources := make(map[string]bool, len(metadata.ActiveTools))
+	for _, info := range metadata.ActiveTools {
+		sources[info.Source] = true
+	}
+
+	var active []tools.ToolLoaderInfo
+	for _, info := range registry.GetActiveToolLoaders() {
+		if sources[info.Source] {
+			active = append(active, info)
+		}
+	}
+	metadata.ActiveTools = active
+	session.SetMetadata(metadata)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TestHelperMCPServer is not a test: run by the restore test as a stdio MCP
+// server with a tool, a prompt and a resource
+func TestHelperMCPServer(t *testing.T) {
+	if os.Getenv("POLLY_TEST_MCP_SERVER") != "1" {
+		t.Skip("helper process")
+	}
+	server := mcp.NewServer(&mcp.Implementation{Name: "docs", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "lookup"}, func(context.Context, *mcp.CallToolRequest, struct{}) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{}, nil, nil
+	})
+	server.AddPrompt(&mcp.Prompt{Name: "review"}, func(context.Context, *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return &mcp.GetPromptResult{Messages: []*mcp.PromptMessage{
+			{Role: "user", Content: &mcp.TextContent{Text: "review this"}},
+		}}, nil
+	})
+	server.AddResource(&mcp.Resource{URI: "docs://readme", Name: "readme", MIMEType: "text/plain"},
+		func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: req.Params.URI, MIMEType: "text/plain", Text: "hello from the readme"},
+			}}, nil
+		})
+	server.Run(context.Background(), &mcp.StdioTransport{})
+	os.Exit(0)
+}
+
+// TestLoadToolsRestoresMCPServerClients checks that a session's MCP server,
+// restored with only its persisted tools, still serves -f mcp:// and
+// --mcp-prompt
+func TestLoadToolsRestoresMCPServerClients(t *testing.T) {
+	data, err := json.Marshal(tools.MCPServersConfig{MCPServers: map[string]tools.MCPConfig{
+		"docs": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=^TestHelperMCPServer$"},
+			Env:     map[string]string{"POLLY_TEST_MCP_SERVER": "1"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(t.TempDir(), "mcp.json")
+	if err := os.WriteFile(configPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second session's only tool is gone from the server, which still
+	// publishes prompts and resources
+	for _, tool := range []string{"docs__lookup", "docs__removed"} {
+		t.Run(tool, func(t *testing.T) {
+			registry, err := loadTools([]tools.ToolLoaderInfo{
+				{Name: tool, Type: "mcp", Source: configPath + "#docs"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer registry.Close()
+
+			if _, ok := registry.Get("docs__" + tools.ResourceToolName); ok {
+				t.Fatalf("docs__%s was not persisted and should stay unloaded", tools.ResourceToolName)
+			}
+			msgs, err := expandMCPPrompt(context.Background(), registry, "docs/review", nil)
+			if err != nil || len(msgs) != 1 || msgs[0].Content != "review this" {
+				t.Fatalf("expandMCPPrompt() = %+v, %v", msgs, err)
+			}
+			parts, err := processFiles([]string{"mcp://docs/docs://readme"}, registry)
+			if err != nil || len(parts) != 1 || parts[0].Text != "hello from the readme" {
+				t.Fatalf("processFiles() = %+v, %v", parts, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"time"
 
+	"github.com/alexschlessinger/pollytool/llm"
 	"github.com/alexschlessinger/pollytool/sessions"
 )
 
@@ -13,6 +14,7 @@ type Settings struct {
 	Temperature      float64 `json:"temperature,omitempty"`
 	MaxTokens        int     `json:"maxTokens,omitempty"`
 	MaxHistoryTokens int     `json:"maxHistoryTokens,omitempty"` // max tokens for context history
+	Compaction       string  `json:"compaction,omitempty"`       // history compaction strategy: trim or summarize
 	ThinkingEffort   string  `json:"thinkingEffort,omitempty"`
 	SystemPrompt     string  `json:"systemPrompt,omitempty"`
 
@@ -22,6 +24,9 @@ type Settings struct {
 
 	// Skill configuration
 	SkillDirs []string `json:"skillDirs,omitempty"`
+
+	// Spending limit for the whole context in USD
+	Budget float64 `json:"budget,omitempty"`
 }
 
 // Config holds all configuration from command-line flags
@@ -29,11 +34,16 @@ type Config struct {
 	Settings // Embed the shared settings
 
 	// Runtime configuration
-	Timeout       time.Duration
-	MaxIterations int
-	BaseURL       string
-	Confirm       bool
-	NoSandbox     bool
+	Timeout        time.Duration
+	MaxIterations  int
+	FallbackModels []string // Models tried in order when the model fails with a retryable error
+	RequestBudget  float64  // Maximum USD spend per request (0 = unlimited)
+	BaseURL        string
+	Confirm        bool
+	Plan           bool // Plan each turn with tools disabled and run the steps once approved
+	NoSandbox      bool
+	MaxToolOutput  int            // Bytes of a tool result sent to the model (0 = unlimited)
+	TaskConfig     llm.TaskConfig // Limits for sub-agents started by the task tool
 
 	// Skill configuration
 	NoSkills   bool
@@ -43,6 +53,7 @@ type Config struct {
 	ContextID      string
 	ResetContext   string // Reset this context (clear history, keep settings)
 	UseLastContext bool   // New field for --last flag
+	Resume         bool   // Continue the context's stopped run instead of starting a turn
 	ListContexts   bool
 	DeleteContext  string
 	AddToContext   bool
@@ -54,12 +65,22 @@ type Config struct {
 	Prompt     string
 	Files      []string // Files/images to include
 	SchemaPath string   // Path to JSON schema file
+	Output     string   // Output format: text or jsonl
 	Quiet      bool
 	Debug      bool
 
 	// Temporary storage for command line tools (before conversion to ActiveTools)
 	Tools []string
 
+	// MCP prompt template expanded ahead of the user message
+	MCPPrompt   string   // server/name
+	PromptArgs  []string // key=value arguments for the prompt
+	ListPrompts bool
+
+	// MCP sampling: completions servers request from polly
+	SamplingModels    []string // Models servers may pick; empty = session model only
+	SamplingMaxTokens int      // Token cap per request (0 = sampling disabled)
+
 	// Skills to load directly (local paths or URLs, auto-activated)
 	Skills []string
 }
@@ -70,9 +91,11 @@ func (s Settings) ToMetadataSettings(m *sessions.Metadata) {
 	m.Temperature = s.Temperature
 	m.MaxTokens = s.MaxTokens
 	m.MaxHistoryTokens = s.MaxHistoryTokens
+	m.Compaction = s.Compaction
 	m.ThinkingEffort = s.ThinkingEffort
 	m.SystemPrompt = s.SystemPrompt
 	m.MaxIterations = s.MaxIterations
 	m.ToolTimeout = s.ToolTimeout
 	m.SkillDirs = s.SkillDirs
+	m.Budget = s.Budget
 }
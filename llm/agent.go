@@ -6,9 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"time"
 
+	"github.com/alexschlessinger/pollytool/audit"
 	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
 	"github.com/alexschlessinger/pollytool/tools"
 	"golang.org/x/sync/errgroup"
 )
@@ -16,9 +19,10 @@ import (
 // Agent handles the agentic loop without owning session state.
 // It executes completions with automatic tool call handling.
 type Agent struct {
-	client LLM
-	tools  *tools.ToolRegistry
-	config AgentConfig
+	client     LLM
+	tools      *tools.ToolRegistry
+	config     AgentConfig
+	outputTool tools.Tool // read_tool_output, offered when MaxToolOutput is set
 }
 
 // AgentConfig configures agent behavior
@@ -27,8 +31,33 @@ type AgentConfig struct {
 	ToolTimeout      time.Duration // Per-tool execution timeout (0 = no timeout)
 	MaxParallelTools int           // Maximum parallel tool executions (0 = unlimited)
 	ResponseTool     string        // If set, require final response via this tool
+	Pricing          *PricingTable // Prices for AgentResponse.Cost (nil = no cost tracking)
+	Budget           float64       // Maximum USD spend per Run (0 = unlimited); requires Pricing
+	MaxToolOutput    int           // Per-tool result cap in bytes; longer results are truncated and saved for read_tool_output (0 = unlimited)
+	TokenBudget      int           // Maximum input+output tokens per Run (0 = unlimited)
+
+	// Permissions decides each tool call before ApproveToolCalls sees it:
+	// allowed calls run, denied calls are refused, and only the calls it
+	// says to ask about go to ApproveToolCalls (refused when that is nil).
+	// When nil, every call goes to ApproveToolCalls.
+	Permissions *tools.PermissionPolicy
+
+	// Audit, when set, gets a record of every tool call the agent
+	// executes, tagged with ContextName.
+	Audit       audit.Sink
+	ContextName string
+
+	// ExternalCost, when set, returns what was spent on the run's behalf
+	// outside its own LLM calls since it was last called, such as MCP
+	// servers' sampling requests. It counts toward Cost and Budget.
+	ExternalCost func() float64
 }
 
+// ErrBudgetExceeded is returned by Run when the next LLM call would push the
+// run's spend past AgentConfig.Budget, or once the run has used up
+// AgentConfig.TokenBudget.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
 // AgentCallbacks provides hooks for observing and customizing agent execution
 type AgentCallbacks struct {
 	// OnReasoning is called when reasoning/thinking content is streamed
@@ -46,26 +75,44 @@ type AgentCallbacks struct {
 	// OnToolStart is called once before parallel tool execution begins with all tool calls
 	OnToolStart func(calls []messages.ChatMessageToolCall)
 
-	// ApproveToolCalls is called before parallel execution with all pending tool calls.
-	// Returns a bool slice indicating which tools are approved.
-	// If nil, all tools are approved.
+	// ApproveToolCalls is called before parallel execution with the pending
+	// tool calls that need approval: all of them, or those AgentConfig.Permissions
+	// says to ask about. Returns a bool slice indicating which are approved.
+	// If nil, all tools are approved unless Permissions says to ask.
 	ApproveToolCalls func(calls []messages.ChatMessageToolCall) []bool
 
+	// OnToolProgress is called with progress and log updates from running
+	// tools that report them, such as MCP tools. Calls may be concurrent.
+	OnToolProgress func(call messages.ChatMessageToolCall, progress tools.ToolProgress)
+
 	// OnToolEnd is called after each tool executes
 	OnToolEnd func(call messages.ChatMessageToolCall, result string, duration time.Duration, err error)
 
+	// OnResponse is called after each LLM call completes, before any of its
+	// tool calls run. The message carries token usage and the stop reason.
+	OnResponse func(response *messages.ChatMessage)
+
 	// OnComplete is called when the final response is ready (no more tool calls)
 	OnComplete func(response *messages.ChatMessage)
 
 	// OnError is called when an error occurs
 	OnError func(err error)
+
+	// OnSubAgentEvent is called with the events of sub-agents started by
+	// the task tool, at any depth. Their events reach no other hook, except
+	// that ApproveToolCalls, BeforeToolExecute and OnToolProgress apply to
+	// their tool calls as to the parent's.
+	OnSubAgentEvent func(event SubAgentEvent)
 }
 
 // AgentResponse contains the results after Run completes
 type AgentResponse struct {
 	Message        *messages.ChatMessage  // Final assistant message (no tool calls)
 	AllMessages    []messages.ChatMessage // All messages generated (assistant + tool results)
-	IterationCount int                    // Number of LLM calls made
+	IterationCount int                    // Number of LLM calls made, including those before a Resume
+	Cost           float64                // USD spent on LLM calls during the run
+	State          *sessions.RunState     // Set when the run stopped early; pass to Resume to continue
+	SubAgents      []sessions.SubAgentRun // Transcripts of sub-agents started by the task tool
 }
 
 func hasToolCall(msg *messages.ChatMessage, name string) bool {
@@ -84,11 +131,36 @@ func NewAgent(client LLM, registry *tools.ToolRegistry, config AgentConfig) *Age
 	if config.MaxIterations <= 0 {
 		config.MaxIterations = 10
 	}
-	return &Agent{
+	agent := &Agent{
 		client: client,
 		tools:  registry,
 		config: config,
 	}
+	if config.MaxToolOutput > 0 {
+		agent.outputTool = tools.NewReadToolOutputTool()
+	}
+	return agent
+}
+
+// WithBudget returns a copy of the agent that limits each Run to budget USD
+// (0 = unlimited). The copy shares the client and tool registry.
+func (a *Agent) WithBudget(budget float64) *Agent {
+	clone := *a
+	clone.config.Budget = budget
+	return &clone
+}
+
+// WithContextName returns a copy of the agent whose audit records name the
+// context name. The copy shares the client and tool registry.
+func (a *Agent) WithContextName(name string) *Agent {
+	clone := *a
+	clone.config.ContextName = name
+	return &clone
+}
+
+// Client returns the LLM the agent sends completions to.
+func (a *Agent) Client() LLM {
+	return a.client
 }
 
 // Run executes a completion with automatic tool call handling.
@@ -99,7 +171,41 @@ func NewAgent(client LLM, registry *tools.ToolRegistry, config AgentConfig) *Age
 // all generated messages (assistant responses + tool results) in
 // AgentResponse.AllMessages. The caller is responsible for adding
 // these to their session.
+//
+// When the run stops early (max iterations, cancellation, an LLM error or
+// the budget) it returns the partial response alongside the error, with
+// State describing where it stopped.
 func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallbacks) (*AgentResponse, error) {
+	return a.run(ctx, req, nil, cb)
+}
+
+// Resume continues a run that stopped early from the State of its
+// response. req.Messages must include the messages the stopped run
+// generated. Tool calls left pending run first, then the loop carries on
+// counting iterations from where it stopped, with up to MaxIterations more
+// LLM calls.
+func (a *Agent) Resume(ctx context.Context, req *CompletionRequest, state *sessions.RunState, cb *AgentCallbacks) (*AgentResponse, error) {
+	if state == nil {
+		return nil, errors.New("no run state to resume")
+	}
+	return a.run(ctx, req, state, cb)
+}
+
+// run makes the run visible to the tools it executes, so the task tool can
+// start sub-agents from it, then runs the loop.
+func (a *Agent) run(ctx context.Context, req *CompletionRequest, state *sessions.RunState, cb *AgentCallbacks) (*AgentResponse, error) {
+	run := &agentRun{agent: a, req: *req, cb: cb}
+	if parent, ok := ctx.Value(agentRunKey{}).(*agentRun); ok {
+		run.depth = parent.depth + 1
+	}
+	resp, err := a.loop(context.WithValue(ctx, agentRunKey{}, run), run, req, state, cb)
+	if resp != nil {
+		resp.SubAgents = run.subAgents
+	}
+	return resp, err
+}
+
+func (a *Agent) loop(ctx context.Context, run *agentRun, req *CompletionRequest, state *sessions.RunState, cb *AgentCallbacks) (*AgentResponse, error) {
 	// Work with a copy of messages - don't mutate input
 	msgs := make([]messages.ChatMessage, len(req.Messages))
 	copy(msgs, req.Messages)
@@ -114,14 +220,48 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 	}
 
 	var allGenerated []messages.ChatMessage
+	var cost float64
+	var tokens int
 	var nudgedResponseTool bool
 	var responseToolCalled bool
 
-	for iteration := 0; iteration < a.config.MaxIterations; iteration++ {
+	start := 0
+	if state != nil {
+		start = state.Iteration
+		if len(state.PendingToolCalls) > 0 {
+			toolMsgs, pending, err := a.runToolCalls(ctx, state.PendingToolCalls, cb)
+			cost += run.takeSubAgentCost() + a.takeExternalCost()
+			msgs = append(msgs, toolMsgs...)
+			allGenerated = append(allGenerated, toolMsgs...)
+			if err != nil {
+				return stoppedResponse(allGenerated, start, cost, pending, err), err
+			}
+			// A pending response tool call finishes the run, as in the loop
+			if a.config.ResponseTool != "" && slices.ContainsFunc(state.PendingToolCalls, func(tc messages.ChatMessageToolCall) bool {
+				return tc.Name == a.config.ResponseTool
+			}) {
+				for i := len(msgs) - 1; i >= 0; i-- {
+					if hasToolCall(&msgs[i], a.config.ResponseTool) {
+						if cb != nil && cb.OnComplete != nil {
+							cb.OnComplete(&msgs[i])
+						}
+						return &AgentResponse{
+							Message:        &msgs[i],
+							AllMessages:    allGenerated,
+							IterationCount: start,
+							Cost:           cost,
+						}, nil
+					}
+				}
+			}
+		}
+	}
+
+	for iteration := start; iteration < start+a.config.MaxIterations; iteration++ {
 		// Check for context cancellation
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return stoppedResponse(allGenerated, iteration, cost, nil, ctx.Err()), ctx.Err()
 		default:
 		}
 
@@ -129,7 +269,27 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 		iterReq := loopReq
 		iterReq.Messages = msgs
 		if a.tools != nil {
+			// Pick up tool changes staged since the last iteration, such as
+			// an MCP server's refreshed tool list
+			a.tools.CommitPendingChanges()
 			iterReq.Tools = a.tools.All()
+			if a.outputTool != nil {
+				if _, exists := a.tools.Get(tools.ReadToolOutputName); !exists {
+					iterReq.Tools = append(iterReq.Tools, a.outputTool)
+				}
+			}
+		}
+
+		// Refuse the call if its prompt alone would exceed the budget
+		err := a.checkBudget(iterReq.Model, msgs, cost)
+		if err == nil && a.config.TokenBudget > 0 && tokens >= a.config.TokenBudget {
+			err = fmt.Errorf("%w: used %d of %d tokens", ErrBudgetExceeded, tokens, a.config.TokenBudget)
+		}
+		if err != nil {
+			if cb != nil && cb.OnError != nil {
+				cb.OnError(err)
+			}
+			return stoppedResponse(allGenerated, iteration, cost, nil, err), err
 		}
 
 		// Stream completion
@@ -140,7 +300,12 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 		// Process events
 		response, err := a.processEvents(ctx, events, cb)
 		if err != nil {
-			return nil, err
+			return stoppedResponse(allGenerated, iteration, cost, nil, err), err
+		}
+		cost += a.callCost(iterReq.Model, response)
+		tokens += response.GetInputTokens() + response.GetOutputTokens()
+		if cb != nil && cb.OnResponse != nil {
+			cb.OnResponse(response)
 		}
 
 		// Ensure content is never null — some providers reject null content in history
@@ -171,6 +336,7 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 				Message:        response,
 				AllMessages:    allGenerated,
 				IterationCount: iteration + 1,
+				Cost:           cost,
 			}, nil
 
 		case messages.StopReasonMaxTokens:
@@ -183,6 +349,7 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 				Message:        response,
 				AllMessages:    allGenerated,
 				IterationCount: iteration + 1,
+				Cost:           cost,
 			}, nil
 
 		case messages.StopReasonContentFilter:
@@ -224,6 +391,7 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 					Message:        response,
 					AllMessages:    allGenerated,
 					IterationCount: iteration + 1,
+					Cost:           cost,
 				}, nil
 			}
 			// Has tool calls, continue to execute them
@@ -240,15 +408,13 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 		}
 
 		// Execute tool calls in parallel
-		toolMsgs, err := a.executeToolsParallel(ctx, response.ToolCalls, cb)
-		if err != nil {
-			return nil, err
-		}
-		if a.tools != nil {
-			a.tools.CommitPendingChanges()
-		}
+		toolMsgs, pending, err := a.runToolCalls(ctx, response.ToolCalls, cb)
+		cost += run.takeSubAgentCost() + a.takeExternalCost()
 		msgs = append(msgs, toolMsgs...)
 		allGenerated = append(allGenerated, toolMsgs...)
+		if err != nil {
+			return stoppedResponse(allGenerated, iteration+1, cost, pending, err), err
+		}
 
 		// Short-circuit when the response tool was called: the caller
 		// extracts the structured response from the tool call's arguments,
@@ -262,6 +428,7 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 				Message:        response,
 				AllMessages:    allGenerated,
 				IterationCount: iteration + 1,
+				Cost:           cost,
 			}, nil
 		}
 	}
@@ -271,11 +438,93 @@ func (a *Agent) Run(ctx context.Context, req *CompletionRequest, cb *AgentCallba
 		cb.OnError(err)
 	}
 	// Return the partial response so the caller can save the history
+	return stoppedResponse(allGenerated, start+a.config.MaxIterations, cost, nil, err), err
+}
+
+// stoppedResponse is the partial response of a run that stopped after
+// iteration LLM calls, with the state Resume needs to continue it.
+func stoppedResponse(allGenerated []messages.ChatMessage, iteration int, cost float64, pending []messages.ChatMessageToolCall, reason error) *AgentResponse {
+	var last *messages.ChatMessage
+	if len(allGenerated) > 0 {
+		last = &allGenerated[len(allGenerated)-1]
+	}
 	return &AgentResponse{
-		Message:        &msgs[len(msgs)-1], // Last message
+		Message:        last,
 		AllMessages:    allGenerated,
-		IterationCount: a.config.MaxIterations,
-	}, err
+		IterationCount: iteration,
+		Cost:           cost,
+		State: &sessions.RunState{
+			Iteration:        iteration,
+			PendingToolCalls: pending,
+			Reason:           reason.Error(),
+		},
+	}
+}
+
+// runToolCalls executes calls and returns the results of those that ran.
+// If ctx is cancelled partway, the calls left without a result are
+// returned as pending along with the error.
+func (a *Agent) runToolCalls(ctx context.Context, calls []messages.ChatMessageToolCall, cb *AgentCallbacks) ([]messages.ChatMessage, []messages.ChatMessageToolCall, error) {
+	results, err := a.executeToolsParallel(ctx, calls, cb)
+	if a.tools != nil {
+		a.tools.CommitPendingChanges()
+	}
+	if err == nil {
+		return results, nil, nil
+	}
+
+	var done []messages.ChatMessage
+	var pending []messages.ChatMessageToolCall
+	for i, result := range results {
+		if result.Role == "" {
+			pending = append(pending, calls[i])
+		} else {
+			done = append(done, result)
+		}
+	}
+	return done, pending, err
+}
+
+// takeExternalCost returns the spend AgentConfig.ExternalCost reports
+func (a *Agent) takeExternalCost() float64 {
+	if a.config.ExternalCost == nil {
+		return 0
+	}
+	return a.config.ExternalCost()
+}
+
+// callCost prices a completed call using the model that actually answered.
+func (a *Agent) callCost(requested string, response *messages.ChatMessage) float64 {
+	model := requested
+	if answered := response.GetModel(); answered != "" {
+		model = answered
+	}
+	cost, _ := a.config.Pricing.Cost(model, response.GetInputTokens(), response.GetOutputTokens())
+	return cost
+}
+
+// checkBudget estimates the prompt cost of the next call and fails if it
+// would take the run past the budget. Output tokens are unknown up front,
+// so a call may still overshoot; the following call is then refused.
+func (a *Agent) checkBudget(model string, msgs []messages.ChatMessage, spent float64) error {
+	if a.config.Budget <= 0 {
+		return nil
+	}
+	price, ok := a.config.Pricing.Lookup(model)
+	if !ok {
+		return fmt.Errorf("cannot enforce budget: no price for model %s (add it to the pricing table)", model)
+	}
+
+	counter := sessions.TokenCounterForModel(model)
+	tokens := 0
+	for _, msg := range msgs {
+		tokens += sessions.CountTokens(msg, counter)
+	}
+	next := price.Cost(tokens, 0)
+	if spent+next > a.config.Budget {
+		return fmt.Errorf("%w: spent $%.4f of $%.2f, next call to %s needs about $%.4f", ErrBudgetExceeded, spent, a.config.Budget, model, next)
+	}
+	return nil
 }
 
 // processEvents processes the event stream and returns the final message
@@ -316,7 +565,7 @@ func (a *Agent) processEvents(ctx context.Context, events <-chan *messages.Strea
 }
 
 // executeTool executes a single tool call and returns the result message
-func (a *Agent) executeTool(ctx context.Context, tc messages.ChatMessageToolCall, cb *AgentCallbacks) messages.ChatMessage {
+func (a *Agent) executeTool(ctx context.Context, tc messages.ChatMessageToolCall, perm toolPermission, cb *AgentCallbacks) (messages.ChatMessage, error) {
 	// Parse args early so we can pass them to BeforeToolExecute
 	var args map[string]any
 	if err := json.Unmarshal([]byte(tc.Arguments), &args); err != nil {
@@ -328,11 +577,26 @@ func (a *Agent) executeTool(ctx context.Context, tc messages.ChatMessageToolCall
 	if cb != nil && cb.BeforeToolExecute != nil {
 		execCtx = cb.BeforeToolExecute(ctx, tc, args)
 	}
+	if cb != nil && cb.OnToolProgress != nil {
+		execCtx = tools.WithProgressReporter(execCtx, func(p tools.ToolProgress) {
+			cb.OnToolProgress(tc, p)
+		})
+	}
+	if a.config.MaxToolOutput > 0 {
+		execCtx = tools.WithOutputLimit(execCtx, a.config.MaxToolOutput)
+	}
+	execCtx = context.WithValue(execCtx, toolCallKey{}, tc)
 
 	start := time.Now()
-	result, err := a.executeToolCall(execCtx, tc, args)
+	result, err := a.executeToolCall(execCtx, tc, args, perm)
 	duration := time.Since(start)
 
+	// read_tool_output pages within the cap itself, so its results are
+	// never saved again
+	if tc.Name != tools.ReadToolOutputName {
+		result = tools.CapOutput(result, a.config.MaxToolOutput)
+	}
+
 	if cb != nil && cb.OnToolEnd != nil {
 		cb.OnToolEnd(tc, result, duration, err)
 	}
@@ -342,13 +606,23 @@ func (a *Agent) executeTool(ctx context.Context, tc messages.ChatMessageToolCall
 		Content:    result,
 		ToolCallID: tc.ID,
 		ToolName:   tc.Name,
-	}
+	}, err
 }
 
-// executeToolCall performs the actual tool execution
-func (a *Agent) executeToolCall(ctx context.Context, tc messages.ChatMessageToolCall, args map[string]any) (string, error) {
-	// Apply timeout
-	if a.config.ToolTimeout > 0 {
+// executeToolCall performs the actual tool execution and records it in the
+// audit log
+func (a *Agent) executeToolCall(ctx context.Context, tc messages.ChatMessageToolCall, args map[string]any, perm toolPermission) (result string, err error) {
+	var tool tools.Tool
+	if a.config.Audit != nil {
+		start := time.Now()
+		defer func() {
+			a.audit(tc, tool, perm, result, start, err)
+		}()
+	}
+
+	// Apply timeout. A sub-agent is bounded by its own iteration and token
+	// budgets instead, and each of its tool calls by this timeout.
+	if a.config.ToolTimeout > 0 && tc.Name != TaskToolName {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, a.config.ToolTimeout)
 		defer cancel()
@@ -368,7 +642,11 @@ func (a *Agent) executeToolCall(ctx context.Context, tc messages.ChatMessageTool
 		return errMsg, errors.New("no tool registry")
 	}
 
-	tool, exists, allowed := a.tools.GetIfAllowed(tc.Name)
+	var exists, allowed bool
+	tool, exists, allowed = a.tools.GetIfAllowed(tc.Name)
+	if !exists && tc.Name == tools.ReadToolOutputName && a.outputTool != nil {
+		tool, exists, allowed = a.outputTool, true, true
+	}
 	if !exists {
 		errMsg := fmt.Sprintf("Tool not found: %s", tc.Name)
 		return errMsg, errors.New("tool not found: " + tc.Name)
@@ -379,7 +657,7 @@ func (a *Agent) executeToolCall(ctx context.Context, tc messages.ChatMessageTool
 	}
 
 	// Execute
-	result, err := tool.Execute(ctx, args)
+	result, err = tool.Execute(ctx, args)
 	if err != nil {
 		if msg, ok := tools.FormatToolError(err); ok {
 			return msg, err
@@ -404,21 +682,15 @@ func (a *Agent) executeToolsParallel(ctx context.Context, toolCalls []messages.C
 	results := make([]messages.ChatMessage, len(toolCalls))
 
 	// Determine which tools are approved
-	approved := make([]bool, len(toolCalls))
-	for i := range approved {
-		approved[i] = true
-	}
-	if cb != nil && cb.ApproveToolCalls != nil {
-		approved = cb.ApproveToolCalls(toolCalls)
-	}
+	perms := a.checkPermissions(toolCalls, cb)
 
 	// Fill in denied results immediately
 	var approvedIndices []int
 	for i, tc := range toolCalls {
-		if !approved[i] {
+		if perms[i].denial != "" {
 			results[i] = messages.ChatMessage{
 				Role:       messages.MessageRoleTool,
-				Content:    "Tool call denied by user.",
+				Content:    perms[i].denial,
 				ToolCallID: tc.ID,
 				ToolName:   tc.Name,
 			}
@@ -437,7 +709,7 @@ func (a *Agent) executeToolsParallel(ctx context.Context, toolCalls []messages.C
 
 	for _, idx := range approvedIndices {
 		idx := idx
-		tc := toolCalls[idx]
+		tc, perm := toolCalls[idx], perms[idx]
 		g.Go(func() error {
 			// Acquire semaphore (respects context cancellation)
 			select {
@@ -447,7 +719,15 @@ func (a *Agent) executeToolsParallel(ctx context.Context, toolCalls []messages.C
 				return ctx.Err()
 			}
 
-			results[idx] = a.executeTool(ctx, tc, cb)
+			result, err := a.executeTool(ctx, tc, perm, cb)
+			// A tool cut off by cancellation has no real result; leave it
+			// empty so the call stays pending for a resumed run. Its error
+			// is whatever the tool made of it, such as a killed command.
+			// Tools that finished keep their results so they don't run twice.
+			if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+				return ctxErr
+			}
+			results[idx] = result
 			return nil
 		})
 	}
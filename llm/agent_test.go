@@ -2,6 +2,9 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/alexschlessinger/pollytool/messages"
@@ -217,3 +220,302 @@ func TestAgentResponseToolShortCircuit(t *testing.T) {
 		t.Fatalf("expected IterationCount=1, got %d", resp.IterationCount)
 	}
 }
+
+// TestAgentOnResponse: OnResponse fires once per LLM call, including calls
+// that only request tools.
+func TestAgentOnResponse(t *testing.T) {
+	fake := &sequentialLLM{
+		responses: []messages.ChatMessage{
+			{
+				Role: messages.MessageRoleAssistant,
+				ToolCalls: []messages.ChatMessageToolCall{
+					{ID: "tc1", Name: "missing", Arguments: `{}`},
+				},
+				StopReason: messages.StopReasonToolUse,
+			},
+			{
+				Role:       messages.MessageRoleAssistant,
+				Content:    "done",
+				StopReason: messages.StopReasonEndTurn,
+			},
+		},
+	}
+
+	var reasons []messages.StopReason
+	agent := NewAgent(fake, tools.NewToolRegistry(nil), AgentConfig{MaxIterations: 5})
+	_, err := agent.Run(context.Background(), &CompletionRequest{
+		Messages: messages.User("hi"),
+	}, &AgentCallbacks{
+		OnResponse: func(response *messages.ChatMessage) {
+			reasons = append(reasons, response.StopReason)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reasons) != 2 || reasons[0] != messages.StopReasonToolUse || reasons[1] != messages.StopReasonEndTurn {
+		t.Fatalf("OnResponse stop reasons = %v", reasons)
+	}
+}
+
+// TestAgentOnToolProgress: updates a tool reports reach OnToolProgress with
+// the call that produced them.
+func TestAgentOnToolProgress(t *testing.T) {
+	fake := &sequentialLLM{
+		responses: []messages.ChatMessage{
+			{
+				Role: messages.MessageRoleAssistant,
+				ToolCalls: []messages.ChatMessageToolCall{
+					{ID: "tc1", Name: "index", Arguments: `{}`},
+				},
+				StopReason: messages.StopReasonToolUse,
+			},
+		},
+	}
+	indexTool := &tools.Func{
+		Name: "index",
+		Run: func(ctx context.Context, _ tools.Args) (string, error) {
+			tools.ReportProgress(ctx, tools.ToolProgress{Progress: 1, Total: 2, Message: "half"})
+			return "ok", nil
+		},
+	}
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{indexTool}), AgentConfig{MaxIterations: 3})
+
+	var got []tools.ToolProgress
+	var callID string
+	_, err := agent.Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, &AgentCallbacks{
+		OnToolProgress: func(call messages.ChatMessageToolCall, progress tools.ToolProgress) {
+			callID = call.ID
+			got = append(got, progress)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Message != "half" || callID != "tc1" {
+		t.Fatalf("progress = %+v from %q", got, callID)
+	}
+}
+
+// requestLLM answers each call with the message its function builds from
+// the request.
+type requestLLM func(req *CompletionRequest) messages.ChatMessage
+
+func (f requestLLM) ChatCompletionStream(_ context.Context, req *CompletionRequest, processor EventStreamProcessor) <-chan *messages.StreamEvent {
+	msgChan := make(chan messages.ChatMessage, 1)
+	msgChan <- f(req)
+	close(msgChan)
+	return processor.ProcessMessagesToEvents(msgChan)
+}
+
+// TestAgentCapsToolOutput: a result over MaxToolOutput reaches the model
+// truncated, and read_tool_output pages through the full text.
+func TestAgentCapsToolOutput(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	full := strings.Repeat("a", 3000) + strings.Repeat("b", 3000)
+	dumpTool := &tools.Func{
+		Name: "dump",
+		Run: func(context.Context, tools.Args) (string, error) {
+			return full, nil
+		},
+	}
+
+	idPattern := regexp.MustCompile(`id "(out-[0-9a-f]+)"`)
+	var truncated string
+	var offered bool
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		last := req.Messages[len(req.Messages)-1]
+		switch last.Role {
+		case messages.MessageRoleUser:
+			return messages.ChatMessage{
+				Role:       messages.MessageRoleAssistant,
+				ToolCalls:  []messages.ChatMessageToolCall{{ID: "tc1", Name: "dump", Arguments: `{}`}},
+				StopReason: messages.StopReasonToolUse,
+			}
+		case messages.MessageRoleTool:
+			if last.ToolName == "dump" {
+				truncated = last.Content
+				for _, tool := range req.Tools {
+					offered = offered || tool.GetName() == tools.ReadToolOutputName
+				}
+				if m := idPattern.FindStringSubmatch(last.Content); m != nil {
+					return messages.ChatMessage{
+						Role:       messages.MessageRoleAssistant,
+						ToolCalls:  []messages.ChatMessageToolCall{{ID: "tc2", Name: tools.ReadToolOutputName, Arguments: `{"id":"` + m[1] + `","offset":2500}`}},
+						StopReason: messages.StopReasonToolUse,
+					}
+				}
+			}
+		}
+		return messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "done", StopReason: messages.StopReasonEndTurn}
+	})
+
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{dumpTool}), AgentConfig{MaxIterations: 5, MaxToolOutput: 1000})
+	resp, err := agent.Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(truncated) > 1000 || !strings.HasPrefix(truncated, "aaa") || !strings.HasSuffix(truncated, "bbb") || !strings.Contains(truncated, "bytes omitted") {
+		t.Errorf("truncated result (%d bytes) = %q", len(truncated), truncated)
+	}
+	if !offered {
+		t.Error("read_tool_output was not offered to the model")
+	}
+
+	var page string
+	for _, msg := range resp.AllMessages {
+		if msg.ToolName == tools.ReadToolOutputName {
+			page = msg.Content
+		}
+	}
+	want := strings.Repeat("a", 500) + strings.Repeat("b", 500) + "\n\n[bytes 2500-3500 of 6000; continue with offset=3500]"
+	if page != want {
+		t.Errorf("read_tool_output page = %q, want %q", page, want)
+	}
+}
+
+func TestAgentResumeRunsPendingToolCalls(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs int
+	slowTool := &tools.Func{
+		Name: "slow",
+		Run: func(ctx context.Context, _ tools.Args) (string, error) {
+			runs++
+			if runs == 1 {
+				// Interrupted the first time it runs
+				cancel()
+				<-ctx.Done()
+				return "", ctx.Err()
+			}
+			return "finished", nil
+		},
+	}
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		if last := req.Messages[len(req.Messages)-1]; last.Role == messages.MessageRoleTool {
+			return messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "done: " + last.Content, StopReason: messages.StopReasonEndTurn}
+		}
+		return messages.ChatMessage{
+			Role:       messages.MessageRoleAssistant,
+			ToolCalls:  []messages.ChatMessageToolCall{{ID: "tc1", Name: "slow", Arguments: `{}`}},
+			StopReason: messages.StopReasonToolUse,
+		}
+	})
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{slowTool}), AgentConfig{MaxIterations: 5})
+
+	history := messages.User("hi")
+	resp, err := agent.Run(ctx, &CompletionRequest{Messages: history}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+	if resp == nil || resp.State == nil {
+		t.Fatalf("Run() = %+v, want a resumable partial response", resp)
+	}
+	if len(resp.AllMessages) != 1 || len(resp.AllMessages[0].ToolCalls) != 1 {
+		t.Errorf("AllMessages = %+v, want only the assistant's tool call", resp.AllMessages)
+	}
+	if resp.State.Iteration != 1 || len(resp.State.PendingToolCalls) != 1 || resp.State.PendingToolCalls[0].ID != "tc1" {
+		t.Errorf("State = %+v, want iteration 1 with tc1 pending", resp.State)
+	}
+
+	history = append(history, resp.AllMessages...)
+	resumed, err := agent.Resume(context.Background(), &CompletionRequest{Messages: history}, resp.State, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.State != nil {
+		t.Errorf("finished run has State %+v", resumed.State)
+	}
+	if resumed.Message.Content != "done: finished" || resumed.IterationCount != 2 {
+		t.Errorf("Resume() = %q after %d iterations, want the pending call's result after 2", resumed.Message.Content, resumed.IterationCount)
+	}
+	if len(resumed.AllMessages) != 2 || resumed.AllMessages[0].ToolCallID != "tc1" {
+		t.Errorf("resumed AllMessages = %+v, want tc1's result then the answer", resumed.AllMessages)
+	}
+}
+
+func TestAgentCancelKeepsFinishedToolResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started, cancelled := make(chan struct{}), make(chan struct{})
+	var fastRuns int
+	// fast finishes after the cancel, slow is cut off by it
+	fast := &tools.Func{
+		Name: "fast",
+		Run: func(context.Context, tools.Args) (string, error) {
+			fastRuns++
+			close(started)
+			<-cancelled
+			return "quick", nil
+		},
+	}
+	slow := &tools.Func{
+		Name: "slow",
+		Run: func(ctx context.Context, _ tools.Args) (string, error) {
+			<-started
+			cancel()
+			close(cancelled)
+			<-ctx.Done()
+			return "", errors.New("signal: killed")
+		},
+	}
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		return messages.ChatMessage{
+			Role: messages.MessageRoleAssistant,
+			ToolCalls: []messages.ChatMessageToolCall{
+				{ID: "tc1", Name: "fast", Arguments: `{}`},
+				{ID: "tc2", Name: "slow", Arguments: `{}`},
+			},
+			StopReason: messages.StopReasonToolUse,
+		}
+	})
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{fast, slow}), AgentConfig{MaxIterations: 5})
+
+	resp, err := agent.Run(ctx, &CompletionRequest{Messages: messages.User("hi")}, nil)
+	if !errors.Is(err, context.Canceled) || resp == nil || resp.State == nil {
+		t.Fatalf("Run() = %+v, %v; want a resumable partial response", resp, err)
+	}
+	if len(resp.AllMessages) != 2 || resp.AllMessages[1].ToolCallID != "tc1" || resp.AllMessages[1].Content != "quick" {
+		t.Errorf("AllMessages = %+v, want the tool calls and fast's result", resp.AllMessages)
+	}
+	if len(resp.State.PendingToolCalls) != 1 || resp.State.PendingToolCalls[0].ID != "tc2" {
+		t.Errorf("PendingToolCalls = %+v, want only the cut off tc2", resp.State.PendingToolCalls)
+	}
+	if fastRuns != 1 {
+		t.Errorf("fast ran %d times", fastRuns)
+	}
+}
+
+func TestAgentResumeAfterMaxIterations(t *testing.T) {
+	calls := 0
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		calls++
+		if calls < 3 {
+			return messages.ChatMessage{
+				Role:       messages.MessageRoleAssistant,
+				ToolCalls:  []messages.ChatMessageToolCall{{ID: "tc", Name: "noop", Arguments: `{}`}},
+				StopReason: messages.StopReasonToolUse,
+			}
+		}
+		return messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "done", StopReason: messages.StopReasonEndTurn}
+	})
+	noop := &tools.Func{Name: "noop", Run: func(context.Context, tools.Args) (string, error) { return "ok", nil }}
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{noop}), AgentConfig{MaxIterations: 2})
+
+	history := messages.User("hi")
+	resp, err := agent.Run(context.Background(), &CompletionRequest{Messages: history}, nil)
+	if err == nil || resp == nil || resp.State == nil {
+		t.Fatalf("Run() = %+v, %v; want max iterations with a run state", resp, err)
+	}
+	if resp.State.Iteration != 2 || len(resp.State.PendingToolCalls) != 0 {
+		t.Errorf("State = %+v, want iteration 2 with nothing pending", resp.State)
+	}
+
+	history = append(history, resp.AllMessages...)
+	resumed, err := agent.Resume(context.Background(), &CompletionRequest{Messages: history}, resp.State, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Message.Content != "done" || resumed.IterationCount != 3 || calls != 3 {
+		t.Errorf("Resume() = %q after %d iterations (%d calls), want done after 3", resumed.Message.Content, resumed.IterationCount, calls)
+	}
+}
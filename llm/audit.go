@@ -0,0 +1,40 @@
+package llm
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/audit"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+// audit writes the record of a tool call started at start to the audit
+// sink. tool is nil when the call never found its tool. A record that
+// cannot be written is logged rather than failing the call.
+func (a *Agent) audit(tc messages.ChatMessageToolCall, tool tools.Tool, perm toolPermission, result string, start time.Time, err error) {
+	rec := audit.Record{
+		Time:       start.UTC(),
+		Context:    a.config.ContextName,
+		ToolCallID: tc.ID,
+		Tool:       tc.Name,
+		Args:       audit.RawArgs(tc.Arguments),
+		ResultHash: audit.HashResult(result),
+		DurationMs: time.Since(start).Milliseconds(),
+		Approval:   perm.approval,
+		Rule:       perm.rule,
+	}
+	if tool != nil {
+		rec.Type = tool.GetType()
+		rec.Source = tool.GetSource()
+		if a.tools != nil {
+			rec.Sandbox = a.tools.SandboxConfigFor(tool)
+		}
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if werr := a.config.Audit.Write(rec); werr != nil {
+		slog.Warn("audit_write_failed", "tool", tc.Name, "id", tc.ID, "error", werr)
+	}
+}
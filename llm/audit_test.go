@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/audit"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+// memoryAudit keeps audit records in memory
+type memoryAudit struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (m *memoryAudit) Write(rec audit.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, rec)
+	return nil
+}
+
+// TestAgentAuditsExecutedCalls: every executed call is recorded with its
+// tool details, result hash, error and approval; refused calls are not.
+func TestAgentAuditsExecutedCalls(t *testing.T) {
+	echo := &tools.Func{
+		Name: "echo",
+		Run: func(_ context.Context, args tools.Args) (string, error) {
+			return args.String("text"), nil
+		},
+	}
+	fail := &tools.Func{
+		Name: "fail",
+		Run: func(context.Context, tools.Args) (string, error) {
+			return "", errors.New("broken")
+		},
+	}
+	fake := &sequentialLLM{responses: []messages.ChatMessage{{
+		Role: messages.MessageRoleAssistant,
+		ToolCalls: []messages.ChatMessageToolCall{
+			{ID: "a", Name: "echo", Arguments: `{"text":"hi"}`},
+			{ID: "b", Name: "fail", Arguments: `{}`},
+			{ID: "c", Name: "echo", Arguments: `{"text":"no"}`},
+		},
+		StopReason: messages.StopReasonToolUse,
+	}}}
+	sink := &memoryAudit{}
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{echo, fail}), AgentConfig{MaxIterations: 3, Audit: sink})
+
+	_, err := agent.WithContextName("work").Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, &AgentCallbacks{
+		ApproveToolCalls: func(calls []messages.ChatMessageToolCall) []bool {
+			approved := make([]bool, len(calls))
+			for i, tc := range calls {
+				approved[i] = tc.ID != "c"
+			}
+			return approved
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID := make(map[string]audit.Record)
+	for _, rec := range sink.records {
+		byID[rec.ToolCallID] = rec
+	}
+	if len(sink.records) != 2 {
+		t.Fatalf("recorded %d calls, want the 2 that ran", len(sink.records))
+	}
+	a := byID["a"]
+	if a.Context != "work" || a.Tool != "echo" || a.Type != "native" || a.Source == "" || a.Approval != audit.ApprovalUser {
+		t.Errorf("record a = %+v", a)
+	}
+	if string(a.Args) != `{"text":"hi"}` || a.ResultHash != audit.HashResult("hi") || a.Time.IsZero() {
+		t.Errorf("record a args %s, hash %s, time %v", a.Args, a.ResultHash, a.Time)
+	}
+	if b := byID["b"]; b.Error != "broken" {
+		t.Errorf("record b error = %q, want broken", b.Error)
+	}
+}
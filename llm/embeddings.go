@@ -26,6 +26,10 @@ type EmbeddingRequest struct {
 	Input      []string // one or more texts
 	Dimensions int      // optional output dimensions for supported providers
 	TaskType   string   // optional, gemini-only; e.g. "RETRIEVAL_DOCUMENT", "RETRIEVAL_QUERY", "CLASSIFICATION"
+
+	// Providers resolves the model prefix, so custom providers backed by
+	// openai or gemini can embed. DefaultProviders when nil.
+	Providers *ProviderRegistry
 }
 
 // EmbeddingResponse is the provider-agnostic embeddings result.
@@ -79,19 +83,32 @@ func Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, erro
 	if model == "" {
 		return nil, fmt.Errorf("embedding model name cannot be empty for provider %q", provider)
 	}
-	if req.TaskType != "" && provider != "gemini" {
+	providers := req.Providers
+	if providers == nil {
+		providers = defaultProviders
+	}
+	resolved, err := providers.resolve(provider)
+	if err != nil {
+		return nil, err
+	}
+	if req.TaskType != "" && resolved.kind != "gemini" {
 		slog.Warn("embedding_task_type_ignored", "provider", provider, "task_type", req.TaskType)
 	}
 
-	switch provider {
+	switch resolved.kind {
 	case "openai":
-		apiKey, err := resolveEmbeddingAPIKey(provider, req.APIKey, req.BaseURL)
+		apiKey, err := resolveEmbeddingAPIKey(providers, resolved, req.APIKey, req.BaseURL)
 		if err != nil {
 			return nil, err
 		}
+		if strings.TrimSpace(req.BaseURL) == "" && resolved.baseURL != "" {
+			local := *req
+			local.BaseURL = resolved.baseURL
+			req = &local
+		}
 		return embedOpenAI(ctx, req, model, apiKey)
 	case "gemini":
-		apiKey, err := resolveEmbeddingAPIKey(provider, req.APIKey, req.BaseURL)
+		apiKey, err := resolveEmbeddingAPIKey(providers, resolved, req.APIKey, req.BaseURL)
 		if err != nil {
 			return nil, err
 		}
@@ -101,18 +118,19 @@ func Embed(ctx context.Context, req *EmbeddingRequest) (*EmbeddingResponse, erro
 	}
 }
 
-func resolveEmbeddingAPIKey(provider, explicit, baseURL string) (string, error) {
+func resolveEmbeddingAPIKey(providers *ProviderRegistry, resolved resolvedProvider, explicit, baseURL string) (string, error) {
 	if explicit != "" {
 		return explicit, nil
 	}
-	// Keep behavior aligned with chat routing: OpenAI-compatible endpoints can be keyless.
-	if provider == "openai" && strings.TrimSpace(baseURL) != "" {
+	// Keep behavior aligned with chat routing: keyless providers and
+	// OpenAI-compatible endpoints can be keyless.
+	if resolved.spec.KeyOptional || (resolved.kind == "openai" && strings.TrimSpace(baseURL) != "") {
 		return "", nil
 	}
-	envVar := getEnvVarNameForProvider(provider)
+	envVar := providers.EnvVarName(resolved.name)
 	key := os.Getenv(envVar)
 	if key == "" {
-		return "", fmt.Errorf("missing API key for provider '%s'. set the %s environment variable", provider, envVar)
+		return "", fmt.Errorf("missing API key for provider '%s'. set the %s environment variable", resolved.name, envVar)
 	}
 	return key, nil
 }
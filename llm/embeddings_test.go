@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"math"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 )
@@ -60,7 +62,11 @@ func TestEmbed_MissingAPIKey(t *testing.T) {
 func TestResolveEmbeddingAPIKey_OpenAIBaseURLAllowsMissingKey(t *testing.T) {
 	t.Setenv("POLLYTOOL_OPENAIKEY", "")
 
-	key, err := resolveEmbeddingAPIKey("openai", "", "http://localhost:11434/v1")
+	resolved, err := defaultProviders.resolve("openai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := resolveEmbeddingAPIKey(defaultProviders, resolved, "", "http://localhost:11434/v1")
 	if err != nil {
 		t.Fatalf("expected nil error for openai base url keyless mode, got %v", err)
 	}
@@ -85,6 +91,46 @@ func TestEmbed_OpenAIBaseURLDoesNotFailAPIKeyValidation(t *testing.T) {
 	}
 }
 
+func TestEmbed_CustomProviderUsesItsBaseURLAndKey(t *testing.T) {
+	var gotAuth, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotPath = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","model":"text-embedding-3-small","data":[{"object":"embedding","index":0,"embedding":[0.5]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	registry := DefaultProviders().Clone()
+	if err := registry.ApplyConfig(ProvidersConfig{Providers: map[string]ProviderAliasConfig{
+		"azure": {Type: "openai", BaseURL: server.URL + "/v1", APIKeyEnv: "AZURE_OPENAI_API_KEY"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AZURE_OPENAI_API_KEY", "azure-key")
+
+	resp, err := Embed(context.Background(), &EmbeddingRequest{
+		Model:     "azure/text-embedding-3-small",
+		Input:     []string{"hello"},
+		Providers: registry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Embeddings) != 1 || resp.InputTokens != 2 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if gotAuth != "Bearer azure-key" || gotPath != "/v1/embeddings" {
+		t.Fatalf("request auth = %q, path = %q", gotAuth, gotPath)
+	}
+
+	// The default registry does not know the alias
+	_, err = Embed(context.Background(), &EmbeddingRequest{Model: "azure/text-embedding-3-small", Input: []string{"hello"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown provider 'azure'") {
+		t.Fatalf("default registry err = %v", err)
+	}
+}
+
 func TestEmbed_GeminiMissingAPIKey(t *testing.T) {
 	t.Setenv("POLLYTOOL_GEMINIKEY", "")
 
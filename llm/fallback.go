@@ -0,0 +1,245 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+// RetryPolicy controls how FallbackLLM retries a model before failing over.
+type RetryPolicy struct {
+	MaxRetries   int           // Retries per model after the first attempt
+	InitialDelay time.Duration // Delay before the first retry
+	MaxDelay     time.Duration // Upper bound for a single delay
+	Multiplier   float64       // Backoff growth factor (default: 2)
+	Jitter       float64       // Random fraction (0-1) added to or removed from each delay
+}
+
+// DefaultRetryPolicy retries twice per model, starting at one second.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:   2,
+	InitialDelay: time.Second,
+	MaxDelay:     20 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// FallbackLLM tries an ordered chain of provider/model strings. Each model is
+// retried with exponential backoff on retryable errors (rate limits,
+// overload, 5xx, transient network failures); once retries are exhausted the
+// next model is tried. Failover only happens while nothing has been streamed
+// to the caller, so partial output is never duplicated.
+//
+// The complete message records the model that answered under
+// messages.MetadataKeyModel.
+type FallbackLLM struct {
+	client LLM
+	models []string
+	policy RetryPolicy
+
+	// sleep is replaceable in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewFallbackLLM wraps client with a fallback chain. The request's own model
+// is tried first, followed by models in order (duplicates are skipped).
+func NewFallbackLLM(client LLM, models []string, policy RetryPolicy) *FallbackLLM {
+	return &FallbackLLM{
+		client: client,
+		models: append([]string(nil), models...),
+		policy: policy,
+		sleep:  sleepContext,
+	}
+}
+
+// chain returns the models to try for a request.
+func (f *FallbackLLM) chain(model string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, m := range append([]string{model}, f.models...) {
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		out = append(out, m)
+	}
+	return out
+}
+
+// ChatCompletionStream implements LLM.
+func (f *FallbackLLM) ChatCompletionStream(ctx context.Context, req *CompletionRequest, processor EventStreamProcessor) <-chan *messages.StreamEvent {
+	out := make(chan *messages.StreamEvent, 10)
+
+	go func() {
+		defer close(out)
+
+		chain := f.chain(req.Model)
+		primaryProvider := providerOf(req.Model)
+		var lastErr *messages.StreamEvent
+
+		for i, model := range chain {
+			attemptReq := *req
+			attemptReq.Model = model
+			// Credentials and endpoints given for the primary model don't
+			// apply to a fallback on another provider.
+			if providerOf(model) != primaryProvider {
+				attemptReq.APIKey = ""
+				attemptReq.BaseURL = ""
+			}
+
+			delay := f.policy.InitialDelay
+			for attempt := 0; ; attempt++ {
+				errEvent, streamed := f.attempt(ctx, &attemptReq, processor, out)
+				if errEvent == nil || streamed {
+					return
+				}
+				lastErr = errEvent
+
+				if ctx.Err() != nil || !IsRetryableError(errEvent.Error) {
+					send(ctx, out, errEvent)
+					return
+				}
+				if attempt >= f.policy.MaxRetries {
+					break
+				}
+
+				wait := f.policy.backoff(delay)
+				slog.Debug("llm_retry", "model", model, "attempt", attempt+1, "delay", wait, "error", errEvent.Error)
+				if err := f.sleep(ctx, wait); err != nil {
+					send(ctx, out, &messages.StreamEvent{Type: messages.EventTypeError, Error: err})
+					return
+				}
+				delay = f.policy.next(delay)
+			}
+
+			if i+1 < len(chain) {
+				slog.Warn("llm_fallback", "from", model, "to", chain[i+1], "error", lastErr.Error)
+			}
+		}
+
+		if lastErr != nil {
+			send(ctx, out, lastErr)
+		}
+	}()
+
+	return out
+}
+
+// attempt runs one completion. Events are held back until the first
+// non-error event arrives; from then on everything is forwarded and the
+// attempt counts as streamed. An error before that point is returned
+// instead of forwarded so the caller can retry or fail over.
+func (f *FallbackLLM) attempt(ctx context.Context, req *CompletionRequest, processor EventStreamProcessor, out chan<- *messages.StreamEvent) (*messages.StreamEvent, bool) {
+	streamed := false
+	for event := range f.client.ChatCompletionStream(ctx, req, processor) {
+		if event.Type == messages.EventTypeError && !streamed {
+			return event, false
+		}
+		if event.Type == messages.EventTypeComplete && event.Message != nil {
+			event.Message.SetModel(req.Model)
+		}
+		streamed = true
+		if !send(ctx, out, event) {
+			return nil, true
+		}
+	}
+	return nil, streamed
+}
+
+// backoff applies jitter and the delay cap.
+func (p RetryPolicy) backoff(d time.Duration) time.Duration {
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		spread := float64(d) * p.Jitter
+		d += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// next returns the base delay for the following retry.
+func (p RetryPolicy) next(d time.Duration) time.Duration {
+	m := p.Multiplier
+	if m <= 0 {
+		m = 2
+	}
+	d = time.Duration(float64(d) * m)
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// send forwards an event unless the caller has gone away.
+func send(ctx context.Context, out chan<- *messages.StreamEvent, event *messages.StreamEvent) bool {
+	select {
+	case out <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func providerOf(model string) string {
+	provider, _, _ := strings.Cut(model, "/")
+	return strings.ToLower(provider)
+}
+
+var retryableStatus = regexp.MustCompile(`\b(408|409|425|429|500|502|503|504|529)\b`)
+
+var retryableMarkers = []string{
+	"overloaded",
+	"rate limit",
+	"rate_limit",
+	"too many requests",
+	"resource_exhausted",
+	"unavailable",
+	"connection reset",
+	"connection refused",
+	"unexpected eof",
+	"i/o timeout",
+	"tls handshake timeout",
+	"server_error",
+}
+
+// IsRetryableError reports whether a provider error looks transient: rate
+// limits, overload, 5xx responses and network failures. Stream errors reach
+// the agent as plain messages, so classification is by error text.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "context canceled") {
+		return false
+	}
+	if retryableStatus.MatchString(msg) {
+		return true
+	}
+	for _, marker := range retryableMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
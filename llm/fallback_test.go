@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+// scriptedLLM replies per model: an error string fails before streaming,
+// anything else is streamed as content.
+type scriptedLLM struct {
+	replies map[string][]string
+	calls   []string
+}
+
+func (s *scriptedLLM) ChatCompletionStream(_ context.Context, req *CompletionRequest, processor EventStreamProcessor) <-chan *messages.StreamEvent {
+	s.calls = append(s.calls, req.Model)
+	reply := "ok"
+	if queue := s.replies[req.Model]; len(queue) > 0 {
+		reply, s.replies[req.Model] = queue[0], queue[1:]
+	}
+
+	msgs := make(chan messages.ChatMessage, 2)
+	if len(reply) > 4 && reply[:4] == "err:" {
+		msg := messages.ChatMessage{Role: messages.MessageRoleAssistant}
+		msg.SetError(errors.New(reply[4:]))
+		msgs <- msg
+	} else {
+		msgs <- messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: reply, StopReason: messages.StopReasonEndTurn}
+	}
+	close(msgs)
+	return processor.ProcessMessagesToEvents(msgs)
+}
+
+func newTestFallback(client LLM, models []string, policy RetryPolicy) (*FallbackLLM, *[]time.Duration) {
+	f := NewFallbackLLM(client, models, policy)
+	var sleeps []time.Duration
+	f.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return f, &sleeps
+}
+
+func collectEvents(events <-chan *messages.StreamEvent) (content string, complete *messages.ChatMessage, err error) {
+	for ev := range events {
+		switch ev.Type {
+		case messages.EventTypeContent:
+			content += ev.Content
+		case messages.EventTypeComplete:
+			complete = ev.Message
+		case messages.EventTypeError:
+			err = ev.Error
+		}
+	}
+	return content, complete, err
+}
+
+func TestFallbackLLM_RetriesThenSucceeds(t *testing.T) {
+	client := &scriptedLLM{replies: map[string][]string{
+		"anthropic/sonnet": {"err:POST \"https://api.anthropic.com/v1/messages\": 529 Overloaded", "hello"},
+	}}
+	f, sleeps := newTestFallback(client, []string{"openai/gpt"}, RetryPolicy{MaxRetries: 2, InitialDelay: time.Second})
+
+	content, complete, err := collectEvents(f.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "anthropic/sonnet"}, messages.NewStreamProcessor()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "hello" {
+		t.Fatalf("content = %q, want hello", content)
+	}
+	if got := complete.GetModel(); got != "anthropic/sonnet" {
+		t.Fatalf("answering model = %q, want anthropic/sonnet", got)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Second {
+		t.Fatalf("sleeps = %v, want one 1s backoff", *sleeps)
+	}
+}
+
+func TestFallbackLLM_FailsOverAfterRetries(t *testing.T) {
+	client := &scriptedLLM{replies: map[string][]string{
+		"anthropic/sonnet": {"err:529 overloaded", "err:529 overloaded", "err:529 overloaded"},
+		"openai/gpt":       {"from fallback"},
+	}}
+	f, sleeps := newTestFallback(client, []string{"openai/gpt"}, RetryPolicy{MaxRetries: 2, InitialDelay: time.Second, Multiplier: 3})
+
+	req := &CompletionRequest{Model: "anthropic/sonnet", APIKey: "anthropic-key", BaseURL: "http://proxy"}
+	content, complete, err := collectEvents(f.ChatCompletionStream(context.Background(), req, messages.NewStreamProcessor()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content != "from fallback" || complete.GetModel() != "openai/gpt" {
+		t.Fatalf("content = %q model = %q, want fallback answer", content, complete.GetModel())
+	}
+	want := []string{"anthropic/sonnet", "anthropic/sonnet", "anthropic/sonnet", "openai/gpt"}
+	if len(client.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", client.calls, want)
+	}
+	if len(*sleeps) != 2 || (*sleeps)[1] != 3*time.Second {
+		t.Fatalf("sleeps = %v, want exponential backoff", *sleeps)
+	}
+	if req.Model != "anthropic/sonnet" {
+		t.Fatal("caller's request must not be mutated")
+	}
+}
+
+func TestFallbackLLM_NonRetryableErrorStops(t *testing.T) {
+	client := &scriptedLLM{replies: map[string][]string{
+		"anthropic/sonnet": {"err:400 invalid_request_error: bad schema"},
+	}}
+	f, _ := newTestFallback(client, []string{"openai/gpt"}, DefaultRetryPolicy)
+
+	_, _, err := collectEvents(f.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "anthropic/sonnet"}, messages.NewStreamProcessor()))
+	if err == nil || len(client.calls) != 1 {
+		t.Fatalf("err = %v calls = %v, want single failed call", err, client.calls)
+	}
+}
+
+func TestFallbackLLM_ReturnsLastErrorWhenChainExhausted(t *testing.T) {
+	client := &scriptedLLM{replies: map[string][]string{
+		"a/one": {"err:429 Too Many Requests"},
+		"b/two": {"err:503 Service Unavailable"},
+	}}
+	f, _ := newTestFallback(client, []string{"b/two", "a/one"}, RetryPolicy{})
+
+	_, _, err := collectEvents(f.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "a/one"}, messages.NewStreamProcessor()))
+	if err == nil || err.Error() != "503 Service Unavailable" {
+		t.Fatalf("err = %v, want last model's error", err)
+	}
+	if len(client.calls) != 2 {
+		t.Fatalf("calls = %v, want duplicate model skipped", client.calls)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want bool
+	}{
+		{`POST "https://api.anthropic.com/v1/messages": 529 Overloaded`, true},
+		{"429 Too Many Requests: rate limit exceeded", true},
+		{"Error 503, Message: The model is overloaded, Status: UNAVAILABLE", true},
+		{"read tcp: connection reset by peer", true},
+		{"401 Unauthorized", false},
+		{"missing API key for provider 'openai'", false},
+		{"context canceled", false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryableError(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("IsRetryableError(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_BackoffCapsAndJitters(t *testing.T) {
+	p := RetryPolicy{MaxDelay: 10 * time.Second, Jitter: 0.5}
+	for range 50 {
+		d := p.backoff(time.Minute)
+		if d < 5*time.Second || d > 15*time.Second {
+			t.Fatalf("backoff() = %v, want within jittered cap", d)
+		}
+	}
+	if got := p.next(8 * time.Second); got != 10*time.Second {
+		t.Fatalf("next() = %v, want capped at MaxDelay", got)
+	}
+}
@@ -8,63 +8,40 @@ import (
 	"github.com/alexschlessinger/pollytool/messages"
 )
 
-type providerFactory func(apiKey, baseURL string) (LLM, error)
-
 // MultiPass routes requests to different LLM providers based on model prefix.
 type MultiPass struct {
 	apiKeys   map[string]string
-	factories map[string]providerFactory
-}
-
-// getEnvVarNameForProvider returns the environment variable name for the given provider
-func getEnvVarNameForProvider(provider string) string {
-	switch provider {
-	case "openai":
-		return "POLLYTOOL_OPENAIKEY"
-	case "anthropic":
-		return "POLLYTOOL_ANTHROPICKEY"
-	case "gemini":
-		return "POLLYTOOL_GEMINIKEY"
-	case "ollama":
-		return "POLLYTOOL_OLLAMAKEY"
-	default:
-		return fmt.Sprintf("POLLYTOOL_%sKEY", strings.ToUpper(provider))
-	}
+	providers *ProviderRegistry
 }
 
 // NewMultiPass creates a new multi-provider router using a snapshot of the
-// provided API keys.
+// provided API keys and of the default provider registry.
 func NewMultiPass(apiKeys map[string]string) *MultiPass {
-	return newMultiPass(apiKeys, defaultProviderFactories())
+	return NewMultiPassWithProviders(apiKeys, defaultProviders)
 }
 
-func newMultiPass(apiKeys map[string]string, factories map[string]providerFactory) *MultiPass {
+// NewMultiPassWithProviders creates a router that resolves providers through
+// a snapshot of registry.
+func NewMultiPassWithProviders(apiKeys map[string]string, registry *ProviderRegistry) *MultiPass {
 	return &MultiPass{
 		apiKeys:   copyAPIKeys(apiKeys),
-		factories: copyProviderFactories(factories),
+		providers: registry.Clone(),
 	}
 }
 
-func defaultProviderFactories() map[string]providerFactory {
-	return map[string]providerFactory{
-		"openai": func(apiKey, baseURL string) (LLM, error) {
-			return NewOpenAIClient(apiKey, baseURL), nil
-		},
-		"anthropic": func(apiKey, _ string) (LLM, error) {
-			return NewAnthropicClient(apiKey), nil
-		},
-		"gemini": func(apiKey, _ string) (LLM, error) {
-			return NewGeminiClient(apiKey)
-		},
-		"ollama": func(apiKey, baseURL string) (LLM, error) {
-			return NewOllamaClient(baseURL, apiKey), nil
-		},
-		"huggingface": func(apiKey, baseURL string) (LLM, error) {
-			if baseURL == "" {
-				baseURL = "https://router.huggingface.co/v1"
-			}
-			return NewOpenAIClient(apiKey, baseURL), nil
-		},
+// newMultiPass builds a router limited to the given factories, keeping the
+// built-in defaults (base URL, key handling) of providers it overrides.
+func newMultiPass(apiKeys map[string]string, factories map[string]ProviderFactory) *MultiPass {
+	registry := NewProviderRegistry()
+	for provider, factory := range factories {
+		spec := defaultProviders.specs[provider]
+		spec.Factory = factory
+		spec.Alias = ""
+		registry.specs[provider] = spec
+	}
+	return &MultiPass{
+		apiKeys:   copyAPIKeys(apiKeys),
+		providers: registry,
 	}
 }
 
@@ -76,14 +53,6 @@ func copyAPIKeys(apiKeys map[string]string) map[string]string {
 	return out
 }
 
-func copyProviderFactories(factories map[string]providerFactory) map[string]providerFactory {
-	out := make(map[string]providerFactory, len(factories))
-	for provider, factory := range factories {
-		out[provider] = factory
-	}
-	return out
-}
-
 // ChatCompletionStream routes the request to the appropriate provider using event-based streaming
 func (m *MultiPass) ChatCompletionStream(ctx context.Context, req *CompletionRequest, processor EventStreamProcessor) <-chan *messages.StreamEvent {
 	// Work on a copy so we don't mutate the caller's request
@@ -103,12 +72,18 @@ func (m *MultiPass) ChatCompletionStream(ctx context.Context, req *CompletionReq
 	// Update the request with the actual model name (without prefix)
 	req.Model = actualModel
 
-	// Populate or validate API key (ollama can be keyless, openai with custom endpoint can be keyless)
+	resolved, err := m.providers.resolve(provider)
+	if err != nil {
+		return processor.ProcessMessagesToEvents(singleErrorMessage(err))
+	}
+
+	// Populate or validate API key (keyless providers and OpenAI-compatible
+	// custom endpoints may omit it)
 	if req.APIKey == "" {
 		if key := m.apiKeys[provider]; key != "" {
 			req.APIKey = key
-		} else if provider != "ollama" && !(provider == "openai" && req.BaseURL != "") {
-			envVar := getEnvVarNameForProvider(provider)
+		} else if !resolved.spec.KeyOptional && !(resolved.kind == "openai" && req.BaseURL != "") {
+			envVar := m.providers.EnvVarName(provider)
 			err := fmt.Errorf("missing API key for provider '%s'. Set the %s environment variable.", provider, envVar)
 			return processor.ProcessMessagesToEvents(singleErrorMessage(err))
 		}
@@ -131,16 +106,16 @@ func (m *MultiPass) ChatCompletionStream(ctx context.Context, req *CompletionReq
 
 // clientFor creates a provider client for the current request.
 func (m *MultiPass) clientFor(provider, apiKey, baseURL string) (LLM, error) {
-	factory, ok := m.factories[provider]
-	if !ok {
-		return nil, fmt.Errorf("unknown provider '%s'. Valid providers: openai, anthropic, gemini, ollama, huggingface", provider)
+	resolved, err := m.providers.resolve(provider)
+	if err != nil {
+		return nil, err
 	}
 
-	if provider == "ollama" && baseURL == "" {
-		baseURL = "http://localhost:11434"
+	if baseURL == "" {
+		baseURL = resolved.baseURL
 	}
 
-	return factory(apiKey, baseURL)
+	return resolved.factory(apiKey, baseURL)
 }
 
 func singleErrorMessage(err error) <-chan messages.ChatMessage {
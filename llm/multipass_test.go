@@ -55,7 +55,7 @@ func TestMultiPass_UnknownProvider_EmitsErrorEvent(t *testing.T) {
 	assertSingleErrorEvent(t, events, "unknown provider 'unknown'")
 }
 
-func TestGetEnvVarNameForProvider_Known(t *testing.T) {
+func TestEnvVarName_Known(t *testing.T) {
 	tests := []struct {
 		provider string
 		want     string
@@ -68,19 +68,19 @@ func TestGetEnvVarNameForProvider_Known(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.provider, func(t *testing.T) {
-			got := getEnvVarNameForProvider(tt.provider)
+			got := defaultProviders.EnvVarName(tt.provider)
 			if got != tt.want {
-				t.Errorf("getEnvVarNameForProvider(%q) = %q, want %q", tt.provider, got, tt.want)
+				t.Errorf("EnvVarName(%q) = %q, want %q", tt.provider, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestGetEnvVarNameForProvider_Unknown(t *testing.T) {
-	got := getEnvVarNameForProvider("mycloud")
+func TestEnvVarName_Unknown(t *testing.T) {
+	got := defaultProviders.EnvVarName("mycloud")
 	want := "POLLYTOOL_MYCLOUDKEY"
 	if got != want {
-		t.Errorf("getEnvVarNameForProvider(%q) = %q, want %q", "mycloud", got, want)
+		t.Errorf("EnvVarName(%q) = %q, want %q", "mycloud", got, want)
 	}
 }
 
@@ -103,7 +103,7 @@ func TestNewMultiPass_SnapshotsAPIKeys(t *testing.T) {
 func TestNewMultiPass_DoesNotConstructProviders(t *testing.T) {
 	var calls int
 
-	m := newMultiPass(map[string]string{"openai": "test-key"}, map[string]providerFactory{
+	m := newMultiPass(map[string]string{"openai": "test-key"}, map[string]ProviderFactory{
 		"openai": func(apiKey, baseURL string) (LLM, error) {
 			calls++
 			return &recordingLLM{}, nil
@@ -121,7 +121,7 @@ func TestNewMultiPass_DoesNotConstructProviders(t *testing.T) {
 func TestMultiPass_ClientFor_DoesNotCacheClients(t *testing.T) {
 	var calls int
 
-	m := newMultiPass(nil, map[string]providerFactory{
+	m := newMultiPass(nil, map[string]ProviderFactory{
 		"openai": func(apiKey, baseURL string) (LLM, error) {
 			calls++
 			return &recordingLLM{}, nil
@@ -144,7 +144,7 @@ func TestMultiPass_UsesDefaultProviderConfig(t *testing.T) {
 	var gotBaseURL string
 	var gotReq *CompletionRequest
 
-	m := newMultiPass(map[string]string{"openai": "default-key"}, map[string]providerFactory{
+	m := newMultiPass(map[string]string{"openai": "default-key"}, map[string]ProviderFactory{
 		"openai": func(apiKey, baseURL string) (LLM, error) {
 			gotAPIKey = apiKey
 			gotBaseURL = baseURL
@@ -184,7 +184,7 @@ func TestMultiPass_OpenAIBaseURLAllowsMissingAPIKey(t *testing.T) {
 	var gotBaseURL string
 	var gotReq *CompletionRequest
 
-	m := newMultiPass(nil, map[string]providerFactory{
+	m := newMultiPass(nil, map[string]ProviderFactory{
 		"openai": func(apiKey, baseURL string) (LLM, error) {
 			gotAPIKey = apiKey
 			gotBaseURL = baseURL
@@ -221,7 +221,7 @@ func TestMultiPass_ClientFor_DefaultsOllamaBaseURL(t *testing.T) {
 	var gotAPIKey string
 	var gotBaseURL string
 
-	m := newMultiPass(nil, map[string]providerFactory{
+	m := newMultiPass(nil, map[string]ProviderFactory{
 		"ollama": func(apiKey, baseURL string) (LLM, error) {
 			gotAPIKey = apiKey
 			gotBaseURL = baseURL
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/alexschlessinger/pollytool/audit"
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+// Tool results sent in place of calls that were not allowed to run
+const (
+	deniedByUser     = "Tool call denied by user."
+	deniedByPolicy   = "Tool call denied by permission policy."
+	deniedNoOneToAsk = "Tool call denied: the permission policy requires approval, but approval is not available."
+)
+
+// toolPermission is the decision on one tool call
+type toolPermission struct {
+	denial   string // Result to send instead of running the call; "" when it may run
+	approval string // How a call that may run was approved, as recorded in the audit log
+	rule     string // Policy rule that allowed the call
+}
+
+// checkPermissions decides which of calls may run, first by the permission
+// policy and then by asking ApproveToolCalls.
+func (a *Agent) checkPermissions(calls []messages.ChatMessageToolCall, cb *AgentCallbacks) []toolPermission {
+	perms := make([]toolPermission, len(calls))
+	var approve func([]messages.ChatMessageToolCall) []bool
+	if cb != nil {
+		approve = cb.ApproveToolCalls
+	}
+
+	policy := a.config.Permissions
+	if policy == nil {
+		for i := range perms {
+			perms[i].approval = audit.ApprovalNone
+		}
+		if approve != nil {
+			for i, ok := range approve(calls) {
+				perms[i].approval = audit.ApprovalUser
+				if !ok {
+					perms[i].denial = deniedByUser
+				}
+			}
+		}
+		return perms
+	}
+
+	var ask []int
+	for i, tc := range calls {
+		var args map[string]any
+		// Unparseable arguments match no argument pattern; the call fails anyway
+		_ = json.Unmarshal([]byte(tc.Arguments), &args)
+		decision := policy.Check(tc.Name, args)
+		rule := "default"
+		if decision.Rule != nil {
+			rule = decision.Rule.String()
+		}
+		slog.Info("tool_permission", "tool", tc.Name, "id", tc.ID, "action", decision.Action, "rule", rule, "source", decision.Source)
+
+		switch decision.Action {
+		case tools.PermissionDeny:
+			perms[i].denial = deniedByPolicy
+		case tools.PermissionAsk:
+			ask = append(ask, i)
+		default:
+			perms[i].approval = audit.ApprovalDefault
+			if decision.Rule != nil {
+				perms[i].approval, perms[i].rule = audit.ApprovalPolicy, rule
+			}
+		}
+	}
+	if len(ask) == 0 {
+		return perms
+	}
+
+	if approve == nil {
+		for _, i := range ask {
+			perms[i].denial = deniedNoOneToAsk
+		}
+		return perms
+	}
+	asked := make([]messages.ChatMessageToolCall, len(ask))
+	for j, i := range ask {
+		asked[j] = calls[i]
+	}
+	answers := approve(asked)
+	for j, i := range ask {
+		slog.Info("tool_permission_answer", "tool", calls[i].Name, "id", calls[i].ID, "approved", answers[j])
+		perms[i].approval = audit.ApprovalUser
+		if !answers[j] {
+			perms[i].denial = deniedByUser
+		}
+	}
+	return perms
+}
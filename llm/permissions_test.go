@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+// TestAgentPermissionsBeforeApproval: the policy decides each call first,
+// and only the calls it asks about reach ApproveToolCalls.
+func TestAgentPermissionsBeforeApproval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	policyFile := `{"rules": [
+		{"tool": "bash", "args": {"command": "^rm "}, "action": "deny"},
+		{"tool": "bash", "args": {"command": "^git push"}, "action": "ask"}
+	]}`
+	if err := os.WriteFile(path, []byte(policyFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy := tools.NewPermissionPolicy(tools.PermissionAllow)
+	if err := policy.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	bash := &tools.Func{
+		Name: "bash",
+		Run: func(context.Context, tools.Args) (string, error) {
+			return "ran", nil
+		},
+	}
+	calls := []messages.ChatMessageToolCall{
+		{ID: "ls", Name: "bash", Arguments: `{"command":"ls"}`},
+		{ID: "rm", Name: "bash", Arguments: `{"command":"rm -rf build"}`},
+		{ID: "push", Name: "bash", Arguments: `{"command":"git push"}`},
+	}
+
+	run := func(approve func([]messages.ChatMessageToolCall) []bool) map[string]string {
+		fake := &sequentialLLM{responses: []messages.ChatMessage{{
+			Role:       messages.MessageRoleAssistant,
+			ToolCalls:  calls,
+			StopReason: messages.StopReasonToolUse,
+		}}}
+		agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{bash}), AgentConfig{MaxIterations: 3, Permissions: policy})
+		resp, err := agent.Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, &AgentCallbacks{ApproveToolCalls: approve})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := make(map[string]string)
+		for _, msg := range resp.AllMessages {
+			if msg.Role == messages.MessageRoleTool {
+				results[msg.ToolCallID] = msg.Content
+			}
+		}
+		return results
+	}
+
+	var asked []string
+	results := run(func(calls []messages.ChatMessageToolCall) []bool {
+		for _, tc := range calls {
+			asked = append(asked, tc.ID)
+		}
+		return make([]bool, len(calls))
+	})
+	if len(asked) != 1 || asked[0] != "push" {
+		t.Errorf("asked about %v, want only push", asked)
+	}
+	want := map[string]string{"ls": "ran", "rm": deniedByPolicy, "push": deniedByUser}
+	for id, result := range want {
+		if results[id] != result {
+			t.Errorf("result of %s = %q, want %q", id, results[id], result)
+		}
+	}
+
+	// Without anyone to ask, calls the policy asks about are refused
+	if got := run(nil)["push"]; got != deniedNoOneToAsk {
+		t.Errorf("result of push without approval = %q, want %q", got, deniedNoOneToAsk)
+	}
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+const planningPrompt = `Before doing anything, plan how you will handle the user's last request.
+Break it into the smallest number of concrete steps that will complete it, in execution order.
+Each step must be self-contained and say what to do and with which tools.
+You cannot call tools while planning; the steps will run one at a time after the user approves the plan.`
+
+// planSchema is the structured output of the planning turn
+var planSchema = &Schema{
+	Raw: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"steps": map[string]any{
+				"type":        "array",
+				"description": "The steps of the plan, in execution order",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"description": map[string]any{
+							"type":        "string",
+							"description": "What the step does",
+						},
+					},
+					"required":             []string{"description"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"steps"},
+		"additionalProperties": false,
+	},
+	Strict: true,
+}
+
+// Plan runs a planning turn over req with tools disabled, asking the model
+// for the steps it would take as structured output. The tools in req are
+// described in the prompt so the steps can name them. The plan's task is
+// the text of the last user message. Errors are reported through
+// cb.OnError as in Run, including a response that is not a valid plan.
+func (a *Agent) Plan(ctx context.Context, req *CompletionRequest, cb *AgentCallbacks) (*sessions.Plan, *AgentResponse, error) {
+	planReq := *req
+	planReq.Messages = withPlanningPrompt(req.Messages, req.Tools)
+	planReq.Tools = nil
+	planReq.ResponseSchema = planSchema
+
+	planner := &Agent{client: a.client, config: a.config}
+	planner.config.MaxIterations = 1
+	planner.config.ResponseTool = ""
+	resp, err := planner.Run(ctx, &planReq, cb)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	plan, err := ParsePlan(resp.Message.Content)
+	if err != nil {
+		if cb != nil && cb.OnError != nil {
+			cb.OnError(err)
+		}
+		return nil, resp, err
+	}
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == messages.MessageRoleUser {
+			plan.Task = req.Messages[i].Content
+			break
+		}
+	}
+	return plan, resp, nil
+}
+
+// ParsePlan reads the structured output of a planning turn into a plan
+// whose steps are all pending.
+func ParsePlan(content string) (*sessions.Plan, error) {
+	var out struct {
+		Steps []struct {
+			Description string `json:"description"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &out); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	plan := &sessions.Plan{}
+	for _, step := range out.Steps {
+		if description := strings.TrimSpace(step.Description); description != "" {
+			plan.Steps = append(plan.Steps, sessions.PlanStep{Description: description, Status: sessions.PlanStepPending})
+		}
+	}
+	if len(plan.Steps) == 0 {
+		return nil, errors.New("the plan has no steps")
+	}
+	return plan, nil
+}
+
+// FormatPlan renders the steps of plan as a numbered list.
+func FormatPlan(plan *sessions.Plan) string {
+	var b strings.Builder
+	for i, step := range plan.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step.Description)
+	}
+	return b.String()
+}
+
+// PlanStepPrompt is the user message that runs step i of plan.
+func PlanStepPrompt(plan *sessions.Plan, i int) string {
+	return fmt.Sprintf("Carry out step %d of %d of the plan: %s\nDo only this step; the remaining steps follow separately. End with a short summary of what you did.",
+		i+1, len(plan.Steps), plan.Steps[i].Description)
+}
+
+// withPlanningPrompt returns msgs with the planning instructions and the
+// available tools added to the system prompt.
+func withPlanningPrompt(msgs []messages.ChatMessage, available []tools.Tool) []messages.ChatMessage {
+	var prompt strings.Builder
+	prompt.WriteString(planningPrompt)
+	if len(available) > 0 {
+		prompt.WriteString("\n\nTools the steps can use:\n")
+		for _, tool := range available {
+			s := tool.GetSchema()
+			fmt.Fprintf(&prompt, "- %s: %s\n", s.Title(), s.Description())
+		}
+	} else {
+		prompt.WriteString("\n\nNo tools will be available to the steps.")
+	}
+
+	out := make([]messages.ChatMessage, 0, len(msgs)+1)
+	if len(msgs) > 0 && msgs[0].Role == messages.MessageRoleSystem {
+		system := msgs[0]
+		system.Content += "\n\n" + prompt.String()
+		out = append(out, system)
+		msgs = msgs[1:]
+	} else {
+		out = append(out, messages.ChatMessage{Role: messages.MessageRoleSystem, Content: prompt.String()})
+	}
+	return append(out, msgs...)
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+func TestAgentPlan(t *testing.T) {
+	echo := &tools.Func{Name: "echo", Desc: "Echo the input", Run: func(context.Context, tools.Args) (string, error) { return "", nil }}
+	var seen *CompletionRequest
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		seen = req
+		return messages.ChatMessage{
+			Role:       messages.MessageRoleAssistant,
+			Content:    `{"steps":[{"description":"look around"},{"description":" "},{"description":"echo it"}]}`,
+			StopReason: messages.StopReasonEndTurn,
+		}
+	})
+	registry := tools.NewToolRegistry([]tools.Tool{echo})
+	agent := NewAgent(fake, registry, AgentConfig{MaxIterations: 5})
+
+	req := &CompletionRequest{
+		Messages: []messages.ChatMessage{
+			{Role: messages.MessageRoleSystem, Content: "be brief"},
+			{Role: messages.MessageRoleUser, Content: "do the thing"},
+		},
+		Tools: registry.All(),
+	}
+	plan, resp, err := agent.Plan(context.Background(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen.Tools) != 0 || seen.ResponseSchema != planSchema {
+		t.Errorf("planning request has %d tools and schema %v, want no tools and the plan schema", len(seen.Tools), seen.ResponseSchema)
+	}
+	system := seen.Messages[0].Content
+	if !strings.HasPrefix(system, "be brief\n\n") || !strings.Contains(system, "- echo: Echo the input") {
+		t.Errorf("planning system prompt = %q, want the original prompt plus the tool list", system)
+	}
+	if req.Messages[0].Content != "be brief" {
+		t.Errorf("Plan modified the caller's messages")
+	}
+
+	want := []sessions.PlanStep{
+		{Description: "look around", Status: sessions.PlanStepPending},
+		{Description: "echo it", Status: sessions.PlanStepPending},
+	}
+	if plan.Task != "do the thing" || len(plan.Steps) != len(want) || plan.Steps[0] != want[0] || plan.Steps[1] != want[1] {
+		t.Errorf("plan = %+v, want task and blank-free steps %+v", plan, want)
+	}
+	if resp.IterationCount != 1 {
+		t.Errorf("IterationCount = %d, want a single planning call", resp.IterationCount)
+	}
+}
+
+func TestParsePlanRejectsEmptyPlans(t *testing.T) {
+	for _, content := range []string{`{"steps":[]}`, `not json`} {
+		if _, err := ParsePlan(content); err == nil {
+			t.Errorf("ParsePlan(%q) succeeded, want an error", content)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ModelPrice is a model's list price in USD per million tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Cost returns the USD cost of the given token usage.
+func (p ModelPrice) Cost(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / 1e6
+}
+
+// PricingTable maps "provider/model" names to prices. Lookups fall back to
+// the longest entry that prefixes the model (so dated snapshots share their
+// base model's price) and then to a "provider/*" wildcard.
+type PricingTable struct {
+	mu     sync.RWMutex
+	prices map[string]ModelPrice
+}
+
+// builtinPrices are list prices at the time of writing. Override or extend
+// them with a pricing file.
+var builtinPrices = map[string]ModelPrice{
+	"openai/gpt-5":                 {Input: 1.25, Output: 10},
+	"openai/gpt-5-mini":            {Input: 0.25, Output: 2},
+	"openai/gpt-5-nano":            {Input: 0.05, Output: 0.40},
+	"openai/gpt-4.1":               {Input: 2, Output: 8},
+	"openai/gpt-4.1-mini":          {Input: 0.40, Output: 1.60},
+	"openai/gpt-4.1-nano":          {Input: 0.10, Output: 0.40},
+	"openai/gpt-4o":                {Input: 2.50, Output: 10},
+	"openai/gpt-4o-mini":           {Input: 0.15, Output: 0.60},
+	"openai/o3":                    {Input: 2, Output: 8},
+	"openai/o3-mini":               {Input: 1.10, Output: 4.40},
+	"openai/o4-mini":               {Input: 1.10, Output: 4.40},
+	"anthropic/claude-opus-4":      {Input: 15, Output: 75},
+	"anthropic/claude-opus-4-5":    {Input: 5, Output: 25},
+	"anthropic/claude-opus-4-6":    {Input: 5, Output: 25},
+	"anthropic/claude-sonnet-4":    {Input: 3, Output: 15},
+	"anthropic/claude-haiku-4":     {Input: 1, Output: 5},
+	"anthropic/claude-3-7":         {Input: 3, Output: 15},
+	"anthropic/claude-3-5-haiku":   {Input: 0.80, Output: 4},
+	"anthropic/claude-3-5-sonnet":  {Input: 3, Output: 15},
+	"gemini/gemini-2.5-pro":        {Input: 1.25, Output: 10},
+	"gemini/gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+	"gemini/gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+	"gemini/gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+	"ollama/*":                     {},
+}
+
+// NewPricingTable creates a table holding prices.
+func NewPricingTable(prices map[string]ModelPrice) *PricingTable {
+	t := &PricingTable{prices: make(map[string]ModelPrice, len(prices))}
+	for model, price := range prices {
+		t.prices[strings.ToLower(model)] = price
+	}
+	return t
+}
+
+// DefaultPricing returns a new table with the built-in prices.
+func DefaultPricing() *PricingTable {
+	return NewPricingTable(builtinPrices)
+}
+
+// Set adds or replaces the price for a model or "provider/*" wildcard.
+func (t *PricingTable) Set(model string, price ModelPrice) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prices[strings.ToLower(model)] = price
+}
+
+// Lookup returns the price for model.
+func (t *PricingTable) Lookup(model string) (ModelPrice, bool) {
+	if t == nil {
+		return ModelPrice{}, false
+	}
+	model = strings.ToLower(model)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if price, ok := t.prices[model]; ok {
+		return price, true
+	}
+
+	// Longest prefix at a name boundary, e.g. "openai/gpt-4o" for "openai/gpt-4o-2024-08-06"
+	keys := make([]string, 0, len(t.prices))
+	for key := range t.prices {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, key := range keys {
+		if rest, ok := strings.CutPrefix(model, key); ok && (rest[0] == '-' || rest[0] == '.' || rest[0] == ':' || rest[0] == '@') {
+			return t.prices[key], true
+		}
+	}
+
+	if provider, _, ok := strings.Cut(model, "/"); ok {
+		if price, ok := t.prices[provider+"/*"]; ok {
+			return price, true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// Cost returns the USD cost of a call to model, and false if it has no price.
+func (t *PricingTable) Cost(model string, inputTokens, outputTokens int) (float64, bool) {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return price.Cost(inputTokens, outputTokens), true
+}
+
+// LoadFile merges prices from a JSON file of the form
+// {"provider/model": {"input": 1.25, "output": 10}}. A missing file is not
+// an error.
+func (t *PricingTable) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read pricing file: %w", err)
+	}
+
+	var prices map[string]ModelPrice
+	if err := json.Unmarshal(data, &prices); err != nil {
+		return fmt.Errorf("parse pricing file %s: %w", path, err)
+	}
+	for model, price := range prices {
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("pricing file %s: negative price for %s", path, model)
+		}
+		t.Set(model, price)
+	}
+	return nil
+}
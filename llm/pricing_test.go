@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+func TestPricingTableLookup(t *testing.T) {
+	table := NewPricingTable(map[string]ModelPrice{
+		"openai/gpt-4o":      {Input: 2.5, Output: 10},
+		"openai/gpt-4o-mini": {Input: 0.15, Output: 0.6},
+		"ollama/*":           {},
+	})
+
+	tests := []struct {
+		model string
+		want  ModelPrice
+		ok    bool
+	}{
+		{"openai/gpt-4o", ModelPrice{Input: 2.5, Output: 10}, true},
+		{"OpenAI/GPT-4o-2024-08-06", ModelPrice{Input: 2.5, Output: 10}, true},
+		{"openai/gpt-4o-mini-2024-07-18", ModelPrice{Input: 0.15, Output: 0.6}, true},
+		{"ollama/llama3", ModelPrice{}, true},
+		{"openai/gpt-4omega", ModelPrice{}, false},
+		{"anthropic/claude", ModelPrice{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := table.Lookup(tt.model)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Lookup(%q) = %+v, %v; want %+v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	cost, ok := table.Cost("openai/gpt-4o", 1_000_000, 500_000)
+	if !ok || math.Abs(cost-7.5) > 1e-9 {
+		t.Fatalf("Cost() = %v, %v; want 7.5", cost, ok)
+	}
+}
+
+func TestPricingTableLoadFile(t *testing.T) {
+	table := DefaultPricing()
+	if err := table.LoadFile(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatalf("LoadFile(missing) error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	if err := os.WriteFile(path, []byte(`{"openai/gpt-4o": {"input": 1, "output": 2}, "custom/model": {"input": 3, "output": 4}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	if got, _ := table.Lookup("openai/gpt-4o"); got != (ModelPrice{Input: 1, Output: 2}) {
+		t.Fatalf("override = %+v", got)
+	}
+	if _, ok := table.Lookup("custom/model"); !ok {
+		t.Fatal("custom model not added")
+	}
+	if _, ok := DefaultPricing().Lookup("custom/model"); ok {
+		t.Fatal("LoadFile must not modify the built-in prices")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"x/y": {"input": -1}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.LoadFile(path); err == nil {
+		t.Fatal("LoadFile() accepted a negative price")
+	}
+}
+
+func TestAgentTracksCostAndEnforcesBudget(t *testing.T) {
+	toolCall := func() messages.ChatMessage {
+		msg := messages.ChatMessage{
+			Role:       messages.MessageRoleAssistant,
+			ToolCalls:  []messages.ChatMessageToolCall{{ID: "1", Name: "missing", Arguments: `{}`}},
+			StopReason: messages.StopReasonToolUse,
+		}
+		msg.SetTokenUsage(1000, 0) // $1 at the test price
+		return msg
+	}
+	pricing := NewPricingTable(map[string]ModelPrice{"test/model": {Input: 1000}})
+	req := &CompletionRequest{Model: "test/model", Messages: messages.User("hello")}
+
+	fake := &sequentialLLM{responses: []messages.ChatMessage{toolCall(), toolCall(), toolCall()}}
+	resp, err := NewAgent(fake, nil, AgentConfig{MaxIterations: 5, Pricing: pricing, Budget: 1.5}).Run(context.Background(), req, nil)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Run() error = %v, want ErrBudgetExceeded", err)
+	}
+	if fake.callCount != 2 || resp == nil || math.Abs(resp.Cost-2) > 1e-9 || len(resp.AllMessages) != 4 {
+		t.Fatalf("calls = %d, resp = %+v; want 2 calls costing $2 with history kept", fake.callCount, resp)
+	}
+
+	// Without a budget the cost is still reported
+	fake = &sequentialLLM{responses: []messages.ChatMessage{toolCall()}}
+	resp, err = NewAgent(fake, nil, AgentConfig{MaxIterations: 5, Pricing: pricing}).Run(context.Background(), req, nil)
+	if err != nil || math.Abs(resp.Cost-1) > 1e-9 {
+		t.Fatalf("Run() = %+v, %v; want cost 1", resp, err)
+	}
+
+	// Spend outside the run's own calls counts toward its budget
+	fake = &sequentialLLM{responses: []messages.ChatMessage{toolCall(), toolCall()}}
+	external := func() float64 { return 1 }
+	resp, err = NewAgent(fake, nil, AgentConfig{MaxIterations: 5, Pricing: pricing, Budget: 1.5, ExternalCost: external}).Run(context.Background(), req, nil)
+	if !errors.Is(err, ErrBudgetExceeded) || fake.callCount != 1 || math.Abs(resp.Cost-2) > 1e-9 {
+		t.Fatalf("Run() = %+v, %v after %d calls; want $2 spent and the second call refused", resp, err, fake.callCount)
+	}
+
+	// A budget cannot be enforced for an unpriced model
+	fake = &sequentialLLM{}
+	_, err = NewAgent(fake, nil, AgentConfig{Pricing: pricing, Budget: 1}).Run(context.Background(), &CompletionRequest{Model: "other/model", Messages: messages.User("hi")}, nil)
+	if err == nil || fake.callCount != 0 {
+		t.Fatalf("Run() error = %v, calls = %d; want refusal before any call", err, fake.callCount)
+	}
+}
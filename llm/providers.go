@@ -0,0 +1,318 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ProviderFactory constructs a client for a provider. baseURL is the request
+// override or, when empty, the provider's default base URL.
+type ProviderFactory func(apiKey, baseURL string) (LLM, error)
+
+// ProviderSpec describes how MultiPass reaches a provider.
+type ProviderSpec struct {
+	// Factory builds the client. Leave nil when Alias is set.
+	Factory ProviderFactory
+	// Alias names a registered provider whose factory this entry reuses,
+	// e.g. "azure" as an alias of "openai" with its own base URL and key.
+	Alias string
+	// BaseURL is used when the request does not set one.
+	BaseURL string
+	// APIKeyEnv is the environment variable holding the provider's key.
+	// Defaults to POLLYTOOL_<NAME>KEY.
+	APIKeyEnv string
+	// KeyOptional allows requests without an API key (e.g. local servers).
+	KeyOptional bool
+}
+
+// resolvedProvider is a spec with its alias chain followed to a factory.
+// spec is the named provider's own, with the APIKeyEnv and KeyOptional it
+// inherits from its targets.
+type resolvedProvider struct {
+	name    string
+	kind    string // name of the provider that supplies the factory
+	factory ProviderFactory
+	baseURL string // first non-empty BaseURL along the alias chain
+	spec    ProviderSpec
+}
+
+// ProviderRegistry maps provider names (the prefix in "provider/model") to
+// the factories and defaults MultiPass uses to build clients.
+type ProviderRegistry struct {
+	mu    sync.RWMutex
+	specs map[string]ProviderSpec
+}
+
+// NewProviderRegistry returns an empty registry.
+func NewProviderRegistry() *ProviderRegistry {
+	return &ProviderRegistry{specs: make(map[string]ProviderSpec)}
+}
+
+var defaultProviders = builtinProviders()
+
+// DefaultProviders returns the process-wide registry that NewMultiPass
+// snapshots. Providers registered here are visible to MultiPass instances
+// created afterwards.
+func DefaultProviders() *ProviderRegistry {
+	return defaultProviders
+}
+
+// RegisterProvider adds or replaces a provider in the default registry.
+func RegisterProvider(name string, spec ProviderSpec) error {
+	return defaultProviders.Register(name, spec)
+}
+
+func builtinProviders() *ProviderRegistry {
+	r := NewProviderRegistry()
+	r.specs["openai"] = ProviderSpec{
+		Factory: func(apiKey, baseURL string) (LLM, error) {
+			return NewOpenAIClient(apiKey, baseURL), nil
+		},
+		APIKeyEnv: "POLLYTOOL_OPENAIKEY",
+	}
+	r.specs["anthropic"] = ProviderSpec{
+		Factory: func(apiKey, _ string) (LLM, error) {
+			return NewAnthropicClient(apiKey), nil
+		},
+		APIKeyEnv: "POLLYTOOL_ANTHROPICKEY",
+	}
+	r.specs["gemini"] = ProviderSpec{
+		Factory: func(apiKey, _ string) (LLM, error) {
+			return NewGeminiClient(apiKey)
+		},
+		APIKeyEnv: "POLLYTOOL_GEMINIKEY",
+	}
+	r.specs["ollama"] = ProviderSpec{
+		Factory: func(apiKey, baseURL string) (LLM, error) {
+			return NewOllamaClient(baseURL, apiKey), nil
+		},
+		BaseURL:     "http://localhost:11434",
+		APIKeyEnv:   "POLLYTOOL_OLLAMAKEY",
+		KeyOptional: true,
+	}
+	r.specs["huggingface"] = ProviderSpec{
+		Alias:     "openai",
+		BaseURL:   "https://router.huggingface.co/v1",
+		APIKeyEnv: "POLLYTOOL_HUGGINGFACEKEY",
+	}
+	return r
+}
+
+// Register adds or replaces a provider. Names are case-insensitive.
+func (r *ProviderRegistry) Register(name string, spec ProviderSpec) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid provider name %q", name)
+	}
+	spec.Alias = strings.ToLower(strings.TrimSpace(spec.Alias))
+	if spec.Factory == nil && spec.Alias == "" {
+		return fmt.Errorf("provider %q needs a factory or an alias", name)
+	}
+	if spec.Factory != nil && spec.Alias != "" {
+		return fmt.Errorf("provider %q cannot set both a factory and an alias", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if spec.Alias != "" {
+		if _, ok := r.specs[spec.Alias]; !ok {
+			return fmt.Errorf("provider %q is an alias of unknown provider %q", name, spec.Alias)
+		}
+	}
+	r.specs[name] = spec
+	if _, err := r.resolveLocked(name); err != nil {
+		delete(r.specs, name)
+		return err
+	}
+	return nil
+}
+
+// Names returns the registered provider names in sorted order.
+func (r *ProviderRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.specs))
+	for name := range r.specs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Has reports whether name is registered.
+func (r *ProviderRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.specs[strings.ToLower(name)]
+	return ok
+}
+
+// EnvVarName returns the environment variable holding the key for provider.
+// Aliases without their own use their target's.
+func (r *ProviderRegistry) EnvVarName(provider string) string {
+	provider = strings.ToLower(provider)
+	if resolved, err := r.resolve(provider); err == nil && resolved.spec.APIKeyEnv != "" {
+		return resolved.spec.APIKeyEnv
+	}
+	return fmt.Sprintf("POLLYTOOL_%sKEY", strings.ToUpper(provider))
+}
+
+// Type returns the provider whose client name is built with, following
+// aliases, e.g. "openai" for an Azure alias. ok is false for unknown names.
+func (r *ProviderRegistry) Type(name string) (kind string, ok bool) {
+	resolved, err := r.resolve(strings.ToLower(name))
+	if err != nil {
+		return "", false
+	}
+	return resolved.kind, true
+}
+
+// APIKeysFromEnv reads the key of every registered provider from its
+// environment variable.
+func (r *ProviderRegistry) APIKeysFromEnv() map[string]string {
+	keys := make(map[string]string)
+	for _, name := range r.Names() {
+		keys[name] = os.Getenv(r.EnvVarName(name))
+	}
+	return keys
+}
+
+// Clone returns an independent copy of the registry.
+func (r *ProviderRegistry) Clone() *ProviderRegistry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := NewProviderRegistry()
+	for name, spec := range r.specs {
+		out.specs[name] = spec
+	}
+	return out
+}
+
+func (r *ProviderRegistry) resolve(name string) (resolvedProvider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resolveLocked(name)
+}
+
+func (r *ProviderRegistry) resolveLocked(name string) (resolvedProvider, error) {
+	spec, ok := r.specs[name]
+	if !ok {
+		names := make([]string, 0, len(r.specs))
+		for n := range r.specs {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return resolvedProvider{}, fmt.Errorf("unknown provider '%s'. Valid providers: %s", name, strings.Join(names, ", "))
+	}
+
+	resolved := resolvedProvider{name: name, kind: name, factory: spec.Factory, baseURL: spec.BaseURL, spec: spec}
+	seen := map[string]bool{name: true}
+	for resolved.factory == nil {
+		target, ok := r.specs[spec.Alias]
+		if !ok {
+			return resolvedProvider{}, fmt.Errorf("provider %q is an alias of unknown provider %q", resolved.kind, spec.Alias)
+		}
+		if seen[spec.Alias] {
+			return resolvedProvider{}, fmt.Errorf("provider %q has a circular alias", name)
+		}
+		seen[spec.Alias] = true
+		resolved.kind = spec.Alias
+		resolved.factory = target.Factory
+		if resolved.baseURL == "" {
+			resolved.baseURL = target.BaseURL
+		}
+		if resolved.spec.APIKeyEnv == "" {
+			resolved.spec.APIKeyEnv = target.APIKeyEnv
+		}
+		resolved.spec.KeyOptional = resolved.spec.KeyOptional || target.KeyOptional
+		spec = target
+	}
+
+	return resolved, nil
+}
+
+// ProvidersConfig is the on-disk format for custom providers and aliases:
+//
+//	{
+//	  "providers": {
+//	    "azure": {
+//	      "type": "openai",
+//	      "base_url": "https://example.openai.azure.com/openai/v1",
+//	      "api_key_env": "AZURE_OPENAI_API_KEY"
+//	    }
+//	  }
+//	}
+type ProvidersConfig struct {
+	Providers map[string]ProviderAliasConfig `json:"providers"`
+}
+
+// ProviderAliasConfig declares a provider backed by a registered one.
+type ProviderAliasConfig struct {
+	Type        string `json:"type"`
+	BaseURL     string `json:"base_url,omitempty"`
+	APIKeyEnv   string `json:"api_key_env,omitempty"`
+	KeyOptional bool   `json:"key_optional,omitempty"`
+}
+
+// LoadConfigFile registers the aliases declared in a JSON providers file.
+// A missing file is not an error.
+func (r *ProviderRegistry) LoadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read providers config: %w", err)
+	}
+
+	var config ProvidersConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse providers config %s: %w", path, err)
+	}
+	return r.ApplyConfig(config)
+}
+
+// ApplyConfig registers the aliases declared in config.
+func (r *ProviderRegistry) ApplyConfig(config ProvidersConfig) error {
+	names := make([]string, 0, len(config.Providers))
+	for name := range config.Providers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	// Entries may alias each other, so register in passes until every
+	// target is known.
+	for len(names) > 0 {
+		var deferred []string
+		for _, name := range names {
+			entry := config.Providers[name]
+			if entry.Type == "" {
+				return fmt.Errorf("provider %q: type is required", name)
+			}
+			if !r.Has(entry.Type) {
+				deferred = append(deferred, name)
+				continue
+			}
+			err := r.Register(name, ProviderSpec{
+				Alias:       entry.Type,
+				BaseURL:     entry.BaseURL,
+				APIKeyEnv:   entry.APIKeyEnv,
+				KeyOptional: entry.KeyOptional,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if len(deferred) == len(names) {
+			name := deferred[0]
+			return fmt.Errorf("provider %q is an alias of unknown provider %q", name, config.Providers[name].Type)
+		}
+		names = deferred
+	}
+	return nil
+}
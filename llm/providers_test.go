@@ -0,0 +1,239 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+func TestProviderRegistry_CustomProviderRoutesThroughMultiPass(t *testing.T) {
+	var gotKey, gotBaseURL, gotModel string
+
+	registry := NewProviderRegistry()
+	err := registry.Register("Gateway", ProviderSpec{
+		Factory: func(apiKey, baseURL string) (LLM, error) {
+			gotKey = apiKey
+			gotBaseURL = baseURL
+			return &recordingLLM{onCall: func(req *CompletionRequest) { gotModel = req.Model }}, nil
+		},
+		BaseURL:   "http://gateway.internal/v1",
+		APIKeyEnv: "GATEWAY_TOKEN",
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	m := NewMultiPassWithProviders(map[string]string{"gateway": "secret"}, registry)
+	drainEvents(m.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "gateway/big-model"}, messages.NewStreamProcessor()))
+
+	if gotKey != "secret" || gotBaseURL != "http://gateway.internal/v1" || gotModel != "big-model" {
+		t.Fatalf("factory got key=%q baseURL=%q model=%q", gotKey, gotBaseURL, gotModel)
+	}
+	if got := registry.EnvVarName("gateway"); got != "GATEWAY_TOKEN" {
+		t.Fatalf("EnvVarName() = %q, want GATEWAY_TOKEN", got)
+	}
+}
+
+func TestProviderRegistry_MultiPassSnapshotsRegistry(t *testing.T) {
+	registry := NewProviderRegistry()
+	m := NewMultiPassWithProviders(nil, registry)
+
+	if err := registry.Register("late", ProviderSpec{Factory: func(string, string) (LLM, error) { return &recordingLLM{}, nil }}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if _, err := m.clientFor("late", "", ""); err == nil {
+		t.Fatal("expected provider registered after construction to be unknown")
+	}
+}
+
+func TestProviderRegistry_AliasInheritsFactoryAndBaseURL(t *testing.T) {
+	var gotBaseURL string
+	registry := NewProviderRegistry()
+	if err := registry.Register("openai", ProviderSpec{Factory: func(_, baseURL string) (LLM, error) {
+		gotBaseURL = baseURL
+		return &recordingLLM{}, nil
+	}}); err != nil {
+		t.Fatalf("Register(openai) error = %v", err)
+	}
+	if err := registry.Register("router", ProviderSpec{Alias: "openai", BaseURL: "https://router.example/v1"}); err != nil {
+		t.Fatalf("Register(router) error = %v", err)
+	}
+	if err := registry.Register("router-eu", ProviderSpec{Alias: "router"}); err != nil {
+		t.Fatalf("Register(router-eu) error = %v", err)
+	}
+
+	m := NewMultiPassWithProviders(nil, registry)
+	if _, err := m.clientFor("router-eu", "k", ""); err != nil {
+		t.Fatalf("clientFor() error = %v", err)
+	}
+	if gotBaseURL != "https://router.example/v1" {
+		t.Fatalf("baseURL = %q, want inherited alias base URL", gotBaseURL)
+	}
+
+	if _, err := m.clientFor("router-eu", "k", "http://override.test"); err != nil {
+		t.Fatalf("clientFor() error = %v", err)
+	}
+	if gotBaseURL != "http://override.test" {
+		t.Fatalf("baseURL = %q, want request override", gotBaseURL)
+	}
+}
+
+func TestProviderRegistry_RegisterRejectsInvalidSpecs(t *testing.T) {
+	registry := NewProviderRegistry()
+	factory := func(string, string) (LLM, error) { return &recordingLLM{}, nil }
+
+	tests := []struct {
+		name    string
+		spec    ProviderSpec
+		wantErr string
+	}{
+		{"", ProviderSpec{Factory: factory}, "invalid provider name"},
+		{"a/b", ProviderSpec{Factory: factory}, "invalid provider name"},
+		{"empty", ProviderSpec{}, "needs a factory or an alias"},
+		{"both", ProviderSpec{Factory: factory, Alias: "x"}, "cannot set both"},
+		{"dangling", ProviderSpec{Alias: "missing"}, "unknown provider"},
+		{"self", ProviderSpec{Alias: "self"}, "unknown provider"},
+	}
+	for _, tt := range tests {
+		err := registry.Register(tt.name, tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Fatalf("Register(%q) error = %v, want substring %q", tt.name, err, tt.wantErr)
+		}
+	}
+	if names := registry.Names(); len(names) != 0 {
+		t.Fatalf("Names() = %v, want none after rejected registrations", names)
+	}
+}
+
+func TestMultiPass_KeyOptionalProviderAllowsMissingKey(t *testing.T) {
+	registry := NewProviderRegistry()
+	called := false
+	if err := registry.Register("local", ProviderSpec{
+		Factory: func(string, string) (LLM, error) {
+			return &recordingLLM{onCall: func(*CompletionRequest) { called = true }}, nil
+		},
+		KeyOptional: true,
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	m := NewMultiPassWithProviders(nil, registry)
+	drainEvents(m.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "local/m"}, messages.NewStreamProcessor()))
+	if !called {
+		t.Fatal("expected keyless provider to be called")
+	}
+}
+
+func TestMultiPass_AliasInheritsKeyOptionalAndKeyEnv(t *testing.T) {
+	var gotBaseURL string
+	registry := NewProviderRegistry()
+	if err := registry.Register("ollama", ProviderSpec{
+		Factory: func(_, baseURL string) (LLM, error) {
+			gotBaseURL = baseURL
+			return &recordingLLM{}, nil
+		},
+		APIKeyEnv:   "POLLYTOOL_OLLAMAKEY",
+		KeyOptional: true,
+	}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.ApplyConfig(ProvidersConfig{Providers: map[string]ProviderAliasConfig{
+		"box": {Type: "ollama", BaseURL: "http://box:11434"},
+	}}); err != nil {
+		t.Fatalf("ApplyConfig() error = %v", err)
+	}
+
+	m := NewMultiPassWithProviders(nil, registry)
+	drainEvents(m.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "box/llama3"}, messages.NewStreamProcessor()))
+	if gotBaseURL != "http://box:11434" {
+		t.Fatalf("baseURL = %q, want the keyless alias called with its base URL", gotBaseURL)
+	}
+	if got := registry.EnvVarName("box"); got != "POLLYTOOL_OLLAMAKEY" {
+		t.Fatalf("EnvVarName(box) = %q, want the target's POLLYTOOL_OLLAMAKEY", got)
+	}
+
+	// The built-in ollama is keyless too
+	builtin := DefaultProviders().Clone()
+	if err := builtin.Register("box", ProviderSpec{Alias: "ollama", BaseURL: "http://box:11434"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if resolved, err := builtin.resolve("box"); err != nil || !resolved.spec.KeyOptional {
+		t.Fatalf("resolve(box) = %+v, %v; want KeyOptional", resolved.spec, err)
+	}
+}
+
+func TestMultiPass_AliasOfOpenAIAllowsMissingKeyWithBaseURL(t *testing.T) {
+	registry := DefaultProviders().Clone()
+	if err := registry.Register("azure", ProviderSpec{Alias: "openai", APIKeyEnv: "AZURE_KEY"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	m := NewMultiPassWithProviders(nil, registry)
+
+	events := m.ChatCompletionStream(context.Background(), &CompletionRequest{Model: "azure/gpt"}, messages.NewStreamProcessor())
+	assertSingleErrorEvent(t, events, "Set the AZURE_KEY environment variable")
+}
+
+func TestProviderRegistry_LoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "providers.json")
+	config := `{
+  "providers": {
+    "azure-eu": {"type": "azure", "base_url": "https://eu.example/v1"},
+    "azure": {"type": "openai", "base_url": "https://us.example/v1", "api_key_env": "AZURE_OPENAI_API_KEY"},
+    "lan": {"type": "ollama", "key_optional": true}
+  }
+}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	registry := DefaultProviders().Clone()
+	if err := registry.LoadConfigFile(path); err != nil {
+		t.Fatalf("LoadConfigFile() error = %v", err)
+	}
+
+	for _, name := range []string{"azure", "azure-eu", "lan"} {
+		if !registry.Has(name) {
+			t.Fatalf("expected provider %q to be registered", name)
+		}
+	}
+	if got := registry.EnvVarName("azure"); got != "AZURE_OPENAI_API_KEY" {
+		t.Fatalf("EnvVarName(azure) = %q", got)
+	}
+	resolved, err := registry.resolve("azure-eu")
+	if err != nil {
+		t.Fatalf("resolve() error = %v", err)
+	}
+	if resolved.kind != "openai" || resolved.baseURL != "https://eu.example/v1" {
+		t.Fatalf("resolved azure-eu = kind %q baseURL %q", resolved.kind, resolved.baseURL)
+	}
+	if kind, ok := registry.Type("Azure-EU"); !ok || kind != "openai" {
+		t.Fatalf("Type(Azure-EU) = %q, %v", kind, ok)
+	}
+	if _, ok := registry.Type("missing"); ok {
+		t.Fatal("Type(missing) should not be found")
+	}
+	if DefaultProviders().Has("azure") {
+		t.Fatal("loading into a clone must not change the default registry")
+	}
+
+	if err := registry.LoadConfigFile(filepath.Join(dir, "missing.json")); err != nil {
+		t.Fatalf("LoadConfigFile(missing) error = %v, want nil", err)
+	}
+}
+
+func TestProviderRegistry_ApplyConfigRejectsUnknownType(t *testing.T) {
+	registry := DefaultProviders().Clone()
+	err := registry.ApplyConfig(ProvidersConfig{Providers: map[string]ProviderAliasConfig{
+		"a": {Type: "b"},
+		"b": {Type: "a"},
+	}})
+	if err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Fatalf("ApplyConfig() error = %v, want unknown provider", err)
+	}
+}
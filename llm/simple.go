@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/alexschlessinger/pollytool/messages"
@@ -14,14 +13,7 @@ import (
 
 // GetDefaultClient creates a new MultiPass router with API keys from the environment.
 func GetDefaultClient() LLM {
-	apiKeys := map[string]string{
-		"openai":      os.Getenv("POLLYTOOL_OPENAIKEY"),
-		"anthropic":   os.Getenv("POLLYTOOL_ANTHROPICKEY"),
-		"gemini":      os.Getenv("POLLYTOOL_GEMINIKEY"),
-		"ollama":      os.Getenv("POLLYTOOL_OLLAMAKEY"),
-		"huggingface": os.Getenv("POLLYTOOL_HUGGINGFACEKEY"),
-	}
-	return NewMultiPass(apiKeys)
+	return NewMultiPass(defaultProviders.APIKeysFromEnv())
 }
 
 // Collect calls ChatCompletionStream on the given LLM client and returns the final content string.
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+// maxSummarizedToolResult bounds how much of each tool result is shown to
+// the summarizer; large outputs rarely matter beyond their gist.
+const maxSummarizedToolResult = 2000
+
+const summarizeSystemPrompt = `You maintain a running summary of a conversation between a user and an AI assistant that can call tools.
+Merge the previous summary (if any) with the new transcript into one updated summary.
+Preserve decisions, facts, constraints, file names, commands, open questions and unfinished work.
+Drop pleasantries and redundant detail. Write plain prose or terse bullet points. Do not address the user.`
+
+// SummarizeConversation asks model to fold msgs into previous, returning the
+// updated rolling summary. It matches the sessions.Summarizer signature once
+// client and model are bound.
+func SummarizeConversation(ctx context.Context, client LLM, model, previous string, msgs []messages.ChatMessage) (string, error) {
+	var prompt strings.Builder
+	if previous != "" {
+		prompt.WriteString("Previous summary:\n")
+		prompt.WriteString(previous)
+		prompt.WriteString("\n\n")
+	}
+	prompt.WriteString("New transcript:\n")
+	prompt.WriteString(renderTranscript(msgs))
+
+	summary, err := Collect(ctx, client, &CompletionRequest{
+		Model: model,
+		Messages: []messages.ChatMessage{
+			{Role: messages.MessageRoleSystem, Content: summarizeSystemPrompt},
+			{Role: messages.MessageRoleUser, Content: prompt.String()},
+		},
+		MaxTokens: 2048,
+	})
+	if err != nil {
+		return "", fmt.Errorf("summarize conversation: %w", err)
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// renderTranscript flattens messages to text so the summarizer request does
+// not need to satisfy provider rules about tool call pairing.
+func renderTranscript(msgs []messages.ChatMessage) string {
+	var b strings.Builder
+	for _, msg := range msgs {
+		switch msg.Role {
+		case messages.MessageRoleTool:
+			result := msg.GetContent()
+			if len(result) > maxSummarizedToolResult {
+				result = result[:maxSummarizedToolResult] + " [truncated]"
+			}
+			fmt.Fprintf(&b, "[tool result %s]: %s\n", msg.ToolName, result)
+		default:
+			if content := msg.GetContent(); strings.TrimSpace(content) != "" {
+				fmt.Fprintf(&b, "[%s]: %s\n", msg.Role, content)
+			}
+			for _, tc := range msg.ToolCalls {
+				fmt.Fprintf(&b, "[%s called %s]: %s\n", msg.Role, tc.Name, tc.Arguments)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+func TestSummarizeConversation_RendersTranscriptForModel(t *testing.T) {
+	var gotReq *CompletionRequest
+	client := &scriptedLLM{replies: map[string][]string{"openai/gpt": {"  rolled summary  "}}}
+
+	summary, err := SummarizeConversation(context.Background(), llmFunc(func(ctx context.Context, req *CompletionRequest, p EventStreamProcessor) <-chan *messages.StreamEvent {
+		gotReq = req
+		return client.ChatCompletionStream(ctx, req, p)
+	}), "openai/gpt", "earlier facts", []messages.ChatMessage{
+		{Role: messages.MessageRoleUser, Content: "list files"},
+		{Role: messages.MessageRoleAssistant, ToolCalls: []messages.ChatMessageToolCall{{ID: "1", Name: "bash", Arguments: `{"command":"ls"}`}}},
+		{Role: messages.MessageRoleTool, ToolName: "bash", Content: strings.Repeat("a", maxSummarizedToolResult+10)},
+	})
+	if err != nil {
+		t.Fatalf("SummarizeConversation() error = %v", err)
+	}
+	if summary != "rolled summary" {
+		t.Fatalf("summary = %q, want trimmed model output", summary)
+	}
+
+	prompt := gotReq.Messages[1].Content
+	for _, want := range []string{"Previous summary:\nearlier facts", "[user]: list files", `[assistant called bash]: {"command":"ls"}`, "[tool result bash]", "[truncated]"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("summarizer prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	if len(gotReq.Tools) != 0 {
+		t.Fatal("summarizer request must not expose tools")
+	}
+}
+
+// llmFunc adapts a function to the LLM interface.
+type llmFunc func(context.Context, *CompletionRequest, EventStreamProcessor) <-chan *messages.StreamEvent
+
+func (f llmFunc) ChatCompletionStream(ctx context.Context, req *CompletionRequest, p EventStreamProcessor) <-chan *messages.StreamEvent {
+	return f(ctx, req, p)
+}
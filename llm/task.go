@@ -0,0 +1,260 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/schema"
+	"github.com/alexschlessinger/pollytool/sessions"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+// TaskToolName is the native tool that delegates work to a sub-agent
+const TaskToolName = "task"
+
+// maxTaskDepth bounds how deeply sub-agents may start sub-agents of their own
+const maxTaskDepth = 3
+
+// TaskConfig limits each sub-agent started by the task tool
+type TaskConfig struct {
+	MaxIterations int // Maximum LLM calls per sub-agent (default: 10)
+	TokenBudget   int // Maximum input+output tokens per sub-agent (0 = unlimited)
+}
+
+// SubAgentEventType identifies what a SubAgentEvent reports
+type SubAgentEventType string
+
+const (
+	SubAgentContent   SubAgentEventType = "content"
+	SubAgentReasoning SubAgentEventType = "reasoning"
+	SubAgentToolStart SubAgentEventType = "tool_start"
+	SubAgentToolEnd   SubAgentEventType = "tool_end"
+	SubAgentResponse  SubAgentEventType = "response"
+	SubAgentError     SubAgentEventType = "error"
+)
+
+// SubAgentEvent reports the activity of a sub-agent started by the task tool
+type SubAgentEvent struct {
+	Depth    int                            // 1 for a sub-agent of the top-level run, 2 for one it started, ...
+	Task     messages.ChatMessageToolCall   // The task call running the sub-agent
+	Type     SubAgentEventType              // What happened
+	Content  string                         // Streamed text for content and reasoning; the result for tool_end
+	Calls    []messages.ChatMessageToolCall // The calls starting for tool_start; the finished call for tool_end
+	Duration time.Duration                  // Execution time for tool_end
+	Response *messages.ChatMessage          // The LLM response for response
+	Err      error                          // Set for error, and for tool_end when the tool failed
+}
+
+// agentRun is what a running loop exposes to its tools through the context
+type agentRun struct {
+	agent *Agent
+	req   CompletionRequest
+	cb    *AgentCallbacks
+	depth int // 0 for a top-level run
+
+	mu           sync.Mutex
+	subAgents    []sessions.SubAgentRun
+	subAgentCost float64
+}
+
+type agentRunKey struct{}
+
+// toolCallKey carries the call a tool is executing for
+type toolCallKey struct{}
+
+// addSubAgents records the transcripts and spend of a finished sub-agent
+func (r *agentRun) addSubAgents(runs []sessions.SubAgentRun, cost float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subAgents = append(r.subAgents, runs...)
+	r.subAgentCost += cost
+}
+
+// takeSubAgentCost returns the spend of sub-agents finished since the last
+// call, so the loop can count it toward its own
+func (r *agentRun) takeSubAgentCost() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cost := r.subAgentCost
+	r.subAgentCost = 0
+	return cost
+}
+
+// TaskTool runs a sub-agent with its own system prompt and a subset of the
+// parent's tools, and returns only the sub-agent's final message.
+type TaskTool struct {
+	tools.NativeTool
+	config TaskConfig
+}
+
+// NewTaskTool creates the task tool. It can only run inside an Agent, whose
+// client, model and tools the sub-agents share.
+func NewTaskTool(config TaskConfig) *TaskTool {
+	if config.MaxIterations <= 0 {
+		config.MaxIterations = 10
+	}
+	return &TaskTool{config: config}
+}
+
+func (t *TaskTool) GetName() string { return TaskToolName }
+
+func (t *TaskTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool(TaskToolName, "Delegate a self-contained task to a sub-agent. The sub-agent starts fresh with only the given instructions and tools, and returns just its final answer.",
+		schema.Params{
+			"prompt": schema.S("The task, with all the context the sub-agent needs"),
+			"system": schema.S("System prompt for the sub-agent (optional)"),
+			"tools":  schema.Strings("Names of your tools the sub-agent may use (default: none)"),
+		},
+		"prompt",
+	)
+}
+
+func (t *TaskTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	parent, ok := ctx.Value(agentRunKey{}).(*agentRun)
+	if !ok {
+		return "", tools.NewToolError("task can only run inside an agent", tools.CodeUnavailable)
+	}
+	if parent.depth >= maxTaskDepth {
+		return "", tools.NewToolError(fmt.Sprintf("sub-agents may nest at most %d deep", maxTaskDepth), tools.CodeUnavailable)
+	}
+
+	args := tools.Args(raw)
+	prompt := args.String("prompt")
+	if prompt == "" {
+		return "", tools.NewToolError("prompt is required", tools.CodeInvalidArgs)
+	}
+	names := args.StringSlice("tools")
+	registry, err := parent.agent.toolSubset(names)
+	if err != nil {
+		return "", tools.NewToolError(err.Error(), tools.CodeInvalidArgs)
+	}
+
+	var msgs []messages.ChatMessage
+	if system := args.String("system"); system != "" {
+		msgs = append(msgs, messages.ChatMessage{Role: messages.MessageRoleSystem, Content: system})
+	}
+	msgs = append(msgs, messages.User(prompt)...)
+
+	req := parent.req
+	req.Messages = msgs
+	req.Tools = nil
+	req.Skills = nil
+	req.ResponseSchema = nil
+
+	config := parent.agent.config
+	child := NewAgent(parent.agent.client, registry, AgentConfig{
+		MaxIterations:    t.config.MaxIterations,
+		TokenBudget:      t.config.TokenBudget,
+		ToolTimeout:      config.ToolTimeout,
+		MaxParallelTools: config.MaxParallelTools,
+		Pricing:          config.Pricing,
+		MaxToolOutput:    config.MaxToolOutput,
+		Permissions:      config.Permissions,
+		Audit:            config.Audit,
+		ContextName:      config.ContextName,
+	})
+
+	task, _ := ctx.Value(toolCallKey{}).(messages.ChatMessageToolCall)
+	started := time.Now()
+	resp, runErr := child.Run(ctx, &req, subAgentCallbacks(parent, task))
+
+	record := sessions.SubAgentRun{
+		TaskID:   task.ID,
+		Depth:    parent.depth + 1,
+		Started:  started,
+		Tools:    names,
+		Messages: msgs,
+	}
+	var cost float64
+	var nested []sessions.SubAgentRun
+	if resp != nil {
+		record.Messages = append(record.Messages, resp.AllMessages...)
+		record.Iterations = resp.IterationCount
+		record.Cost = resp.Cost
+		cost = resp.Cost
+		nested = resp.SubAgents
+	}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	parent.addSubAgents(append([]sessions.SubAgentRun{record}, nested...), cost)
+
+	if runErr != nil {
+		msg := fmt.Sprintf("sub-agent stopped: %v", runErr)
+		if resp != nil && resp.Message != nil && resp.Message.Role == messages.MessageRoleAssistant && resp.Message.Content != "" {
+			msg += "\nLast message: " + resp.Message.Content
+		}
+		return "", tools.NewToolError(msg, tools.CodeUnavailable)
+	}
+	return resp.Message.Content, nil
+}
+
+// toolSubset builds a registry of the named tools from the agent's own, so
+// a sub-agent shares their sandboxes, MCP connections and skill policy.
+func (a *Agent) toolSubset(names []string) (*tools.ToolRegistry, error) {
+	var selected []tools.Tool
+	for _, name := range names {
+		// Every agent with an output cap brings its own read_tool_output
+		if name == tools.ReadToolOutputName && a.outputTool != nil {
+			continue
+		}
+		var tool tools.Tool
+		exists, allowed := false, false
+		if a.tools != nil {
+			tool, exists, allowed = a.tools.GetIfAllowed(name)
+		}
+		if !exists {
+			return nil, fmt.Errorf("unknown tool: %s", name)
+		}
+		if !allowed {
+			return nil, fmt.Errorf("tool not allowed by active skill policy: %s", name)
+		}
+		selected = append(selected, tool)
+	}
+	return tools.NewToolRegistry(selected), nil
+}
+
+// subAgentCallbacks reports a sub-agent's activity to the parent run's
+// OnSubAgentEvent, and applies the parent's approval and tool hooks to its
+// tool calls.
+func subAgentCallbacks(parent *agentRun, task messages.ChatMessageToolCall) *AgentCallbacks {
+	cb := parent.cb
+	if cb == nil {
+		return nil
+	}
+	depth := parent.depth + 1
+	emit := func(event SubAgentEvent) {
+		if cb.OnSubAgentEvent != nil {
+			event.Depth, event.Task = depth, task
+			cb.OnSubAgentEvent(event)
+		}
+	}
+	return &AgentCallbacks{
+		OnReasoning: func(content string) {
+			emit(SubAgentEvent{Type: SubAgentReasoning, Content: content})
+		},
+		OnContent: func(content string) {
+			emit(SubAgentEvent{Type: SubAgentContent, Content: content})
+		},
+		BeforeToolExecute: cb.BeforeToolExecute,
+		ApproveToolCalls:  cb.ApproveToolCalls,
+		OnToolProgress:    cb.OnToolProgress,
+		OnToolStart: func(calls []messages.ChatMessageToolCall) {
+			emit(SubAgentEvent{Type: SubAgentToolStart, Calls: calls})
+		},
+		OnToolEnd: func(call messages.ChatMessageToolCall, result string, duration time.Duration, err error) {
+			emit(SubAgentEvent{Type: SubAgentToolEnd, Calls: []messages.ChatMessageToolCall{call}, Content: result, Duration: duration, Err: err})
+		},
+		OnResponse: func(response *messages.ChatMessage) {
+			emit(SubAgentEvent{Type: SubAgentResponse, Response: response})
+		},
+		OnError: func(err error) {
+			emit(SubAgentEvent{Type: SubAgentError, Err: err})
+		},
+		// Deeper sub-agents mark their own depth
+		OnSubAgentEvent: cb.OnSubAgentEvent,
+	}
+}
@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/alexschlessinger/pollytool/tools"
+)
+
+func TestTaskToolRunsSubAgent(t *testing.T) {
+	var echoed int
+	echo := &tools.Func{
+		Name: "echo",
+		Run: func(context.Context, tools.Args) (string, error) {
+			echoed++
+			return "echoed", nil
+		},
+	}
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		last := req.Messages[len(req.Messages)-1]
+		if req.Messages[0].Content == "be a helper" {
+			// The sub-agent: one tool call, then an answer
+			if last.Role == messages.MessageRoleTool {
+				return messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "child saw " + last.Content, StopReason: messages.StopReasonEndTurn}
+			}
+			return messages.ChatMessage{
+				Role:       messages.MessageRoleAssistant,
+				ToolCalls:  []messages.ChatMessageToolCall{{ID: "c1", Name: "echo", Arguments: `{}`}},
+				StopReason: messages.StopReasonToolUse,
+			}
+		}
+		if last.Role == messages.MessageRoleTool {
+			return messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: "parent got " + last.Content, StopReason: messages.StopReasonEndTurn}
+		}
+		return messages.ChatMessage{
+			Role:       messages.MessageRoleAssistant,
+			ToolCalls:  []messages.ChatMessageToolCall{{ID: "t1", Name: TaskToolName, Arguments: `{"prompt":"check","system":"be a helper","tools":["echo"]}`}},
+			StopReason: messages.StopReasonToolUse,
+		}
+	})
+
+	registry := tools.NewToolRegistry([]tools.Tool{echo, NewTaskTool(TaskConfig{MaxIterations: 3})})
+	agent := NewAgent(fake, registry, AgentConfig{MaxIterations: 3})
+
+	var mu sync.Mutex
+	var parentTools []string
+	var events []SubAgentEvent
+	resp, err := agent.Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, &AgentCallbacks{
+		OnToolStart: func(calls []messages.ChatMessageToolCall) {
+			for _, tc := range calls {
+				parentTools = append(parentTools, tc.Name)
+			}
+		},
+		OnSubAgentEvent: func(event SubAgentEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Message.Content != "parent got child saw echoed" || echoed != 1 {
+		t.Errorf("final = %q with echo run %d times, want only the child's answer returned", resp.Message.Content, echoed)
+	}
+	if len(parentTools) != 1 || parentTools[0] != TaskToolName {
+		t.Errorf("parent OnToolStart saw %v, want only the task call", parentTools)
+	}
+	var sawChildTool bool
+	for _, event := range events {
+		if event.Depth != 1 || event.Task.ID != "t1" {
+			t.Errorf("event %+v not marked as depth 1 of task t1", event)
+		}
+		sawChildTool = sawChildTool || (event.Type == SubAgentToolStart && event.Calls[0].Name == "echo")
+	}
+	if !sawChildTool {
+		t.Errorf("events = %+v, want the child's echo call", events)
+	}
+
+	if len(resp.SubAgents) != 1 {
+		t.Fatalf("SubAgents = %+v, want 1 transcript", resp.SubAgents)
+	}
+	run := resp.SubAgents[0]
+	if run.TaskID != "t1" || run.Depth != 1 || run.Iterations != 2 || len(run.Messages) != 5 || run.Messages[0].Content != "be a helper" {
+		t.Errorf("transcript = %+v", run)
+	}
+}
+
+func TestTaskToolRejectsUnknownTools(t *testing.T) {
+	calls := 0
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		calls++
+		if last := req.Messages[len(req.Messages)-1]; last.Role == messages.MessageRoleTool {
+			return messages.ChatMessage{Role: messages.MessageRoleAssistant, Content: last.Content, StopReason: messages.StopReasonEndTurn}
+		}
+		return messages.ChatMessage{
+			Role:       messages.MessageRoleAssistant,
+			ToolCalls:  []messages.ChatMessageToolCall{{ID: "t1", Name: TaskToolName, Arguments: `{"prompt":"check","tools":["bash"]}`}},
+			StopReason: messages.StopReasonToolUse,
+		}
+	})
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{NewTaskTool(TaskConfig{})}), AgentConfig{MaxIterations: 3})
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Message.Content, "unknown tool: bash") || len(resp.SubAgents) != 0 || calls != 2 {
+		t.Errorf("Run() = %q with %d sub-agents after %d calls, want the task refused", resp.Message.Content, len(resp.SubAgents), calls)
+	}
+}
+
+func TestAgentTokenBudget(t *testing.T) {
+	fake := requestLLM(func(req *CompletionRequest) messages.ChatMessage {
+		msg := messages.ChatMessage{
+			Role:       messages.MessageRoleAssistant,
+			ToolCalls:  []messages.ChatMessageToolCall{{ID: "tc", Name: "noop", Arguments: `{}`}},
+			StopReason: messages.StopReasonToolUse,
+		}
+		msg.SetTokenUsage(60, 10)
+		return msg
+	})
+	noop := &tools.Func{Name: "noop", Run: func(context.Context, tools.Args) (string, error) { return "ok", nil }}
+	agent := NewAgent(fake, tools.NewToolRegistry([]tools.Tool{noop}), AgentConfig{MaxIterations: 10, TokenBudget: 100})
+
+	resp, err := agent.Run(context.Background(), &CompletionRequest{Messages: messages.User("hi")}, nil)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Run() error = %v, want ErrBudgetExceeded", err)
+	}
+	if resp.IterationCount != 2 {
+		t.Errorf("IterationCount = %d, want 2 calls before 140 tokens passed the budget of 100", resp.IterationCount)
+	}
+}
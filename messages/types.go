@@ -86,12 +86,13 @@ const (
 	MessageRoleTool      = "tool"
 )
 
-// Metadata keys for token usage and terminal errors
+// Metadata keys for token usage, terminal errors and the answering model
 const (
 	MetadataKeyInputTokens  = "input_tokens"
 	MetadataKeyOutputTokens = "output_tokens"
 	MetadataKeyIsError      = "is_error"
 	MetadataKeyError        = "error"
+	MetadataKeyModel        = "model"
 )
 
 // GetInputTokens returns the input token count from metadata, or 0 if not set
@@ -162,3 +163,23 @@ func (m *ChatMessage) GetError() error {
 	}
 	return nil
 }
+
+// SetModel records the provider/model that produced the message.
+func (m *ChatMessage) SetModel(model string) {
+	if model == "" {
+		return
+	}
+	if m.Metadata == nil {
+		m.Metadata = make(map[string]any)
+	}
+	m.Metadata[MetadataKeyModel] = model
+}
+
+// GetModel returns the provider/model that produced the message, or "" if unknown.
+func (m *ChatMessage) GetModel() string {
+	if m.Metadata == nil {
+		return ""
+	}
+	model, _ := m.Metadata[MetadataKeyModel].(string)
+	return model
+}
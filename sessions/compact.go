@@ -0,0 +1,172 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+// Compaction strategies selectable through Metadata.Compaction.
+const (
+	// CompactionTrim drops the oldest messages once MaxHistoryTokens is exceeded (default).
+	CompactionTrim = "trim"
+	// CompactionSummarize folds the oldest messages into a rolling summary.
+	CompactionSummarize = "summarize"
+)
+
+// metadataKeySummary marks the rolling summary message in history.
+const metadataKeySummary = "compaction_summary"
+
+// summaryPrefix introduces the summary so the model reads it as context.
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// compactKeepRatio is the share of MaxHistoryTokens kept verbatim; the rest
+// is left for the summary.
+const compactKeepRatio = 0.75
+
+// ErrTurnTooLarge is returned by CompactHistory when the latest user turn
+// alone does not fit in MaxHistoryTokens.
+var ErrTurnTooLarge = errors.New("latest user turn does not fit in the history limit")
+
+// Summarizer condenses messages that no longer fit into a summary. previous
+// is the existing rolling summary ("" if none) and must be folded in.
+type Summarizer func(ctx context.Context, previous string, dropped []messages.ChatMessage) (string, error)
+
+// Compactor is implemented by sessions that support summarization compaction.
+type Compactor interface {
+	// Compact summarizes the oldest messages when the history exceeds
+	// MaxHistoryTokens. It is a no-op unless Compaction is "summarize".
+	Compact(ctx context.Context, summarize Summarizer) error
+}
+
+// UsesSummaryCompaction reports whether metadata selects summarization.
+func UsesSummaryCompaction(m *Metadata) bool {
+	return m != nil && m.Compaction == CompactionSummarize && m.MaxHistoryTokens > 0
+}
+
+// ValidateCompaction checks a compaction strategy name.
+func ValidateCompaction(strategy string) error {
+	switch strategy {
+	case "", CompactionTrim, CompactionSummarize:
+		return nil
+	default:
+		return fmt.Errorf("invalid compaction strategy %q (use %q or %q)", strategy, CompactionTrim, CompactionSummarize)
+	}
+}
+
+// IsSummaryMessage reports whether msg is a rolling summary produced by compaction.
+func IsSummaryMessage(msg messages.ChatMessage) bool {
+	v, _ := msg.Metadata[metadataKeySummary].(bool)
+	return v
+}
+
+// SummaryText returns the summary carried by a summary message.
+func SummaryText(msg messages.ChatMessage) string {
+	return strings.TrimPrefix(msg.Content, summaryPrefix)
+}
+
+// NewSummaryMessage builds the history message that carries a rolling summary.
+func NewSummaryMessage(summary string) messages.ChatMessage {
+	return messages.ChatMessage{
+		Role:     messages.MessageRoleUser,
+		Content:  summaryPrefix + summary,
+		Metadata: map[string]any{metadataKeySummary: true},
+	}
+}
+
+// CompactHistory is the summarizing counterpart of TrimHistory. When the
+// history exceeds maxTokens, the oldest messages are passed to summarize
+// together with any existing summary, and replaced by a single summary
+// message placed after the system prompt. The cut never separates an
+// assistant tool_call from its tool results, and the latest user turn is
+// always kept verbatim; when it cannot fit, the history is returned as is
+// with ErrTurnTooLarge. On summarizer failure the error is returned along
+// with a plain TrimHistory result. counter estimates messages without
+// provider token counts; nil uses HeuristicCounter.
+func CompactHistory(ctx context.Context, history []messages.ChatMessage, maxTokens int, counter TokenCounter, summarize Summarizer) ([]messages.ChatMessage, error) {
+	if maxTokens <= 0 || len(history) == 0 {
+		return history, nil
+	}
+
+	total := 0
+	for _, msg := range history {
+		total += CountMessageTokens(msg, counter)
+	}
+	if total <= maxTokens {
+		return history, nil
+	}
+
+	var head []messages.ChatMessage
+	rest := history
+	if rest[0].Role == messages.MessageRoleSystem {
+		head = rest[:1]
+		rest = rest[1:]
+	}
+
+	previous := ""
+	if len(rest) > 0 && IsSummaryMessage(rest[0]) {
+		previous = SummaryText(rest[0])
+		rest = rest[1:]
+	}
+
+	// The latest user turn is kept whatever the budget
+	turn := len(rest)
+	for i := len(rest) - 1; i >= 0; i-- {
+		if rest[i].Role == messages.MessageRoleUser {
+			turn = i
+			break
+		}
+	}
+	needed := 0
+	for _, msg := range head {
+		needed += CountMessageTokens(msg, counter)
+	}
+	for _, msg := range rest[turn:] {
+		needed += CountMessageTokens(msg, counter)
+	}
+	if needed > maxTokens {
+		return history, fmt.Errorf("%w: it needs about %d tokens, over the limit of %d", ErrTurnTooLarge, needed, maxTokens)
+	}
+
+	// Keep the newest messages that fit in the verbatim budget
+	budget := int(float64(maxTokens) * compactKeepRatio)
+	used := 0
+	cut := len(rest)
+	for cut > 0 {
+		tokens := CountMessageTokens(rest[cut-1], counter)
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		cut--
+	}
+
+	// Tool results must stay with the assistant message that called them,
+	// so a kept suffix may not start with tool results.
+	for cut < len(rest) && rest[cut].Role == messages.MessageRoleTool {
+		cut++
+	}
+	cut = min(cut, turn)
+
+	dropped := rest[:cut]
+	if len(dropped) == 0 {
+		return history, nil
+	}
+
+	summary, err := summarize(ctx, previous, dropped)
+	if err == nil && strings.TrimSpace(summary) == "" {
+		err = fmt.Errorf("summarizer returned an empty summary")
+	}
+	if err != nil {
+		return TrimHistoryWithCounter(history, maxTokens, counter), fmt.Errorf("compaction failed, trimmed instead: %w", err)
+	}
+
+	result := make([]messages.ChatMessage, 0, len(head)+1+len(rest)-cut)
+	result = append(result, head...)
+	result = append(result, NewSummaryMessage(strings.TrimSpace(summary)))
+	result = append(result, rest[cut:]...)
+	return result, nil
+}
@@ -0,0 +1,221 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+// padded returns content of roughly n tokens under EstimateTokens.
+func padded(n int) string {
+	return strings.Repeat("x", (n-4)*4)
+}
+
+func TestCompactHistory_UnderLimitIsNoop(t *testing.T) {
+	history := []messages.ChatMessage{
+		{Role: messages.MessageRoleSystem, Content: "sys"},
+		{Role: messages.MessageRoleUser, Content: "hi"},
+	}
+	called := false
+	got, err := CompactHistory(context.Background(), history, 1000, nil, func(context.Context, string, []messages.ChatMessage) (string, error) {
+		called = true
+		return "", nil
+	})
+	if err != nil || called || len(got) != 2 {
+		t.Fatalf("CompactHistory() = %d msgs, err %v, called %v; want untouched history", len(got), err, called)
+	}
+}
+
+func TestCompactHistory_FoldsDroppedTurnsKeepingToolPairs(t *testing.T) {
+	history := []messages.ChatMessage{
+		{Role: messages.MessageRoleSystem, Content: "sys"},
+		{Role: messages.MessageRoleUser, Content: padded(20)},
+		{Role: messages.MessageRoleAssistant, ToolCalls: []messages.ChatMessageToolCall{{ID: "1", Name: "bash", Arguments: `{}`}}},
+		{Role: messages.MessageRoleTool, ToolCallID: "1", Content: padded(20)},
+		{Role: messages.MessageRoleTool, ToolCallID: "1", Content: padded(20)},
+		{Role: messages.MessageRoleAssistant, Content: padded(20)},
+		{Role: messages.MessageRoleUser, Content: "next"},
+	}
+
+	var gotDropped []messages.ChatMessage
+	got, err := CompactHistory(context.Background(), history, 60, nil, func(_ context.Context, previous string, dropped []messages.ChatMessage) (string, error) {
+		if previous != "" {
+			t.Fatalf("previous = %q, want empty on first compaction", previous)
+		}
+		gotDropped = dropped
+		return "user asked for bash", nil
+	})
+	if err != nil {
+		t.Fatalf("CompactHistory() error = %v", err)
+	}
+
+	// Budget keeps ~45 tokens: the cut would land inside the tool results,
+	// so both results go to the summary along with their tool call.
+	if len(gotDropped) != 4 || gotDropped[1].ToolCalls == nil || gotDropped[3].Role != messages.MessageRoleTool {
+		t.Fatalf("dropped = %#v, want user + tool call + both results", gotDropped)
+	}
+	if len(got) != 4 || got[0].Role != messages.MessageRoleSystem || !IsSummaryMessage(got[1]) || got[2].Role != messages.MessageRoleAssistant || got[3].Content != "next" {
+		t.Fatalf("compacted history = %#v", got)
+	}
+	if SummaryText(got[1]) != "user asked for bash" {
+		t.Fatalf("summary = %q", SummaryText(got[1]))
+	}
+}
+
+func TestCompactHistory_RollsPreviousSummary(t *testing.T) {
+	history := []messages.ChatMessage{
+		{Role: messages.MessageRoleSystem, Content: "sys"},
+		NewSummaryMessage("earlier"),
+		{Role: messages.MessageRoleUser, Content: padded(40)},
+		{Role: messages.MessageRoleAssistant, Content: padded(40)},
+		{Role: messages.MessageRoleUser, Content: "next"},
+	}
+
+	got, err := CompactHistory(context.Background(), history, 60, nil, func(_ context.Context, previous string, dropped []messages.ChatMessage) (string, error) {
+		if previous != "earlier" {
+			t.Fatalf("previous = %q, want earlier summary", previous)
+		}
+		for _, msg := range dropped {
+			if IsSummaryMessage(msg) {
+				t.Fatal("previous summary must not be passed as a dropped message")
+			}
+		}
+		return "earlier + more", nil
+	})
+	if err != nil {
+		t.Fatalf("CompactHistory() error = %v", err)
+	}
+	if len(got) != 4 || SummaryText(got[1]) != "earlier + more" || got[3].Content != "next" {
+		t.Fatalf("compacted history = %#v", got)
+	}
+}
+
+func TestCompactHistory_FallsBackToTrimOnError(t *testing.T) {
+	history := []messages.ChatMessage{
+		{Role: messages.MessageRoleSystem, Content: "sys"},
+		{Role: messages.MessageRoleUser, Content: padded(40)},
+		{Role: messages.MessageRoleAssistant, Content: padded(40)},
+		{Role: messages.MessageRoleUser, Content: "next"},
+	}
+
+	got, err := CompactHistory(context.Background(), history, 60, nil, func(context.Context, string, []messages.ChatMessage) (string, error) {
+		return "", errors.New("provider down")
+	})
+	if err == nil || !strings.Contains(err.Error(), "provider down") {
+		t.Fatalf("CompactHistory() error = %v, want summarizer error", err)
+	}
+	if len(got) != 3 || got[1].Role != messages.MessageRoleAssistant || got[2].Content != "next" {
+		t.Fatalf("fallback history = %#v, want TrimHistory result", got)
+	}
+}
+
+func TestCompactHistory_KeepsLatestUserTurn(t *testing.T) {
+	history := []messages.ChatMessage{
+		{Role: messages.MessageRoleSystem, Content: "sys"},
+		{Role: messages.MessageRoleUser, Content: padded(10)},
+		{Role: messages.MessageRoleAssistant, Content: padded(10)},
+		{Role: messages.MessageRoleUser, Content: padded(50)},
+	}
+	summarize := func(_ context.Context, _ string, dropped []messages.ChatMessage) (string, error) {
+		if len(dropped) != 2 {
+			t.Fatalf("dropped = %#v, want the earlier exchange only", dropped)
+		}
+		return "earlier", nil
+	}
+
+	// The turn is over the verbatim budget but still fits the limit
+	got, err := CompactHistory(context.Background(), history, 60, nil, summarize)
+	if err != nil {
+		t.Fatalf("CompactHistory() error = %v", err)
+	}
+	if len(got) != 3 || !IsSummaryMessage(got[1]) || got[2].Content != history[3].Content {
+		t.Fatalf("compacted history = %#v, want the latest turn verbatim", got)
+	}
+
+	// A turn over the limit is an error and the history is left alone
+	got, err = CompactHistory(context.Background(), history, 40, nil, func(context.Context, string, []messages.ChatMessage) (string, error) {
+		t.Fatal("summarizer should not run when the turn cannot fit")
+		return "", nil
+	})
+	if !errors.Is(err, ErrTurnTooLarge) {
+		t.Fatalf("CompactHistory() error = %v, want ErrTurnTooLarge", err)
+	}
+	if len(got) != len(history) {
+		t.Fatalf("history = %#v, want it untouched", got)
+	}
+}
+
+func TestSessionCompact_SummarizeModeDefersTrimAndPersists(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			session, err := store.Get("compact")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer session.Close()
+			if err := session.UpdateMetadata(&Metadata{Compaction: CompactionSummarize}); err != nil {
+				t.Fatalf("UpdateMetadata() error = %v", err)
+			}
+
+			for range 4 {
+				session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleUser, Content: padded(30)})
+			}
+			if got := len(session.GetHistory()); got != 5 {
+				t.Fatalf("history length = %d, want 5 (no trimming before Compact)", got)
+			}
+
+			compactor, ok := session.(Compactor)
+			if !ok {
+				t.Fatalf("%T does not implement Compactor", session)
+			}
+			if err := compactor.Compact(context.Background(), func(context.Context, string, []messages.ChatMessage) (string, error) {
+				return "summary", nil
+			}); err != nil {
+				t.Fatalf("Compact() error = %v", err)
+			}
+
+			history := session.GetHistory()
+			if len(history) < 2 || !IsSummaryMessage(history[1]) {
+				t.Fatalf("history after Compact = %#v, want summary after system prompt", history)
+			}
+			if session.GetTotalTokens() > 70 {
+				t.Fatalf("GetTotalTokens() = %d, want within MaxHistoryTokens", session.GetTotalTokens())
+			}
+		})
+	}
+
+	fileStore := testStores(t)["File"]
+	session, _ := fileStore.Get("persisted")
+	_ = session.UpdateMetadata(&Metadata{Compaction: CompactionSummarize})
+	for range 4 {
+		session.AddMessage(messages.ChatMessage{Role: messages.MessageRoleUser, Content: padded(30)})
+	}
+	_ = session.(Compactor).Compact(context.Background(), func(context.Context, string, []messages.ChatMessage) (string, error) {
+		return "kept on disk", nil
+	})
+	session.Close()
+
+	reloaded, err := fileStore.Get("persisted")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer reloaded.Close()
+	history := reloaded.GetHistory()
+	if len(history) < 2 || !IsSummaryMessage(history[1]) || SummaryText(history[1]) != "kept on disk" {
+		t.Fatalf("reloaded history = %#v, want persisted summary", history)
+	}
+}
+
+func TestSessionCompact_TrimModeIsNoop(t *testing.T) {
+	s := newTestSession(&Metadata{MaxHistoryTokens: 10})
+	s.history = []messages.ChatMessage{{Role: messages.MessageRoleUser, Content: padded(40)}}
+	if err := s.Compact(context.Background(), func(context.Context, string, []messages.ChatMessage) (string, error) {
+		t.Fatal("summarizer should not run without summarize compaction")
+		return "", nil
+	}); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+}
@@ -295,13 +295,51 @@ func (s *FileSession) AddMessage(msg messages.ChatMessage) {
 	s.save()
 }
 
-// trimHistory limits the session history to MaxHistoryTokens
+// trimHistory limits the session history to MaxHistoryTokens.
+// Summarizing contexts are left to Compact.
 func (s *FileSession) trimHistory() {
-	if s.Metadata.MaxHistoryTokens > 0 {
-		s.History = TrimHistory(s.History, s.Metadata.MaxHistoryTokens)
+	if s.Metadata.MaxHistoryTokens > 0 && !UsesSummaryCompaction(s.Metadata) {
+		s.History = TrimHistoryWithCounter(s.History, s.Metadata.MaxHistoryTokens, s.tokenCounter())
 	}
 }
 
+// tokenCounter returns the counter for the context's model.
+// Callers must hold s.mu.
+func (s *FileSession) tokenCounter() TokenCounter {
+	if s.Metadata == nil {
+		return HeuristicCounter{}
+	}
+	return TokenCounterForModel(s.Metadata.Model)
+}
+
+// Compact folds the oldest messages into a rolling summary when the context
+// uses summarize compaction and exceeds MaxHistoryTokens. The summary is
+// persisted with the history.
+func (s *FileSession) Compact(ctx context.Context, summarize Summarizer) error {
+	s.mu.RLock()
+	if !UsesSummaryCompaction(s.Metadata) {
+		s.mu.RUnlock()
+		return nil
+	}
+	history := CopyHistory(s.History)
+	maxTokens := s.Metadata.MaxHistoryTokens
+	counter := s.tokenCounter()
+	s.mu.RUnlock()
+
+	// Summarize without holding the lock; the LLM call can be slow
+	compacted, err := CompactHistory(ctx, history, maxTokens, counter, summarize)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Keep messages added while summarizing
+	s.History = append(compacted, s.History[min(len(history), len(s.History)):]...)
+	s.Updated = time.Now()
+	if saveErr := s.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return err
+}
+
 // Clear clears the session history
 func (s *FileSession) Clear() {
 	s.mu.Lock()
@@ -452,9 +490,10 @@ func (s *FileSession) GetTotalTokens() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	counter := s.tokenCounter()
 	total := 0
 	for _, msg := range s.History {
-		total += GetMessageTokens(msg)
+		total += CountMessageTokens(msg, counter)
 	}
 	return total
 }
@@ -469,9 +508,10 @@ func (s *FileSession) GetCapacityPercentage() float64 {
 		return 0 // No limit set
 	}
 
+	counter := s.tokenCounter()
 	total := 0
 	for _, msg := range s.History {
-		total += GetMessageTokens(msg)
+		total += CountMessageTokens(msg, counter)
 	}
 
 	return float64(total) / float64(s.Metadata.MaxHistoryTokens) * 100
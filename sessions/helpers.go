@@ -11,7 +11,13 @@ import (
 // TrimHistory applies smart trimming to a message history slice.
 // It keeps the system prompt (first message) and the most recent messages that fit within the token limit.
 // maxTokens: maximum tokens to keep (0 = unlimited).
+// Messages without provider token counts are estimated with HeuristicCounter.
 func TrimHistory(history []messages.ChatMessage, maxTokens int) []messages.ChatMessage {
+	return TrimHistoryWithCounter(history, maxTokens, nil)
+}
+
+// TrimHistoryWithCounter is TrimHistory using counter for the active model.
+func TrimHistoryWithCounter(history []messages.ChatMessage, maxTokens int, counter TokenCounter) []messages.ChatMessage {
 	if len(history) == 0 {
 		return history
 	}
@@ -38,7 +44,7 @@ func TrimHistory(history []messages.ChatMessage, maxTokens int) []messages.ChatM
 		// Calculate tokens from newest to oldest
 		keepCount := 0
 		for i := len(msgs) - 1; i >= 0; i-- {
-			tokens := GetMessageTokens(msgs[i])
+			tokens := CountMessageTokens(msgs[i], counter)
 			if currentTokens+tokens > maxTokens {
 				break
 			}
@@ -75,6 +81,12 @@ func TrimHistory(history []messages.ChatMessage, maxTokens int) []messages.ChatM
 // It prefers actual token counts from provider metadata if available,
 // otherwise falls back to estimation.
 func GetMessageTokens(msg messages.ChatMessage) int {
+	return CountMessageTokens(msg, nil)
+}
+
+// CountMessageTokens is GetMessageTokens with counter used for the estimate.
+// A nil counter uses HeuristicCounter.
+func CountMessageTokens(msg messages.ChatMessage, counter TokenCounter) int {
 	// Prefer actual tokens from metadata
 	if input := msg.GetInputTokens(); input > 0 {
 		return input
@@ -83,41 +95,14 @@ func GetMessageTokens(msg messages.ChatMessage) int {
 		return output
 	}
 	// Fall back to estimate
-	return EstimateTokens(msg)
+	return CountTokens(msg, counter)
 }
 
 // EstimateTokens provides a rough estimate of tokens in a message.
-// It uses a simple heuristic: 1 token ≈ 4 characters.
+// It uses a simple heuristic: 1 token ≈ 4 characters, and prices images
+// with OpenAIImageTokens.
 func EstimateTokens(msg messages.ChatMessage) int {
-	count := 0
-
-	// Content
-	count += len(msg.Content) / 4
-
-	// Multimodal parts
-	for _, part := range msg.Parts {
-		if part.Type == "text" {
-			count += len(part.Text) / 4
-		}
-		// TODO: Add estimation for images if needed
-	}
-
-	// Tool calls
-	for _, tc := range msg.ToolCalls {
-		count += len(tc.Name) / 4
-		count += len(tc.Arguments) / 4
-	}
-
-	// Reasoning
-	count += len(msg.Reasoning) / 4
-
-	// Tool Call ID
-	count += len(msg.ToolCallID) / 4
-
-	// Base overhead per message
-	count += 4
-
-	return count
+	return CountTokens(msg, HeuristicCounter{})
 }
 
 // CopyHistory creates a defensive copy of the history slice
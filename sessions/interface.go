@@ -59,6 +59,7 @@ type Metadata struct {
 	Temperature      float64                `json:"temperature,omitempty"`
 	MaxTokens        int                    `json:"maxTokens,omitempty"`
 	MaxHistoryTokens int                    `json:"maxHistoryTokens,omitempty"`
+	Compaction       string                 `json:"compaction,omitempty"` // "trim" (default) or "summarize"
 	ThinkingEffort   string                 `json:"thinkingEffort,omitempty"`
 	SystemPrompt     string                 `json:"systemPrompt,omitempty"`
 	ActiveTools      []tools.ToolLoaderInfo `json:"activeTools,omitempty"`
@@ -67,4 +68,95 @@ type Metadata struct {
 	ToolTimeout      time.Duration          `json:"toolTimeout,omitempty"`
 	SkillDirs        []string               `json:"skillDirs,omitempty"`
 	SkillSources     []string               `json:"skillSources,omitempty"`
+	Budget           float64                `json:"budget,omitempty"` // Maximum total USD spend (0 = unlimited)
+
+	// Accumulated usage
+	TotalCost float64 `json:"totalCost,omitempty"` // USD spent across all runs
+
+	// Agent run that stopped early and can be resumed
+	RunState *RunState `json:"runState,omitempty"`
+
+	// Transcripts of sub-agents started by the task tool, oldest first
+	SubAgents []SubAgentRun `json:"subAgents,omitempty"`
+
+	// Approved plan of the last planned turn and the progress of its steps
+	Plan *Plan `json:"plan,omitempty"`
+}
+
+// Plan step statuses
+const (
+	PlanStepPending = "pending"
+	PlanStepRunning = "running"
+	PlanStepDone    = "done"
+	PlanStepFailed  = "failed"
+)
+
+// Plan is the step list of a plan-then-execute turn. Each step runs as a
+// turn of its own once the plan is approved.
+type Plan struct {
+	Task  string     `json:"task"`  // The request the plan was made for
+	Steps []PlanStep `json:"steps"` // Steps in execution order
+}
+
+// PlanStep is one step of a Plan and its progress.
+type PlanStep struct {
+	Description string `json:"description"`      // What the step does
+	Status      string `json:"status"`           // PlanStepPending, PlanStepRunning, PlanStepDone or PlanStepFailed
+	Result      string `json:"result,omitempty"` // The step's final message, or why it failed
+}
+
+// NextStep returns the index of the first step not yet done, or -1 when
+// the plan is complete.
+func (p *Plan) NextStep() int {
+	if p == nil {
+		return -1
+	}
+	for i, step := range p.Steps {
+		if step.Status != PlanStepDone {
+			return i
+		}
+	}
+	return -1
+}
+
+// SubAgentRun is the transcript of a sub-agent started by the task tool.
+// Only its final message went back to the parent; the rest is kept here
+// for inspection.
+type SubAgentRun struct {
+	TaskID     string                 `json:"taskId"`          // Tool call that started the sub-agent
+	Depth      int                    `json:"depth"`           // 1 for a sub-agent of the top-level run
+	Started    time.Time              `json:"started"`         // When the sub-agent started
+	Tools      []string               `json:"tools,omitempty"` // Tools the sub-agent could use
+	Messages   []messages.ChatMessage `json:"messages"`        // Its system prompt, task and everything it generated
+	Iterations int                    `json:"iterations"`      // LLM calls made
+	Cost       float64                `json:"cost,omitempty"`  // USD spent
+	Error      string                 `json:"error,omitempty"` // Why it stopped early, if it did
+}
+
+// RunState records where an agent run stopped early (max iterations, a
+// timeout, an interrupt or the budget) so it can continue without starting
+// over. The messages the run generated are already in the history.
+type RunState struct {
+	Iteration        int                            `json:"iteration"`                  // LLM calls made before stopping
+	PendingToolCalls []messages.ChatMessageToolCall `json:"pendingToolCalls,omitempty"` // Requested calls that never ran
+	Reason           string                         `json:"reason,omitempty"`           // Why the run stopped
+}
+
+// AbandonPending returns tool results for the calls that never ran, for
+// closing out a stopped run that will not be resumed. Providers reject a
+// history whose tool calls lack results.
+func (s *RunState) AbandonPending() []messages.ChatMessage {
+	if s == nil {
+		return nil
+	}
+	var results []messages.ChatMessage
+	for _, tc := range s.PendingToolCalls {
+		results = append(results, messages.ChatMessage{
+			Role:       messages.MessageRoleTool,
+			Content:    "Tool call not run: the run was interrupted.",
+			ToolCallID: tc.ID,
+			ToolName:   tc.Name,
+		})
+	}
+	return results
 }
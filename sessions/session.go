@@ -1,6 +1,7 @@
 package sessions
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -178,12 +179,45 @@ func (s *LocalSession) AddMessage(msg messages.ChatMessage) {
 	s.trimHistory()
 }
 
-// trimHistory limits the session history to MaxHistoryTokens
+// trimHistory limits the session history to MaxHistoryTokens.
+// Summarizing contexts are left to Compact.
 func (s *LocalSession) trimHistory() {
-	if s.metadata.MaxHistoryTokens == 0 {
+	if s.metadata.MaxHistoryTokens == 0 || UsesSummaryCompaction(s.metadata) {
 		return
 	}
-	s.history = TrimHistory(s.history, s.metadata.MaxHistoryTokens)
+	s.history = TrimHistoryWithCounter(s.history, s.metadata.MaxHistoryTokens, s.tokenCounter())
+}
+
+// tokenCounter returns the counter for the context's model.
+// Callers must hold s.mu.
+func (s *LocalSession) tokenCounter() TokenCounter {
+	if s.metadata == nil {
+		return HeuristicCounter{}
+	}
+	return TokenCounterForModel(s.metadata.Model)
+}
+
+// Compact folds the oldest messages into a rolling summary when the context
+// uses summarize compaction and exceeds MaxHistoryTokens.
+func (s *LocalSession) Compact(ctx context.Context, summarize Summarizer) error {
+	s.mu.RLock()
+	if !UsesSummaryCompaction(s.metadata) {
+		s.mu.RUnlock()
+		return nil
+	}
+	history := CopyHistory(s.history)
+	maxTokens := s.metadata.MaxHistoryTokens
+	counter := s.tokenCounter()
+	s.mu.RUnlock()
+
+	// Summarize without holding the lock; the LLM call can be slow
+	compacted, err := CompactHistory(ctx, history, maxTokens, counter, summarize)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Keep messages added while summarizing
+	s.history = append(compacted, s.history[min(len(history), len(s.history)):]...)
+	return err
 }
 
 // Clear clears the session history
@@ -251,9 +285,10 @@ func (s *LocalSession) GetTotalTokens() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	counter := s.tokenCounter()
 	total := 0
 	for _, msg := range s.history {
-		total += GetMessageTokens(msg)
+		total += CountMessageTokens(msg, counter)
 	}
 	return total
 }
@@ -268,9 +303,10 @@ func (s *LocalSession) GetCapacityPercentage() float64 {
 		return 0 // No limit set
 	}
 
+	counter := s.tokenCounter()
 	total := 0
 	for _, msg := range s.history {
-		total += GetMessageTokens(msg)
+		total += CountMessageTokens(msg, counter)
 	}
 
 	return float64(total) / float64(s.metadata.MaxHistoryTokens) * 100
@@ -472,3 +472,18 @@ func TestExpiryGoroutine(t *testing.T) {
 		t.Errorf("Session2 should have expired, expected 1 message, got %d", len(finalHistory2))
 	}
 }
+
+func TestPlanNextStep(t *testing.T) {
+	plan := &Plan{Steps: []PlanStep{
+		{Status: PlanStepDone},
+		{Status: PlanStepFailed},
+		{Status: PlanStepPending},
+	}}
+	if next := plan.NextStep(); next != 1 {
+		t.Errorf("NextStep() = %d, want the failed step", next)
+	}
+	plan.Steps[1].Status, plan.Steps[2].Status = PlanStepDone, PlanStepDone
+	if next := plan.NextStep(); next != -1 {
+		t.Errorf("NextStep() = %d for a finished plan, want -1", next)
+	}
+}
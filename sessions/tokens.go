@@ -0,0 +1,265 @@
+package sessions
+
+import (
+	"encoding/base64"
+	"image"
+	_ "image/gif"  // register GIF for image.DecodeConfig
+	_ "image/jpeg" // register JPEG for image.DecodeConfig
+	_ "image/png"  // register PNG for image.DecodeConfig
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/alexschlessinger/pollytool/messages"
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+func init() {
+	// Use the embedded BPE ranks instead of downloading them on first use
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// messageOverhead approximates the per-message framing tokens (role, separators).
+const messageOverhead = 4
+
+// TokenCounter counts tokens the way a model family's tokenizer would.
+type TokenCounter interface {
+	// CountText returns the number of tokens in text.
+	CountText(text string) int
+	// CountImage returns the token cost of an image. width and height are
+	// in pixels and are 0 when the dimensions are unknown.
+	CountImage(width, height int) int
+}
+
+// ImageCost computes the token cost of an image from its pixel dimensions.
+type ImageCost func(width, height int) int
+
+// HeuristicCounter estimates 1 token per 4 characters. It is used when the
+// model is unknown.
+type HeuristicCounter struct{}
+
+// CountText implements TokenCounter.
+func (HeuristicCounter) CountText(text string) int {
+	return len(text) / 4
+}
+
+// CountImage implements TokenCounter.
+func (HeuristicCounter) CountImage(width, height int) int {
+	return OpenAIImageTokens(width, height)
+}
+
+// BPECounter counts tokens with an offline tiktoken encoding such as
+// "o200k_base" or "cl100k_base". Encodings load lazily on first use.
+type BPECounter struct {
+	encoding string
+	images   ImageCost
+
+	once sync.Once
+	enc  *tiktoken.Tiktoken
+
+	// Long strings (file contents, tool output) are counted repeatedly as
+	// history is trimmed, so their counts are cached.
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+// bpeCacheMin and bpeCacheMax bound which strings are cached and how many.
+const (
+	bpeCacheMin = 256
+	bpeCacheMax = 1024
+)
+
+// NewBPECounter returns a counter for the named tiktoken encoding. images
+// prices image parts; nil uses OpenAIImageTokens.
+func NewBPECounter(encoding string, images ImageCost) *BPECounter {
+	if images == nil {
+		images = OpenAIImageTokens
+	}
+	return &BPECounter{encoding: encoding, images: images}
+}
+
+// CountText implements TokenCounter. It falls back to the heuristic if the
+// encoding cannot be loaded.
+func (c *BPECounter) CountText(text string) int {
+	if text == "" {
+		return 0
+	}
+	c.once.Do(func() {
+		c.enc, _ = tiktoken.GetEncoding(c.encoding)
+	})
+	if c.enc == nil {
+		return HeuristicCounter{}.CountText(text)
+	}
+	if len(text) < bpeCacheMin {
+		return len(c.enc.EncodeOrdinary(text))
+	}
+
+	c.mu.Lock()
+	n, ok := c.cache[text]
+	c.mu.Unlock()
+	if ok {
+		return n
+	}
+	n = len(c.enc.EncodeOrdinary(text))
+	c.mu.Lock()
+	if c.cache == nil || len(c.cache) >= bpeCacheMax {
+		c.cache = make(map[string]int)
+	}
+	c.cache[text] = n
+	c.mu.Unlock()
+	return n
+}
+
+// CountImage implements TokenCounter.
+func (c *BPECounter) CountImage(width, height int) int {
+	return c.images(width, height)
+}
+
+// OpenAIImageTokens prices an image using OpenAI's high-detail tiling: the
+// image is fit within 2048x2048, scaled so its short side is at most 768,
+// then billed 170 tokens per 512px tile plus 85. Unknown sizes are priced
+// as 1024x1024.
+func OpenAIImageTokens(width, height int) int {
+	w, h := imageSize(width, height)
+	w, h = fitWithin(w, h, 2048)
+	if short := min(w, h); short > 768 {
+		scale := 768 / short
+		w, h = w*scale, h*scale
+	}
+	tiles := math.Ceil(w/512) * math.Ceil(h/512)
+	return 85 + 170*int(tiles)
+}
+
+// AnthropicImageTokens prices an image as width*height/750 after Anthropic's
+// resize to a 1568px long edge. Unknown sizes are priced as 1024x1024.
+func AnthropicImageTokens(width, height int) int {
+	w, h := imageSize(width, height)
+	w, h = fitWithin(w, h, 1568)
+	return int(math.Ceil(w * h / 750))
+}
+
+// GeminiImageTokens prices an image at 258 tokens when both sides are at
+// most 384px, otherwise 258 per 768x768 tile. Unknown sizes are priced as
+// 1024x1024.
+func GeminiImageTokens(width, height int) int {
+	w, h := imageSize(width, height)
+	if w <= 384 && h <= 384 {
+		return 258
+	}
+	return 258 * int(math.Ceil(w/768)*math.Ceil(h/768))
+}
+
+// imageSize substitutes 1024x1024 for unknown dimensions.
+func imageSize(width, height int) (float64, float64) {
+	if width <= 0 || height <= 0 {
+		return 1024, 1024
+	}
+	return float64(width), float64(height)
+}
+
+// fitWithin scales w and h down so neither exceeds limit.
+func fitWithin(w, h, limit float64) (float64, float64) {
+	if long := max(w, h); long > limit {
+		scale := limit / long
+		w, h = math.Floor(w*scale), math.Floor(h*scale)
+	}
+	return w, h
+}
+
+// Shared counters; encodings are loaded once per process.
+var (
+	o200kCounter     = NewBPECounter("o200k_base", OpenAIImageTokens)
+	cl100kCounter    = NewBPECounter("cl100k_base", OpenAIImageTokens)
+	anthropicCounter = NewBPECounter("cl100k_base", AnthropicImageTokens)
+	geminiCounter    = NewBPECounter("o200k_base", GeminiImageTokens)
+)
+
+var (
+	countersMu sync.RWMutex
+	counters   = map[string]TokenCounter{}
+)
+
+// RegisterTokenCounter installs a counter for every model of a provider
+// (the part of "provider/model" before the slash), overriding the built-in
+// choice.
+func RegisterTokenCounter(provider string, counter TokenCounter) {
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	counters[strings.ToLower(provider)] = counter
+}
+
+// TokenCounterForModel returns the counter for a "provider/model" name.
+// OpenAI models use their exact encoding; other providers are approximated
+// with the closest offline encoding. An empty model uses HeuristicCounter.
+func TokenCounterForModel(model string) TokenCounter {
+	if model == "" {
+		return HeuristicCounter{}
+	}
+	provider, name, found := strings.Cut(strings.ToLower(model), "/")
+	if !found {
+		provider, name = "", provider
+	}
+
+	countersMu.RLock()
+	custom, ok := counters[provider]
+	countersMu.RUnlock()
+	if ok {
+		return custom
+	}
+
+	switch provider {
+	case "anthropic":
+		return anthropicCounter
+	case "gemini":
+		return geminiCounter
+	case "openai":
+		// Pre-4o chat models use cl100k_base
+		if strings.HasPrefix(name, "gpt-4") && !strings.HasPrefix(name, "gpt-4o") && !strings.HasPrefix(name, "gpt-4.") ||
+			strings.HasPrefix(name, "gpt-3.5") {
+			return cl100kCounter
+		}
+	}
+	return o200kCounter
+}
+
+// CountTokens counts the tokens of a message with counter, covering text,
+// images, tool calls, reasoning and per-message overhead. A nil counter
+// uses HeuristicCounter.
+func CountTokens(msg messages.ChatMessage, counter TokenCounter) int {
+	if counter == nil {
+		counter = HeuristicCounter{}
+	}
+	count := counter.CountText(msg.Content)
+
+	for _, part := range msg.Parts {
+		switch part.Type {
+		case "text":
+			count += counter.CountText(part.Text)
+		case "image_base64":
+			w, h := imageDimensions(part.ImageData)
+			count += counter.CountImage(w, h)
+		case "image_url":
+			count += counter.CountImage(0, 0)
+		}
+	}
+
+	for _, tc := range msg.ToolCalls {
+		count += counter.CountText(tc.Name)
+		count += counter.CountText(tc.Arguments)
+	}
+	count += counter.CountText(msg.Reasoning)
+	count += counter.CountText(msg.ToolCallID)
+
+	return count + messageOverhead
+}
+
+// imageDimensions decodes the header of a base64 image. It returns 0, 0 for
+// formats the standard library cannot read.
+func imageDimensions(data string) (int, int) {
+	cfg, _, err := image.DecodeConfig(base64.NewDecoder(base64.StdEncoding, strings.NewReader(data)))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
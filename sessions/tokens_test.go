@@ -0,0 +1,152 @@
+package sessions
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/messages"
+)
+
+func TestTokenCounterForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  TokenCounter
+	}{
+		{"", HeuristicCounter{}},
+		{"openai/gpt-4o-mini", o200kCounter},
+		{"openai/gpt-4.1", o200kCounter},
+		{"openai/gpt-4-turbo", cl100kCounter},
+		{"openai/gpt-3.5-turbo", cl100kCounter},
+		{"anthropic/claude-sonnet-4", anthropicCounter},
+		{"gemini/gemini-2.5-flash", geminiCounter},
+		{"ollama/llama3", o200kCounter},
+	}
+	for _, tt := range tests {
+		if got := TokenCounterForModel(tt.model); got != tt.want {
+			t.Errorf("TokenCounterForModel(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterTokenCounter(t *testing.T) {
+	custom := HeuristicCounter{}
+	RegisterTokenCounter("Custom", custom)
+	defer func() {
+		countersMu.Lock()
+		delete(counters, "custom")
+		countersMu.Unlock()
+	}()
+
+	if got := TokenCounterForModel("custom/model"); got != custom {
+		t.Fatalf("TokenCounterForModel(custom/model) = %v, want registered counter", got)
+	}
+}
+
+func TestBPECounter_CountText(t *testing.T) {
+	// "hello world" is two tokens in both encodings
+	for _, c := range []*BPECounter{o200kCounter, cl100kCounter} {
+		if got := c.CountText("hello world"); got != 2 {
+			t.Errorf("%s CountText(hello world) = %d, want 2", c.encoding, got)
+		}
+	}
+
+	// Long strings go through the cache and must count the same twice
+	long := strings.Repeat("lorem ipsum dolor sit amet ", 40)
+	first := o200kCounter.CountText(long)
+	if first == 0 || o200kCounter.CountText(long) != first {
+		t.Fatalf("cached CountText mismatch: %d", first)
+	}
+}
+
+func TestBPECounter_UnknownEncodingFallsBack(t *testing.T) {
+	c := NewBPECounter("no_such_encoding", nil)
+	if got := c.CountText("12345678"); got != 2 {
+		t.Fatalf("CountText() = %d, want heuristic 2", got)
+	}
+}
+
+func TestImageTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		cost ImageCost
+		w, h int
+		want int
+	}{
+		{"openai small", OpenAIImageTokens, 512, 512, 255},
+		{"openai 1024 square", OpenAIImageTokens, 1024, 1024, 765},
+		{"openai unknown", OpenAIImageTokens, 0, 0, 765},
+		{"openai large", OpenAIImageTokens, 4096, 2048, 1105},
+		{"anthropic", AnthropicImageTokens, 1000, 750, 1000},
+		{"anthropic resized", AnthropicImageTokens, 3136, 3136, 3279},
+		{"gemini small", GeminiImageTokens, 300, 300, 258},
+		{"gemini tiled", GeminiImageTokens, 1536, 800, 1032},
+	}
+	for _, tt := range tests {
+		if got := tt.cost(tt.w, tt.h); got != tt.want {
+			t.Errorf("%s: cost(%d, %d) = %d, want %d", tt.name, tt.w, tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestCountTokens_UsesImageDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1000, 750))); err != nil {
+		t.Fatal(err)
+	}
+	msg := messages.ChatMessage{
+		Role: messages.MessageRoleUser,
+		Parts: []messages.ContentPart{
+			{Type: "text", Text: "hello world"},
+			{Type: "image_base64", MimeType: "image/png", ImageData: base64.StdEncoding.EncodeToString(buf.Bytes())},
+		},
+	}
+
+	// 2 text + 1000 image + 4 overhead
+	if got := CountTokens(msg, anthropicCounter); got != 1006 {
+		t.Fatalf("CountTokens() = %d, want 1006", got)
+	}
+	// Undecodable data is priced as an unknown-size image
+	msg.Parts[1].ImageData = "AAA"
+	if got := CountTokens(msg, o200kCounter); got != 2+765+4 {
+		t.Fatalf("CountTokens() = %d, want %d", got, 2+765+4)
+	}
+}
+
+func TestSessionTokens_UseModelCounter(t *testing.T) {
+	// About 65 tokens with BPE but 94 with the heuristic, against a 70 token limit
+	msg := messages.ChatMessage{Role: messages.MessageRoleUser, Content: strings.Repeat("token ", 60)}
+
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			heuristic, err := store.Get("heuristic")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer heuristic.Close()
+			heuristic.AddMessage(msg)
+			if got := len(heuristic.GetHistory()); got != 1 {
+				t.Fatalf("heuristic history length = %d, want message trimmed", got)
+			}
+
+			bpe, err := store.Get("bpe")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer bpe.Close()
+			if err := bpe.UpdateMetadata(&Metadata{Model: "openai/gpt-4o"}); err != nil {
+				t.Fatalf("UpdateMetadata() error = %v", err)
+			}
+			bpe.AddMessage(msg)
+			if got := len(bpe.GetHistory()); got != 2 {
+				t.Fatalf("bpe history length = %d, want message kept", got)
+			}
+			if got, want := bpe.GetTotalTokens(), CountTokens(bpe.GetHistory()[0], o200kCounter)+CountTokens(msg, o200kCounter); got != want {
+				t.Fatalf("GetTotalTokens() = %d, want %d", got, want)
+			}
+		})
+	}
+}
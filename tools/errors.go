@@ -9,10 +9,14 @@ import (
 // a *ToolError from Execute, the agent serializes it as JSON with "error"
 // and "code" fields instead of the generic "Error: ..." format.
 type ToolError struct {
-	Message string `json:"error"`
-	Code    string `json:"code,omitempty"`
+	Message   string `json:"error"`
+	Code      string `json:"code,omitempty"`
+	Retryable bool   `json:"retryable,omitempty"` // The same call may succeed if retried
 }
 
+// CodeUnavailable is returned when a tool's server can't be reached.
+const CodeUnavailable = "UNAVAILABLE"
+
 func (e *ToolError) Error() string { return e.Message }
 
 // NewToolError creates a structured tool error with message and code.
@@ -25,7 +29,11 @@ func NewToolError(message, code string) *ToolError {
 func FormatToolError(err error) (string, bool) {
 	var toolErr *ToolError
 	if errors.As(err, &toolErr) {
-		return Error(toolErr.Message, toolErr.Code), true
+		b, err := json.Marshal(toolErr)
+		if err != nil {
+			return Error(toolErr.Message, toolErr.Code), true
+		}
+		return string(b), true
 	}
 	return "", false
 }
@@ -0,0 +1,597 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/schema"
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+)
+
+// Error codes returned by the file system tools.
+const (
+	CodeInvalidArgs      = "INVALID_ARGS"
+	CodeNotFound         = "NOT_FOUND"
+	CodePermissionDenied = "PERMISSION_DENIED"
+	CodeIsDirectory      = "IS_DIRECTORY"
+	CodeBinaryFile       = "BINARY_FILE"
+	CodeNoMatch          = "NO_MATCH"
+	CodeAmbiguousMatch   = "AMBIGUOUS_MATCH"
+	CodeInvalidPattern   = "INVALID_PATTERN"
+	CodeIOError          = "IO_ERROR"
+)
+
+// File tool limits.
+const (
+	readDefaultLimit = 2000     // lines returned by read_file without a limit
+	maxLineLength    = 2000     // longer lines are cut in read_file and grep output
+	maxSearchResults = 500      // glob and grep stop after this many results
+	maxGrepFileSize  = 10 << 20 // larger files are skipped by grep
+	binarySniffSize  = 8000     // bytes inspected for NUL to detect binary files
+)
+
+// FileTools returns the read_file, write_file, edit_file, glob and grep
+// tools. Relative paths resolve against workDir (the process directory when
+// empty). A non-nil policy restricts access the way the bash sandbox would.
+func FileTools(workDir string, policy *sandbox.Config) []Tool {
+	access := fileAccess{workDir: workDir, policy: policy}
+	return []Tool{
+		&ReadFileTool{fileAccess: access},
+		&WriteFileTool{fileAccess: access},
+		&EditFileTool{fileAccess: access},
+		&GlobTool{fileAccess: access},
+		&GrepTool{fileAccess: access},
+	}
+}
+
+// fileAccess resolves tool paths and enforces the sandbox policy in-process.
+type fileAccess struct {
+	workDir string
+	policy  *sandbox.Config
+}
+
+func (a fileAccess) sandboxPolicy() *sandbox.Config { return a.policy }
+
+// path returns the absolute path named by args[key].
+func (a fileAccess) path(args Args, key string) (string, error) {
+	p := strings.TrimSpace(args.String(key))
+	if p == "" {
+		return "", NewToolError(key+" must be a non-empty string", CodeInvalidArgs)
+	}
+	return a.abs(p), nil
+}
+
+// root returns the absolute directory named by args[key], defaulting to workDir.
+func (a fileAccess) root(args Args, key string) string {
+	if p := strings.TrimSpace(args.String(key)); p != "" {
+		return a.abs(p)
+	}
+	return a.abs(".")
+}
+
+func (a fileAccess) abs(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[2:])
+		}
+	}
+	if !filepath.IsAbs(p) {
+		base := a.workDir
+		if base == "" {
+			base, _ = os.Getwd()
+		}
+		p = filepath.Join(base, p)
+	}
+	return filepath.Clean(p)
+}
+
+func (a fileAccess) checkRead(p string) error {
+	if a.policy == nil {
+		return nil
+	}
+	if err := a.policy.CheckRead(p); err != nil {
+		return NewToolError(err.Error(), CodePermissionDenied)
+	}
+	return nil
+}
+
+func (a fileAccess) checkWrite(p string) error {
+	if a.policy == nil {
+		return nil
+	}
+	if err := a.policy.CheckWrite(p); err != nil {
+		return NewToolError(err.Error(), CodePermissionDenied)
+	}
+	return nil
+}
+
+// walk visits the files under root, skipping .git and directories the
+// policy forbids reading. rel is slash-separated and relative to root.
+func (a fileAccess) walk(ctx context.Context, root string, fn func(p, rel string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // unreadable entries are skipped
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && (d.Name() == ".git" || a.checkRead(p) != nil) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if a.checkRead(p) != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		return fn(p, filepath.ToSlash(rel), d)
+	})
+}
+
+// fsError converts an os error to a ToolError with a matching code.
+func fsError(err error, p string) error {
+	var toolErr *ToolError
+	switch {
+	case errors.As(err, &toolErr):
+		return err
+	case errors.Is(err, fs.ErrNotExist):
+		return NewToolError(fmt.Sprintf("%s does not exist", p), CodeNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		return NewToolError(fmt.Sprintf("permission denied: %s", p), CodePermissionDenied)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	default:
+		return NewToolError(err.Error(), CodeIOError)
+	}
+}
+
+// statFile ensures p exists and is a regular file.
+func statFile(p string) (fs.FileInfo, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fsError(err, p)
+	}
+	if info.IsDir() {
+		return nil, NewToolError(fmt.Sprintf("%s is a directory", p), CodeIsDirectory)
+	}
+	return info, nil
+}
+
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffSize)], 0) >= 0
+}
+
+func truncateLine(line string) string {
+	if len(line) > maxLineLength {
+		return line[:maxLineLength] + "... [line truncated]"
+	}
+	return line
+}
+
+// ReadFileTool returns a file's lines with line numbers.
+type ReadFileTool struct {
+	NativeTool
+	fileAccess
+}
+
+// NewReadFileTool creates the read_file tool. policy may be nil.
+func NewReadFileTool(workDir string, policy *sandbox.Config) *ReadFileTool {
+	return &ReadFileTool{fileAccess: fileAccess{workDir: workDir, policy: policy}}
+}
+
+func (t *ReadFileTool) GetName() string { return "read_file" }
+
+func (t *ReadFileTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool("read_file", "Read a text file. Lines are returned with 1-based line numbers; use offset and limit to page through large files.",
+		schema.Params{
+			"file_path": schema.S("Path of the file to read"),
+			"offset":    schema.Int("Line number to start reading from (default 1)"),
+			"limit":     schema.Int(fmt.Sprintf("Maximum number of lines to return (default %d)", readDefaultLimit)),
+		},
+		"file_path",
+	)
+}
+
+func (t *ReadFileTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	args := Args(raw)
+	p, err := t.path(args, "file_path")
+	if err != nil {
+		return "", err
+	}
+	if err := t.checkRead(p); err != nil {
+		return "", err
+	}
+	if _, err := statFile(p); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return "", fsError(err, p)
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	if head, _ := reader.Peek(binarySniffSize); isBinary(head) {
+		return "", NewToolError(fmt.Sprintf("%s is a binary file", p), CodeBinaryFile)
+	}
+
+	offset := max(args.Int("offset", 1), 1)
+	limit := args.Int("limit", readDefaultLimit)
+	if limit <= 0 {
+		limit = readDefaultLimit
+	}
+
+	var b strings.Builder
+	lineNo, shown := 0, 0
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lineNo++
+			if lineNo >= offset {
+				if shown == limit {
+					fmt.Fprintf(&b, "... more lines follow; continue with offset=%d\n", lineNo)
+					break
+				}
+				fmt.Fprintf(&b, "%6d\t%s\n", lineNo, truncateLine(strings.TrimRight(line, "\r\n")))
+				shown++
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fsError(err, p)
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case lineNo == 0:
+		return "(empty file)", nil
+	case shown == 0:
+		return "", NewToolError(fmt.Sprintf("offset %d is past the end of the file (%d lines)", offset, lineNo), CodeInvalidArgs)
+	}
+	return strings.TrimRight(b.String(), "\n"), nil
+}
+
+// WriteFileTool creates or overwrites a file.
+type WriteFileTool struct {
+	NativeTool
+	fileAccess
+}
+
+// NewWriteFileTool creates the write_file tool. policy may be nil.
+func NewWriteFileTool(workDir string, policy *sandbox.Config) *WriteFileTool {
+	return &WriteFileTool{fileAccess: fileAccess{workDir: workDir, policy: policy}}
+}
+
+func (t *WriteFileTool) GetName() string { return "write_file" }
+
+func (t *WriteFileTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool("write_file", "Create or overwrite a file with the given content. Missing parent directories are created.",
+		schema.Params{
+			"file_path": schema.S("Path of the file to write"),
+			"content":   schema.S("The full content to write"),
+		},
+		"file_path", "content",
+	)
+}
+
+func (t *WriteFileTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	_ = ctx
+	args := Args(raw)
+	p, err := t.path(args, "file_path")
+	if err != nil {
+		return "", err
+	}
+	content, ok := args["content"].(string)
+	if !ok {
+		return "", NewToolError("content must be a string", CodeInvalidArgs)
+	}
+	if err := t.checkWrite(p); err != nil {
+		return "", err
+	}
+
+	mode := fs.FileMode(0o644)
+	created := true
+	if info, err := os.Stat(p); err == nil {
+		if info.IsDir() {
+			return "", NewToolError(fmt.Sprintf("%s is a directory", p), CodeIsDirectory)
+		}
+		mode = info.Mode().Perm()
+		created = false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", fsError(err, p)
+	}
+	if err := os.WriteFile(p, []byte(content), mode); err != nil {
+		return "", fsError(err, p)
+	}
+
+	return Result(map[string]any{"path": p, "bytes": len(content), "created": created}), nil
+}
+
+// EditFileTool replaces an exact string in a file.
+type EditFileTool struct {
+	NativeTool
+	fileAccess
+}
+
+// NewEditFileTool creates the edit_file tool. policy may be nil.
+func NewEditFileTool(workDir string, policy *sandbox.Config) *EditFileTool {
+	return &EditFileTool{fileAccess: fileAccess{workDir: workDir, policy: policy}}
+}
+
+func (t *EditFileTool) GetName() string { return "edit_file" }
+
+func (t *EditFileTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool("edit_file", "Replace an exact string in a file. old_string must match exactly once unless replace_all is set; include surrounding lines to make it unique.",
+		schema.Params{
+			"file_path":   schema.S("Path of the file to edit"),
+			"old_string":  schema.S("The exact text to replace, including whitespace"),
+			"new_string":  schema.S("The replacement text"),
+			"replace_all": schema.Bool("Replace every occurrence instead of requiring a unique match"),
+		},
+		"file_path", "old_string", "new_string",
+	)
+}
+
+func (t *EditFileTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	_ = ctx
+	args := Args(raw)
+	p, err := t.path(args, "file_path")
+	if err != nil {
+		return "", err
+	}
+	oldString := args.String("old_string")
+	newString, ok := args["new_string"].(string)
+	if oldString == "" || !ok {
+		return "", NewToolError("old_string must be non-empty and new_string must be a string", CodeInvalidArgs)
+	}
+	if oldString == newString {
+		return "", NewToolError("old_string and new_string are identical", CodeInvalidArgs)
+	}
+	if err := t.checkWrite(p); err != nil {
+		return "", err
+	}
+	info, err := statFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", fsError(err, p)
+	}
+	content := string(data)
+
+	count := strings.Count(content, oldString)
+	switch {
+	case count == 0:
+		return "", NewToolError(fmt.Sprintf("old_string not found in %s", p), CodeNoMatch)
+	case count > 1 && !args.Bool("replace_all"):
+		return "", NewToolError(fmt.Sprintf("old_string matches %d times in %s; add context to make it unique or set replace_all", count, p), CodeAmbiguousMatch)
+	}
+
+	updated := strings.Replace(content, oldString, newString, max(count, 1))
+	if err := os.WriteFile(p, []byte(updated), info.Mode().Perm()); err != nil {
+		return "", fsError(err, p)
+	}
+
+	return Result(map[string]any{"path": p, "replacements": count}), nil
+}
+
+// GlobTool lists files matching a glob pattern.
+type GlobTool struct {
+	NativeTool
+	fileAccess
+}
+
+// NewGlobTool creates the glob tool. policy may be nil.
+func NewGlobTool(workDir string, policy *sandbox.Config) *GlobTool {
+	return &GlobTool{fileAccess: fileAccess{workDir: workDir, policy: policy}}
+}
+
+func (t *GlobTool) GetName() string { return "glob" }
+
+func (t *GlobTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool("glob", "Find files by glob pattern, e.g. \"*.go\" or \"src/**/*.ts\" (** matches any number of directories). Returns paths relative to the search root.",
+		schema.Params{
+			"pattern": schema.S("Slash-separated glob pattern relative to path"),
+			"path":    schema.S("Directory to search (default: working directory)"),
+		},
+		"pattern",
+	)
+}
+
+func (t *GlobTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	args := Args(raw)
+	pattern := strings.Trim(strings.TrimSpace(args.String("pattern")), "/")
+	if pattern == "" {
+		return "", NewToolError("pattern must be a non-empty string", CodeInvalidArgs)
+	}
+	if err := validateGlob(pattern); err != nil {
+		return "", err
+	}
+	root := t.root(args, "path")
+	if err := t.checkRead(root); err != nil {
+		return "", err
+	}
+
+	var files []string
+	truncated := false
+	err := t.walk(ctx, root, func(p, rel string, d fs.DirEntry) error {
+		if !matchGlob(pattern, rel) {
+			return nil
+		}
+		if len(files) == maxSearchResults {
+			truncated = true
+			return filepath.SkipAll
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return "", fsError(err, root)
+	}
+
+	sort.Strings(files)
+	return Result(map[string]any{"root": root, "files": files, "truncated": truncated}), nil
+}
+
+// validateGlob rejects malformed pattern segments.
+func validateGlob(pattern string) error {
+	for _, seg := range strings.Split(pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return NewToolError(fmt.Sprintf("invalid glob pattern %q: %v", pattern, err), CodeInvalidPattern)
+		}
+	}
+	return nil
+}
+
+// matchGlob matches a slash-separated path against pattern. A "**" segment
+// matches zero or more directories; other segments use path.Match.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// GrepTool searches file contents with a regular expression.
+type GrepTool struct {
+	NativeTool
+	fileAccess
+}
+
+// NewGrepTool creates the grep tool. policy may be nil.
+func NewGrepTool(workDir string, policy *sandbox.Config) *GrepTool {
+	return &GrepTool{fileAccess: fileAccess{workDir: workDir, policy: policy}}
+}
+
+func (t *GrepTool) GetName() string { return "grep" }
+
+func (t *GrepTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool("grep", "Search file contents with a regular expression (Go RE2 syntax). Binary files and .git are skipped.",
+		schema.Params{
+			"pattern":     schema.S("Regular expression to search for"),
+			"path":        schema.S("File or directory to search (default: working directory)"),
+			"glob":        schema.S("Only search files matching this glob, e.g. \"*.go\" or \"src/**/*.ts\""),
+			"ignore_case": schema.Bool("Match case-insensitively"),
+		},
+		"pattern",
+	)
+}
+
+// grepMatch is a single matching line.
+type grepMatch struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+func (t *GrepTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	args := Args(raw)
+	expr := args.String("pattern")
+	if expr == "" {
+		return "", NewToolError("pattern must be a non-empty string", CodeInvalidArgs)
+	}
+	if args.Bool("ignore_case") {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return "", NewToolError(fmt.Sprintf("invalid regular expression: %v", err), CodeInvalidPattern)
+	}
+	filter := strings.Trim(strings.TrimSpace(args.String("glob")), "/")
+	if filter != "" {
+		if err := validateGlob(filter); err != nil {
+			return "", err
+		}
+	}
+
+	root := t.root(args, "path")
+	if err := t.checkRead(root); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fsError(err, root)
+	}
+
+	matches := []grepMatch{}
+	truncated := false
+	search := func(p, rel string) error {
+		data, err := os.ReadFile(p)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if !re.MatchString(line) {
+				continue
+			}
+			if len(matches) == maxSearchResults {
+				truncated = true
+				return filepath.SkipAll
+			}
+			matches = append(matches, grepMatch{File: rel, Line: i + 1, Text: truncateLine(strings.TrimRight(line, "\r"))})
+		}
+		return nil
+	}
+
+	if !info.IsDir() {
+		_ = search(root, filepath.Base(root))
+	} else {
+		err = t.walk(ctx, root, func(p, rel string, d fs.DirEntry) error {
+			if filter != "" && !matchGlob(filter, rel) && !matchGlob(filter, d.Name()) {
+				return nil
+			}
+			if info, err := d.Info(); err != nil || info.Size() > maxGrepFileSize || !info.Mode().IsRegular() {
+				return nil
+			}
+			return search(p, rel)
+		})
+		if err != nil {
+			return "", fsError(err, root)
+		}
+	}
+
+	return Result(map[string]any{"root": root, "matches": matches, "truncated": truncated}), nil
+}
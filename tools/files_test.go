@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+)
+
+// toolErrorCode returns the ToolError code carried by err, or "".
+func toolErrorCode(err error) string {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr.Code
+	}
+	return ""
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadFileTool(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.txt"), "one\ntwo\nthree\n")
+	writeTestFile(t, filepath.Join(dir, "bin"), "ab\x00cd")
+	tool := NewReadFileTool(dir, nil)
+	ctx := context.Background()
+
+	got, err := tool.Execute(ctx, map[string]any{"file_path": "a.txt"})
+	if err != nil || got != "     1\tone\n     2\ttwo\n     3\tthree" {
+		t.Fatalf("Execute() = %q, %v", got, err)
+	}
+
+	got, err = tool.Execute(ctx, map[string]any{"file_path": "a.txt", "offset": float64(2), "limit": float64(1)})
+	if err != nil || !strings.HasPrefix(got, "     2\ttwo\n") || !strings.Contains(got, "offset=3") {
+		t.Fatalf("paged Execute() = %q, %v", got, err)
+	}
+
+	for _, tt := range []struct {
+		args map[string]any
+		code string
+	}{
+		{map[string]any{}, CodeInvalidArgs},
+		{map[string]any{"file_path": "missing.txt"}, CodeNotFound},
+		{map[string]any{"file_path": "."}, CodeIsDirectory},
+		{map[string]any{"file_path": "bin"}, CodeBinaryFile},
+		{map[string]any{"file_path": "a.txt", "offset": float64(10)}, CodeInvalidArgs},
+	} {
+		if _, err := tool.Execute(ctx, tt.args); toolErrorCode(err) != tt.code {
+			t.Errorf("Execute(%v) error = %v, want code %s", tt.args, err, tt.code)
+		}
+	}
+}
+
+func TestWriteAndEditFileTools(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	write := NewWriteFileTool(dir, nil)
+	edit := NewEditFileTool(dir, nil)
+
+	got, err := write.Execute(ctx, map[string]any{"file_path": "sub/f.go", "content": "a := 1\nb := 1\n"})
+	if err != nil || !strings.Contains(got, `"created":true`) {
+		t.Fatalf("write Execute() = %q, %v", got, err)
+	}
+
+	if _, err := edit.Execute(ctx, map[string]any{"file_path": "sub/f.go", "old_string": ":= 1", "new_string": ":= 2"}); toolErrorCode(err) != CodeAmbiguousMatch {
+		t.Fatalf("ambiguous edit error = %v, want %s", err, CodeAmbiguousMatch)
+	}
+	if _, err := edit.Execute(ctx, map[string]any{"file_path": "sub/f.go", "old_string": "c :=", "new_string": "d :="}); toolErrorCode(err) != CodeNoMatch {
+		t.Fatalf("missing edit error = %v, want %s", err, CodeNoMatch)
+	}
+	if _, err := edit.Execute(ctx, map[string]any{"file_path": "sub/f.go", "old_string": "a := 1", "new_string": "a := 3"}); err != nil {
+		t.Fatalf("unique edit error = %v", err)
+	}
+	got, err = edit.Execute(ctx, map[string]any{"file_path": "sub/f.go", "old_string": "1", "new_string": "4", "replace_all": true})
+	if err != nil || !strings.Contains(got, `"replacements":1`) {
+		t.Fatalf("replace_all Execute() = %q, %v", got, err)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dir, "sub", "f.go"))
+	if string(data) != "a := 3\nb := 4\n" {
+		t.Fatalf("file content = %q", data)
+	}
+}
+
+func TestFileToolsRespectSandboxPolicy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writable := t.TempDir()
+	readonly := t.TempDir()
+	writeTestFile(t, filepath.Join(home, ".ssh", "id_rsa"), "secret")
+	writeTestFile(t, filepath.Join(readonly, "keep.txt"), "keep")
+
+	policy := &sandbox.Config{WritablePaths: []string{writable}}
+	ctx := context.Background()
+	read := NewReadFileTool("", policy)
+	write := NewWriteFileTool("", policy)
+
+	if _, err := read.Execute(ctx, map[string]any{"file_path": filepath.Join(home, ".ssh", "id_rsa")}); toolErrorCode(err) != CodePermissionDenied {
+		t.Fatalf("read denied path error = %v, want %s", err, CodePermissionDenied)
+	}
+	if _, err := write.Execute(ctx, map[string]any{"file_path": filepath.Join(writable, "ok.txt"), "content": "x"}); err != nil {
+		t.Fatalf("write to writable path error = %v", err)
+	}
+
+	// t.TempDir lives under the OS temp dir, which is always writable, so
+	// use DenyWrite to exercise the refusal path for writes and edits.
+	denyAll := &sandbox.Config{DenyWrite: true}
+	if _, err := NewWriteFileTool("", denyAll).Execute(ctx, map[string]any{"file_path": filepath.Join(writable, "no.txt"), "content": "x"}); toolErrorCode(err) != CodePermissionDenied {
+		t.Fatalf("write with DenyWrite error = %v, want %s", err, CodePermissionDenied)
+	}
+	if _, err := NewEditFileTool("", denyAll).Execute(ctx, map[string]any{"file_path": filepath.Join(readonly, "keep.txt"), "old_string": "keep", "new_string": "lost"}); toolErrorCode(err) != CodePermissionDenied {
+		t.Fatalf("edit with DenyWrite error = %v, want %s", err, CodePermissionDenied)
+	}
+
+	// ReadPaths exempts a denied path
+	exempt := NewReadFileTool("", &sandbox.Config{ReadPaths: []string{"~/.ssh"}})
+	if _, err := exempt.Execute(ctx, map[string]any{"file_path": filepath.Join(home, ".ssh", "id_rsa")}); err != nil {
+		t.Fatalf("read exempted path error = %v", err)
+	}
+}
+
+func TestGlobTool(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "pkg/a.go", "pkg/deep/b.go", "pkg/deep/c.txt", ".git/x.go"} {
+		writeTestFile(t, filepath.Join(dir, name), "")
+	}
+	tool := NewGlobTool(dir, nil)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", []string{"main.go"}},
+		{"**/*.go", []string{"main.go", "pkg/a.go", "pkg/deep/b.go"}},
+		{"pkg/**", []string{"pkg/a.go", "pkg/deep/b.go", "pkg/deep/c.txt"}},
+		{"pkg/*/*.txt", []string{"pkg/deep/c.txt"}},
+	}
+	for _, tt := range tests {
+		got, err := tool.Execute(context.Background(), map[string]any{"pattern": tt.pattern})
+		if err != nil {
+			t.Fatalf("Execute(%q) error = %v", tt.pattern, err)
+		}
+		var result struct {
+			Files []string `json:"files"`
+		}
+		if err := json.Unmarshal([]byte(got), &result); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(result.Files, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Execute(%q) files = %v, want %v", tt.pattern, result.Files, tt.want)
+		}
+	}
+
+	if _, err := tool.Execute(context.Background(), map[string]any{"pattern": "[a"}); toolErrorCode(err) != CodeInvalidPattern {
+		t.Fatalf("bad pattern error = %v, want %s", err, CodeInvalidPattern)
+	}
+	if _, err := tool.Execute(context.Background(), map[string]any{"pattern": "*", "path": "nope"}); toolErrorCode(err) != CodeNotFound {
+		t.Fatalf("missing root error = %v, want %s", err, CodeNotFound)
+	}
+}
+
+func TestGrepTool(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "a.go"), "package a\nfunc Hello() {}\n")
+	writeTestFile(t, filepath.Join(dir, "sub", "b.txt"), "hello there\n")
+	writeTestFile(t, filepath.Join(dir, "bin.dat"), "hello\x00")
+	tool := NewGrepTool(dir, nil)
+	ctx := context.Background()
+
+	run := func(args map[string]any) []grepMatch {
+		t.Helper()
+		got, err := tool.Execute(ctx, args)
+		if err != nil {
+			t.Fatalf("Execute(%v) error = %v", args, err)
+		}
+		var result struct {
+			Matches []grepMatch `json:"matches"`
+		}
+		if err := json.Unmarshal([]byte(got), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result.Matches
+	}
+
+	if got := run(map[string]any{"pattern": "hello"}); len(got) != 1 || got[0].File != "sub/b.txt" || got[0].Line != 1 {
+		t.Fatalf("case-sensitive matches = %+v", got)
+	}
+	if got := run(map[string]any{"pattern": "hello", "ignore_case": true, "glob": "*.go"}); len(got) != 1 || got[0].File != "a.go" || got[0].Line != 2 {
+		t.Fatalf("filtered matches = %+v", got)
+	}
+	if got := run(map[string]any{"pattern": "package", "path": "a.go"}); len(got) != 1 {
+		t.Fatalf("single file matches = %+v", got)
+	}
+	if _, err := tool.Execute(ctx, map[string]any{"pattern": "("}); toolErrorCode(err) != CodeInvalidPattern {
+		t.Fatalf("bad regexp error = %v, want %s", err, CodeInvalidPattern)
+	}
+}
+
+func TestRegistryProvidesFileTools(t *testing.T) {
+	registry := NewToolRegistry(nil)
+	for _, name := range []string{"read_file", "write_file", "edit_file", "glob", "grep"} {
+		if _, err := registry.LoadToolAuto(name); err != nil {
+			t.Fatalf("LoadToolAuto(%q) error = %v", name, err)
+		}
+		if _, ok := registry.Get(name); !ok {
+			t.Fatalf("%s not registered", name)
+		}
+	}
+}
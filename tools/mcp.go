@@ -9,16 +9,20 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alexschlessinger/pollytool/schema"
 	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 // MCPTool wraps an MCP tool to implement the Tool interface
 type MCPTool struct {
 	session      *mcp.ClientSession
+	client       *MCPClient // Routes progress notifications; nil disables them
 	tool         *mcp.Tool
 	Source       string             // Server spec that provided this tool
 	cachedSchema *schema.ToolSchema // Cached converted schema
@@ -89,10 +93,29 @@ func (m *MCPTool) Execute(ctx context.Context, args map[string]any) (string, err
 		Name:      m.tool.Name,
 		Arguments: args,
 	}
+	if report := progressReporter(ctx); report != nil && m.client != nil {
+		token := m.client.trackProgress(report)
+		defer m.client.untrackProgress(token)
+		params.SetProgressToken(token)
+	}
+
+	// Calls made while the server is reconnecting fail fast so the model
+	// can retry once it is back
+	session := m.session
+	if m.client != nil {
+		if m.client.State() == MCPReconnecting {
+			return "", m.client.unavailableError()
+		}
+		session = m.client.current()
+	}
 
-	// Call the tool via MCP
-	result, err := m.session.CallTool(ctx, params)
+	// Call the tool via MCP. Cancelling ctx sends notifications/cancelled
+	// to the server.
+	result, err := session.CallTool(ctx, params)
 	if err != nil {
+		if m.client != nil && ctx.Err() == nil && m.client.lost(session, err) {
+			return "", m.client.unavailableError()
+		}
 		return "", fmt.Errorf("MCP tool execution failed: %v", err)
 	}
 
@@ -132,9 +155,15 @@ type MCPConfig struct {
 	Transport string            `json:"transport,omitempty"` // "stdio" | "sse" | "streamable"
 	Headers   map[string]string `json:"headers,omitempty"`   // Auth headers, API keys
 	Timeout   string            `json:"timeout,omitempty"`   // Connection timeout (e.g., "30s")
+	Auth      string            `json:"auth,omitempty"`      // "oauth" to authorize in the browser
+	Scopes    []string          `json:"scopes,omitempty"`    // OAuth scopes to request
 
 	// Sandboxing (stdio only). true for defaults, or {"allowNetwork":true,"writablePaths":[...]}.
 	Sandbox json.RawMessage `json:"sandbox,omitempty"`
+
+	// true runs the server in the pool daemon when it is running (stdio
+	// only). Pooled servers can't use sampling or elicitation.
+	Pool bool `json:"pool,omitempty"`
 }
 
 // SandboxConfig returns the parsed sandbox config for merging overrides,
@@ -149,6 +178,11 @@ func (c *MCPConfig) SandboxOptOut() bool {
 	return string(c.Sandbox) == "false"
 }
 
+// pooled reports whether the server opted in to running in the pool daemon
+func (c *MCPConfig) pooled() bool {
+	return (c.Transport == "" || c.Transport == "stdio") && c.Pool
+}
+
 // MCPServersConfig represents the Claude Desktop format with multiple servers
 type MCPServersConfig struct {
 	MCPServers map[string]MCPConfig `json:"mcpServers"`
@@ -270,11 +304,41 @@ func httpClientWithTimeout(headers map[string]string, timeout time.Duration) *ht
 	}
 }
 
-// MCPClient manages connection to an MCP server
+// remoteHTTPClient creates the HTTP client for a remote server's transport
+func remoteHTTPClient(config *MCPConfig, timeout time.Duration) *http.Client {
+	if config.Auth == "oauth" {
+		return oauthHTTPClient(config, timeout)
+	}
+	return httpClientWithTimeout(config.Headers, timeout)
+}
+
+// MCPClient manages connection to an MCP server. The connection is
+// supervised: when it drops, the client reconnects in the background.
 type MCPClient struct {
-	session    *mcp.ClientSession
 	client     *mcp.Client
-	serverSpec string // The server spec (JSON file path) for this client
+	serverSpec string          // The server spec (JSON file path) for this client
+	namespace  string          // Namespace the server's tools are registered under
+	sandboxCfg *sandbox.Config // Sandbox the server runs in; nil when unsandboxed
+
+	dial   func() (mcp.Transport, error) // Creates a fresh transport per connection
+	opts   mcpClientOptions
+	ctx    context.Context // Cancelled by Close to stop reconnecting
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	session *mcp.ClientSession
+	done    chan struct{} // Closed when session's connection ends
+	state   MCPConnectionState
+
+	// Tool names (without namespace) left out when the server was loaded
+	// with a filter, so refreshes keep them out
+	skipTools map[string]bool
+
+	// Progress reporters of in-flight tool calls, by progress token
+	progressMu    sync.Mutex
+	progress      map[string]func(ToolProgress)
+	progressCount int
+	notifyOnRead  atomic.Bool // Notifications are reported by notifyingConn
 }
 
 // NewMCPClient creates a new MCP client from a server spec
@@ -339,17 +403,71 @@ func NewMCPClient(serverSpec string) (*MCPClient, error) {
 	return client, nil
 }
 
+// SamplingHandler runs an LLM completion on behalf of the named MCP server
+type SamplingHandler func(ctx context.Context, server string, params *mcp.CreateMessageParams) (*mcp.CreateMessageResult, error)
+
+// MCPClientOption configures the client side of MCP connections
+type MCPClientOption func(*mcpClientOptions)
+
+// ElicitationHandler asks the user for input on behalf of the named MCP server
+type ElicitationHandler func(ctx context.Context, server string, params *mcp.ElicitParams) (*mcp.ElicitResult, error)
+
+type mcpClientOptions struct {
+	sampling        SamplingHandler
+	elicitation     ElicitationHandler
+	roots           []*mcp.Root
+	toolsChanged    func(*MCPClient)
+	connectionState func(*MCPClient, MCPConnectionState)
+}
+
+// WithSampling advertises the sampling capability and answers servers'
+// completion requests with h
+func WithSampling(h SamplingHandler) MCPClientOption {
+	return func(o *mcpClientOptions) {
+		o.sampling = h
+	}
+}
+
+// WithElicitation advertises the elicitation capability and answers
+// servers' requests for user input with h
+func WithElicitation(h ElicitationHandler) MCPClientOption {
+	return func(o *mcpClientOptions) {
+		o.elicitation = h
+	}
+}
+
+// withRoots sets the roots advertised to the server, replacing the default
+// of the working directory
+func withRoots(roots []*mcp.Root) MCPClientOption {
+	return func(o *mcpClientOptions) {
+		o.roots = roots
+	}
+}
+
+// withToolListChanged calls fn, on its own goroutine, whenever the server
+// announces that its tool list changed, and after reconnecting
+func withToolListChanged(fn func(*MCPClient)) MCPClientOption {
+	return func(o *mcpClientOptions) {
+		o.toolsChanged = fn
+	}
+}
+
 // NewMCPClientFromConfig creates a new MCP client from a JSON configuration.
 // If sb is non-nil and transport is stdio, the server process runs sandboxed.
-func NewMCPClientFromConfig(config *MCPConfig, sb sandbox.Sandbox) (*MCPClient, error) {
-	ctx := context.Background()
+func NewMCPClientFromConfig(config *MCPConfig, sb sandbox.Sandbox, opts ...MCPClientOption) (*MCPClient, error) {
+	dial, err := mcpDialer(config, sb, "")
+	if err != nil {
+		return nil, err
+	}
 
-	// Create the MCP client
-	client := mcp.NewClient(&mcp.Implementation{
-		Name:    "pollytool",
-		Version: "1.0.0",
-	}, nil)
+	// serverSpec will be set by caller if needed
+	return connectMCPClient(context.Background(), dial, opts...)
+}
 
+// mcpDialer validates config and returns a function that creates a fresh
+// transport per connection, including reconnects. Stdio servers start in
+// dir, or the working directory when dir is empty.
+func mcpDialer(config *MCPConfig, sb sandbox.Sandbox, dir string) (func() (mcp.Transport, error), error) {
 	// Parse timeout (default 30s for remote transports)
 	timeout := 30 * time.Second
 	if config.Timeout != "" {
@@ -358,27 +476,40 @@ func NewMCPClientFromConfig(config *MCPConfig, sb sandbox.Sandbox) (*MCPClient,
 		}
 	}
 
-	var transport mcp.Transport
+	switch config.Auth {
+	case "", "oauth":
+	default:
+		return nil, fmt.Errorf("unknown auth mode: %s (supported: oauth)", config.Auth)
+	}
+
+	// Remote transports share one HTTP client so OAuth tokens carry over
+	var dial func() (mcp.Transport, error)
 
 	switch config.Transport {
 	case "sse":
 		if config.URL == "" {
 			return nil, fmt.Errorf("SSE transport requires a URL")
 		}
-		slog.Debug("mcp_sse_connecting", "url", config.URL)
-		transport = &mcp.SSEClientTransport{
-			Endpoint:   config.URL,
-			HTTPClient: httpClientWithTimeout(config.Headers, timeout),
+		httpClient := remoteHTTPClient(config, timeout)
+		dial = func() (mcp.Transport, error) {
+			slog.Debug("mcp_sse_connecting", "url", config.URL)
+			return &mcp.SSEClientTransport{
+				Endpoint:   config.URL,
+				HTTPClient: httpClient,
+			}, nil
 		}
 
 	case "streamable":
 		if config.URL == "" {
 			return nil, fmt.Errorf("streamable transport requires a URL")
 		}
-		slog.Debug("mcp_http_connecting", "url", config.URL)
-		transport = &mcp.StreamableClientTransport{
-			Endpoint:   config.URL,
-			HTTPClient: httpClientWithTimeout(config.Headers, timeout),
+		httpClient := remoteHTTPClient(config, timeout)
+		dial = func() (mcp.Transport, error) {
+			slog.Debug("mcp_http_connecting", "url", config.URL)
+			return &mcp.StreamableClientTransport{
+				Endpoint:   config.URL,
+				HTTPClient: httpClient,
+			}, nil
 		}
 
 	case "stdio", "":
@@ -386,72 +517,278 @@ func NewMCPClientFromConfig(config *MCPConfig, sb sandbox.Sandbox) (*MCPClient,
 		if config.Command == "" {
 			return nil, fmt.Errorf("stdio transport requires a command")
 		}
+		if config.Auth != "" {
+			return nil, fmt.Errorf("%s auth requires a remote transport", config.Auth)
+		}
 
-		// Create the command with arguments
-		cmd := exec.Command(config.Command, config.Args...)
-
-		// Set environment variables if provided
-		if len(config.Env) > 0 {
-			// Start with current environment
-			cmd.Env = os.Environ()
-			// Add/override with config environment variables
-			for key, value := range config.Env {
-				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+		dial = func() (mcp.Transport, error) {
+			// Create the command with arguments
+			cmd := exec.Command(config.Command, config.Args...)
+			cmd.Dir = dir
+
+			// Set environment variables if provided
+			if len(config.Env) > 0 {
+				// Start with current environment
+				cmd.Env = os.Environ()
+				// Add/override with config environment variables
+				for key, value := range config.Env {
+					cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+				}
 			}
-		}
 
-		// Set up stderr to see any error output from the server
-		cmd.Stderr = os.Stderr
+			// Set up stderr to see any error output from the server
+			cmd.Stderr = os.Stderr
 
-		if err := sandbox.WrapCmd(sb, cmd); err != nil {
-			return nil, fmt.Errorf("sandbox: %w", err)
-		}
+			if err := sandbox.WrapCmd(sb, cmd); err != nil {
+				return nil, fmt.Errorf("sandbox: %w", err)
+			}
 
-		slog.Debug("mcp_stdio_connecting", "command", config.Command, "arguments", config.Args)
-		transport = &mcp.CommandTransport{Command: cmd}
+			slog.Debug("mcp_stdio_connecting", "command", config.Command, "arguments", config.Args)
+			return &mcp.CommandTransport{Command: cmd}, nil
+		}
 
 	default:
 		return nil, fmt.Errorf("unknown transport type: %s (supported: stdio, sse, streamable)", config.Transport)
 	}
+	return dial, nil
+}
+
+// connectMCPClient creates a client with opts, connects it over a transport
+// from dial and supervises the connection
+func connectMCPClient(ctx context.Context, dial func() (mcp.Transport, error), opts ...MCPClientOption) (*MCPClient, error) {
+	o := mcpClientOptions{roots: workspaceRoots(nil)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Handlers look up the server's name when called, since the namespace
+	// is assigned after connecting
+	c := &MCPClient{opts: o}
+	c.dial = func() (mcp.Transport, error) {
+		transport, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		// The streamable connection gets session updates through a hook
+		// only the SDK can call, so it can't be wrapped
+		if _, ok := transport.(*mcp.StreamableClientTransport); ok {
+			return transport, nil
+		}
+		c.notifyOnRead.Store(true)
+		return notifyingTransport{Transport: transport, client: c}, nil
+	}
+	clientOpts := &mcp.ClientOptions{KeepAlive: mcpKeepAlive}
+	if o.sampling != nil {
+		clientOpts.CreateMessageHandler = func(ctx context.Context, req *mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+			return o.sampling(ctx, c.Name(), req.Params)
+		}
+	}
+	if o.elicitation != nil {
+		clientOpts.ElicitationHandler = func(ctx context.Context, req *mcp.ElicitRequest) (*mcp.ElicitResult, error) {
+			return o.elicitation(ctx, c.Name(), req.Params)
+		}
+	}
+	clientOpts.ProgressNotificationHandler = func(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+		if !c.notifyOnRead.Load() {
+			c.reportProgress(req.Params)
+		}
+	}
+	clientOpts.LoggingMessageHandler = func(_ context.Context, req *mcp.LoggingMessageRequest) {
+		if !c.notifyOnRead.Load() {
+			c.reportLog(req.Params)
+		}
+	}
+	if o.toolsChanged != nil {
+		clientOpts.ToolListChangedHandler = func(context.Context, *mcp.ToolListChangedRequest) {
+			// Listing tools from inside the notification handler would
+			// block the connection, so refresh in the background
+			go o.toolsChanged(c)
+		}
+	}
+	client := mcp.NewClient(&mcp.Implementation{
+		Name:    "pollytool",
+		Version: "1.0.0",
+	}, clientOpts)
+	client.AddRoots(o.roots...)
 
+	transport, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
 	session, err := client.Connect(ctx, transport, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to MCP server: %v", err)
 	}
+	c.client = client
+	c.session = session
+	c.done = make(chan struct{})
+	c.state = MCPConnected
+	c.ctx, c.cancel = context.WithCancel(context.Background())
 
-	return &MCPClient{
-		session: session,
-		client:  client,
-		// serverSpec will be set by caller if needed
-	}, nil
+	c.sessionStarted(ctx, session)
+	go c.supervise(session, c.done)
+	return c, nil
+}
+
+// sessionStarted finishes setting up a new session
+func (c *MCPClient) sessionStarted(ctx context.Context, session *mcp.ClientSession) {
+	// Servers only send log messages once a level is set
+	if init := session.InitializeResult(); init != nil && init.Capabilities != nil && init.Capabilities.Logging != nil {
+		if err := session.SetLoggingLevel(ctx, &mcp.SetLoggingLevelParams{Level: "info"}); err != nil {
+			slog.Debug("mcp_set_logging_level_failed", "error", err)
+		}
+	}
+}
+
+// trackProgress registers report for a tool call and returns its progress token
+func (c *MCPClient) trackProgress(report func(ToolProgress)) string {
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+
+	if c.progress == nil {
+		c.progress = make(map[string]func(ToolProgress))
+	}
+	c.progressCount++
+	token := fmt.Sprintf("polly-%d", c.progressCount)
+	c.progress[token] = report
+	return token
+}
+
+func (c *MCPClient) untrackProgress(token string) {
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+	delete(c.progress, token)
+}
+
+// notifyingTransport reports progress and log notifications as its
+// connection reads them. The SDK runs notification handlers on another
+// goroutine, so a tool call's result could otherwise arrive, and the call
+// stop listening, before the updates the server sent ahead of it.
+type notifyingTransport struct {
+	mcp.Transport
+	client *MCPClient
+}
+
+func (t notifyingTransport) Connect(ctx context.Context) (mcp.Connection, error) {
+	conn, err := t.Transport.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return notifyingConn{Connection: conn, client: t.client}, nil
 }
 
-// ListTools returns all tools available from the MCP server
+type notifyingConn struct {
+	mcp.Connection
+	client *MCPClient
+}
+
+func (c notifyingConn) Read(ctx context.Context) (jsonrpc.Message, error) {
+	msg, err := c.Connection.Read(ctx)
+	if req, ok := msg.(*jsonrpc.Request); ok && err == nil && !req.IsCall() {
+		switch req.Method {
+		case "notifications/progress":
+			var params mcp.ProgressNotificationParams
+			if json.Unmarshal(req.Params, &params) == nil {
+				c.client.reportProgress(&params)
+			}
+		case "notifications/message":
+			var params mcp.LoggingMessageParams
+			if json.Unmarshal(req.Params, &params) == nil {
+				c.client.reportLog(&params)
+			}
+		}
+	}
+	return msg, err
+}
+
+// reportProgress passes a progress notification to the call that owns its token
+func (c *MCPClient) reportProgress(params *mcp.ProgressNotificationParams) {
+	token, _ := params.ProgressToken.(string)
+	c.progressMu.Lock()
+	report := c.progress[token]
+	c.progressMu.Unlock()
+
+	if report != nil {
+		report(ToolProgress{Progress: params.Progress, Total: params.Total, Message: params.Message})
+	}
+}
+
+// reportLog passes a server log message to every in-flight tool call, since
+// log messages are not tied to a request
+func (c *MCPClient) reportLog(params *mcp.LoggingMessageParams) {
+	message, ok := params.Data.(string)
+	if !ok {
+		data, _ := json.Marshal(params.Data)
+		message = string(data)
+	}
+	slog.Debug("mcp_server_log", "server_name", c.Name(), "level", params.Level, "message", message)
+
+	c.progressMu.Lock()
+	reports := make([]func(ToolProgress), 0, len(c.progress))
+	for _, report := range c.progress {
+		reports = append(reports, report)
+	}
+	c.progressMu.Unlock()
+
+	for _, report := range reports {
+		report(ToolProgress{Level: string(params.Level), Message: message})
+	}
+}
+
+// Name returns the namespace the server's tools are registered under,
+// falling back to its server spec
+func (c *MCPClient) Name() string {
+	if c.namespace != "" {
+		return c.namespace
+	}
+	return c.serverSpec
+}
+
+// ListTools returns all tools available from the MCP server, plus a
+// read_resource tool when the server exposes resources
 func (c *MCPClient) ListTools() ([]Tool, error) {
 	ctx := context.Background()
 
 	// List available tools using the Tools iterator
 	var tools []Tool
-	for tool, err := range c.session.Tools(ctx, nil) {
+	session := c.current()
+	for tool, err := range session.Tools(ctx, nil) {
 		if err != nil {
 			return nil, fmt.Errorf("error listing tools: %v", err)
 		}
 		if tool != nil {
 			slog.Debug("mcp_tool_loaded", "tool_name", tool.Name, "description", tool.Description)
-			mcpTool := NewMCPTool(c.session, tool)
+			mcpTool := NewMCPTool(session, tool)
+			mcpTool.client = c
 			// Set the source to the server spec so it can be persisted
 			mcpTool.Source = c.serverSpec
 			tools = append(tools, mcpTool)
 		}
 	}
 
+	if c.HasResources() && !hasTool(tools, ResourceToolName) {
+		tools = append(tools, NewMCPResourceTool(c))
+	}
+
 	return tools, nil
 }
 
-// Close closes the MCP client connection
+func hasTool(tools []Tool, name string) bool {
+	for _, tool := range tools {
+		if tool.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the MCP client connection and stops reconnecting
 func (c *MCPClient) Close() error {
-	if c.session != nil {
-		return c.session.Close()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	if session := c.current(); session != nil {
+		return session.Close()
 	}
 	return nil
 }
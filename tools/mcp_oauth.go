@@ -0,0 +1,493 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/oauthex"
+	"golang.org/x/oauth2"
+)
+
+// How long to wait for the user to finish authorizing in the browser
+const oauthAuthorizeTimeout = 5 * time.Minute
+
+// oauthCacheDir returns the directory holding cached OAuth credentials,
+// one file per server. Tests point it elsewhere.
+var oauthCacheDir = func() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pollytool", "oauth"), nil
+}
+
+// openAuthURL sends the user to the authorization page. Tests replace it
+// with a client that follows the URL directly.
+var openAuthURL = func(server, authURL string) {
+	fmt.Fprintf(os.Stderr, "Authorize access to %s by opening:\n  %s\n", server, authURL)
+	if err := openBrowser(authURL); err != nil {
+		slog.Debug("mcp_oauth_browser_failed", "error", err)
+	}
+}
+
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// oauthCredentials is what gets cached for a server: the dynamically
+// registered client, the endpoints it was registered with, and its token
+type oauthCredentials struct {
+	ClientID     string        `json:"client_id"`
+	ClientSecret string        `json:"client_secret,omitempty"`
+	AuthURL      string        `json:"auth_url"`
+	TokenURL     string        `json:"token_url"`
+	Resource     string        `json:"resource,omitempty"`
+	Scopes       []string      `json:"scopes,omitempty"`
+	Token        *oauth2.Token `json:"token,omitempty"`
+}
+
+func (c *oauthCredentials) config(redirectURL string) *oauth2.Config {
+	style := oauth2.AuthStyleAutoDetect
+	if c.ClientSecret == "" {
+		style = oauth2.AuthStyleInParams
+	}
+	return &oauth2.Config{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   c.AuthURL,
+			TokenURL:  c.TokenURL,
+			AuthStyle: style,
+		},
+		RedirectURL: redirectURL,
+		Scopes:      c.Scopes,
+	}
+}
+
+// oauthCachePath returns the cache file for a server URL
+func oauthCachePath(server string) (string, error) {
+	dir, err := oauthCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(server))
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// loadOAuthCredentials returns the cached credentials for server, or nil
+// if there are none
+func loadOAuthCredentials(server string) (*oauthCredentials, error) {
+	path, err := oauthCachePath(server)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var creds oauthCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("invalid token cache %s: %w", path, err)
+	}
+	return &creds, nil
+}
+
+// saveOAuthCredentials writes the credentials for server, readable only by
+// the current user
+func saveOAuthCredentials(server string, creds *oauthCredentials) error {
+	path, err := oauthCachePath(server)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".token-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// oauthTransport authorizes requests to a remote MCP server with a bearer
+// token. The token comes from the cache, is refreshed when it expires, and
+// a 401 runs the browser authorization flow before the request is retried.
+type oauthTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration // Per-request limit, as http.Client.Timeout would apply
+	server  string        // MCP endpoint URL, also the cache key
+	scopes  []string
+
+	mu     sync.Mutex
+	loaded bool
+	creds  *oauthCredentials
+}
+
+// oauthHTTPClient creates an HTTP client for a server configured with
+// "auth": "oauth". The client itself has no timeout, since authorizing can
+// take as long as the user needs; the transport limits each request instead.
+func oauthHTTPClient(config *MCPConfig, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &oauthTransport{
+			base:    &headerRoundTripper{base: http.DefaultTransport, headers: config.Headers},
+			timeout: timeout,
+			server:  config.URL,
+			scopes:  config.Scopes,
+		},
+	}
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := t.token(req.Context())
+	resp, err := t.send(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// Can't replay the body, so let the caller see the 401
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if err := t.reauthorize(req.Context(), token, resp.Header); err != nil {
+		return nil, fmt.Errorf("oauth authorization for %s: %w", t.server, err)
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.send(retry, t.token(req.Context()))
+}
+
+// send performs one request with token, if any, within the timeout
+func (t *oauthTransport) send(req *http.Request, token string) (*http.Response, error) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), t.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(req.Context())
+	}
+	req = req.Clone(ctx)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request's context once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// token returns a usable access token, refreshing it if it has expired, or
+// "" if there is none
+func (t *oauthTransport) token(ctx context.Context) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		creds, err := loadOAuthCredentials(t.server)
+		if err != nil {
+			slog.Debug("mcp_oauth_cache_load_failed", "url", t.server, "error", err)
+		}
+		t.creds, t.loaded = creds, true
+	}
+	if t.creds == nil || t.creds.Token == nil {
+		return ""
+	}
+	if !t.creds.Token.Valid() && !t.refresh(ctx) {
+		return ""
+	}
+	return t.creds.Token.AccessToken
+}
+
+// refresh exchanges the refresh token for a new token and caches it.
+// Callers hold t.mu.
+func (t *oauthTransport) refresh(ctx context.Context) bool {
+	if t.creds.Token.RefreshToken == "" {
+		return false
+	}
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, t.httpClient())
+	expired := *t.creds.Token
+	expired.Expiry = time.Unix(1, 0)
+	token, err := t.creds.config("").TokenSource(ctx, &expired).Token()
+	if err != nil {
+		slog.Debug("mcp_oauth_refresh_failed", "url", t.server, "error", err)
+		return false
+	}
+	t.creds.Token = token
+	t.save()
+	return true
+}
+
+// reauthorize gets a new token after the server rejected failed, first by
+// refreshing and otherwise by asking the user to authorize in the browser
+func (t *oauthTransport) reauthorize(ctx context.Context, failed string, header http.Header) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.creds != nil && t.creds.Token != nil {
+		if t.creds.Token.AccessToken != failed {
+			// Another request already got a new token
+			return nil
+		}
+		if t.refresh(ctx) && t.creds.Token.AccessToken != failed {
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, oauthAuthorizeTimeout)
+	defer cancel()
+	creds, err := t.authorize(ctx, header)
+	if err != nil {
+		return err
+	}
+	t.creds = creds
+	t.save()
+	return nil
+}
+
+// save writes the credentials to the cache. Callers hold t.mu.
+func (t *oauthTransport) save() {
+	if err := saveOAuthCredentials(t.server, t.creds); err != nil {
+		slog.Warn("mcp_oauth_cache_save_failed", "url", t.server, "error", err)
+	}
+}
+
+// httpClient is used for discovery, registration and token requests
+func (t *oauthTransport) httpClient() *http.Client {
+	return &http.Client{Transport: t.base, Timeout: t.timeout}
+}
+
+// authorize runs the authorization code flow with PKCE: it discovers the
+// authorization server from the 401's challenge, registers a client for a
+// loopback redirect, and exchanges the code the browser is sent back with
+func (t *oauthTransport) authorize(ctx context.Context, header http.Header) (*oauthCredentials, error) {
+	client := t.httpClient()
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+
+	resource, issuer, scopes := t.discoverResource(ctx, client, header)
+	asm, err := auth.GetAuthServerMetadata(ctx, issuer, client)
+	if err != nil {
+		return nil, fmt.Errorf("authorization server metadata: %w", err)
+	}
+	if asm == nil {
+		// Servers without metadata use the default endpoints
+		base := strings.TrimSuffix(issuer, "/")
+		asm = &oauthex.AuthServerMeta{
+			Issuer:                issuer,
+			AuthorizationEndpoint: base + "/authorize",
+			TokenEndpoint:         base + "/token",
+			RegistrationEndpoint:  base + "/register",
+		}
+	}
+	if asm.RegistrationEndpoint == "" {
+		return nil, fmt.Errorf("authorization server %s does not support dynamic client registration", issuer)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the redirect: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://%s/callback", listener.Addr())
+
+	reg, err := oauthex.RegisterClient(ctx, asm.RegistrationEndpoint, &oauthex.ClientRegistrationMetadata{
+		ClientName:              "polly",
+		RedirectURIs:            []string{redirectURI},
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		ResponseTypes:           []string{"code"},
+		TokenEndpointAuthMethod: "none",
+		Scope:                   strings.Join(scopes, " "),
+	}, client)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("client registration: %w", err)
+	}
+
+	creds := &oauthCredentials{
+		ClientID:     reg.ClientID,
+		ClientSecret: reg.ClientSecret,
+		AuthURL:      asm.AuthorizationEndpoint,
+		TokenURL:     asm.TokenEndpoint,
+		Resource:     resource,
+		Scopes:       scopes,
+	}
+	cfg := creds.config(redirectURI)
+	verifier := oauth2.GenerateVerifier()
+	state := rand.Text()
+	resourceOpt := oauth2.SetAuthURLParam("resource", resource)
+	authURL := cfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), resourceOpt)
+
+	code, err := waitForAuthCode(ctx, listener, state, func() { openAuthURL(t.server, authURL) })
+	if err != nil {
+		return nil, err
+	}
+	creds.Token, err = cfg.Exchange(ctx, code, oauth2.VerifierOption(verifier), resourceOpt)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+	return creds, nil
+}
+
+// discoverResource finds the protected resource metadata for the server,
+// trying the URL from the challenge and then the well-known locations. It
+// returns the resource to request a token for, the authorization server,
+// and the scopes to ask for. Without metadata the server's origin is
+// assumed to be its own authorization server.
+func (t *oauthTransport) discoverResource(ctx context.Context, client *http.Client, header http.Header) (resource, issuer string, scopes []string) {
+	var metadataURL, challengeScope string
+	challenges, _ := oauthex.ParseWWWAuthenticate(header.Values("WWW-Authenticate"))
+	for _, c := range challenges {
+		if c.Scheme == "bearer" {
+			metadataURL = c.Params["resource_metadata"]
+			challengeScope = c.Params["scope"]
+			break
+		}
+	}
+	if len(t.scopes) > 0 {
+		scopes = t.scopes
+	} else if challengeScope != "" {
+		scopes = strings.Fields(challengeScope)
+	}
+
+	u, err := url.Parse(t.server)
+	if err != nil {
+		return t.server, t.server, scopes
+	}
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+
+	type candidate struct{ metadata, resource string }
+	var candidates []candidate
+	if metadataURL != "" {
+		candidates = append(candidates, candidate{metadataURL, t.server})
+	}
+	if p := strings.Trim(u.Path, "/"); p != "" {
+		candidates = append(candidates, candidate{origin + "/.well-known/oauth-protected-resource/" + p, t.server})
+	}
+	candidates = append(candidates, candidate{origin + "/.well-known/oauth-protected-resource", origin})
+
+	for _, c := range candidates {
+		prm, err := oauthex.GetProtectedResourceMetadata(ctx, c.metadata, c.resource, client)
+		if err != nil || len(prm.AuthorizationServers) == 0 {
+			slog.Debug("mcp_oauth_resource_metadata", "url", c.metadata, "error", err)
+			continue
+		}
+		if scopes == nil {
+			scopes = prm.ScopesSupported
+		}
+		return c.resource, prm.AuthorizationServers[0], scopes
+	}
+	return t.server, origin, scopes
+}
+
+// waitForAuthCode serves the loopback redirect on listener, calls open, and
+// returns the authorization code the browser comes back with
+func waitForAuthCode(ctx context.Context, listener net.Listener, state string, open func()) (string, error) {
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("authorization denied: %s", cmp.Or(q.Get("error_description"), q.Get("error")))
+		case q.Get("state") != state:
+			res.err = errors.New("authorization response has the wrong state")
+		case q.Get("code") == "":
+			res.err = errors.New("authorization response has no code")
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Authorized. You can close this window and return to polly.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	open()
+	select {
+	case res := <-results:
+		return res.code, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("timed out waiting for authorization: %w", ctx.Err())
+	}
+}
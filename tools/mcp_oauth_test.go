@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// fakeAuthServer is an MCP server protected by its own OAuth authorization
+// server, with dynamic registration and PKCE
+type fakeAuthServer struct {
+	*httptest.Server
+
+	mu             sync.Mutex
+	access         string            // The one access token the MCP endpoint accepts
+	refresh        map[string]bool   // Refresh tokens that can still be used
+	challenges     map[string]string // Code challenge by authorization code
+	issued         int
+	authorizations int
+	refreshes      int
+}
+
+func newFakeAuthServer(t *testing.T) *fakeAuthServer {
+	t.Helper()
+	f := &fakeAuthServer{refresh: map[string]bool{}, challenges: map[string]string{}}
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "hosted", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "ping"}, func(context.Context, *mcp.CallToolRequest, struct{}) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "pong"}}}, nil, nil
+	})
+	mcpHandler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		ok := f.access != "" && r.Header.Get("Authorization") == "Bearer "+f.access
+		f.mu.Unlock()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s/.well-known/oauth-protected-resource/mcp"`, f.URL))
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mcpHandler.ServeHTTP(w, r)
+	})
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"resource":              f.URL + "/mcp",
+			"authorization_servers": []string{f.URL},
+			"scopes_supported":      []string{"tools"},
+		})
+	})
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           f.URL,
+			"authorization_endpoint":           f.URL + "/authorize",
+			"token_endpoint":                   f.URL + "/token",
+			"registration_endpoint":            f.URL + "/register",
+			"response_types_supported":         []string{"code"},
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+		var meta map[string]any
+		json.NewDecoder(r.Body).Decode(&meta)
+		meta["client_id"] = "polly-test"
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(meta)
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("client_id") != "polly-test" || q.Get("code_challenge_method") != "S256" || q.Get("resource") != f.URL+"/mcp" {
+			http.Error(w, "bad authorization request", http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.authorizations++
+		code := fmt.Sprintf("code-%d", f.authorizations)
+		f.challenges[code] = q.Get("code_challenge")
+		f.mu.Unlock()
+		redirect, _ := url.Parse(q.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			challenge, ok := f.challenges[r.Form.Get("code")]
+			if !ok || challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			delete(f.challenges, r.Form.Get("code"))
+		case "refresh_token":
+			if !f.refresh[r.Form.Get("refresh_token")] {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			f.refreshes++
+		default:
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		f.issued++
+		f.access = fmt.Sprintf("access-%d", f.issued)
+		refresh := fmt.Sprintf("refresh-%d", f.issued)
+		f.refresh[refresh] = true
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  f.access,
+			"refresh_token": refresh,
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	})
+
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+// revoke invalidates every token the server has issued
+func (f *fakeAuthServer) revoke() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.access = ""
+	clear(f.refresh)
+}
+
+func (f *fakeAuthServer) counts() (authorizations, refreshes int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.authorizations, f.refreshes
+}
+
+// useTestOAuth caches tokens in a temp dir and follows authorization URLs
+// directly instead of opening a browser
+func useTestOAuth(t *testing.T) string {
+	dir := t.TempDir()
+	prevDir, prevOpen := oauthCacheDir, openAuthURL
+	oauthCacheDir = func() (string, error) { return dir, nil }
+	openAuthURL = func(_, authURL string) {
+		resp, err := http.Get(authURL)
+		if err != nil {
+			t.Errorf("following authorization URL: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}
+	t.Cleanup(func() { oauthCacheDir, openAuthURL = prevDir, prevOpen })
+	return dir
+}
+
+func connectOAuth(t *testing.T, f *fakeAuthServer) {
+	t.Helper()
+	client, err := NewMCPClientFromConfig(&MCPConfig{Transport: "streamable", URL: f.URL + "/mcp", Auth: "oauth"}, nil)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer client.Close()
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := serverTools[0].Execute(context.Background(), nil)
+	if err != nil || !strings.Contains(out, "pong") {
+		t.Fatalf("Execute() = %q, %v", out, err)
+	}
+}
+
+func TestMCPOAuthAuthorizesAndCaches(t *testing.T) {
+	dir := useTestOAuth(t)
+	f := newFakeAuthServer(t)
+
+	connectOAuth(t, f)
+	if auths, _ := f.counts(); auths != 1 {
+		t.Fatalf("authorizations = %d, want 1", auths)
+	}
+
+	creds, err := loadOAuthCredentials(f.URL + "/mcp")
+	if err != nil || creds == nil {
+		t.Fatalf("cached credentials = %v, %v", creds, err)
+	}
+	if creds.ClientID != "polly-test" || creds.Token.AccessToken != "access-1" || creds.Token.RefreshToken != "refresh-1" {
+		t.Errorf("cached credentials = %+v", creds)
+	}
+	if len(creds.Scopes) != 1 || creds.Scopes[0] != "tools" {
+		t.Errorf("scopes = %v, want the resource's supported scopes", creds.Scopes)
+	}
+	path, _ := oauthCachePath(f.URL + "/mcp")
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 || !strings.HasPrefix(path, dir) {
+		t.Errorf("cache file %s: %v, %v", path, info, err)
+	}
+
+	// A new connection reuses the cached token without authorizing again
+	connectOAuth(t, f)
+	if auths, _ := f.counts(); auths != 1 {
+		t.Errorf("authorizations after reconnect = %d, want 1", auths)
+	}
+}
+
+func TestMCPOAuthRefreshesExpiredToken(t *testing.T) {
+	useTestOAuth(t)
+	f := newFakeAuthServer(t)
+	connectOAuth(t, f)
+
+	server := f.URL + "/mcp"
+	creds, _ := loadOAuthCredentials(server)
+	creds.Token.Expiry = time.Now().Add(-time.Minute)
+	if err := saveOAuthCredentials(server, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	connectOAuth(t, f)
+	if auths, refreshes := f.counts(); auths != 1 || refreshes != 1 {
+		t.Errorf("authorizations, refreshes = %d, %d, want 1, 1", auths, refreshes)
+	}
+	if creds, _ := loadOAuthCredentials(server); creds.Token.AccessToken != "access-2" {
+		t.Errorf("cached access token = %q, want the refreshed one", creds.Token.AccessToken)
+	}
+}
+
+func TestMCPOAuthReauthorizesOnUnauthorized(t *testing.T) {
+	useTestOAuth(t)
+	f := newFakeAuthServer(t)
+	connectOAuth(t, f)
+
+	// The cached token looks valid but the server no longer accepts it, and
+	// the refresh token is gone too, so the user authorizes again
+	f.revoke()
+	connectOAuth(t, f)
+	if auths, refreshes := f.counts(); auths != 2 || refreshes != 0 {
+		t.Errorf("authorizations, refreshes = %d, %d, want 2, 0", auths, refreshes)
+	}
+}
+
+func TestMCPConfigAuthValidation(t *testing.T) {
+	tests := []struct {
+		config MCPConfig
+		want   string
+	}{
+		{MCPConfig{Transport: "streamable", URL: "http://127.0.0.1:1/mcp", Auth: "basic"}, "unknown auth mode"},
+		{MCPConfig{Command: "server", Auth: "oauth"}, "requires a remote transport"},
+	}
+	for _, tt := range tests {
+		_, err := NewMCPClientFromConfig(&tt.config, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("NewMCPClientFromConfig(%+v) error = %v, want %q", tt.config, err, tt.want)
+		}
+	}
+}
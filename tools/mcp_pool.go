@@ -0,0 +1,702 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MCPPool keeps stdio MCP servers running between polly invocations. A
+// daemon serves it on a unix socket; registries created WithMCPPool get a
+// proxy session per invocation, so each server starts once and stays warm
+// until it has been idle for the pool's timeout.
+//
+// Pool wire protocol: each connection starts with one JSON request line and
+// one JSON response line. Connect requests then speak MCP on the same
+// connection.
+type MCPPool struct {
+	newSandbox func(sandbox.Config) (sandbox.Sandbox, error)
+	dialer     func(*MCPConfig, sandbox.Sandbox, string) (func() (mcp.Transport, error), error)
+	idle       time.Duration
+	started    time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*poolEntry // By poolRequest.key
+	conns    map[net.Conn]bool     // Open connections, closed on shutdown
+	clients  int                   // Proxy sessions in use
+	lastUsed time.Time             // When the last proxy session ended
+	closed   bool
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// poolEntry is one pooled server and the proxy servers mirroring it
+type poolEntry struct {
+	name    string
+	command string
+	dir     string
+	ready   chan struct{} // Closed once client or err is set
+	client  *MCPClient
+	err     error
+
+	// Guarded by the pool's mutex
+	clients  int
+	lastUsed time.Time
+
+	mu      sync.Mutex
+	tools   []*mcp.Tool
+	proxies map[*mcp.Server]bool
+}
+
+// MCPPoolStatus describes a running pool
+type MCPPoolStatus struct {
+	PID     int             `json:"pid"`
+	Started time.Time       `json:"started"`
+	Servers []MCPPoolServer `json:"servers"`
+}
+
+// MCPPoolServer describes one pooled server
+type MCPPoolServer struct {
+	Name     string             `json:"name"`
+	Command  string             `json:"command"`
+	Dir      string             `json:"dir"`
+	State    MCPConnectionState `json:"state"`
+	Clients  int                `json:"clients"` // Invocations using it now
+	LastUsed time.Time          `json:"lastUsed"`
+}
+
+type poolRequest struct {
+	Op      string          `json:"op"`                // "connect", "status" or "stop"
+	Name    string          `json:"name,omitempty"`    // Namespace, for status and logs
+	Config  *MCPConfig      `json:"config,omitempty"`  // Server to connect to
+	Sandbox *sandbox.Config `json:"sandbox,omitempty"` // nil runs the server unsandboxed
+	Dir     string          `json:"dir,omitempty"`     // Working directory for the server
+	Roots   []*mcp.Root     `json:"roots,omitempty"`
+}
+
+type poolResponse struct {
+	Error  string         `json:"error,omitempty"`
+	Status *MCPPoolStatus `json:"status,omitempty"`
+}
+
+// key identifies the server a connect request asks for. Requests differing
+// only in namespace share a server.
+func (req *poolRequest) key() string {
+	data, _ := json.Marshal(struct {
+		Config  *MCPConfig
+		Sandbox *sandbox.Config
+		Dir     string
+	}{req.Config, req.Sandbox, req.Dir})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// poolHandshakeTimeout bounds the request and response exchange, which
+// includes starting the server on first use
+const poolHandshakeTimeout = time.Minute
+
+// NewMCPPool creates a pool that sandboxes servers with newSandbox and stops
+// those unused for idle. With idle <= 0 servers run until the pool stops.
+func NewMCPPool(newSandbox func(sandbox.Config) (sandbox.Sandbox, error), idle time.Duration) *MCPPool {
+	now := time.Now()
+	return &MCPPool{
+		newSandbox: newSandbox,
+		dialer:     mcpDialer,
+		idle:       idle,
+		started:    now,
+		entries:    make(map[string]*poolEntry),
+		conns:      make(map[net.Conn]bool),
+		lastUsed:   now,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Serve accepts connections on l until ctx is done, a stop request arrives,
+// or the pool has had no servers or connections for its idle timeout. It
+// closes l and every pooled server before returning.
+func (p *MCPPool) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-p.stop:
+		}
+		cancel()
+		l.Close()
+	}()
+	if p.idle > 0 {
+		go p.reap(ctx, cancel)
+	}
+
+	var wg sync.WaitGroup
+	var err error
+	for {
+		conn, acceptErr := l.Accept()
+		if acceptErr != nil {
+			if ctx.Err() == nil {
+				err = acceptErr
+			}
+			break
+		}
+		p.mu.Lock()
+		p.conns[conn] = true
+		p.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.handle(conn)
+			p.mu.Lock()
+			delete(p.conns, conn)
+			p.mu.Unlock()
+			conn.Close()
+		}()
+	}
+
+	cancel()
+	p.shutdown()
+	wg.Wait()
+	return err
+}
+
+// shutdown closes every connection and pooled server
+func (p *MCPPool) shutdown() {
+	p.mu.Lock()
+	p.closed = true
+	var conns []net.Conn
+	for conn := range p.conns {
+		conns = append(conns, conn)
+	}
+	var clients []*MCPClient
+	for key, e := range p.entries {
+		select {
+		case <-e.ready:
+			clients = append(clients, e.client)
+		default:
+			// Still starting; acquire closes it once it sees the pool closed
+		}
+		delete(p.entries, key)
+	}
+	p.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+// handle answers one connection's request
+func (p *MCPPool) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	var req poolRequest
+	conn.SetReadDeadline(time.Now().Add(poolHandshakeTimeout))
+	line, err := r.ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		slog.Debug("mcp_pool_bad_request", "error", err)
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	switch req.Op {
+	case "status":
+		status := p.status()
+		writePoolResponse(conn, poolResponse{Status: &status})
+
+	case "stop":
+		writePoolResponse(conn, poolResponse{})
+		p.stopOnce.Do(func() { close(p.stop) })
+
+	case "connect":
+		e, err := p.acquire(&req)
+		if err != nil {
+			writePoolResponse(conn, poolResponse{Error: err.Error()})
+			return
+		}
+		defer p.release(e)
+		if err := writePoolResponse(conn, poolResponse{}); err != nil {
+			return
+		}
+		e.serveProxy(&mcp.IOTransport{Reader: bufferedConn{r, conn}, Writer: conn})
+
+	default:
+		writePoolResponse(conn, poolResponse{Error: fmt.Sprintf("unknown pool request: %q", req.Op)})
+	}
+}
+
+// acquire returns the pooled server for req, starting it on first use
+func (p *MCPPool) acquire(req *poolRequest) (*poolEntry, error) {
+	if req.Config == nil {
+		return nil, fmt.Errorf("connect request without a server config")
+	}
+
+	key := req.key()
+	p.mu.Lock()
+	e, ok := p.entries[key]
+	if !ok {
+		e = &poolEntry{
+			name:     req.Name,
+			command:  formatConfigDisplay(*req.Config),
+			dir:      req.Dir,
+			ready:    make(chan struct{}),
+			lastUsed: time.Now(),
+			proxies:  make(map[*mcp.Server]bool),
+		}
+		p.entries[key] = e
+	}
+	e.clients++
+	p.clients++
+	p.mu.Unlock()
+
+	if !ok {
+		client, err := p.start(req, e)
+		p.mu.Lock()
+		if err == nil && p.closed {
+			client.Close()
+			err = errors.New("MCP pool is shutting down")
+		}
+		if err != nil && p.entries[key] == e {
+			delete(p.entries, key)
+		}
+		p.mu.Unlock()
+		e.client, e.err = client, err
+		close(e.ready)
+	}
+
+	<-e.ready
+	if e.err != nil {
+		p.release(e)
+		return nil, e.err
+	}
+	return e, nil
+}
+
+func (p *MCPPool) release(e *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	e.clients--
+	e.lastUsed = now
+	p.clients--
+	p.lastUsed = now
+}
+
+// start connects to the server req describes and lists its tools
+func (p *MCPPool) start(req *poolRequest, e *poolEntry) (*MCPClient, error) {
+	var sb sandbox.Sandbox
+	if req.Sandbox != nil {
+		if p.newSandbox == nil {
+			return nil, fmt.Errorf("sandbox for MCP server %s: sandboxing not available", req.Name)
+		}
+		var err error
+		if sb, err = p.newSandbox(*req.Sandbox); err != nil {
+			return nil, fmt.Errorf("sandbox for MCP server %s: %w", req.Name, err)
+		}
+	}
+	dial, err := p.dialer(req.Config, sb, req.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := connectMCPClient(context.Background(), dial, withRoots(req.Roots), withToolListChanged(e.refreshTools))
+	if err != nil {
+		return nil, err
+	}
+	client.namespace = req.Name
+	tools, err := listServerTools(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	e.setTools(client, tools)
+	slog.Debug("mcp_pool_server_started", "server_name", req.Name, "command", e.command, "dir", req.Dir)
+	return client, nil
+}
+
+// reap closes servers idle for the pool's timeout and stops the pool once
+// it has nothing left to do
+func (p *MCPPool) reap(ctx context.Context, stop context.CancelFunc) {
+	ticker := time.NewTicker(max(p.idle/4, 10*time.Millisecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		var idle []*poolEntry
+		p.mu.Lock()
+		for key, e := range p.entries {
+			if e.clients == 0 && now.Sub(e.lastUsed) >= p.idle {
+				delete(p.entries, key)
+				idle = append(idle, e)
+			}
+		}
+		exit := len(p.entries) == 0 && p.clients == 0 && now.Sub(p.lastUsed) >= p.idle
+		p.mu.Unlock()
+
+		for _, e := range idle {
+			slog.Debug("mcp_pool_server_idle", "server_name", e.name, "dir", e.dir)
+			e.client.Close()
+		}
+		if exit {
+			slog.Debug("mcp_pool_idle")
+			stop()
+			return
+		}
+	}
+}
+
+func (p *MCPPool) status() MCPPoolStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := MCPPoolStatus{PID: os.Getpid(), Started: p.started, Servers: []MCPPoolServer{}}
+	for _, e := range p.entries {
+		select {
+		case <-e.ready:
+		default:
+			continue
+		}
+		status.Servers = append(status.Servers, MCPPoolServer{
+			Name:     e.name,
+			Command:  e.command,
+			Dir:      e.dir,
+			State:    e.client.State(),
+			Clients:  e.clients,
+			LastUsed: e.lastUsed,
+		})
+	}
+	sort.Slice(status.Servers, func(i, j int) bool {
+		a, b := status.Servers[i], status.Servers[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Dir < b.Dir
+	})
+	return status
+}
+
+// serveProxy serves one invocation a proxy for the pooled server until the
+// connection ends
+func (e *poolEntry) serveProxy(transport mcp.Transport) {
+	ctx := context.Background()
+	var opts *mcp.ServerOptions
+	impl := &mcp.Implementation{Name: e.name, Version: "pooled"}
+	if init := e.client.current().InitializeResult(); init != nil {
+		if init.ServerInfo != nil {
+			impl = init.ServerInfo
+		}
+		opts = &mcp.ServerOptions{Instructions: init.Instructions}
+	}
+	server := mcp.NewServer(impl, opts)
+	mirrorCatalog(ctx, server, e.client)
+
+	e.mu.Lock()
+	mirrorTools(server, e.client, nil, e.tools)
+	e.proxies[server] = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		delete(e.proxies, server)
+		e.mu.Unlock()
+	}()
+
+	session, err := server.Connect(ctx, transport, nil)
+	if err != nil {
+		slog.Debug("mcp_pool_proxy_failed", "server_name", e.name, "error", err)
+		return
+	}
+	session.Wait()
+}
+
+// setTools records the server's tool list and mirrors it on every proxy
+func (e *poolEntry) setTools(client *MCPClient, tools []*mcp.Tool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	old := e.tools
+	e.tools = tools
+	for server := range e.proxies {
+		mirrorTools(server, client, old, tools)
+	}
+}
+
+// refreshTools re-lists tools after the server announces a change or
+// reconnects; the proxies pass the change on to their invocations
+func (e *poolEntry) refreshTools(client *MCPClient) {
+	tools, err := listServerTools(client)
+	if err != nil {
+		slog.Debug("mcp_pool_refresh_failed", "server_name", e.name, "error", err)
+		return
+	}
+	e.setTools(client, tools)
+}
+
+func listServerTools(client *MCPClient) ([]*mcp.Tool, error) {
+	var tools []*mcp.Tool
+	for tool, err := range client.current().Tools(context.Background(), nil) {
+		if err != nil {
+			return nil, fmt.Errorf("error listing tools: %v", err)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// mirrorTools replaces old with tools on a proxy server. Tools without an
+// object input schema can't be served and are left out.
+func mirrorTools(server *mcp.Server, client *MCPClient, old, tools []*mcp.Tool) {
+	keep := make(map[string]bool)
+	for _, tool := range tools {
+		keep[tool.Name] = true
+	}
+	var stale []string
+	for _, tool := range old {
+		if !keep[tool.Name] {
+			stale = append(stale, tool.Name)
+		}
+	}
+	if len(stale) > 0 {
+		server.RemoveTools(stale...)
+	}
+
+	for _, tool := range tools {
+		var s struct {
+			Type string `json:"type"`
+		}
+		if data, err := json.Marshal(tool.InputSchema); err != nil || json.Unmarshal(data, &s) != nil || s.Type != "object" {
+			slog.Debug("mcp_pool_tool_skipped", "tool_name", tool.Name)
+			continue
+		}
+		server.AddTool(tool, forwardCall(client))
+	}
+}
+
+// forwardCall returns a proxy handler that makes the call on the pooled
+// server's live session, relaying progress, log messages and cancellation
+func forwardCall(client *MCPClient) mcp.ToolHandler {
+	return func(ctx context.Context, req *mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if client.State() == MCPReconnecting {
+			return unavailableResult(client), nil
+		}
+
+		params := &mcp.CallToolParams{Name: req.Params.Name}
+		if len(req.Params.Arguments) > 0 {
+			params.Arguments = req.Params.Arguments
+		}
+		if token := req.Params.GetProgressToken(); token != nil {
+			upstream := client.trackProgress(func(p ToolProgress) {
+				if p.Level != "" {
+					req.Session.Log(ctx, &mcp.LoggingMessageParams{Level: mcp.LoggingLevel(p.Level), Data: p.Message})
+					return
+				}
+				req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{ProgressToken: token, Progress: p.Progress, Total: p.Total, Message: p.Message})
+			})
+			defer client.untrackProgress(upstream)
+			params.SetProgressToken(upstream)
+		}
+
+		session := client.current()
+		result, err := session.CallTool(ctx, params)
+		if err != nil && ctx.Err() == nil && client.lost(session, err) {
+			return unavailableResult(client), nil
+		}
+		return result, err
+	}
+}
+
+// unavailableResult reports a lost server as a tool error, since structured
+// errors don't survive the proxy
+func unavailableResult(client *MCPClient) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: client.unavailableError().Error()}},
+	}
+}
+
+// mirrorCatalog copies the server's resources, resource templates and
+// prompts onto a proxy server. They are listed once per proxy session.
+func mirrorCatalog(ctx context.Context, server *mcp.Server, client *MCPClient) {
+	if client.HasResources() {
+		read := func(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return client.current().ReadResource(ctx, req.Params)
+		}
+		resources, err := client.ListResources(ctx)
+		if err != nil {
+			slog.Debug("mcp_pool_list_resources_failed", "error", err)
+		}
+		for _, resource := range resources {
+			mirror(resource.URI, func() { server.AddResource(resource, read) })
+		}
+		templates, err := client.ListResourceTemplates(ctx)
+		if err != nil {
+			slog.Debug("mcp_pool_list_templates_failed", "error", err)
+		}
+		for _, template := range templates {
+			mirror(template.URITemplate, func() { server.AddResourceTemplate(template, read) })
+		}
+	}
+
+	if client.HasPrompts() {
+		get := func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return client.current().GetPrompt(ctx, req.Params)
+		}
+		prompts, err := client.ListPrompts(ctx)
+		if err != nil {
+			slog.Debug("mcp_pool_list_prompts_failed", "error", err)
+		}
+		for _, prompt := range prompts {
+			server.AddPrompt(prompt, get)
+		}
+	}
+}
+
+// mirror runs add, skipping the item if the SDK rejects it as invalid
+func mirror(name string, add func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Debug("mcp_pool_item_skipped", "name", name, "error", r)
+		}
+	}()
+	add()
+}
+
+// bufferedConn reads through r, which may hold bytes already read from the
+// connection
+type bufferedConn struct {
+	r *bufio.Reader
+	net.Conn
+}
+
+func (c bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func writePoolResponse(conn net.Conn, resp poolResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(append(data, '\n'))
+	return err
+}
+
+// poolHandshake sends req over conn and reads the response, returning a
+// reader holding anything the daemon sent after it
+func poolHandshake(conn net.Conn, req poolRequest) (*bufio.Reader, *poolResponse, error) {
+	conn.SetDeadline(time.Now().Add(poolHandshakeTimeout))
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, nil, fmt.Errorf("MCP pool: %w", err)
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("MCP pool: %w", err)
+	}
+	var resp poolResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, nil, fmt.Errorf("MCP pool: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, nil, errors.New(resp.Error)
+	}
+	conn.SetDeadline(time.Time{})
+	return r, &resp, nil
+}
+
+// poolRoundTrip sends a request that needs nothing beyond the response
+func poolRoundTrip(socket string, req poolRequest) (*poolResponse, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_, resp, err := poolHandshake(conn, req)
+	return resp, err
+}
+
+// QueryMCPPool returns the status of the pool daemon listening on socket
+func QueryMCPPool(socket string) (*MCPPoolStatus, error) {
+	resp, err := poolRoundTrip(socket, poolRequest{Op: "status"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status == nil {
+		return nil, fmt.Errorf("MCP pool: empty status")
+	}
+	return resp.Status, nil
+}
+
+// StopMCPPool asks the pool daemon listening on socket to close its servers
+// and exit
+func StopMCPPool(socket string) error {
+	_, err := poolRoundTrip(socket, poolRequest{Op: "stop"})
+	return err
+}
+
+// newPoolRequest describes a server to the pool daemon, resolving paths
+// against this process's working directory
+func newPoolRequest(name string, config *MCPConfig, sandboxCfg *sandbox.Config) poolRequest {
+	req := poolRequest{Op: "connect", Name: name, Config: config, Dir: fileAccess{}.abs(".")}
+	var writable []string
+	if sandboxCfg != nil {
+		cfg := *sandboxCfg
+		cfg.WritablePaths = absPaths(cfg.WritablePaths)
+		cfg.ReadPaths = absPaths(cfg.ReadPaths)
+		req.Sandbox = &cfg
+		writable = cfg.WritablePaths
+	}
+	req.Roots = workspaceRoots(writable)
+	return req
+}
+
+func absPaths(paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		out = append(out, fileAccess{}.abs(p))
+	}
+	return out
+}
+
+// pooledDialer connects through the pool daemon on socket, or calls direct
+// when the daemon isn't running
+func pooledDialer(socket string, req poolRequest, direct func() (mcp.Transport, error)) func() (mcp.Transport, error) {
+	return func() (mcp.Transport, error) {
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			slog.Debug("mcp_pool_unreachable", "socket", socket, "error", err)
+			return direct()
+		}
+		r, _, err := poolHandshake(conn, req)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		slog.Debug("mcp_pool_connected", "server_name", req.Name, "socket", socket)
+		return &mcp.IOTransport{Reader: bufferedConn{r, conn}, Writer: conn}, nil
+	}
+}
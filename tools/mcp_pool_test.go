@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// startTestPool serves a pool on a temp socket whose servers all run server
+// in process. It returns the socket, a count of servers started and the
+// result of Serve.
+func startTestPool(t *testing.T, server *mcp.Server, idle time.Duration) (string, *atomic.Int32, <-chan error) {
+	t.Helper()
+	pool := NewMCPPool(nil, idle)
+	var starts atomic.Int32
+	pool.dialer = func(*MCPConfig, sandbox.Sandbox, string) (func() (mcp.Transport, error), error) {
+		starts.Add(1)
+		return func() (mcp.Transport, error) {
+			serverTransport, clientTransport := mcp.NewInMemoryTransports()
+			if _, err := server.Connect(context.Background(), serverTransport, nil); err != nil {
+				return nil, err
+			}
+			return clientTransport, nil
+		}, nil
+	}
+
+	socket := filepath.Join(t.TempDir(), "pool.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, stopped := make(chan error, 1), make(chan struct{})
+	go func() {
+		done <- pool.Serve(context.Background(), l)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		StopMCPPool(socket)
+		<-stopped
+	})
+	return socket, &starts, done
+}
+
+func newPooledTestServer() *mcp.Server {
+	server := mcp.NewServer(&mcp.Implementation{Name: "warm", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "ping"}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		if token := req.Params.GetProgressToken(); token != nil {
+			req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{ProgressToken: token, Progress: 1, Message: "warming"})
+			// Clients handle notifications concurrently with responses, and
+			// the relay adds a hop; let the update land before returning
+			time.Sleep(50 * time.Millisecond)
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "pong"}}}, nil, nil
+	})
+	return server
+}
+
+// createPooledTestConfig writes a config for the pool's test server,
+// opted in to pooling
+func createPooledTestConfig(t *testing.T) string {
+	t.Helper()
+	data, err := json.Marshal(MCPServersConfig{MCPServers: map[string]MCPConfig{
+		"warm": {Command: "warm-server", Pool: true},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "mcp.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMCPPoolSharesServer(t *testing.T) {
+	socket, starts, done := startTestPool(t, newPooledTestServer(), time.Hour)
+	configPath := createPooledTestConfig(t)
+
+	var registries []*ToolRegistry
+	for range 2 {
+		registry := NewToolRegistry(nil, WithMCPPool(socket))
+		if _, err := registry.LoadMCPServer(configPath); err != nil {
+			t.Fatal(err)
+		}
+		registries = append(registries, registry)
+
+		tool, ok := registry.Get("warm__ping")
+		if !ok {
+			t.Fatal("warm__ping not loaded through the pool")
+		}
+		var mu sync.Mutex
+		var progress []ToolProgress
+		ctx := WithProgressReporter(context.Background(), func(p ToolProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		})
+		out, err := tool.Execute(ctx, nil)
+		if err != nil || !strings.Contains(out, "pong") {
+			t.Fatalf("Execute() = %q, %v", out, err)
+		}
+		mu.Lock()
+		if len(progress) != 1 || progress[0].Message != "warming" {
+			t.Errorf("progress = %+v, want the server's update relayed", progress)
+		}
+		mu.Unlock()
+	}
+	if n := starts.Load(); n != 1 {
+		t.Errorf("servers started = %d, want 1 shared by both registries", n)
+	}
+
+	status, err := QueryMCPPool(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if len(status.Servers) != 1 {
+		t.Fatalf("status servers = %+v, want 1", status.Servers)
+	}
+	if s := status.Servers[0]; s.Name != "warm" || s.Clients != 2 || s.State != MCPConnected || s.Dir != wd || s.Command != "warm-server" {
+		t.Errorf("status server = %+v", s)
+	}
+
+	for _, registry := range registries {
+		registry.Close()
+	}
+	if err := StopMCPPool(socket); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pool did not stop")
+	}
+}
+
+func TestMCPPoolExitsWhenIdle(t *testing.T) {
+	socket, _, done := startTestPool(t, newPooledTestServer(), 50*time.Millisecond)
+	registry := NewToolRegistry(nil, WithMCPPool(socket))
+	if _, err := registry.LoadMCPServer(createPooledTestConfig(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	// In use, the server outlives the idle timeout
+	time.Sleep(200 * time.Millisecond)
+	if status, err := QueryMCPPool(socket); err != nil || len(status.Servers) != 1 {
+		t.Fatalf("status while in use = %+v, %v", status, err)
+	}
+
+	registry.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("idle pool did not exit")
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("socket left behind after exit: %v", err)
+	}
+}
+
+func TestMCPPoolUnreachableStartsDirectly(t *testing.T) {
+	var direct int
+	dial := pooledDialer(filepath.Join(t.TempDir(), "missing.sock"), poolRequest{Op: "connect"}, func() (mcp.Transport, error) {
+		direct++
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+		if _, err := newPooledTestServer().Connect(context.Background(), serverTransport, nil); err != nil {
+			return nil, err
+		}
+		return clientTransport, nil
+	})
+	client, err := connectMCPClient(context.Background(), dial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if direct != 1 {
+		t.Errorf("direct dials = %d, want 1", direct)
+	}
+}
+
+func TestMCPConfigPooled(t *testing.T) {
+	tests := []struct {
+		config MCPConfig
+		want   bool
+	}{
+		{MCPConfig{Command: "server"}, false},
+		{MCPConfig{Command: "server", Pool: true}, true},
+		{MCPConfig{Transport: "streamable", URL: "http://localhost/mcp", Pool: true}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.config.pooled(); got != tt.want {
+			t.Errorf("%+v.pooled() = %v, want %v", tt.config, got, tt.want)
+		}
+	}
+}
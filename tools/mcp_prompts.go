@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// HasPrompts reports whether the server advertises the prompts capability
+func (c *MCPClient) HasPrompts() bool {
+	init := c.current().InitializeResult()
+	return init != nil && init.Capabilities != nil && init.Capabilities.Prompts != nil
+}
+
+// ListPrompts returns the prompt templates the server publishes
+func (c *MCPClient) ListPrompts(ctx context.Context) ([]*mcp.Prompt, error) {
+	var prompts []*mcp.Prompt
+	for prompt, err := range c.current().Prompts(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("error listing prompts: %w", err)
+		}
+		prompts = append(prompts, prompt)
+	}
+	return prompts, nil
+}
+
+// GetPrompt expands the named prompt with args into its messages
+func (c *MCPClient) GetPrompt(ctx context.Context, name string, args map[string]string) (*mcp.GetPromptResult, error) {
+	result, err := c.current().GetPrompt(ctx, &mcp.GetPromptParams{Name: name, Arguments: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt %s: %w", name, err)
+	}
+	return result, nil
+}
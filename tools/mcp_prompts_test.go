@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func connectPromptServer(t *testing.T) *MCPClient {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: "library", Version: "1.0.0"}, nil)
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "review",
+		Description: "Review a change",
+		Arguments:   []*mcp.PromptArgument{{Name: "focus", Required: true}},
+	}, func(_ context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return &mcp.GetPromptResult{Messages: []*mcp.PromptMessage{
+			{Role: "user", Content: &mcp.TextContent{Text: "Review with a focus on " + req.Params.Arguments["focus"]}},
+		}}, nil
+	})
+
+	client := connectInMemory(t, server)
+	client.namespace = "library"
+	return client
+}
+
+func TestMCPClientPrompts(t *testing.T) {
+	client := connectPromptServer(t)
+	ctx := context.Background()
+
+	if !client.HasPrompts() || client.HasResources() {
+		t.Fatalf("HasPrompts() = %v, HasResources() = %v", client.HasPrompts(), client.HasResources())
+	}
+	prompts, err := client.ListPrompts(ctx)
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "review" || !prompts[0].Arguments[0].Required {
+		t.Fatalf("ListPrompts() = %v, %v", prompts, err)
+	}
+
+	result, err := client.GetPrompt(ctx, "review", map[string]string{"focus": "errors"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, ok := result.Messages[0].Content.(*mcp.TextContent)
+	if !ok || text.Text != "Review with a focus on errors" {
+		t.Fatalf("GetPrompt() = %+v", result.Messages[0])
+	}
+	if _, err := client.GetPrompt(ctx, "missing", nil); err == nil {
+		t.Fatal("GetPrompt() of an unknown prompt should fail")
+	}
+}
+
+func TestRegistryMCPServerClients(t *testing.T) {
+	client := connectPromptServer(t)
+	registry := NewToolRegistry(nil)
+	registry.serverClients[client.namespace] = client
+
+	if got, ok := registry.MCPServerClient("library"); !ok || got != client {
+		t.Fatalf("MCPServerClient(library) = %v, %v", got, ok)
+	}
+	if _, ok := registry.MCPServerClient("other"); ok {
+		t.Fatal("MCPServerClient(other) should not be found")
+	}
+	if got := registry.MCPServerNamespaces(); !slices.Equal(got, []string{"library"}) {
+		t.Fatalf("MCPServerNamespaces() = %v", got)
+	}
+
+	registry.Close()
+	if len(registry.MCPServerNamespaces()) != 0 {
+		t.Fatal("Close() should forget server clients")
+	}
+}
@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/alexschlessinger/pollytool/schema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ResourceToolName is the bare name of the tool added for MCP servers that
+// expose resources. It is namespaced like the server's own tools.
+const ResourceToolName = "read_resource"
+
+// HasResources reports whether the server advertises the resources capability
+func (c *MCPClient) HasResources() bool {
+	init := c.current().InitializeResult()
+	return init != nil && init.Capabilities != nil && init.Capabilities.Resources != nil
+}
+
+// ListResources returns the concrete resources the server exposes
+func (c *MCPClient) ListResources(ctx context.Context) ([]*mcp.Resource, error) {
+	var resources []*mcp.Resource
+	for resource, err := range c.current().Resources(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("error listing resources: %w", err)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// ListResourceTemplates returns the URI templates the server can resolve
+func (c *MCPClient) ListResourceTemplates(ctx context.Context) ([]*mcp.ResourceTemplate, error) {
+	var templates []*mcp.ResourceTemplate
+	for template, err := range c.current().ResourceTemplates(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("error listing resource templates: %w", err)
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+// ReadResource fetches the contents of the resource at uri
+func (c *MCPClient) ReadResource(ctx context.Context, uri string) ([]*mcp.ResourceContents, error) {
+	result, err := c.current().ReadResource(ctx, &mcp.ReadResourceParams{URI: uri})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource %s: %w", uri, err)
+	}
+	return result.Contents, nil
+}
+
+// MCPResourceTool lets the model list and read an MCP server's resources
+type MCPResourceTool struct {
+	client *MCPClient
+	Source string // Server spec that provided this tool
+}
+
+// NewMCPResourceTool creates the read_resource tool for a connected server
+func NewMCPResourceTool(client *MCPClient) *MCPResourceTool {
+	return &MCPResourceTool{client: client, Source: client.serverSpec}
+}
+
+func (t *MCPResourceTool) GetName() string   { return ResourceToolName }
+func (t *MCPResourceTool) GetType() string   { return "mcp" }
+func (t *MCPResourceTool) GetSource() string { return t.Source }
+
+func (t *MCPResourceTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool(ResourceToolName, "Read a resource from this MCP server by URI. Call without a uri to list the server's resources and URI templates.",
+		schema.Params{
+			"uri": schema.S("URI of the resource to read; omit to list what is available"),
+		},
+	)
+}
+
+func (t *MCPResourceTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	uri := strings.TrimSpace(Args(raw).String("uri"))
+	if uri == "" {
+		return t.list(ctx)
+	}
+
+	contents, err := t.client.ReadResource(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	if len(contents) == 0 {
+		return "", NewToolError(fmt.Sprintf("resource %s has no contents", uri), CodeNotFound)
+	}
+
+	var b strings.Builder
+	for i, content := range contents {
+		if len(contents) > 1 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "=== %s ===\n", content.URI)
+		}
+		if content.Blob != nil {
+			fmt.Fprintf(&b, "[binary content, %s, %d bytes]\n", cmp.Or(content.MIMEType, "unknown type"), len(content.Blob))
+			continue
+		}
+		b.WriteString(content.Text)
+	}
+	return b.String(), nil
+}
+
+// list returns the server's resources and templates as JSON
+func (t *MCPResourceTool) list(ctx context.Context) (string, error) {
+	resources, err := t.client.ListResources(ctx)
+	if err != nil {
+		return "", err
+	}
+	templates, err := t.client.ListResourceTemplates(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	type entry struct {
+		URI         string `json:"uri,omitempty"`
+		URITemplate string `json:"uriTemplate,omitempty"`
+		Name        string `json:"name"`
+		Description string `json:"description,omitempty"`
+		MIMEType    string `json:"mimeType,omitempty"`
+	}
+	out := struct {
+		Resources []entry `json:"resources"`
+		Templates []entry `json:"templates,omitempty"`
+	}{Resources: []entry{}}
+	for _, r := range resources {
+		out.Resources = append(out.Resources, entry{URI: r.URI, Name: r.Name, Description: r.Description, MIMEType: r.MIMEType})
+	}
+	for _, rt := range templates {
+		out.Templates = append(out.Templates, entry{URITemplate: rt.URITemplate, Name: rt.Name, Description: rt.Description, MIMEType: rt.MIMEType})
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal resource list: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// connectResourceServer starts an in-memory MCP server with one resource and
+// one template and returns a client connected to it.
+func connectResourceServer(t *testing.T) *MCPClient {
+	t.Helper()
+	server := mcp.NewServer(&mcp.Implementation{Name: "docs", Version: "1.0.0"}, nil)
+	server.AddResource(&mcp.Resource{URI: "docs://readme", Name: "readme", MIMEType: "text/plain"},
+		func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: req.Params.URI, MIMEType: "text/plain", Text: "hello from the readme"},
+			}}, nil
+		})
+	server.AddResourceTemplate(&mcp.ResourceTemplate{URITemplate: "docs://pages/{name}", Name: "page"},
+		func(_ context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+			return &mcp.ReadResourceResult{Contents: []*mcp.ResourceContents{
+				{URI: req.Params.URI, MIMEType: "image/png", Blob: []byte{1, 2, 3}},
+			}}, nil
+		})
+
+	client := connectInMemory(t, server)
+	client.serverSpec = "docs.json#docs"
+	return client
+}
+
+func TestMCPClientResources(t *testing.T) {
+	client := connectResourceServer(t)
+	ctx := context.Background()
+
+	if !client.HasResources() {
+		t.Fatal("HasResources() = false")
+	}
+	resources, err := client.ListResources(ctx)
+	if err != nil || len(resources) != 1 || resources[0].URI != "docs://readme" {
+		t.Fatalf("ListResources() = %v, %v", resources, err)
+	}
+	templates, err := client.ListResourceTemplates(ctx)
+	if err != nil || len(templates) != 1 || templates[0].URITemplate != "docs://pages/{name}" {
+		t.Fatalf("ListResourceTemplates() = %v, %v", templates, err)
+	}
+	contents, err := client.ReadResource(ctx, "docs://readme")
+	if err != nil || len(contents) != 1 || contents[0].Text != "hello from the readme" {
+		t.Fatalf("ReadResource() = %v, %v", contents, err)
+	}
+	if _, err := client.ReadResource(ctx, "docs://missing"); err == nil {
+		t.Fatal("ReadResource() of an unknown URI should fail")
+	}
+}
+
+func TestMCPResourceTool(t *testing.T) {
+	client := connectResourceServer(t)
+	ctx := context.Background()
+
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serverTools) != 1 || serverTools[0].GetName() != ResourceToolName {
+		t.Fatalf("ListTools() = %v, want only %s", serverTools, ResourceToolName)
+	}
+	tool := serverTools[0]
+	if tool.GetType() != "mcp" || tool.GetSource() != "docs.json#docs" {
+		t.Fatalf("type = %q, source = %q", tool.GetType(), tool.GetSource())
+	}
+
+	out, err := tool.Execute(ctx, map[string]any{"uri": "docs://readme"})
+	if err != nil || out != "hello from the readme" {
+		t.Fatalf("read = %q, %v", out, err)
+	}
+
+	out, err = tool.Execute(ctx, map[string]any{"uri": "docs://pages/logo"})
+	if err != nil || !strings.Contains(out, "image/png, 3 bytes") {
+		t.Fatalf("read blob = %q, %v", out, err)
+	}
+
+	out, err = tool.Execute(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listing struct {
+		Resources []struct{ URI string } `json:"resources"`
+		Templates []struct {
+			URITemplate string `json:"uriTemplate"`
+		} `json:"templates"`
+	}
+	if err := json.Unmarshal([]byte(out), &listing); err != nil {
+		t.Fatalf("list output %q: %v", out, err)
+	}
+	if len(listing.Resources) != 1 || listing.Resources[0].URI != "docs://readme" ||
+		len(listing.Templates) != 1 || listing.Templates[0].URITemplate != "docs://pages/{name}" {
+		t.Fatalf("listing = %+v", listing)
+	}
+}
+
+func TestRegistryMCPResourceClient(t *testing.T) {
+	client := connectResourceServer(t)
+	client.namespace = "docs"
+	registry := NewToolRegistry(nil)
+
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []stagedToolRecord
+	for _, tool := range serverTools {
+		if record, ok := mcpToolRecord(client, tool); ok {
+			records = append(records, record)
+		}
+	}
+	registry.stagePreparedTools(records, []*MCPClient{client})
+	if _, ok := registry.MCPServerClient("docs"); ok {
+		t.Fatal("staged server should not be found before commit")
+	}
+
+	registry.CommitPendingChanges()
+	if got, ok := registry.MCPServerClient("docs"); !ok || got != client {
+		t.Fatalf("MCPServerClient(docs) = %v, %v", got, ok)
+	}
+	if _, ok := registry.Get("docs__" + ResourceToolName); !ok {
+		t.Fatalf("docs__%s should be registered", ResourceToolName)
+	}
+	if _, ok := registry.MCPServerClient("other"); ok {
+		t.Fatal("MCPServerClient(other) should not be found")
+	}
+}
@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"net/url"
+	"path/filepath"
+	"slices"
+
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// workspaceRoots returns the working directory followed by the given writable
+// paths as file:// roots, without duplicates
+func workspaceRoots(writablePaths []string) []*mcp.Root {
+	var roots []*mcp.Root
+	seen := make(map[string]bool)
+	for _, p := range append([]string{"."}, writablePaths...) {
+		dir := fileAccess{}.abs(p)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		u := url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}
+		roots = append(roots, &mcp.Root{URI: u.String(), Name: filepath.Base(dir)})
+	}
+	return roots
+}
+
+// mcpClientOptions returns the registry's client options for a server: its
+// roots, which are the working directory plus the sandbox's writable paths
+// when the server is sandboxed, a refresh on tool list changes and
+// reconnects, and connection state reporting
+func (r *ToolRegistry) mcpClientOptions(config *MCPConfig) []MCPClientOption {
+	var writable []string
+	if cfg, err := r.mcpSandboxConfig(config); err == nil && cfg != nil {
+		writable = cfg.WritablePaths
+	}
+	return append(slices.Clip(r.mcpOptions), withRoots(workspaceRoots(writable)), withToolListChanged(r.refreshMCPTools), withConnectionState(r.mcpConnectionChanged))
+}
+
+// mcpSandboxConfig returns the sandbox config for a server: the base config
+// merged with the server's overrides, or nil when it runs unsandboxed
+func (r *ToolRegistry) mcpSandboxConfig(config *MCPConfig) (*sandbox.Config, error) {
+	if r.sandboxFactory == nil || config.SandboxOptOut() {
+		return nil, nil
+	}
+	cfg := r.baseSandboxCfg
+	overlay, err := config.SandboxConfig()
+	if err != nil {
+		return nil, err
+	}
+	if overlay != nil {
+		cfg = cfg.Merge(*overlay)
+	}
+	return &cfg, nil
+}
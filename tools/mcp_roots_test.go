@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestWorkspaceRoots(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	roots := workspaceRoots([]string{dir, wd, dir})
+	if len(roots) != 2 {
+		t.Fatalf("workspaceRoots() = %v, want the working directory and %s", roots, dir)
+	}
+	if roots[0].URI != "file://"+filepath.ToSlash(wd) || roots[1].URI != "file://"+filepath.ToSlash(dir) {
+		t.Fatalf("URIs = %s, %s", roots[0].URI, roots[1].URI)
+	}
+	if roots[1].Name != filepath.Base(dir) {
+		t.Fatalf("Name = %q", roots[1].Name)
+	}
+}
+
+func TestMCPClientRoots(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "fs", Version: "1.0.0"}, nil)
+	var got []*mcp.Root
+	mcp.AddTool(server, &mcp.Tool{Name: "roots"}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		result, err := req.Session.ListRoots(ctx, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		got = result.Roots
+		return &mcp.CallToolResult{}, nil, nil
+	})
+
+	dir := t.TempDir()
+	client := connectInMemory(t, server, withRoots(workspaceRoots([]string{dir})))
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := serverTools[0].Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].URI != "file://"+filepath.ToSlash(dir) {
+		t.Fatalf("server saw roots %v", got)
+	}
+}
+
+func TestRegistryMCPRootsFollowSandbox(t *testing.T) {
+	roots := func(registry *ToolRegistry, config *MCPConfig) []*mcp.Root {
+		var o mcpClientOptions
+		for _, opt := range registry.mcpClientOptions(config) {
+			opt(&o)
+		}
+		return o.roots
+	}
+	base, extra := t.TempDir(), t.TempDir()
+	config := &MCPConfig{Command: "server", Sandbox: []byte(`{"writablePaths":["` + extra + `"]}`)}
+
+	if got := roots(NewToolRegistry(nil), config); len(got) != 1 {
+		t.Fatalf("unsandboxed roots = %v, want only the working directory", got)
+	}
+
+	registry := NewToolRegistry(nil, WithSandboxFactory(mockSandboxFactory(&mockSandbox{}), sandbox.Config{WritablePaths: []string{base}}))
+	got := roots(registry, config)
+	if len(got) != 3 || got[1].URI != "file://"+filepath.ToSlash(base) || got[2].URI != "file://"+filepath.ToSlash(extra) {
+		t.Fatalf("sandboxed roots = %v", got)
+	}
+
+	config.Sandbox = []byte("false")
+	if got := roots(registry, config); len(got) != 1 {
+		t.Fatalf("opted-out roots = %v, want only the working directory", got)
+	}
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MCPConnectionState is the state of a supervised MCP connection
+type MCPConnectionState string
+
+const (
+	MCPConnected    MCPConnectionState = "connected"
+	MCPReconnecting MCPConnectionState = "reconnecting"
+)
+
+// Supervision timings, shortened by tests
+var (
+	mcpKeepAlive         = 30 * time.Second       // Ping interval; a failed ping drops the session
+	mcpReconnectDelay    = 500 * time.Millisecond // First reconnect delay, doubled per failure
+	mcpMaxReconnectDelay = 30 * time.Second
+	mcpLostGrace         = 100 * time.Millisecond // How long a failed call waits to see the session end
+)
+
+// withConnectionState calls fn whenever the client loses or regains its
+// connection
+func withConnectionState(fn func(*MCPClient, MCPConnectionState)) MCPClientOption {
+	return func(o *mcpClientOptions) {
+		o.connectionState = fn
+	}
+}
+
+// State returns the client's connection state
+func (c *MCPClient) State() MCPConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// current returns the live session, or the lost one while reconnecting
+func (c *MCPClient) current() *mcp.ClientSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+func (c *MCPClient) setState(state MCPConnectionState) {
+	c.mu.Lock()
+	c.state = state
+	c.mu.Unlock()
+
+	slog.Debug("mcp_connection_state", "server_name", c.Name(), "state", state)
+	if c.opts.connectionState != nil {
+		c.opts.connectionState(c, state)
+	}
+}
+
+// supervise waits for session to end and, unless the client was closed,
+// reconnects with backoff, swaps in the new session and re-lists tools
+func (c *MCPClient) supervise(session *mcp.ClientSession, done chan struct{}) {
+	for {
+		err := session.Wait()
+		close(done)
+		if c.ctx.Err() != nil {
+			return
+		}
+		slog.Debug("mcp_connection_lost", "server_name", c.Name(), "error", err)
+		c.setState(MCPReconnecting)
+
+		if session = c.reconnect(); session == nil {
+			return
+		}
+		done = make(chan struct{})
+		c.mu.Lock()
+		if c.ctx.Err() != nil {
+			// Closed while the new session was starting
+			c.mu.Unlock()
+			session.Close()
+			return
+		}
+		c.session, c.done = session, done
+		c.mu.Unlock()
+
+		c.sessionStarted(c.ctx, session)
+		c.setState(MCPConnected)
+		if c.opts.toolsChanged != nil {
+			c.opts.toolsChanged(c)
+		}
+	}
+}
+
+// reconnect dials until a new session is up, doubling the delay between
+// attempts. It returns nil if the client is closed first.
+func (c *MCPClient) reconnect() *mcp.ClientSession {
+	delay := mcpReconnectDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		transport, err := c.dial()
+		if err == nil {
+			var session *mcp.ClientSession
+			if session, err = c.client.Connect(c.ctx, transport, nil); err == nil {
+				slog.Debug("mcp_reconnected", "server_name", c.Name(), "attempt", attempt)
+				return session
+			}
+		}
+		slog.Debug("mcp_reconnect_failed", "server_name", c.Name(), "attempt", attempt, "error", err)
+		delay = min(delay*2, mcpMaxReconnectDelay)
+	}
+}
+
+// lost reports whether a call on session failed because its connection
+// went away rather than because the server returned an error
+func (c *MCPClient) lost(session *mcp.ClientSession, err error) bool {
+	if errors.Is(err, mcp.ErrConnectionClosed) {
+		return true
+	}
+	var wireErr *jsonrpc.Error
+	if errors.As(err, &wireErr) {
+		return false
+	}
+
+	c.mu.Lock()
+	current, done := c.session, c.done
+	c.mu.Unlock()
+	if current != session {
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(mcpLostGrace):
+		return false
+	}
+}
+
+// unavailableError tells the model that a call failed because the server is
+// reconnecting, and that it can try again
+func (c *MCPClient) unavailableError() error {
+	return &ToolError{
+		Message:   fmt.Sprintf("MCP server %s disconnected and is reconnecting; retry the call shortly", c.Name()),
+		Code:      CodeUnavailable,
+		Retryable: true,
+	}
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// setReconnectDelay shortens or lengthens the reconnect backoff for a test
+func setReconnectDelay(t *testing.T, d time.Duration) {
+	prev, prevMax := mcpReconnectDelay, mcpMaxReconnectDelay
+	mcpReconnectDelay, mcpMaxReconnectDelay = d, d
+	t.Cleanup(func() { mcpReconnectDelay, mcpMaxReconnectDelay = prev, prevMax })
+}
+
+// connectCrashable serves server over pipes and returns a client plus a
+// function that cuts the current connection, as if the server process died
+func connectCrashable(t *testing.T, server *mcp.Server, opts ...MCPClientOption) (*MCPClient, func()) {
+	t.Helper()
+	var mu sync.Mutex
+	var pipes []io.Closer
+	dial := func() (mcp.Transport, error) {
+		clientR, serverW := io.Pipe()
+		serverR, clientW := io.Pipe()
+		if _, err := server.Connect(context.Background(), &mcp.IOTransport{Reader: serverR, Writer: serverW}, nil); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		pipes = append(pipes, clientR, clientW, serverR, serverW)
+		mu.Unlock()
+		return &mcp.IOTransport{Reader: clientR, Writer: clientW}, nil
+	}
+	crash := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, p := range pipes {
+			p.Close()
+		}
+		pipes = nil
+	}
+	t.Cleanup(crash)
+
+	client, err := connectMCPClient(context.Background(), dial, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client, crash
+}
+
+func waitForState(t *testing.T, states <-chan MCPConnectionState, want MCPConnectionState) {
+	t.Helper()
+	select {
+	case got := <-states:
+		if got != want {
+			t.Fatalf("connection state = %s, want %s", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", want)
+	}
+}
+
+func TestMCPClientReconnects(t *testing.T) {
+	setReconnectDelay(t, 10*time.Millisecond)
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "flaky", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "ping"}, func(context.Context, *mcp.CallToolRequest, struct{}) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "pong"}}}, nil, nil
+	})
+
+	states := make(chan MCPConnectionState, 4)
+	relisted := make(chan struct{}, 1)
+	client, crash := connectCrashable(t, server,
+		withConnectionState(func(_ *MCPClient, state MCPConnectionState) { states <- state }),
+		withToolListChanged(func(*MCPClient) { relisted <- struct{}{} }),
+	)
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crash()
+	waitForState(t, states, MCPReconnecting)
+	waitForState(t, states, MCPConnected)
+	select {
+	case <-relisted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("tools were not re-listed after reconnecting")
+	}
+
+	// Tools listed before the drop use the new session
+	out, err := serverTools[0].Execute(context.Background(), nil)
+	if err != nil || !strings.Contains(out, "pong") {
+		t.Fatalf("Execute() after reconnect = %q, %v", out, err)
+	}
+	if client.State() != MCPConnected {
+		t.Errorf("State() = %s, want connected", client.State())
+	}
+}
+
+func TestMCPToolCallLostIsRetryable(t *testing.T) {
+	// Stay disconnected for the rest of the test
+	setReconnectDelay(t, time.Hour)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	server := mcp.NewServer(&mcp.Implementation{Name: "crashy", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "hang"}, func(ctx context.Context, _ *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+		case <-release:
+		}
+		return nil, nil, ctx.Err()
+	})
+
+	states := make(chan MCPConnectionState, 4)
+	client, crash := connectCrashable(t, server, withConnectionState(func(_ *MCPClient, state MCPConnectionState) { states <- state }))
+	client.namespace = "crashy"
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := serverTools[0].Execute(context.Background(), nil)
+		errs <- err
+	}()
+	<-started
+	crash()
+
+	var callErr error
+	select {
+	case callErr = <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("in-flight call did not fail when the connection dropped")
+	}
+	checkRetryable := func(err error) {
+		t.Helper()
+		msg, ok := FormatToolError(err)
+		if !ok || !strings.Contains(msg, `"code":"UNAVAILABLE"`) || !strings.Contains(msg, `"retryable":true`) || !strings.Contains(msg, "crashy") {
+			t.Errorf("error = %v (%s), want a retryable UNAVAILABLE tool error", err, msg)
+		}
+	}
+	checkRetryable(callErr)
+
+	// Calls while reconnecting fail fast the same way
+	waitForState(t, states, MCPReconnecting)
+	_, err = serverTools[0].Execute(context.Background(), nil)
+	checkRetryable(err)
+}
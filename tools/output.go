@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alexschlessinger/pollytool/schema"
+)
+
+// ReadToolOutputName is the tool that pages through tool output cut by CapOutput
+const ReadToolOutputName = "read_tool_output"
+
+// readOutputDefaultLimit is the page size of read_tool_output when the
+// context carries no output limit
+const readOutputDefaultLimit = 16000
+
+// toolOutputDir holds the full text of truncated tool results. It is kept
+// under the user's home rather than the shared temp directory, since tool
+// output can be private. A variable so tests can move it.
+var toolOutputDir = func() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pollytool", "tool-output"), nil
+}
+
+// toolOutputMaxAge is how long saved output is kept. Files older than this
+// are removed the first time a process saves output.
+const toolOutputMaxAge = 7 * 24 * time.Hour
+
+var pruneToolOutputOnce sync.Once
+
+var outputIDPattern = regexp.MustCompile(`^out-[0-9a-f]{16}$`)
+
+type outputLimitKey struct{}
+
+// WithOutputLimit attaches the cap on tool result size to ctx, which
+// read_tool_output uses as its largest page
+func WithOutputLimit(ctx context.Context, limit int) context.Context {
+	return context.WithValue(ctx, outputLimitKey{}, limit)
+}
+
+func outputLimit(ctx context.Context) int {
+	if limit, ok := ctx.Value(outputLimitKey{}).(int); ok && limit > 0 {
+		return limit
+	}
+	return readOutputDefaultLimit
+}
+
+// CapOutput returns output unchanged if it fits in limit bytes. Otherwise it
+// saves the full text for read_tool_output and returns its head and tail
+// around a marker naming the saved copy.
+func CapOutput(output string, limit int) string {
+	if limit <= 0 || len(output) <= limit {
+		return output
+	}
+
+	id, err := saveToolOutput(output)
+	marker := func(omitted int) string {
+		if err != nil {
+			return fmt.Sprintf("\n\n[... %d of %d bytes omitted; the full output could not be saved: %v ...]\n\n", omitted, len(output), err)
+		}
+		return fmt.Sprintf("\n\n[... %d of %d bytes omitted. Call %s with id %q and an offset to read the full output ...]\n\n", omitted, len(output), ReadToolOutputName, id)
+	}
+
+	// Keep at least a little of each end even when the limit is tiny
+	keep := max(limit-len(marker(len(output))), 2)
+	head := runeStart(output, keep/2)
+	tail := runeStart(output, len(output)-(keep-keep/2))
+	return output[:head] + marker(tail-head) + output[tail:]
+}
+
+// runeStart moves i back to the start of the UTF-8 sequence it falls in
+func runeStart(s string, i int) int {
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+func saveToolOutput(output string) (string, error) {
+	dir, err := toolOutputDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	pruneToolOutputOnce.Do(func() {
+		pruneToolOutput(dir, time.Now().Add(-toolOutputMaxAge))
+	})
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	id := "out-" + hex.EncodeToString(b[:])
+	if err := os.WriteFile(filepath.Join(dir, id+".txt"), []byte(output), 0600); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// pruneToolOutput removes the saved output in dir last written before cutoff
+func pruneToolOutput(dir string, cutoff time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".txt")
+		if !ok || !outputIDPattern.MatchString(id) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				slog.Debug("tool_output_prune_failed", "file", entry.Name(), "error", err)
+			}
+		}
+	}
+}
+
+// ReadToolOutputTool pages through tool output saved by CapOutput.
+type ReadToolOutputTool struct {
+	NativeTool
+}
+
+// NewReadToolOutputTool creates the read_tool_output tool.
+func NewReadToolOutputTool() *ReadToolOutputTool {
+	return &ReadToolOutputTool{}
+}
+
+func (t *ReadToolOutputTool) GetName() string { return ReadToolOutputName }
+
+func (t *ReadToolOutputTool) GetSchema() *schema.ToolSchema {
+	return schema.Tool(ReadToolOutputName, "Read the full output of a tool call whose result was truncated. Use the id from the truncation marker and page through with offset.",
+		schema.Params{
+			"id":     schema.S("Output id from the truncation marker"),
+			"offset": schema.Int("Byte offset to start reading from (default 0)"),
+			"limit":  schema.Int("Maximum number of bytes to return (default and maximum: the tool output limit)"),
+		},
+		"id",
+	)
+}
+
+func (t *ReadToolOutputTool) Execute(ctx context.Context, raw map[string]any) (string, error) {
+	args := Args(raw)
+	id := args.String("id")
+	if !outputIDPattern.MatchString(id) {
+		return "", NewToolError(fmt.Sprintf("invalid output id: %q", id), CodeInvalidArgs)
+	}
+
+	dir, err := toolOutputDir()
+	if err != nil {
+		return "", NewToolError(err.Error(), CodeIOError)
+	}
+	f, err := os.Open(filepath.Join(dir, id+".txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", NewToolError(fmt.Sprintf("no saved output with id %s", id), CodeNotFound)
+		}
+		return "", NewToolError(err.Error(), CodeIOError)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", NewToolError(err.Error(), CodeIOError)
+	}
+	size := int(info.Size())
+
+	offset := args.Int("offset", 0)
+	if offset < 0 || offset >= size {
+		return "", NewToolError(fmt.Sprintf("offset %d is outside the output (%d bytes)", offset, size), CodeInvalidArgs)
+	}
+	maxLimit := outputLimit(ctx)
+	limit := args.Int("limit", maxLimit)
+	if limit <= 0 || limit > maxLimit {
+		limit = maxLimit
+	}
+
+	// Read a few extra bytes so the page can end on a character boundary
+	buf := make([]byte, min(limit+utf8.UTFMax, size-offset))
+	if _, err := f.ReadAt(buf, int64(offset)); err != nil && err != io.EOF {
+		return "", NewToolError(err.Error(), CodeIOError)
+	}
+	page := string(buf)
+	end := len(page)
+	if end > limit {
+		end = runeStart(page, limit)
+		if end == 0 {
+			end = limit
+		}
+	}
+	page = page[:end]
+
+	next := offset + end
+	if next >= size {
+		return fmt.Sprintf("%s\n\n[bytes %d-%d of %d; end of output]", page, offset, next, size), nil
+	}
+	return fmt.Sprintf("%s\n\n[bytes %d-%d of %d; continue with offset=%d]", page, offset, next, size, next), nil
+}
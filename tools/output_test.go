@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func useTestOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	prev := toolOutputDir
+	toolOutputDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { toolOutputDir = prev })
+}
+
+func TestCapOutput(t *testing.T) {
+	useTestOutputDir(t)
+
+	if got := CapOutput("short", 100); got != "short" {
+		t.Errorf("CapOutput under the limit = %q", got)
+	}
+	if got := CapOutput(strings.Repeat("x", 500), 0); len(got) != 500 {
+		t.Errorf("CapOutput with no limit returned %d bytes", len(got))
+	}
+
+	// Multi-byte characters are never split
+	full := strings.Repeat("é", 2000)
+	got := CapOutput(full, 400)
+	if !utf8.ValidString(got) || len(got) > 400 || !strings.Contains(got, "of 4000 bytes omitted") {
+		t.Errorf("CapOutput = %q (%d bytes)", got, len(got))
+	}
+}
+
+func TestReadToolOutputPages(t *testing.T) {
+	useTestOutputDir(t)
+	full := strings.Repeat("0123456789", 30)
+	capped := CapOutput(full, 200)
+	id := regexp.MustCompile(`out-[0-9a-f]{16}`).FindString(capped)
+	if id == "" {
+		t.Fatalf("no output id in %q", capped)
+	}
+
+	tool := NewReadToolOutputTool()
+	ctx := WithOutputLimit(context.Background(), 200)
+	var read strings.Builder
+	for offset := 0; ; {
+		out, err := tool.Execute(ctx, map[string]any{"id": id, "offset": offset, "limit": 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, trailer, _ := strings.Cut(out, "\n\n[")
+		if len(page) > 200 {
+			t.Fatalf("page of %d bytes exceeds the output limit", len(page))
+		}
+		read.WriteString(page)
+		if strings.Contains(trailer, "end of output") {
+			break
+		}
+		offset += len(page)
+	}
+	if read.String() != full {
+		t.Errorf("paged output = %q, want %q", read.String(), full)
+	}
+
+	for _, args := range []map[string]any{
+		{"id": "../../etc/passwd"},
+		{"id": id, "offset": 300},
+	} {
+		if _, err := tool.Execute(ctx, args); err == nil {
+			t.Errorf("Execute(%v) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestPruneToolOutput(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * toolOutputMaxAge)
+	for name, modTime := range map[string]time.Time{
+		"out-0000000000000001.txt": old,
+		"out-0000000000000002.txt": time.Now(),
+		"notes.txt":                old, // Not saved output
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruneToolOutput(dir, time.Now().Add(-toolOutputMaxAge))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	if want := []string{"notes.txt", "out-0000000000000002.txt"}; !slices.Equal(left, want) {
+		t.Errorf("left %v, want %v", left, want)
+	}
+}
@@ -0,0 +1,258 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// PermissionAction is what a permission rule decides for the calls it matches
+type PermissionAction string
+
+const (
+	PermissionAllow PermissionAction = "allow" // Run without asking
+	PermissionDeny  PermissionAction = "deny"  // Refuse without asking
+	PermissionAsk   PermissionAction = "ask"   // Ask the user first
+)
+
+// PermissionRule matches tool calls by tool name and argument values
+type PermissionRule struct {
+	Tool   string            `json:"tool"`           // Glob on the tool name, e.g. "bash" or "github__*"
+	Args   map[string]string `json:"args,omitempty"` // Unanchored regexes on argument values, all of which must match
+	Action PermissionAction  `json:"action"`
+}
+
+// PermissionDecision is the outcome of checking a tool call against a
+// PermissionPolicy
+type PermissionDecision struct {
+	Action PermissionAction
+	Rule   *PermissionRule // The deciding rule; nil when the default applied
+	Source string          // File the rule came from
+}
+
+// permissionFile is the JSON layout of a policy file
+type permissionFile struct {
+	Rules []PermissionRule `json:"rules"`
+}
+
+type compiledRule struct {
+	rule   PermissionRule
+	args   map[string]*regexp.Regexp
+	source string
+}
+
+// PermissionPolicy decides whether tool calls run, ask first or are refused,
+// from rules loaded from policy files. A deny rule beats an ask rule, which
+// beats an allow rule, wherever they were loaded from. Calls no rule matches
+// get the default action.
+type PermissionPolicy struct {
+	mu       sync.RWMutex
+	rules    []compiledRule
+	fallback PermissionAction
+}
+
+// NewPermissionPolicy creates a policy with no rules, whose default action
+// is fallback.
+func NewPermissionPolicy(fallback PermissionAction) *PermissionPolicy {
+	return &PermissionPolicy{fallback: fallback}
+}
+
+// LoadFile adds the rules of a policy file of the form
+// {"rules": [{"tool": "bash", "args": {"command": "^rm "}, "action": "deny"}]}.
+// A missing file is not an error.
+func (p *PermissionPolicy) LoadFile(path string) error {
+	return p.loadFile(path, false)
+}
+
+// LoadUntrustedFile adds the deny and ask rules of a policy file someone
+// else may have written, such as one checked into a project. Its allow
+// rules are ignored, so it can only tighten the policy.
+func (p *PermissionPolicy) LoadUntrustedFile(path string) error {
+	return p.loadFile(path, true)
+}
+
+func (p *PermissionPolicy) loadFile(path string, untrusted bool) error {
+	file, err := readPermissionFile(path)
+	if err != nil {
+		return err
+	}
+	compiled := make([]compiledRule, 0, len(file.Rules))
+	for i, rule := range file.Rules {
+		c, err := compileRule(rule, path)
+		if err != nil {
+			return fmt.Errorf("permission file %s: rule %d: %w", path, i+1, err)
+		}
+		if untrusted && rule.Action == PermissionAllow {
+			slog.Warn("permission_rule_ignored", "file", path, "rule", rule.String(), "reason", "allow rules are only read from your own permission file")
+			continue
+		}
+		compiled = append(compiled, c)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = append(p.rules, compiled...)
+	return nil
+}
+
+func readPermissionFile(path string) (*permissionFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &permissionFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read permission file: %w", err)
+	}
+	var file permissionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse permission file %s: %w", path, err)
+	}
+	return &file, nil
+}
+
+func compileRule(rule PermissionRule, source string) (compiledRule, error) {
+	switch rule.Action {
+	case PermissionAllow, PermissionDeny, PermissionAsk:
+	default:
+		return compiledRule{}, fmt.Errorf("invalid action %q (expected allow, deny or ask)", rule.Action)
+	}
+	if rule.Tool == "" {
+		return compiledRule{}, errors.New("missing tool")
+	}
+	if _, err := filepath.Match(rule.Tool, ""); err != nil {
+		return compiledRule{}, fmt.Errorf("invalid tool pattern %q: %w", rule.Tool, err)
+	}
+	c := compiledRule{rule: rule, args: make(map[string]*regexp.Regexp, len(rule.Args)), source: source}
+	for name, pattern := range rule.Args {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("invalid pattern for argument %s: %w", name, err)
+		}
+		c.args[name] = re
+	}
+	return c, nil
+}
+
+// Empty reports whether the policy has no rules.
+func (p *PermissionPolicy) Empty() bool {
+	if p == nil {
+		return true
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.rules) == 0
+}
+
+// permissionRank orders actions by precedence when several rules match
+var permissionRank = map[PermissionAction]int{PermissionAllow: 1, PermissionAsk: 2, PermissionDeny: 3}
+
+// Check decides a call of tool name with args. A nil policy allows it.
+func (p *PermissionPolicy) Check(name string, args map[string]any) PermissionDecision {
+	if p == nil {
+		return PermissionDecision{Action: PermissionAllow}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	decision := PermissionDecision{Action: p.fallback}
+	for i := range p.rules {
+		r := &p.rules[i]
+		if !r.matches(name, args) {
+			continue
+		}
+		if decision.Rule == nil || permissionRank[r.rule.Action] > permissionRank[decision.Action] {
+			decision = PermissionDecision{Action: r.rule.Action, Rule: &r.rule, Source: r.source}
+		}
+	}
+	return decision
+}
+
+func (r *compiledRule) matches(name string, args map[string]any) bool {
+	if !matchesToolPattern(r.rule.Tool, name) {
+		return false
+	}
+	for arg, re := range r.args {
+		value, ok := args[arg]
+		if !ok {
+			return false
+		}
+		text, isString := value.(string)
+		if !isString {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return false
+			}
+			text = string(encoded)
+		}
+		if !re.MatchString(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// permissionScopeArgs are the arguments that name what a call acts on: the
+// command bash runs, or the file or directory a file tool touches
+var permissionScopeArgs = []string{"command", "file_path", "path"}
+
+// ScopedAllowRule returns an allow rule for calls of tool name with the
+// same values of args' scope arguments, e.g. the exact command or file
+// path. ok is false when args have none, as a rule for such a call would
+// allow every call of the tool.
+func ScopedAllowRule(name string, args map[string]any) (rule PermissionRule, ok bool) {
+	rule = PermissionRule{Tool: name, Action: PermissionAllow, Args: map[string]string{}}
+	for _, arg := range permissionScopeArgs {
+		if value, isString := args[arg].(string); isString && value != "" {
+			rule.Args[arg] = "^" + regexp.QuoteMeta(value) + "$"
+		}
+	}
+	return rule, len(rule.Args) > 0
+}
+
+// AlwaysAllow adds the ScopedAllowRule for a call to the policy and saves
+// it to the policy file at path. Calls without scope arguments are refused.
+func (p *PermissionPolicy) AlwaysAllow(path, name string, args map[string]any) (PermissionRule, error) {
+	rule, ok := ScopedAllowRule(name, args)
+	if !ok {
+		return rule, fmt.Errorf("%s calls cannot be always allowed: no command or path to limit the rule to", name)
+	}
+	c, err := compileRule(rule, path)
+	if err != nil {
+		return rule, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := readPermissionFile(path)
+	if err != nil {
+		return rule, err
+	}
+	file.Rules = append(file.Rules, rule)
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return rule, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return rule, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return rule, fmt.Errorf("save permission file: %w", err)
+	}
+	p.rules = append(p.rules, c)
+	return rule, nil
+}
+
+// String describes the rule for logs and prompts.
+func (r PermissionRule) String() string {
+	if len(r.Args) == 0 {
+		return fmt.Sprintf("%s %s", r.Action, r.Tool)
+	}
+	args, _ := json.Marshal(r.Args)
+	return fmt.Sprintf("%s %s %s", r.Action, r.Tool, args)
+}
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePermissionFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPermissionPolicyCheck(t *testing.T) {
+	project := writePermissionFile(t, `{"rules": [
+		{"tool": "bash", "action": "allow"},
+		{"tool": "bash", "args": {"command": "^rm "}, "action": "deny"},
+		{"tool": "github__*", "action": "ask"}
+	]}`)
+	user := writePermissionFile(t, `{"rules": [
+		{"tool": "bash", "args": {"command": "git push"}, "action": "ask"},
+		{"tool": "read_file", "args": {"limit": "^[0-9]+$"}, "action": "allow"}
+	]}`)
+
+	policy := NewPermissionPolicy(PermissionAsk)
+	for _, path := range []string{project, user, filepath.Join(t.TempDir(), "missing.json")} {
+		if err := policy.LoadFile(path); err != nil {
+			t.Fatalf("LoadFile(%s) error = %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		tool   string
+		args   map[string]any
+		want   PermissionAction
+		source string
+	}{
+		{"allowed tool", "bash", map[string]any{"command": "ls"}, PermissionAllow, project},
+		{"deny beats allow", "bash", map[string]any{"command": "rm -rf /"}, PermissionDeny, project},
+		{"ask from another file beats allow", "bash", map[string]any{"command": "git push origin"}, PermissionAsk, user},
+		{"glob", "github__create_issue", nil, PermissionAsk, project},
+		{"non-string argument", "read_file", map[string]any{"limit": 10}, PermissionAllow, user},
+		{"missing argument", "read_file", nil, PermissionAsk, ""},
+		{"no rule", "write_file", nil, PermissionAsk, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Check(tt.tool, tt.args)
+			if got.Action != tt.want || got.Source != tt.source {
+				t.Errorf("Check(%s) = %s from %q, want %s from %q", tt.tool, got.Action, got.Source, tt.want, tt.source)
+			}
+			if (got.Rule == nil) != (tt.source == "") {
+				t.Errorf("Check(%s) rule = %v", tt.tool, got.Rule)
+			}
+		})
+	}
+
+	var nilPolicy *PermissionPolicy
+	if got := nilPolicy.Check("bash", nil); got.Action != PermissionAllow {
+		t.Errorf("nil policy Check() = %s, want allow", got.Action)
+	}
+}
+
+func TestPermissionPolicyUntrustedFileCannotAllow(t *testing.T) {
+	path := writePermissionFile(t, `{"rules": [
+		{"tool": "*", "action": "allow"},
+		{"tool": "bash", "args": {"command": "^rm "}, "action": "deny"},
+		{"tool": "write_file", "action": "ask"}
+	]}`)
+	policy := NewPermissionPolicy(PermissionAsk)
+	if err := policy.LoadUntrustedFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		tool string
+		args map[string]any
+		want PermissionAction
+	}{
+		{"bash", map[string]any{"command": "ls"}, PermissionAsk},
+		{"bash", map[string]any{"command": "rm -rf /"}, PermissionDeny},
+		{"write_file", nil, PermissionAsk},
+	} {
+		if got := policy.Check(tt.tool, tt.args); got.Action != tt.want {
+			t.Errorf("Check(%s %v) = %s, want %s", tt.tool, tt.args, got.Action, tt.want)
+		}
+	}
+}
+
+func TestPermissionPolicyLoadFileRejectsBadRules(t *testing.T) {
+	for _, content := range []string{
+		`{"rules": [{"tool": "bash", "action": "maybe"}]}`,
+		`{"rules": [{"action": "allow"}]}`,
+		`{"rules": [{"tool": "bash", "args": {"command": "("}, "action": "deny"}]}`,
+		`{"rules": [`,
+	} {
+		if err := NewPermissionPolicy(PermissionAllow).LoadFile(writePermissionFile(t, content)); err == nil {
+			t.Errorf("LoadFile(%s) error = nil", content)
+		}
+	}
+}
+
+func TestPermissionPolicyAlwaysAllow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "permissions.json")
+	policy := NewPermissionPolicy(PermissionAsk)
+
+	rule, err := policy.AlwaysAllow(path, "bash", map[string]any{"command": "go test ./..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Args["command"] != `^go test \./\.\.\.$` {
+		t.Errorf("rule = %s, want the exact command", rule)
+	}
+	if _, err := policy.AlwaysAllow(path, "write_file", map[string]any{"file_path": "a.txt", "content": "x"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rules apply at once and are saved for the next run
+	reloaded := NewPermissionPolicy(PermissionAsk)
+	if err := reloaded.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []*PermissionPolicy{policy, reloaded} {
+		if got := p.Check("bash", map[string]any{"command": "go test ./..."}).Action; got != PermissionAllow {
+			t.Errorf("saved command = %s, want allow", got)
+		}
+		if got := p.Check("bash", map[string]any{"command": "go test ./... && rm -rf /"}).Action; got != PermissionAsk {
+			t.Errorf("other command = %s, want ask", got)
+		}
+		if got := p.Check("write_file", map[string]any{"file_path": "a.txt", "content": "y"}).Action; got != PermissionAllow {
+			t.Errorf("saved write_file = %s, want allow", got)
+		}
+		if got := p.Check("write_file", map[string]any{"file_path": "b.txt", "content": "x"}).Action; got != PermissionAsk {
+			t.Errorf("write_file of another file = %s, want ask", got)
+		}
+	}
+}
+
+func TestPermissionPolicyAlwaysAllowScopesRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "permissions.json")
+	policy := NewPermissionPolicy(PermissionAsk)
+
+	tests := []struct {
+		tool  string
+		args  map[string]any
+		other map[string]any // A call the saved rule must not allow
+	}{
+		{"read_file", map[string]any{"file_path": "notes.txt"}, map[string]any{"file_path": "/etc/shadow"}},
+		{"edit_file", map[string]any{"file_path": "main.go", "old_string": "a", "new_string": "b"}, map[string]any{"file_path": "main.go.bak", "old_string": "a", "new_string": "b"}},
+		{"grep", map[string]any{"pattern": "TODO", "path": "src"}, map[string]any{"pattern": "TODO", "path": "/"}},
+		{"docs__search", map[string]any{"path": "guide", "query": "x"}, map[string]any{"path": "other", "query": "x"}},
+	}
+	for _, tt := range tests {
+		if _, err := policy.AlwaysAllow(path, tt.tool, tt.args); err != nil {
+			t.Fatalf("AlwaysAllow(%s) error = %v", tt.tool, err)
+		}
+		if got := policy.Check(tt.tool, tt.args).Action; got != PermissionAllow {
+			t.Errorf("%s saved call = %s, want allow", tt.tool, got)
+		}
+		if got := policy.Check(tt.tool, tt.other).Action; got != PermissionAsk {
+			t.Errorf("%s other call = %s, want ask", tt.tool, got)
+		}
+	}
+
+	// Nothing limits a rule for these calls, so none is saved
+	for _, tt := range []struct {
+		tool string
+		args map[string]any
+	}{
+		{"github__create_issue", map[string]any{"title": "bug"}},
+		{"glob", map[string]any{"pattern": "*.go"}},
+		{"task", nil},
+	} {
+		if _, err := policy.AlwaysAllow(path, tt.tool, tt.args); err == nil {
+			t.Errorf("AlwaysAllow(%s) error = nil, want refusal", tt.tool)
+		}
+		if got := policy.Check(tt.tool, tt.args).Action; got != PermissionAsk {
+			t.Errorf("%s = %s, want ask", tt.tool, got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ToolProgress is a progress or log update from a running tool
+type ToolProgress struct {
+	Progress float64 // Work done so far, in units the tool chooses
+	Total    float64 // Total work, or 0 when unknown
+	Message  string
+	Level    string // Log level for log messages; empty for progress updates
+}
+
+// String renders the update for a status line, e.g. "3/10 indexing" or
+// "[warning] cache miss"
+func (p ToolProgress) String() string {
+	var parts []string
+	switch {
+	case p.Level != "":
+		parts = append(parts, "["+p.Level+"]")
+	case p.Total > 0:
+		parts = append(parts, fmt.Sprintf("%g/%g", p.Progress, p.Total))
+	case p.Progress > 0:
+		parts = append(parts, fmt.Sprintf("%g", p.Progress))
+	}
+	if p.Message != "" {
+		parts = append(parts, p.Message)
+	}
+	return strings.Join(parts, " ")
+}
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a context through which tools that support it
+// send progress and log updates to report while they run. report may be
+// called concurrently.
+func WithProgressReporter(ctx context.Context, report func(ToolProgress)) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, report)
+}
+
+// progressReporter returns the reporter attached to ctx, or nil
+func progressReporter(ctx context.Context) func(ToolProgress) {
+	report, _ := ctx.Value(progressReporterKey{}).(func(ToolProgress))
+	return report
+}
+
+// ReportProgress sends an update to the reporter attached to ctx, if any
+func ReportProgress(ctx context.Context, progress ToolProgress) {
+	if report := progressReporter(ctx); report != nil {
+		report(progress)
+	}
+}
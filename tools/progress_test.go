@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestToolProgressString(t *testing.T) {
+	tests := []struct {
+		progress ToolProgress
+		want     string
+	}{
+		{ToolProgress{Progress: 3, Total: 10, Message: "indexing"}, "3/10 indexing"},
+		{ToolProgress{Progress: 42}, "42"},
+		{ToolProgress{Level: "warning", Message: "cache miss"}, "[warning] cache miss"},
+		{ToolProgress{Message: "starting"}, "starting"},
+	}
+	for _, tt := range tests {
+		if got := tt.progress.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.progress, got, tt.want)
+		}
+	}
+}
+
+func TestMCPToolProgressAndLogs(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "indexer", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "index"}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		if err := req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+			ProgressToken: req.Params.GetProgressToken(),
+			Progress:      1,
+			Total:         2,
+			Message:       "half way",
+		}); err != nil {
+			return nil, nil, err
+		}
+		if err := req.Session.Log(ctx, &mcp.LoggingMessageParams{Level: "warning", Data: "slow disk"}); err != nil {
+			return nil, nil, err
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: "done"}}}, nil, nil
+	})
+
+	client := connectInMemory(t, server)
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var got []ToolProgress
+	ctx := WithProgressReporter(context.Background(), func(p ToolProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, p)
+	})
+	if _, err := serverTools[0].Execute(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Updates the server sent before the result are all reported by the
+	// time Execute returns
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"1/2 half way", "[warning] slow disk"}
+	if len(got) != len(want) {
+		t.Fatalf("updates = %+v, want %q", got, want)
+	}
+	for i, p := range got {
+		if p.String() != want[i] {
+			t.Errorf("update %d = %q, want %q", i, p, want[i])
+		}
+	}
+}
+
+func TestMCPToolCancellationReachesServer(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	server := mcp.NewServer(&mcp.Implementation{Name: "slow", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "wait"}, func(ctx context.Context, _ *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, nil, ctx.Err()
+	})
+
+	client := connectInMemory(t, server)
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := serverTools[0].Execute(ctx, nil)
+		errc <- err
+	}()
+	<-started
+	cancel()
+
+	if err := <-errc; err == nil {
+		t.Fatal("Execute() should fail once cancelled")
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server never saw the cancellation")
+	}
+}
@@ -1,10 +1,13 @@
 package tools
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 
@@ -54,13 +57,19 @@ type ToolRegistry struct {
 	nativeTools map[string]func() Tool // toolName -> factory
 
 	// MCP tracking
-	toolClients map[string]*MCPClient // toolName -> client
-	serverTools map[string][]string   // serverSpec -> toolNames
+	toolClients   map[string]*MCPClient // toolName -> client
+	serverTools   map[string][]string   // serverSpec -> toolNames
+	serverClients map[string]*MCPClient // namespace -> client, including prompt-only servers
 
 	// Runtime activation state
 	pendingTools       map[string]Tool
 	pendingToolClients map[string]*MCPClient
 	pendingServerTools map[string][]string
+	pendingClients     map[string]*MCPClient // namespace -> client of a staged server
+	pendingRemovals    map[string]bool       // Tools an MCP server no longer offers
+	pendingRefresh     bool                  // A server's tool list changed since the last commit
+	onToolListChanged  func()
+	onMCPConnection    func(server string, state MCPConnectionState)
 
 	alwaysAllowedTools map[string]bool
 	policyActive       bool
@@ -74,11 +83,19 @@ type ToolRegistry struct {
 	// Sandbox factory and base config
 	sandboxFactory func(sandbox.Config) (sandbox.Sandbox, error)
 	baseSandboxCfg sandbox.Config
+
+	// Client-side handlers for MCP connections
+	mcpOptions []MCPClientOption
+
+	// Pool daemon socket for stdio MCP servers; empty starts them directly
+	poolSocket string
 }
 
 type registryOptions struct {
 	sandboxFactory func(sandbox.Config) (sandbox.Sandbox, error)
 	baseSandboxCfg sandbox.Config
+	mcpOptions     []MCPClientOption
+	poolSocket     string
 }
 
 // RegistryOption configures a ToolRegistry.
@@ -92,6 +109,21 @@ func WithSandboxFactory(factory func(sandbox.Config) (sandbox.Sandbox, error), b
 	}
 }
 
+// WithMCPClientOptions applies opts to every MCP client the registry connects.
+func WithMCPClientOptions(opts ...MCPClientOption) RegistryOption {
+	return func(o *registryOptions) {
+		o.mcpOptions = append(o.mcpOptions, opts...)
+	}
+}
+
+// WithMCPPool connects stdio MCP servers through the pool daemon listening
+// on socket, starting them directly whenever it isn't running.
+func WithMCPPool(socket string) RegistryOption {
+	return func(o *registryOptions) {
+		o.poolSocket = socket
+	}
+}
+
 // HasSandbox reports whether sandboxing is available.
 func (r *ToolRegistry) HasSandbox() bool {
 	return r.sandboxFactory != nil
@@ -109,6 +141,39 @@ func (r *ToolRegistry) NewSandbox(overlay *sandbox.Config) (sandbox.Sandbox, err
 	return r.sandboxFactory(cfg)
 }
 
+// SandboxConfigFor returns the sandbox config tool runs under, or nil when
+// it runs unsandboxed. File tools run in-process but enforce the config
+// themselves.
+func (r *ToolRegistry) SandboxConfigFor(tool Tool) *sandbox.Config {
+	if n, ok := tool.(*NamespacedTool); ok {
+		tool = n.Tool
+	}
+	switch t := tool.(type) {
+	case *BashTool:
+		if t.sandbox != nil {
+			cfg := r.baseSandboxCfg
+			return &cfg
+		}
+	case *ShellTool:
+		if t.sandbox != nil {
+			cfg := r.baseSandboxCfg
+			if t.sandboxCfg != nil {
+				cfg = cfg.Merge(*t.sandboxCfg)
+			}
+			return &cfg
+		}
+	case *MCPTool:
+		if t.client != nil {
+			return t.client.sandboxCfg
+		}
+	case *MCPResourceTool:
+		return t.client.sandboxCfg
+	case interface{ sandboxPolicy() *sandbox.Config }:
+		return t.sandboxPolicy()
+	}
+	return nil
+}
+
 // NewSandboxDirect creates a sandbox from an explicit config, ignoring the base config.
 func (r *ToolRegistry) NewSandboxDirect(cfg sandbox.Config) (sandbox.Sandbox, error) {
 	if r.sandboxFactory == nil {
@@ -124,12 +189,12 @@ type stagedToolRecord struct {
 	serverSpec string
 }
 
-func closeStagedToolRecords(records []stagedToolRecord) {
+func closeMCPClients(clients []*MCPClient) {
 	closed := make(map[*MCPClient]bool)
-	for _, record := range records {
-		if record.client != nil && !closed[record.client] {
-			record.client.Close()
-			closed[record.client] = true
+	for _, client := range clients {
+		if !closed[client] {
+			client.Close()
+			closed[client] = true
 		}
 	}
 }
@@ -146,14 +211,19 @@ func NewToolRegistry(tools []Tool, opts ...RegistryOption) *ToolRegistry {
 		nativeTools:        make(map[string]func() Tool),
 		toolClients:        make(map[string]*MCPClient),
 		serverTools:        make(map[string][]string),
+		serverClients:      make(map[string]*MCPClient),
 		pendingTools:       make(map[string]Tool),
 		pendingToolClients: make(map[string]*MCPClient),
 		pendingServerTools: make(map[string][]string),
+		pendingClients:     make(map[string]*MCPClient),
+		pendingRemovals:    make(map[string]bool),
 		alwaysAllowedTools: make(map[string]bool),
 		autoAllowedTools:   make(map[string]bool),
 		pendingAutoAllowed: make(map[string]bool),
 		sandboxFactory:     o.sandboxFactory,
 		baseSandboxCfg:     o.baseSandboxCfg,
+		mcpOptions:         o.mcpOptions,
+		poolSocket:         o.poolSocket,
 	}
 
 	registry.nativeTools["bash"] = func() Tool {
@@ -166,6 +236,16 @@ func NewToolRegistry(tools []Tool, opts ...RegistryOption) *ToolRegistry {
 		return bt
 	}
 
+	// File tools run in-process, so they enforce the sandbox policy themselves
+	var filePolicy *sandbox.Config
+	if registry.sandboxFactory != nil {
+		cfg := registry.baseSandboxCfg
+		filePolicy = &cfg
+	}
+	for _, tool := range FileTools("", filePolicy) {
+		registry.nativeTools[tool.GetName()] = func() Tool { return tool }
+	}
+
 	for _, tool := range tools {
 		registry.Register(tool)
 	}
@@ -284,6 +364,7 @@ func (r *ToolRegistry) Remove(namespacedName string) {
 		if !stillInUse {
 			slog.Debug("mcp_client_closed", "reason", "no_remaining_tools")
 			client.Close()
+			r.forgetServerClient(client)
 		}
 	}
 }
@@ -363,12 +444,23 @@ func appendUniqueStrings(dst []string, src []string) []string {
 }
 
 func (r *ToolRegistry) stageTool(name string, tool Tool, client *MCPClient) {
+	delete(r.pendingRemovals, name)
 	r.pendingTools[name] = tool
 	if client != nil {
 		r.pendingToolClients[name] = client
 	}
 }
 
+// stageRemoval queues a tool to be dropped, undoing any staged addition
+func (r *ToolRegistry) stageRemoval(name string) {
+	delete(r.pendingTools, name)
+	delete(r.pendingToolClients, name)
+	for serverSpec, toolNames := range r.pendingServerTools {
+		r.pendingServerTools[serverSpec] = slices.DeleteFunc(toolNames, func(n string) bool { return n == name })
+	}
+	r.pendingRemovals[name] = true
+}
+
 func (r *ToolRegistry) stageServerTools(serverSpec string, toolNames []string) {
 	if len(toolNames) == 0 {
 		r.pendingServerTools[serverSpec] = nil
@@ -393,12 +485,15 @@ func (r *ToolRegistry) stageSkillAllowance(patterns, autoAllowed []string) {
 	}
 }
 
-// CommitPendingChanges applies staged skill activations between agent turns.
+// CommitPendingChanges applies staged skill activations and MCP tool list
+// refreshes between agent turns.
 func (r *ToolRegistry) CommitPendingChanges() {
 	r.mu.RLock()
 	empty := len(r.pendingTools) == 0 &&
 		len(r.pendingToolClients) == 0 &&
 		len(r.pendingServerTools) == 0 &&
+		len(r.pendingClients) == 0 &&
+		len(r.pendingRemovals) == 0 &&
 		!r.pendingPolicyActive &&
 		len(r.pendingAllowedPatterns) == 0 &&
 		len(r.pendingAutoAllowed) == 0
@@ -408,8 +503,15 @@ func (r *ToolRegistry) CommitPendingChanges() {
 	}
 
 	r.mu.Lock()
-	defer r.mu.Unlock()
 
+	for name := range r.pendingRemovals {
+		delete(r.tools, name)
+		delete(r.toolClients, name)
+		for serverSpec, toolNames := range r.serverTools {
+			r.serverTools[serverSpec] = slices.DeleteFunc(toolNames, func(n string) bool { return n == name })
+		}
+		slog.Debug("mcp_tool_removed", "tool_name", name)
+	}
 	for name, tool := range r.pendingTools {
 		r.tools[name] = tool
 	}
@@ -419,6 +521,9 @@ func (r *ToolRegistry) CommitPendingChanges() {
 	for serverSpec, toolNames := range r.pendingServerTools {
 		r.serverTools[serverSpec] = appendUniqueStrings(r.serverTools[serverSpec], toolNames)
 	}
+	for namespace, client := range r.pendingClients {
+		r.serverClients[namespace] = client
+	}
 	if r.pendingPolicyActive {
 		r.policyActive = true
 	}
@@ -430,9 +535,20 @@ func (r *ToolRegistry) CommitPendingChanges() {
 	r.pendingTools = make(map[string]Tool)
 	r.pendingToolClients = make(map[string]*MCPClient)
 	r.pendingServerTools = make(map[string][]string)
+	r.pendingClients = make(map[string]*MCPClient)
+	r.pendingRemovals = make(map[string]bool)
 	r.pendingPolicyActive = false
 	r.pendingAllowedPatterns = nil
 	r.pendingAutoAllowed = make(map[string]bool)
+
+	notify := r.pendingRefresh && r.onToolListChanged != nil
+	onToolListChanged := r.onToolListChanged
+	r.pendingRefresh = false
+	r.mu.Unlock()
+
+	if notify {
+		onToolListChanged()
+	}
 }
 
 // LoadMCPServer connects to an MCP server and registers its tools with namespace
@@ -467,6 +583,7 @@ func (r *ToolRegistry) UnloadMCPServer(serverSpec string) error {
 	// Close client
 	if client != nil {
 		client.Close()
+		r.forgetServerClient(client)
 		slog.Debug("mcp_server_closed", "server_name", GetMCPDisplayName(serverSpec))
 	}
 
@@ -549,10 +666,14 @@ func joinNamespacePrefix(prefix, name string) string {
 	}
 }
 
-func (r *ToolRegistry) stagePreparedTools(records []stagedToolRecord) {
+func (r *ToolRegistry) stagePreparedTools(records []stagedToolRecord, clients []*MCPClient) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	// Prompt-only servers have no records, so their clients are staged too
+	for _, client := range clients {
+		r.pendingClients[client.namespace] = client
+	}
 	for _, record := range records {
 		r.stageTool(record.name, record.tool, record.client)
 		if record.serverSpec != "" {
@@ -562,83 +683,108 @@ func (r *ToolRegistry) stagePreparedTools(records []stagedToolRecord) {
 	}
 }
 
-func (r *ToolRegistry) prepareSingleMCPServerWithNamespace(jsonFile, serverName, namespace string, config *MCPConfig) ([]stagedToolRecord, []string, error) {
+// prepareSingleMCPServerWithNamespace connects to one server and returns its
+// tool records. The client is returned unless it was closed for having
+// neither tools nor prompts.
+func (r *ToolRegistry) prepareSingleMCPServerWithNamespace(jsonFile, serverName, namespace string, config *MCPConfig) ([]stagedToolRecord, []string, *MCPClient, error) {
+	sandboxCfg, err := r.mcpSandboxConfig(config)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid sandbox config for MCP server %s: %w", serverName, err)
+	}
 	var sb sandbox.Sandbox
-	if r.sandboxFactory != nil && !config.SandboxOptOut() {
-		overlayCfg, err := config.SandboxConfig()
+	if sandboxCfg != nil {
+		sb, err = r.sandboxFactory(*sandboxCfg)
 		if err != nil {
-			return nil, nil, fmt.Errorf("invalid sandbox config for MCP server %s: %w", serverName, err)
-		}
-		sb, err = r.NewSandbox(overlayCfg)
-		if err != nil {
-			return nil, nil, fmt.Errorf("sandbox for MCP server %s: %w", serverName, err)
+			return nil, nil, nil, fmt.Errorf("sandbox for MCP server %s: %w", serverName, err)
 		}
 	}
 
-	client, err := NewMCPClientFromConfig(config, sb)
+	dial, err := mcpDialer(config, sb, "")
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	if r.poolSocket != "" && config.pooled() {
+		dial = pooledDialer(r.poolSocket, newPoolRequest(namespace, config, sandboxCfg), dial)
+	}
+	client, err := connectMCPClient(context.Background(), dial, r.mcpClientOptions(config)...)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
 	serverSpec := fmt.Sprintf("%s#%s", jsonFile, serverName)
 	client.serverSpec = serverSpec
+	client.namespace = namespace
+	client.sandboxCfg = sandboxCfg
 
 	serverTools, err := client.ListTools()
 	if err != nil {
 		client.Close()
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	var records []stagedToolRecord
 	var toolNames []string
 	for _, tool := range serverTools {
-		s := tool.GetSchema()
-		if s == nil || s.Title() == "" {
+		record, ok := mcpToolRecord(client, tool)
+		if !ok {
 			continue
 		}
-
-		namespacedName := fmt.Sprintf("%s__%s", namespace, s.Title())
-		if mcpTool, ok := tool.(*MCPTool); ok {
-			mcpTool.Source = serverSpec
-		}
-
-		wrappedTool := &NamespacedTool{
-			Tool:           tool,
-			namespacedName: namespacedName,
-		}
-		records = append(records, stagedToolRecord{
-			name:       namespacedName,
-			tool:       wrappedTool,
-			client:     client,
-			serverSpec: serverSpec,
-		})
-		toolNames = append(toolNames, namespacedName)
+		records = append(records, record)
+		toolNames = append(toolNames, record.name)
 	}
 
-	if len(records) == 0 {
+	if len(records) == 0 && !client.HasPrompts() {
 		client.Close()
+		return nil, nil, nil, nil
 	}
 
-	return records, toolNames, nil
+	return records, toolNames, client, nil
+}
+
+// mcpToolRecord namespaces one of client's tools. ok is false for tools
+// without a name.
+func mcpToolRecord(client *MCPClient, tool Tool) (record stagedToolRecord, ok bool) {
+	s := tool.GetSchema()
+	if s == nil || s.Title() == "" {
+		return stagedToolRecord{}, false
+	}
+
+	namespacedName := fmt.Sprintf("%s__%s", client.namespace, s.Title())
+	if mcpTool, ok := tool.(*MCPTool); ok {
+		mcpTool.Source = client.serverSpec
+	}
+	return stagedToolRecord{
+		name: namespacedName,
+		tool: &NamespacedTool{
+			Tool:           tool,
+			namespacedName: namespacedName,
+		},
+		client:     client,
+		serverSpec: client.serverSpec,
+	}, true
 }
 
-func (r *ToolRegistry) prepareMCPServerWithNamespacePrefix(serverSpec, namespacePrefix string) ([]stagedToolRecord, LoadResult, error) {
+func (r *ToolRegistry) prepareMCPServerWithNamespacePrefix(serverSpec, namespacePrefix string) ([]stagedToolRecord, []*MCPClient, LoadResult, error) {
 	jsonFile, serverName := ParseServerSpec(serverSpec)
 
 	configs, err := LoadMCPConfigFile(jsonFile)
 	if err != nil {
-		return nil, LoadResult{}, err
+		return nil, nil, LoadResult{}, err
 	}
 
 	var records []stagedToolRecord
+	var clients []*MCPClient
 	result := LoadResult{Type: "mcp"}
 	appendServer := func(name string, config MCPConfig) error {
 		namespace := joinNamespacePrefix(namespacePrefix, name)
-		serverRecords, toolNames, err := r.prepareSingleMCPServerWithNamespace(jsonFile, name, namespace, &config)
+		serverRecords, toolNames, client, err := r.prepareSingleMCPServerWithNamespace(jsonFile, name, namespace, &config)
 		if err != nil {
 			return err
 		}
 		records = append(records, serverRecords...)
+		if client != nil {
+			clients = append(clients, client)
+		}
 		result.Servers = append(result.Servers, ServerResult{Name: namespace, ToolNames: toolNames})
 		return nil
 	}
@@ -650,28 +796,28 @@ func (r *ToolRegistry) prepareMCPServerWithNamespacePrefix(serverSpec, namespace
 			for name := range configs {
 				available = append(available, name)
 			}
-			return nil, LoadResult{}, fmt.Errorf("server %q not found in config (available: %v)", serverName, available)
+			return nil, nil, LoadResult{}, fmt.Errorf("server %q not found in config (available: %v)", serverName, available)
 		}
 		if err := appendServer(serverName, config); err != nil {
-			closeStagedToolRecords(records)
-			return nil, LoadResult{}, err
+			closeMCPClients(clients)
+			return nil, nil, LoadResult{}, err
 		}
-		return records, result, nil
+		return records, clients, result, nil
 	}
 
 	for name, config := range configs {
 		if err := appendServer(name, config); err != nil {
-			closeStagedToolRecords(records)
-			return nil, LoadResult{}, fmt.Errorf("server %s: %w", name, err)
+			closeMCPClients(clients)
+			return nil, nil, LoadResult{}, fmt.Errorf("server %s: %w", name, err)
 		}
 	}
 
-	return records, result, nil
+	return records, clients, result, nil
 }
 
 // LoadMCPServerWithNamespacePrefix loads all servers from a config file with an explicit namespace prefix.
 func (r *ToolRegistry) LoadMCPServerWithNamespacePrefix(serverSpec, namespacePrefix string) (LoadResult, error) {
-	records, result, err := r.prepareMCPServerWithNamespacePrefix(serverSpec, namespacePrefix)
+	records, clients, result, err := r.prepareMCPServerWithNamespacePrefix(serverSpec, namespacePrefix)
 	if err != nil {
 		return LoadResult{}, err
 	}
@@ -679,6 +825,10 @@ func (r *ToolRegistry) LoadMCPServerWithNamespacePrefix(serverSpec, namespacePre
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	for _, client := range clients {
+		r.serverClients[client.namespace] = client
+	}
+
 	for _, record := range records {
 		r.tools[record.name] = record.tool
 		if record.client != nil {
@@ -695,11 +845,12 @@ func (r *ToolRegistry) LoadMCPServerWithNamespacePrefix(serverSpec, namespacePre
 
 // stageMCPServerWithNamespacePrefix queues MCP tools to be activated on the next turn.
 func (r *ToolRegistry) stageMCPServerWithNamespacePrefix(serverSpec, namespacePrefix string) (LoadResult, error) {
-	records, result, err := r.prepareMCPServerWithNamespacePrefix(serverSpec, namespacePrefix)
+	records, clients, result, err := r.prepareMCPServerWithNamespacePrefix(serverSpec, namespacePrefix)
 	if err != nil {
 		return LoadResult{}, err
 	}
-	r.stagePreparedTools(records)
+
+	r.stagePreparedTools(records, clients)
 	return result, nil
 }
 
@@ -834,11 +985,15 @@ func (r *ToolRegistry) LoadMCPServerWithFilter(serverSpec string, allowedTools [
 	}
 
 	// Create client
-	client, err := NewMCPClientFromConfig(&config, sb)
+	client, err := NewMCPClientFromConfig(&config, sb, r.mcpClientOptions(&config)...)
 	if err != nil {
 		return err
 	}
 	client.serverSpec = serverSpec
+	client.namespace = namespace
+	if sb != nil {
+		client.sandboxCfg, _ = r.mcpSandboxConfig(&config)
+	}
 
 	// Get all tools from server
 	tools, err := client.ListTools()
@@ -865,38 +1020,139 @@ func (r *ToolRegistry) LoadMCPServerWithFilter(serverSpec string, allowedTools [
 	defer r.mu.Unlock()
 
 	var toolNames []string
+	client.skipTools = make(map[string]bool)
 	for _, tool := range tools {
-		s := tool.GetSchema()
-		if s != nil && s.Title() != "" {
-			// Only register if this tool is in the allowed list
-			if allowed[s.Title()] {
-				// Create namespaced name
-				namespacedName := fmt.Sprintf("%s__%s", namespace, s.Title())
-
-				// Set source on the tool for persistence
-				if mcpTool, ok := tool.(*MCPTool); ok {
-					mcpTool.Source = serverSpec
-				}
-
-				// Wrap the tool to provide namespaced schema
-				wrappedTool := &NamespacedTool{
-					Tool:           tool,
-					namespacedName: namespacedName,
-				}
-				r.tools[namespacedName] = wrappedTool
-				r.toolClients[namespacedName] = client
-				toolNames = append(toolNames, namespacedName)
-				slog.Debug("mcp_tool_registered", "tool_name", namespacedName)
-			}
+		record, ok := mcpToolRecord(client, tool)
+		if !ok {
+			continue
 		}
+		// Only register if this tool is in the allowed list
+		bareName := strings.TrimPrefix(record.name, namespace+"__")
+		if !allowed[bareName] {
+			client.skipTools[bareName] = true
+			continue
+		}
+		r.tools[record.name] = record.tool
+		r.toolClients[record.name] = client
+		toolNames = append(toolNames, record.name)
+		slog.Debug("mcp_tool_registered", "tool_name", record.name)
 	}
 
 	// Track which tools came from this server
 	r.serverTools[serverSpec] = toolNames
+	if len(toolNames) > 0 || client.HasPrompts() || client.HasResources() {
+		r.serverClients[namespace] = client
+	} else {
+		client.Close()
+	}
 
 	return nil
 }
 
+// OnToolListChanged sets fn to run after CommitPendingChanges applies a
+// refreshed MCP tool list, e.g. to persist the active tools
+func (r *ToolRegistry) OnToolListChanged(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onToolListChanged = fn
+}
+
+// OnMCPConnectionState sets fn to run when an MCP server's connection drops
+// or comes back. fn runs on the client's supervising goroutine.
+func (r *ToolRegistry) OnMCPConnectionState(fn func(server string, state MCPConnectionState)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onMCPConnection = fn
+}
+
+func (r *ToolRegistry) mcpConnectionChanged(client *MCPClient, state MCPConnectionState) {
+	r.mu.RLock()
+	fn := r.onMCPConnection
+	r.mu.RUnlock()
+	if fn != nil {
+		fn(client.Name(), state)
+	}
+}
+
+// refreshMCPTools re-lists a server's tools after it announces a change and
+// stages the difference, so the update lands between agent iterations
+func (r *ToolRegistry) refreshMCPTools(client *MCPClient) {
+	serverTools, err := client.ListTools()
+	if err != nil {
+		slog.Debug("mcp_tools_refresh_failed", "server_name", client.Name(), "error", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The server's tools as they will be after the next commit
+	current := make(map[string]bool)
+	for name, c := range r.toolClients {
+		if c == client && !r.pendingRemovals[name] {
+			current[name] = true
+		}
+	}
+	for name, c := range r.pendingToolClients {
+		if c == client {
+			current[name] = true
+		}
+	}
+	if len(current) == 0 && r.serverClients[client.namespace] != client {
+		// Unloaded in the meantime
+		return
+	}
+
+	var toolNames []string
+	for _, tool := range serverTools {
+		record, ok := mcpToolRecord(client, tool)
+		if !ok || client.skipTools[strings.TrimPrefix(record.name, client.namespace+"__")] {
+			continue
+		}
+		r.stageTool(record.name, record.tool, client)
+		toolNames = append(toolNames, record.name)
+		delete(current, record.name)
+	}
+	r.stageServerTools(client.serverSpec, toolNames)
+	for name := range current {
+		r.stageRemoval(name)
+	}
+	r.pendingRefresh = true
+	slog.Debug("mcp_tools_refreshed", "server_name", client.Name(), "tool_count", len(toolNames))
+}
+
+// MCPServerClient returns the client of a loaded MCP server by the namespace
+// its tools are registered under
+func (r *ToolRegistry) MCPServerClient(namespace string) (*MCPClient, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	client, ok := r.serverClients[namespace]
+	return client, ok
+}
+
+// MCPServerNamespaces returns the namespaces of loaded MCP servers, sorted
+func (r *ToolRegistry) MCPServerNamespaces() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	namespaces := make([]string, 0, len(r.serverClients))
+	for namespace := range r.serverClients {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// forgetServerClient drops a closed client from the namespace index
+func (r *ToolRegistry) forgetServerClient(client *MCPClient) {
+	for namespace, c := range r.serverClients {
+		if c == client {
+			delete(r.serverClients, namespace)
+		}
+	}
+}
+
 // GetLoadedMCPServers returns list of loaded server specs
 func (r *ToolRegistry) GetLoadedMCPServers() []string {
 	r.mu.RLock()
@@ -928,14 +1184,30 @@ func (r *ToolRegistry) Close() error {
 			closed[client] = true
 		}
 	}
+	for _, client := range r.serverClients {
+		if !closed[client] {
+			client.Close()
+			closed[client] = true
+		}
+	}
+	for _, client := range r.pendingClients {
+		if !closed[client] {
+			client.Close()
+			closed[client] = true
+		}
+	}
 
 	// Clear maps
 	r.tools = make(map[string]Tool)
 	r.toolClients = make(map[string]*MCPClient)
 	r.serverTools = make(map[string][]string)
+	r.serverClients = make(map[string]*MCPClient)
 	r.pendingTools = make(map[string]Tool)
 	r.pendingToolClients = make(map[string]*MCPClient)
 	r.pendingServerTools = make(map[string][]string)
+	r.pendingClients = make(map[string]*MCPClient)
+	r.pendingRemovals = make(map[string]bool)
+	r.pendingRefresh = false
 	r.alwaysAllowedTools = make(map[string]bool)
 	r.autoAllowedTools = make(map[string]bool)
 	r.pendingAutoAllowed = make(map[string]bool)
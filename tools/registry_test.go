@@ -3,8 +3,10 @@ package tools
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/alexschlessinger/pollytool/schema"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 type testTool struct {
@@ -52,3 +54,64 @@ func TestRegistryRemove(t *testing.T) {
 		t.Error("Expected tool to not exist after removal")
 	}
 }
+
+func TestRegistryRefreshesMCPToolsOnListChanged(t *testing.T) {
+	noop := func(context.Context, *mcp.CallToolRequest, struct{}) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{}, nil, nil
+	}
+	server := mcp.NewServer(&mcp.Implementation{Name: "dyn", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "old"}, noop)
+	mcp.AddTool(server, &mcp.Tool{Name: "hidden"}, noop)
+
+	registry := NewToolRegistry(nil)
+	client := connectInMemory(t, server, withToolListChanged(registry.refreshMCPTools))
+	client.namespace = "dyn"
+	client.serverSpec = "dyn.json#dyn"
+	client.skipTools = map[string]bool{"hidden": true}
+
+	serverTools, err := client.ListTools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range serverTools {
+		if record, ok := mcpToolRecord(client, tool); ok && tool.GetName() == "old" {
+			registry.stagePreparedTools([]stagedToolRecord{record}, nil)
+		}
+	}
+	registry.CommitPendingChanges()
+
+	changed := 0
+	registry.OnToolListChanged(func() { changed++ })
+
+	server.RemoveTools("old")
+	mcp.AddTool(server, &mcp.Tool{Name: "new"}, noop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		registry.CommitPendingChanges()
+		_, hasNew := registry.Get("dyn__new")
+		_, hasOld := registry.Get("dyn__old")
+		if hasNew && !hasOld {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tools after refresh: new=%v old=%v", hasNew, hasOld)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, ok := registry.Get("dyn__hidden"); ok {
+		t.Error("a tool filtered out at load time came back on refresh")
+	}
+	if changed == 0 {
+		t.Error("OnToolListChanged hook did not run")
+	}
+	if got := registry.GetLoadedMCPServers(); len(got) != 1 || got[0] != "dyn.json#dyn" {
+		t.Errorf("GetLoadedMCPServers() = %v", got)
+	}
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	if names := registry.serverTools["dyn.json#dyn"]; len(names) != 1 || names[0] != "dyn__new" {
+		t.Errorf("serverTools = %v, want [dyn__new]", names)
+	}
+}
@@ -0,0 +1,86 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathDenied is returned by CheckRead and CheckWrite when the policy
+// forbids access to a path.
+var ErrPathDenied = errors.New("access denied by sandbox policy")
+
+// CheckRead applies the sandbox read policy to path for tools that access
+// files in-process: paths under DeniedPaths are refused unless exempted by
+// ReadPaths.
+func (c Config) CheckRead(path string) error {
+	resolved := resolvePath(path)
+
+	for _, rp := range c.ReadPaths {
+		if isWithin(resolved, resolvePath(expandTilde(rp))) {
+			return nil
+		}
+	}
+	for _, denied := range ExpandHome(DeniedPaths) {
+		if isWithin(resolved, resolvePath(denied.Path)) {
+			return fmt.Errorf("%w: %s is a protected path", ErrPathDenied, path)
+		}
+	}
+	return nil
+}
+
+// CheckWrite applies the sandbox write policy to path for tools that access
+// files in-process: writes are allowed only inside /tmp, the OS temp dir and
+// WritablePaths, and never when DenyWrite is set.
+func (c Config) CheckWrite(path string) error {
+	if c.DenyWrite {
+		return fmt.Errorf("%w: writes are disabled", ErrPathDenied)
+	}
+	if err := c.CheckRead(path); err != nil {
+		return err
+	}
+
+	resolved := resolvePath(path)
+	roots := append([]string{"/tmp", os.TempDir()}, c.WritablePaths...)
+	for _, root := range roots {
+		if isWithin(resolved, resolvePath(expandTilde(root))) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is outside the writable paths", ErrPathDenied, path)
+}
+
+// resolvePath returns an absolute, symlink-free form of path. Components
+// that do not exist yet are appended to the resolved existing parent, so
+// new files cannot escape through a symlinked directory.
+func resolvePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	var missing []string
+	current := abs
+	for {
+		if real, err := filepath.EvalSymlinks(current); err == nil {
+			parts := append([]string{real}, missing...)
+			return filepath.Join(parts...)
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return abs
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
+
+// isWithin reports whether path equals root or is inside it.
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
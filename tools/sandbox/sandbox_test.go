@@ -2,8 +2,11 @@ package sandbox
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -230,3 +233,38 @@ func TestExpandHome(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigCheckReadWrite(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := filepath.Join(home, "project")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A symlink inside the project must not open a way into ~/.ssh
+	if err := os.MkdirAll(filepath.Join(home, ".ssh"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(home, ".ssh"), filepath.Join(project, "keys")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Paths outside the temp dirs so only WritablePaths can allow them
+	writable := filepath.Join(string(filepath.Separator), "polly-writable")
+	cfg := Config{WritablePaths: []string{writable}}
+	if err := cfg.CheckWrite(filepath.Join(writable, "new", "file.txt")); err != nil {
+		t.Fatalf("CheckWrite(writable file) = %v", err)
+	}
+	if err := cfg.CheckWrite(filepath.Join(string(filepath.Separator), "polly-outside", "x")); !errors.Is(err, ErrPathDenied) {
+		t.Fatalf("CheckWrite(outside) = %v, want ErrPathDenied", err)
+	}
+	if err := cfg.CheckRead(filepath.Join(project, "keys", "id_rsa")); !errors.Is(err, ErrPathDenied) {
+		t.Fatalf("CheckRead(symlink into ~/.ssh) = %v, want ErrPathDenied", err)
+	}
+	if err := cfg.CheckWrite(filepath.Join(os.TempDir(), "x")); err != nil {
+		t.Fatalf("CheckWrite(temp) = %v", err)
+	}
+	if err := (Config{DenyWrite: true}).CheckWrite(filepath.Join(os.TempDir(), "x")); !errors.Is(err, ErrPathDenied) {
+		t.Fatalf("CheckWrite with DenyWrite = %v, want ErrPathDenied", err)
+	}
+}
@@ -84,6 +84,7 @@ func (t *SkillActivateTool) activate(name string) (string, error) {
 	allowedPatterns := parseAllowedToolPatterns(skill.AllowedTools)
 	if !alreadyActivated {
 		var records []stagedToolRecord
+		var clients []*MCPClient
 
 		mcpFiles, err := skill.ListFiles("mcp")
 		if err != nil {
@@ -98,20 +99,21 @@ func (t *SkillActivateTool) activate(name string) (string, error) {
 				return "", err
 			}
 
-			prepared, result, err := t.registry.prepareMCPServerWithNamespacePrefix(configPath, skill.Name)
+			prepared, preparedClients, result, err := t.registry.prepareMCPServerWithNamespacePrefix(configPath, skill.Name)
 			if err != nil {
-				closeStagedToolRecords(records)
+				closeMCPClients(clients)
 				return "", fmt.Errorf("load skill MCP config %s: %w", rel, err)
 			}
 			records = append(records, prepared...)
+			clients = append(clients, preparedClients...)
 			for _, server := range result.Servers {
 				loadedMCPServers = append(loadedMCPServers, server.Name)
 				loadedTools = append(loadedTools, server.ToolNames...)
 			}
 		}
 
-		if len(records) > 0 {
-			t.registry.stagePreparedTools(records)
+		if len(records) > 0 || len(clients) > 0 {
+			t.registry.stagePreparedTools(records, clients)
 		}
 		t.registry.stageSkillAllowance(allowedPatterns, loadedTools)
 
@@ -201,6 +201,9 @@ func TestStageMCPServerWithNamespacePrefix(t *testing.T) {
 	if !foundPrefixedTool {
 		t.Fatal("expected committed MCP tools to use the skill-prefixed namespace")
 	}
+	if _, ok := registry.MCPServerClient("clock-skill-time"); !ok {
+		t.Fatal("committed MCP server should be found by its namespace")
+	}
 }
 
 func TestSkillReadFileToolReadsRelativeFile(t *testing.T) {
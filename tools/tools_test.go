@@ -7,11 +7,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/alexschlessinger/pollytool/tools/sandbox"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 // checkUvxAvailable checks if uvx is available on the system
@@ -54,6 +56,29 @@ func createMCPTestConfig(t *testing.T, serverName, command string, args []string
 	return f.Name()
 }
 
+// connectInMemory serves server over in-memory transports and returns a
+// polly client connected to it
+func connectInMemory(t *testing.T, server *mcp.Server, opts ...MCPClientOption) *MCPClient {
+	t.Helper()
+	ctx := context.Background()
+	dial := func() (mcp.Transport, error) {
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+		serverSession, err := server.Connect(ctx, serverTransport, nil)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() { serverSession.Close() })
+		return clientTransport, nil
+	}
+
+	client, err := connectMCPClient(ctx, dial, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
 func TestMCPClient(t *testing.T) {
 	checkUvxAvailable(t)
 
@@ -959,6 +984,34 @@ func (m *mockSandbox) Wrap(cmd *exec.Cmd) error {
 	return m.err
 }
 
+func TestRegistrySandboxConfigFor(t *testing.T) {
+	dir := t.TempDir()
+	base := sandbox.Config{WritablePaths: []string{dir}}
+	registry := NewToolRegistry(nil, WithSandboxFactory(mockSandboxFactory(&mockSandbox{}), base))
+	if _, err := registry.LoadShellTool(createSandboxedTestScript(t, dir)); err != nil {
+		t.Fatalf("Failed to load shell tool: %v", err)
+	}
+	if _, err := registry.LoadToolAuto("bash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.LoadToolAuto("read_file"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tool := range registry.All() {
+		cfg := registry.SandboxConfigFor(tool)
+		if cfg == nil || !slices.Equal(cfg.WritablePaths, base.WritablePaths) {
+			t.Errorf("SandboxConfigFor(%s) = %+v, want the base config", tool.GetName(), cfg)
+		}
+	}
+	if cfg := registry.SandboxConfigFor(&Func{Name: "inline"}); cfg != nil {
+		t.Errorf("SandboxConfigFor(in-process func) = %+v, want nil", cfg)
+	}
+	if cfg := NewToolRegistry(nil).SandboxConfigFor(NewBashTool("")); cfg != nil {
+		t.Errorf("SandboxConfigFor(unsandboxed bash) = %+v, want nil", cfg)
+	}
+}
+
 func mockSandboxFactory(sb *mockSandbox) func(sandbox.Config) (sandbox.Sandbox, error) {
 	return func(cfg sandbox.Config) (sandbox.Sandbox, error) {
 		return sb, nil
@@ -1013,3 +1066,77 @@ fi
 		t.Errorf("Expected 1 tool (valid only), got %d", len(tools))
 	}
 }
+
+func TestMCPSampling(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "summarizer", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "summarize"}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		result, err := req.Session.CreateMessage(ctx, &mcp.CreateMessageParams{
+			MaxTokens: 100,
+			Messages:  []*mcp.SamplingMessage{{Role: "user", Content: &mcp.TextContent{Text: "summarize this"}}},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{result.Content}}, nil, nil
+	})
+
+	var gotServer string
+	client := connectInMemory(t, server, WithSampling(func(_ context.Context, name string, params *mcp.CreateMessageParams) (*mcp.CreateMessageResult, error) {
+		gotServer = name
+		text := params.Messages[0].Content.(*mcp.TextContent).Text
+		return &mcp.CreateMessageResult{Content: &mcp.TextContent{Text: "sampled: " + text}, Model: "test/model", Role: "assistant"}, nil
+	}))
+	client.namespace = "summarizer"
+
+	serverTools, err := client.ListTools()
+	if err != nil || len(serverTools) != 1 {
+		t.Fatalf("ListTools() = %v, %v", serverTools, err)
+	}
+	out, err := serverTools[0].Execute(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "sampled: summarize this") || gotServer != "summarizer" {
+		t.Fatalf("output = %s, server = %q", out, gotServer)
+	}
+}
+
+func TestMCPElicitation(t *testing.T) {
+	server := mcp.NewServer(&mcp.Implementation{Name: "deploy", Version: "1.0.0"}, nil)
+	mcp.AddTool(server, &mcp.Tool{Name: "release"}, func(ctx context.Context, req *mcp.CallToolRequest, _ struct{}) (*mcp.CallToolResult, any, error) {
+		result, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+			Message: "Which environment?",
+			RequestedSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"env": map[string]any{"type": "string"}},
+			},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		text := result.Action
+		if env, ok := result.Content["env"].(string); ok {
+			text += ": " + env
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: text}}}, nil, nil
+	})
+
+	var gotServer string
+	client := connectInMemory(t, server, WithElicitation(func(_ context.Context, name string, params *mcp.ElicitParams) (*mcp.ElicitResult, error) {
+		gotServer = name
+		return &mcp.ElicitResult{Action: "accept", Content: map[string]any{"env": "staging"}}, nil
+	}))
+	client.namespace = "deploy"
+
+	serverTools, err := client.ListTools()
+	if err != nil || len(serverTools) != 1 {
+		t.Fatalf("ListTools() = %v, %v", serverTools, err)
+	}
+	out, err := serverTools[0].Execute(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "accept: staging") || gotServer != "deploy" {
+		t.Fatalf("output = %s, server = %q", out, gotServer)
+	}
+}